@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cato-logger/internal/api"
+	"cato-logger/internal/config"
+	"cato-logger/internal/logging"
+)
+
+// newAPIClient creates the Cato API client with the configured timeouts,
+// response limits, keys, custom query, and debug capture
+func newAPIClient(cfg *config.Config, logger *logging.Logger) (*api.Client, error) {
+	apiClient := api.NewClient(
+		cfg.CatoAPIURL,
+		cfg.CatoAPIKey,
+		cfg.CatoAccountID,
+		api.Timeouts{
+			Dial:           time.Duration(cfg.DialTimeout) * time.Second,
+			TLSHandshake:   time.Duration(cfg.TLSHandshakeTimeout) * time.Second,
+			ResponseHeader: time.Duration(cfg.ResponseHeaderTimeout) * time.Second,
+			BodyRead:       time.Duration(cfg.BodyReadTimeout) * time.Second,
+		},
+		logger.Component("api"),
+	)
+	apiClient = apiClient.WithLimits(api.Limits{
+		MaxResponseBytes: int64(cfg.MaxResponseMB) << 20,
+		MaxPageEvents:    cfg.MaxPageEvents,
+		WarnOnly:         cfg.ResponseLimitAction == "warn",
+	})
+	apiClient.SetAPIKeys(cfg.CatoAPIKey, cfg.CatoAPIKeyNext)
+	// A rejected key without a next key is re-read from the config file and
+	// its secret reference, so a rotated key is picked up without a restart
+	startupCfg := cfg
+	apiClient.SetKeySource(func(context.Context) (string, string, error) {
+		fresh, err := config.Reload(startupCfg)
+		if err != nil {
+			return "", "", err
+		}
+		return fresh.CatoAPIKey, fresh.CatoAPIKeyNext, nil
+	})
+	if cfg.CatoQueryFile != "" {
+		query, err := api.LoadQuery(cfg.CatoQueryFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load custom eventsFeed query: %w", err)
+		}
+		apiClient = apiClient.WithQuery(query)
+		logger.Info("using custom eventsFeed query", "query_file", cfg.CatoQueryFile)
+	}
+	if cfg.APICaptureDir != "" {
+		capture, err := api.NewCapture(cfg.APICaptureDir, cfg.APICaptureCount)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize API debug capture: %w", err)
+		}
+		apiClient = apiClient.WithCapture(capture)
+		logger.Warn("API debug capture enabled, raw API traffic is written to disk",
+			"directory", cfg.APICaptureDir,
+			"keep_calls", cfg.APICaptureCount)
+	}
+	return apiClient, nil
+}
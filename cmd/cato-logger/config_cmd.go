@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"cato-logger/internal/config"
+)
+
+// runConfigCommand handles "cato-logger config <subcommand>"
+func runConfigCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: cato-logger config <init|schema|show|check> [flags]")
+		return 2
+	}
+
+	switch args[0] {
+	case "init":
+		return runConfigInit(args[1:])
+	case "schema":
+		return runConfigSchema()
+	case "show":
+		return runConfigShow(args[1:])
+	case "check":
+		return runConfigCheck(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown config subcommand: %s\n", args[0])
+		return 2
+	}
+}
+
+// runConfigInit writes a commented example config, prompting for values when interactive
+func runConfigInit(args []string) int {
+	defaults := config.DefaultExampleOptions()
+
+	fs := flag.NewFlagSet("config init", flag.ContinueOnError)
+	out := fs.String("out", "./config.json", "Path to write the config file (- for stdout)")
+	force := fs.Bool("force", false, "Overwrite an existing file")
+	interactive := fs.Bool("interactive", false, "Prompt for values on the terminal")
+	apiKey := fs.String("api-key", "", "Cato API key")
+	accountID := fs.String("account-id", "", "Cato account ID")
+	syslogServer := fs.String("syslog-server", defaults.SyslogServer, "Syslog server hostname or IP")
+	syslogPort := fs.Int("syslog-port", defaults.SyslogPort, "Syslog server port")
+	syslogProtocol := fs.String("syslog-protocol", defaults.SyslogProtocol, "Syslog protocol (tcp, udp or relp)")
+	markerFile := fs.String("marker-file", defaults.MarkerFile, "Path of the marker state file")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	opts := config.ExampleOptions{
+		APIKey:         *apiKey,
+		AccountID:      *accountID,
+		SyslogServer:   *syslogServer,
+		SyslogPort:     *syslogPort,
+		SyslogProtocol: *syslogProtocol,
+		MarkerFile:     *markerFile,
+	}
+
+	if *interactive {
+		if err := promptExampleOptions(os.Stdin, os.Stdout, &opts); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			return 1
+		}
+	}
+
+	if *out == "-" {
+		if err := config.WriteExample(os.Stdout, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: failed to generate config: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	if !*force {
+		if _, err := os.Lstat(*out); err == nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %s already exists (use --force to overwrite)\n", *out)
+			return 1
+		}
+	}
+	if err := writeExampleFile(*out, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: failed to write config: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("Wrote example configuration to %s\n", *out)
+	if opts.APIKey == "" || opts.AccountID == "" {
+		fmt.Println("Remember to set cato.api_key and cato.account_id before starting the service.")
+	}
+	return 0
+}
+
+// writeExampleFile writes the example config to a temporary file next to
+// path and renames it over path, so a failed write never leaves a truncated
+// config behind
+func writeExampleFile(path string, opts config.ExampleOptions) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if err := config.WriteExample(tmp, opts); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// runConfigSchema prints the JSON Schema for the config file
+func runConfigSchema() int {
+	data, err := json.MarshalIndent(config.Schema(), "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: failed to generate schema: %v\n", err)
+		return 1
+	}
+	fmt.Println(string(data))
+	return 0
+}
+
+// runConfigShow prints the configuration the service would run with: the
+// config file with defaults applied, secret references resolved and the
+// command-line overrides, which it accepts like the service, taking
+// precedence. Secret values are redacted.
+func runConfigShow(args []string) int {
+	fs := flag.NewFlagSet("config show", flag.ContinueOnError)
+	cfg, err := config.LoadArgs(fs, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: failed to load configuration: %v\n", err)
+		return 2
+	}
+
+	data, err := json.MarshalIndent(cfg.Redacted(), "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: failed to encode configuration: %v\n", err)
+		return 1
+	}
+	fmt.Println(string(data))
+
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: invalid configuration: %v\n", err)
+		return 2
+	}
+	return 0
+}
+
+// runConfigCheck prints the validation errors and the warnings about
+// settings likely to cause trouble, with a hint for each. It exits 2 when
+// the service would not start and 0 otherwise, warnings included.
+func runConfigCheck(args []string) int {
+	fs := flag.NewFlagSet("config check", flag.ContinueOnError)
+	cfg, err := config.LoadArgs(fs, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: failed to load configuration: %v\n", err)
+		return 2
+	}
+
+	report := cfg.Check()
+	report.Write(os.Stdout)
+	if len(report.Errors) > 0 {
+		return 2
+	}
+	return 0
+}
+
+// promptExampleOptions asks for each value, keeping the current one when the answer is empty
+func promptExampleOptions(in io.Reader, out io.Writer, opts *config.ExampleOptions) error {
+	reader := bufio.NewReader(in)
+
+	ask := func(label, current string) (string, error) {
+		if current != "" {
+			fmt.Fprintf(out, "%s [%s]: ", label, current)
+		} else {
+			fmt.Fprintf(out, "%s: ", label)
+		}
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		if answer := strings.TrimSpace(line); answer != "" {
+			return answer, nil
+		}
+		return current, nil
+	}
+
+	var err error
+	if opts.APIKey, err = ask("Cato API key", opts.APIKey); err != nil {
+		return err
+	}
+	if opts.AccountID, err = ask("Cato account ID", opts.AccountID); err != nil {
+		return err
+	}
+	if opts.SyslogServer, err = ask("Syslog server", opts.SyslogServer); err != nil {
+		return err
+	}
+
+	port, err := ask("Syslog port", strconv.Itoa(opts.SyslogPort))
+	if err != nil {
+		return err
+	}
+	if opts.SyslogPort, err = strconv.Atoi(port); err != nil {
+		return fmt.Errorf("invalid syslog port: %s", port)
+	}
+
+	if opts.SyslogProtocol, err = ask("Syslog protocol (tcp/udp/relp)", opts.SyslogProtocol); err != nil {
+		return err
+	}
+	if opts.MarkerFile, err = ask("Marker file", opts.MarkerFile); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"cato-logger/internal/config"
+	"cato-logger/internal/logging"
+)
+
+// discoverAccounts returns the accounts to poll: the configured ones plus, with
+// cato.discovery.parent_account_id set, every child account of the parent,
+// minus the excluded ones
+func discoverAccounts(ctx context.Context, cfg *config.Config, feeds *feedSet, logger *logging.Logger) ([]string, error) {
+	exclude := make(map[string]bool, len(cfg.DiscoveryExclude))
+	for _, id := range cfg.DiscoveryExclude {
+		exclude[id] = true
+	}
+
+	seen := make(map[string]bool)
+	var accountIDs []string
+	addAccount := func(id string) {
+		if id != "" && !seen[id] && !exclude[id] {
+			seen[id] = true
+			accountIDs = append(accountIDs, id)
+		}
+	}
+
+	for _, id := range cfg.AccountIDs() {
+		addAccount(id)
+	}
+
+	if cfg.DiscoveryParentID != "" {
+		children, err := feeds.apiClient.ListSubAccounts(ctx, cfg.DiscoveryParentID)
+		if err != nil {
+			return nil, fmt.Errorf("sub-account discovery failed: %w", err)
+		}
+		for _, child := range children {
+			addAccount(child.ID)
+		}
+		logger.Info("sub-accounts discovered",
+			"parent_account_id", cfg.DiscoveryParentID,
+			"child_accounts", len(children),
+			"polled_accounts", len(accountIDs))
+	}
+
+	return accountIDs, nil
+}
+
+// refreshAccounts re-runs discovery and adds or removes runners so the polled
+// accounts match. On failure the current set is kept.
+func refreshAccounts(ctx context.Context, cfg *config.Config, feeds *feedSet, logger *logging.Logger) {
+	accountIDs, err := discoverAccounts(ctx, cfg, feeds, logger)
+	if err != nil {
+		logger.Warn("keeping current account set", "error", err.Error())
+		return
+	}
+
+	added, removed := diffAccounts(feeds.accounts(), accountIDs)
+	if len(removed) > 0 {
+		logger.Info("accounts removed from polling", "accounts", removed)
+		feeds.remove(removed)
+	}
+	if len(added) > 0 {
+		logger.Info("accounts added to polling", "accounts", added)
+		if err := feeds.add(cfg, added); err != nil {
+			logger.Error("failed to add discovered accounts", "error", err.Error())
+		}
+	}
+}
+
+// diffAccounts returns the IDs only in next (added) and only in current (removed)
+func diffAccounts(current, next []string) (added, removed []string) {
+	inCurrent := make(map[string]bool, len(current))
+	for _, id := range current {
+		inCurrent[id] = true
+	}
+	inNext := make(map[string]bool, len(next))
+	for _, id := range next {
+		inNext[id] = true
+		if !inCurrent[id] {
+			added = append(added, id)
+		}
+	}
+	for _, id := range current {
+		if !inNext[id] {
+			removed = append(removed, id)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
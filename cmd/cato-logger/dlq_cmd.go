@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"cato-logger/internal/config"
+	"cato-logger/internal/dlq"
+	"cato-logger/internal/logging"
+	"cato-logger/internal/output"
+	"cato-logger/internal/processor"
+)
+
+// replayBatchSize caps the records sent to an output in one write
+const replayBatchSize = 1000
+
+// runDLQCommand handles "cato-logger dlq <subcommand>"
+func runDLQCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: cato-logger dlq replay [flags]")
+		return 2
+	}
+
+	switch args[0] {
+	case "replay":
+		return runDLQReplay(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown dlq subcommand: %s\n", args[0])
+		return 2
+	}
+}
+
+// replayItem is a dead-letter entry and the record it resends
+type replayItem struct {
+	entry  dlq.Entry
+	record output.Record
+}
+
+// runDLQReplay resends dead-lettered events to the configured outputs. The
+// file is moved aside first so the running service can keep appending to it;
+// entries that fail again are appended back to the live file.
+func runDLQReplay(args []string) int {
+	fs := flag.NewFlagSet("dlq replay", flag.ContinueOnError)
+	configPath := fs.String("config", "", "Path to config.json file")
+	file := fs.String("file", "", "Dead-letter file to replay (defaults to dead_letter.file)")
+	dryRun := fs.Bool("dry-run", false, "Summarize the entries without sending them")
+	verbose := fs.Bool("verbose", false, "Log replay progress to stderr")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := config.LoadFile(*configPath)
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: invalid configuration: %v\n", err)
+		return 2
+	}
+
+	path := *file
+	if path == "" {
+		path = cfg.DeadLetterFile
+	}
+	if path == "" {
+		fmt.Fprintln(os.Stderr, "ERROR: no dead-letter file configured (set dead_letter.file or use --file)")
+		return 2
+	}
+
+	// Resume an interrupted replay before taking the live file. The live
+	// file is moved aside before it is read, so entries the service appends
+	// meanwhile start a new file instead of being removed with the replayed
+	// ones. A dry run reads the live file where it is.
+	replayPath := path + ".replaying"
+	resumed := true
+	if _, err := os.Stat(replayPath); os.IsNotExist(err) {
+		resumed = false
+	}
+	switch {
+	case resumed && !*dryRun:
+		fmt.Printf("resuming interrupted replay of %s; run again afterwards to replay %s\n", replayPath, path)
+	case !resumed && *dryRun:
+		replayPath = path
+	case !resumed:
+		if err := os.Rename(path, replayPath); os.IsNotExist(err) {
+			fmt.Printf("%s has no entries to replay\n", path)
+			return 0
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: failed to move dead-letter file aside: %v\n", err)
+			return 1
+		}
+	}
+
+	entries, err := dlq.Read(replayPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	if len(entries) == 0 {
+		if !*dryRun {
+			os.Remove(replayPath)
+		}
+		fmt.Printf("%s has no entries to replay\n", replayPath)
+		return 0
+	}
+
+	if *dryRun {
+		printDLQSummary(replayPath, entries)
+		return 0
+	}
+
+	level := "error"
+	if *verbose {
+		level = "debug"
+	}
+	logger, err := logging.New(logging.Options{Level: level, Format: "text", Output: "stderr"})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	defer logger.Close()
+
+	stages, err := buildStages(cfg, logger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+
+	sinks, err := output.Build(cfg.EffectiveOutputs(), output.Options{
+		ConnTimeout:   time.Duration(cfg.ConnTimeout) * time.Second,
+		Logger:        logger,
+		Formats:       newFormats(cfg),
+		FormatWorkers: cfg.FormatWorkers,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: failed to initialize outputs: %v\n", err)
+		return 1
+	}
+	defer output.CloseAll(sinks)
+
+	// Outputs format records with their feed's CEF mapping profile
+	feeds := make(map[string]bool)
+	for _, feed := range cfg.EffectiveFeeds() {
+		feeds[feed.Name] = true
+	}
+
+	// Queue every entry for the outputs it still has to reach
+	pending := make(map[string][]replayItem)
+	var failed []dlq.Entry
+	for _, entry := range entries {
+		if !feeds[entry.Feed] {
+			entry.Error = fmt.Sprintf("feed %s is no longer configured", entry.Feed)
+			failed = append(failed, entry)
+			continue
+		}
+
+		record := output.Record{Feed: entry.Feed, Fields: entry.Fields, Hostname: entry.Hostname}
+		if entry.Reason == dlq.ReasonFormat {
+			if record, err = replayFormat(cfg, entry.Feed, stages, entry.Fields); err != nil {
+				entry.Error = err.Error()
+				failed = append(failed, entry)
+				continue
+			}
+		}
+
+		queued := false
+		for _, sink := range sinks {
+			if entry.Output == "" || entry.Output == sink.Name() {
+				pending[sink.Name()] = append(pending[sink.Name()], replayItem{entry: entry, record: record})
+				queued = true
+			}
+		}
+		if !queued {
+			entry.Error = fmt.Sprintf("output %s is no longer configured", entry.Output)
+			failed = append(failed, entry)
+		}
+	}
+
+	ctx := context.Background()
+	sent := 0
+	for _, sink := range sinks {
+		items := pending[sink.Name()]
+		for start := 0; start < len(items); start += replayBatchSize {
+			batch := items[start:min(start+replayBatchSize, len(items))]
+			records := make([]output.Record, len(batch))
+			for i, item := range batch {
+				records[i] = item.record
+			}
+
+			if _, err := sink.Write(ctx, records); err != nil {
+				// The stages have run now, so a retry only needs delivery
+				for _, item := range batch {
+					entry := item.entry
+					entry.Reason = dlq.ReasonDelivery
+					entry.Output = sink.Name()
+					entry.Error = err.Error()
+					entry.Fields, entry.Hostname = item.record.Fields, item.record.Hostname
+					failed = append(failed, entry)
+				}
+				continue
+			}
+			sent += len(batch)
+		}
+	}
+
+	if len(failed) > 0 {
+		queue, err := dlq.Open(path, 0)
+		if err == nil {
+			err = queue.Append(failed)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: failed to requeue %d entries, %s was kept: %v\n", len(failed), replayPath, err)
+			return 1
+		}
+	}
+	if err := os.Remove(replayPath); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: failed to remove %s: %v\n", replayPath, err)
+		return 1
+	}
+
+	fmt.Printf("replayed %d deliveries from %d entries\n", sent, len(entries))
+	if len(failed) > 0 {
+		fmt.Printf("%d entries failed again and were returned to %s\n", len(failed), path)
+		return 1
+	}
+	return 0
+}
+
+// replayFormat runs the stages on an event that failed them when it was received
+func replayFormat(cfg *config.Config, feed string, stages []processor.Stage, fields map[string]string) (record output.Record, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("formatting failed: %v", r)
+		}
+	}()
+	return processor.Format(cfg, feed, stages, fields), nil
+}
+
+// printDLQSummary prints entry counts per reason and output
+func printDLQSummary(path string, entries []dlq.Entry) {
+	counts := make(map[string]int)
+	for _, entry := range entries {
+		key := entry.Reason
+		if entry.Output != "" {
+			key += " (output " + entry.Output + ")"
+		}
+		counts[key]++
+	}
+
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fmt.Printf("%s: %d entries, oldest %s\n", path, len(entries), entries[0].Time.Format(time.RFC3339))
+	for _, key := range keys {
+		fmt.Printf("  %-30s %d\n", key, counts[key])
+	}
+}
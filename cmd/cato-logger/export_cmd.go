@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+
+	"cato-logger/internal/api"
+	"cato-logger/internal/config"
+	"cato-logger/internal/logging"
+	"cato-logger/internal/marker"
+	"cato-logger/internal/processor"
+)
+
+// csvLeadingColumns come first in a CSV export, the other fields follow
+// in name order
+var csvLeadingColumns = []string{"time", "event_type", "event_sub_type"}
+
+// runExportCommand handles "cato-logger export": it reads the events of a
+// time range from the events feed and writes them to a file, without
+// sending them to the outputs or moving the marker
+func runExportCommand(args []string) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	configPath := fs.String("config", "", "Path to config.json file")
+	fromFlag := fs.String("from", "", "Export events at or after this RFC 3339 time")
+	toFlag := fs.String("to", "", "Export events before this RFC 3339 time (default: now)")
+	format := fs.String("format", "json", "Output format: json, cef or csv")
+	outPath := fs.String("out", "", "File to write, - for standard output")
+	feedName := fs.String("feed", "", "Feed name, required when several feeds are configured")
+	accountID := fs.String("account", "", "Account ID, required when several accounts are polled")
+	startMarker := fs.String("marker", "", "Marker to start reading at, instead of the marker history")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	usage := "usage: cato-logger export --from <time> [--to <time>] [--format json|cef|csv] --out <file> [--config <file>] [--feed <name>] [--account <id>] [--marker <marker>]"
+	if *fromFlag == "" || *outPath == "" {
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+	if *format != "json" && *format != "cef" && *format != "csv" {
+		fmt.Fprintf(os.Stderr, "ERROR: unknown format %s, use json, cef or csv\n", *format)
+		return 2
+	}
+	from, err := time.Parse(time.RFC3339, *fromFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: --from: %v\n", err)
+		return 2
+	}
+	to := time.Now()
+	if *toFlag != "" {
+		if to, err = time.Parse(time.RFC3339, *toFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: --to: %v\n", err)
+			return 2
+		}
+	}
+	if !to.After(from) {
+		fmt.Fprintln(os.Stderr, "ERROR: --to must be after --from")
+		return 2
+	}
+
+	cfg, err := config.LoadFile(*configPath)
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: invalid configuration: %v\n", err)
+		return 2
+	}
+
+	feed, account, err := exportSelection(cfg, *feedName, *accountID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 2
+	}
+
+	// Progress goes to stderr when the events go to stdout
+	status := io.Writer(os.Stdout)
+	if *outPath == "-" {
+		status = os.Stderr
+	}
+
+	start := *startMarker
+	if start == "" {
+		cipher, err := stateCipher(cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			return 2
+		}
+		history, err := marker.ReadHistory(cfg.AccountMarkerFile(feed, account), cipher)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			return 1
+		}
+		entry, err := marker.FindEntry(history, from.Format(time.RFC3339))
+		if err != nil {
+			fmt.Fprintf(status, "%v; reading from the oldest event Cato keeps\n", err)
+		} else {
+			start = entry.Marker
+			fmt.Fprintf(status, "starting at the marker saved %s\n", entry.Time.Format(time.RFC3339))
+		}
+	}
+
+	logger, err := logging.New(logging.Options{Level: "error", Format: "text", Output: "stderr"})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	defer logger.Close()
+
+	stages, err := buildStages(cfg, logger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+
+	api.SetIdentity(api.Identity{Version: version, DeploymentID: cfg.DeploymentID})
+	client, err := newAPIClient(cfg, logger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	client = client.WithAccount(account, logger.Component("api"))
+	if len(feed.Filters) > 0 {
+		client = client.WithFilters(eventFilters(feed.Filters), logger.Component("api"))
+	}
+
+	out := io.Writer(os.Stdout)
+	if *outPath != "-" {
+		file, err := os.Create(*outPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			return 1
+		}
+		defer file.Close()
+		out = file
+	}
+	w := bufio.NewWriter(out)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	formatter := newFormats(cfg).Formatter(*format)
+	var rows []map[string]string // CSV needs every field name before the header
+	var exported, pages, failed int
+	current := start
+	var fetchErr error
+	for {
+		page, err := client.FetchWithRetry(ctx, current, cfg.RetryAttempts, time.Duration(cfg.RetryDelay)*time.Second)
+		if err != nil {
+			fetchErr = err
+			break
+		}
+		pages++
+
+		// The feed is in roughly the order Cato received the events, so a
+		// page that starts after the range ends the export
+		past := len(page.Events) > 0
+		for _, fields := range page.Events {
+			t, ok := processor.EventTime(fields)
+			if !ok || t.Before(to) {
+				past = false
+			}
+			if !ok || t.Before(from) || !t.Before(to) {
+				continue
+			}
+
+			record, err := replayFormat(cfg, feed.Name, stages, fields)
+			if err != nil {
+				failed++
+				continue
+			}
+			if *format == "csv" {
+				rows = append(rows, record.Fields)
+				exported++
+				continue
+			}
+			line, err := formatter.Format(record)
+			if err != nil {
+				failed++
+				continue
+			}
+			w.Write(line)
+			w.WriteByte('\n')
+			exported++
+		}
+
+		if pages%10 == 0 {
+			fmt.Fprintf(status, "read %d pages, %d events exported\n", pages, exported)
+		}
+		if past || !page.HasMore || page.NewMarker == "" {
+			break
+		}
+		current = page.NewMarker
+	}
+
+	if *format == "csv" {
+		if err := writeExportCSV(w, rows); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			return 1
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+
+	fmt.Fprintf(status, "exported %d events from %d pages to %s\n", exported, pages, *outPath)
+	if failed > 0 {
+		fmt.Fprintf(status, "%d events failed formatting and were skipped\n", failed)
+	}
+	if fetchErr != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: export stopped early: %v\n", fetchErr)
+		return 1
+	}
+	return 0
+}
+
+// exportSelection returns the feed and account an export reads
+func exportSelection(cfg *config.Config, feedName, accountID string) (config.Feed, string, error) {
+	feeds := cfg.EffectiveFeeds()
+	var selected *config.Feed
+	for i := range feeds {
+		if feeds[i].Name == feedName || (feedName == "" && len(feeds) == 1) {
+			selected = &feeds[i]
+		}
+	}
+	if selected == nil {
+		if feedName == "" {
+			return config.Feed{}, "", fmt.Errorf("several feeds are configured, select one with --feed: %v", feedNames(feeds))
+		}
+		return config.Feed{}, "", fmt.Errorf("feed %s is not configured", feedName)
+	}
+	if selected.Type == "audit" {
+		return config.Feed{}, "", fmt.Errorf("feed %s is an audit feed, export reads events feeds only", selected.Name)
+	}
+
+	if accountID == "" {
+		if cfg.MultiAccount() {
+			return config.Feed{}, "", fmt.Errorf("several accounts are polled, select one with --account")
+		}
+		accountID = cfg.CatoAccountID
+		if ids := cfg.AccountIDs(); len(ids) > 0 {
+			accountID = ids[0]
+		}
+	}
+	return *selected, accountID, nil
+}
+
+// writeExportCSV writes events as CSV with a column for every field any of
+// them has
+func writeExportCSV(w io.Writer, rows []map[string]string) error {
+	leading := make(map[string]bool, len(csvLeadingColumns))
+	for _, name := range csvLeadingColumns {
+		leading[name] = true
+	}
+	seen := make(map[string]bool)
+	var others []string
+	for _, row := range rows {
+		for name := range row {
+			if !leading[name] && !seen[name] {
+				seen[name] = true
+				others = append(others, name)
+			}
+		}
+	}
+	sort.Strings(others)
+	columns := append(append([]string(nil), csvLeadingColumns...), others...)
+
+	cw := csv.NewWriter(w)
+	cw.Write(columns)
+	record := make([]string, len(columns))
+	for _, row := range rows {
+		for i, name := range columns {
+			record[i] = row[name]
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+	return cw.Error()
+}
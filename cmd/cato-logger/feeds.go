@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cato-logger/internal/api"
+	"cato-logger/internal/config"
+	"cato-logger/internal/dlq"
+	"cato-logger/internal/logging"
+	"cato-logger/internal/marker"
+	"cato-logger/internal/output"
+	"cato-logger/internal/processor"
+)
+
+// feedRunner is the processing pipeline for one Cato API feed and account
+type feedRunner struct {
+	feed      config.Feed
+	accountID string
+	name      string // Feed name, plus the account ID when polling several accounts
+	markerMgr *marker.Manager
+	proc      *processor.Processor
+	stale     bool // Marker has not advanced within state.stale_after_minutes
+}
+
+// feedSet holds a runner per feed and account. Runners share the stages,
+// outputs, stats, and dead-letter queue but keep their own API query and
+// marker. Outputs format records with the CEF mapping profile of their
+// feed, held in formats.
+type feedSet struct {
+	apiClient  *api.Client
+	sinks      []output.Sink
+	formats    *output.Formats
+	stages     []processor.Stage
+	stats      *processor.Stats
+	deadLetter *dlq.Queue     // nil when disabled
+	cipher     *marker.Cipher // Encrypts marker files, nil when disabled
+	logger     *logging.Logger
+	runners    []*feedRunner
+}
+
+// add creates runners for every configured feed of each account
+func (s *feedSet) add(cfg *config.Config, accountIDs []string) error {
+	for _, feed := range cfg.EffectiveFeeds() {
+		for _, accountID := range accountIDs {
+			name := feed.Name
+			attrs := []any{"feed", feed.Name}
+			if cfg.MultiAccount() {
+				name = feed.Name + "/" + accountID
+				attrs = append(attrs, "account_id", accountID)
+			}
+
+			markerFile := cfg.AccountMarkerFile(feed, accountID)
+			markerMgr, err := marker.New(markerFile, s.cipher, s.logger.Component("marker").With(attrs...))
+			if err != nil {
+				return fmt.Errorf("feed %s: failed to initialize marker manager: %w", name, err)
+			}
+			if cfg.MarkerHistory > 0 {
+				if err := markerMgr.KeepHistory(cfg.MarkerHistory); err != nil {
+					return fmt.Errorf("feed %s: %w", name, err)
+				}
+			}
+
+			apiLogger := s.logger.Component("api").With(attrs...)
+			client := s.apiClient.WithAccount(accountID, apiLogger)
+			if feed.Type == "audit" {
+				client = client.ForAudit(feed.TimeFrame, apiLogger)
+			} else if len(feed.Filters) > 0 {
+				client = client.WithFilters(eventFilters(feed.Filters), apiLogger)
+			}
+
+			proc := processor.New(cfg, feed.Name, client, s.sinks, s.stages, markerMgr, s.stats,
+				s.logger.Component("processor").With(attrs...))
+			if s.deadLetter != nil {
+				proc.EnableDeadLetter(s.deadLetter, accountID)
+			}
+			if cfg.MarkerJournal {
+				entry, err := markerMgr.KeepJournal()
+				if err != nil {
+					return fmt.Errorf("feed %s: %w", name, err)
+				}
+				proc.EnableJournal(entry)
+			}
+
+			s.logger.Info("feed initialized",
+				"feed", name,
+				"type", feed.Type,
+				"marker_file", markerFile,
+				"field_mappings", len(feed.FieldMappings),
+				"filters", len(feed.Filters))
+
+			s.runners = append(s.runners, &feedRunner{
+				feed:      feed,
+				accountID: accountID,
+				name:      name,
+				markerMgr: markerMgr,
+				proc:      proc,
+			})
+		}
+	}
+	return nil
+}
+
+// remove drops the runners of the given accounts. Their marker files are
+// kept so polling resumes where it stopped if an account comes back.
+func (s *feedSet) remove(accountIDs []string) {
+	drop := make(map[string]bool, len(accountIDs))
+	for _, id := range accountIDs {
+		drop[id] = true
+	}
+
+	kept := s.runners[:0]
+	for _, runner := range s.runners {
+		if drop[runner.accountID] {
+			s.logger.Info("feed removed", "feed", runner.name)
+			continue
+		}
+		kept = append(kept, runner)
+	}
+	s.runners = kept
+}
+
+// accounts returns the account IDs currently polled
+func (s *feedSet) accounts() []string {
+	seen := make(map[string]bool)
+	var ids []string
+	for _, runner := range s.runners {
+		if !seen[runner.accountID] {
+			seen[runner.accountID] = true
+			ids = append(ids, runner.accountID)
+		}
+	}
+	return ids
+}
+
+// reconfigure swaps a reloaded configuration and stage list into every
+// runner, and the reloaded CEF mapping profiles into the outputs' formats.
+// The feed list is restart-only, so every running feed is still configured.
+func (s *feedSet) reconfigure(cfg *config.Config, stages []processor.Stage) {
+	s.stages = stages
+	for _, feed := range cfg.EffectiveFeeds() {
+		s.formats.SetCEF(feed.Name, newCEFFormatter(cfg, feed))
+	}
+	for _, runner := range s.runners {
+		runner.proc.Reconfigure(cfg, stages)
+	}
+}
+
+// process runs one processing cycle for every runner, at most maxConcurrent
+// at a time, and reports whether all of them succeeded
+func (s *feedSet) process(ctx context.Context, maxConcurrent int) bool {
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
+	}
+
+	var wg sync.WaitGroup
+	var failed atomic.Bool
+	slots := make(chan struct{}, maxConcurrent)
+
+	for _, runner := range s.runners {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(runner *feedRunner) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			if !runner.proc.ProcessWithRecovery(ctx) {
+				failed.Store(true)
+			}
+		}(runner)
+	}
+
+	wg.Wait()
+	return !failed.Load()
+}
+
+// saveDelivered saves every runner's marker up to the pages its outputs
+// delivered. It is called at shutdown after the outputs are closed.
+func (s *feedSet) saveDelivered(ctx context.Context) {
+	for _, runner := range s.runners {
+		runner.proc.SaveDelivered(ctx)
+	}
+}
+
+// checkStaleness raises the stale-marker alarm for runners whose marker has
+// not advanced within threshold, and clears it once they advance again. A
+// zero threshold disables the alarm. Every runner's marker is recorded in
+// the stats along the way.
+func (s *feedSet) checkStaleness(threshold time.Duration) {
+	var stale []string
+	markers := make([]processor.MarkerInfo, 0, len(s.runners))
+	for _, runner := range s.runners {
+		lastUpdate := runner.markerMgr.LastUpdate()
+		age := time.Since(lastUpdate)
+		markers = append(markers, processor.MarkerInfo{
+			Feed:       runner.name,
+			Marker:     runner.markerMgr.Get(),
+			LastUpdate: lastUpdate,
+			Stale:      threshold > 0 && age > threshold,
+		})
+		if threshold > 0 && age > threshold {
+			stale = append(stale, runner.name)
+			if !runner.stale {
+				runner.stale = true
+				s.stats.IncrementStaleMarkerAlarms()
+				s.logger.Error("marker has not advanced, feed may be stuck",
+					"feed", runner.name,
+					"marker_age_sec", int(age.Seconds()),
+					"threshold_sec", int(threshold.Seconds()))
+			}
+			continue
+		}
+		if runner.stale {
+			runner.stale = false
+			s.logger.Info("marker advancing again", "feed", runner.name)
+		}
+	}
+	s.stats.SetStaleFeeds(stale)
+	s.stats.SetMarkers(markers)
+}
+
+// markers returns the current marker of every runner, keyed by runner name
+func (s *feedSet) markers() map[string]string {
+	markers := make(map[string]string, len(s.runners))
+	for _, runner := range s.runners {
+		markers[runner.name] = runner.markerMgr.Get()
+	}
+	return markers
+}
+
+// stateCipher returns the cipher for marker and history files, or nil when
+// state.encryption_key is not set
+func stateCipher(cfg *config.Config) (*marker.Cipher, error) {
+	if cfg.StateKey == "" {
+		return nil, nil
+	}
+	return marker.NewCipher(cfg.StateKey)
+}
+
+// eventFilters converts configured feed filters to API filter inputs
+func eventFilters(filters []config.FeedFilter) []api.EventFilter {
+	converted := make([]api.EventFilter, len(filters))
+	for i, filter := range filters {
+		converted[i] = api.EventFilter{
+			FieldName: filter.Field,
+			Operator:  filter.Operator,
+			Values:    filter.Values,
+		}
+	}
+	return converted
+}
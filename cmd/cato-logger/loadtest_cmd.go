@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"cato-logger/internal/apitest"
+	"cato-logger/internal/config"
+	"cato-logger/internal/logging"
+	"cato-logger/internal/output"
+)
+
+// loadTestShortfall is the share of the target rate a load test may miss
+// before it fails
+const loadTestShortfall = 0.05
+
+// sinkLoad is what one output took during a load test
+type sinkLoad struct {
+	events    int64
+	bytes     int64
+	errors    int
+	latencies []time.Duration // Duration of every Write
+	dropped   atomic.Int64
+	truncated atomic.Int64
+}
+
+// runLoadTestCommand handles "cato-logger loadtest": it generates events at
+// a fixed rate, runs them through the configured stages and outputs, and
+// reports throughput, allocations, and drops, so sizing can be checked
+// before go-live
+func runLoadTestCommand(args []string) int {
+	fs := flag.NewFlagSet("loadtest", flag.ContinueOnError)
+	configPath := fs.String("config", "", "Path to config.json file")
+	eps := fs.Float64("eps", 1000, "Events per second to generate (0 as fast as the outputs take them)")
+	duration := fs.Duration("duration", 30*time.Second, "How long to generate events")
+	batchSize := fs.Int("batch", 1000, "Events per write, like a page of the events feed")
+	feedName := fs.String("feed", "", "Feed whose mapping profile formats the events (defaults to the first feed)")
+	outputName := fs.String("output", "", "Only load this output (default: all outputs)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *eps < 0 || *duration <= 0 || *batchSize <= 0 {
+		fmt.Fprintln(os.Stderr, "usage: cato-logger loadtest [--config <file>] [--eps N] [--duration D] [--batch N] [--feed <name>] [--output <name>]")
+		return 2
+	}
+
+	cfg, err := config.LoadFile(*configPath)
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: invalid configuration: %v\n", err)
+		return 2
+	}
+
+	feed := *feedName
+	if feed == "" {
+		feed = cfg.EffectiveFeeds()[0].Name
+	}
+	known := false
+	for _, f := range cfg.EffectiveFeeds() {
+		known = known || f.Name == feed
+	}
+	if !known {
+		fmt.Fprintf(os.Stderr, "ERROR: feed %s is not configured\n", feed)
+		return 2
+	}
+
+	var outputs []config.Output
+	for _, out := range cfg.EffectiveOutputs() {
+		if *outputName == "" || out.Name == *outputName {
+			outputs = append(outputs, out)
+		}
+	}
+	if len(outputs) == 0 {
+		fmt.Fprintf(os.Stderr, "ERROR: output %s is not configured\n", *outputName)
+		return 2
+	}
+
+	logger, err := logging.New(logging.Options{Level: "error", Format: "text", Output: "stderr"})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	defer logger.Close()
+
+	stages, err := buildStages(cfg, logger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+
+	loads := make(map[string]*sinkLoad, len(outputs))
+	for _, out := range outputs {
+		loads[out.Name] = &sinkLoad{}
+	}
+	sinks, err := output.Build(outputs, output.Options{
+		ConnTimeout:   time.Duration(cfg.ConnTimeout) * time.Second,
+		Logger:        logger,
+		OnDrop:        func(name string) { loads[name].dropped.Add(1) },
+		OnTruncate:    func(name string) { loads[name].truncated.Add(1) },
+		Formats:       newFormats(cfg),
+		FormatWorkers: cfg.FormatWorkers,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: failed to initialize outputs: %v\n", err)
+		return 1
+	}
+	defer output.CloseAll(sinks)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	rate := "as fast as possible"
+	if *eps > 0 {
+		rate = fmt.Sprintf("%.0f events/sec", *eps)
+	}
+	fmt.Printf("load testing %d output(s) with feed %s at %s for %s\n", len(sinks), feed, rate, *duration)
+
+	var before, sample runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	peakHeap := before.HeapInuse
+	lastSample := time.Now()
+
+	var generated int64
+	var formatFailed int
+	var formatTime time.Duration
+	start := time.Now()
+	deadline := start.Add(*duration)
+
+	for time.Now().Before(deadline) && ctx.Err() == nil {
+		// Pace the batches so the events arrive at the target rate
+		if *eps > 0 {
+			due := start.Add(time.Duration(float64(generated) / *eps * float64(time.Second)))
+			if wait := time.Until(due); wait > 0 {
+				select {
+				case <-time.After(min(wait, time.Until(deadline))):
+				case <-ctx.Done():
+				}
+				if !time.Now().Before(deadline) || ctx.Err() != nil {
+					break
+				}
+			}
+		}
+
+		formatStart := time.Now()
+		records := make([]output.Record, 0, *batchSize)
+		for i := 0; i < *batchSize; i++ {
+			event := apitest.Event("loadtest", generated, time.Now())
+			generated++
+			record, err := replayFormat(cfg, feed, stages, event)
+			if err != nil {
+				formatFailed++
+				continue
+			}
+			records = append(records, record)
+		}
+		formatTime += time.Since(formatStart)
+
+		for _, sink := range sinks {
+			load := loads[sink.Name()]
+			writeStart := time.Now()
+			n, err := sink.Write(ctx, records)
+			load.latencies = append(load.latencies, time.Since(writeStart))
+			if err != nil {
+				load.errors++
+				continue
+			}
+			load.events += int64(len(records))
+			load.bytes += n
+		}
+
+		if time.Since(lastSample) >= time.Second {
+			runtime.ReadMemStats(&sample)
+			peakHeap = max(peakHeap, sample.HeapInuse)
+			lastSample = time.Now()
+		}
+	}
+	elapsed := time.Since(start)
+
+	// Queued outputs accept records before delivering them
+	undelivered := waitDelivered(sinks, deliveryTimeout)
+
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+	peakHeap = max(peakHeap, after.HeapInuse)
+
+	achieved := float64(generated) / elapsed.Seconds()
+	fmt.Printf("generated %d events in %s: %.1f events/sec", generated, elapsed.Round(time.Millisecond), achieved)
+	if *eps > 0 {
+		fmt.Printf(" (%.1f%% of target)", achieved / *eps * 100)
+	}
+	fmt.Println()
+	if generated > 0 {
+		fmt.Printf("generation and stages: %s/event, %d events failed formatting\n",
+			(formatTime / time.Duration(generated)).Round(time.Nanosecond), formatFailed)
+		fmt.Printf("memory: %s/event, %d allocations/event, peak heap %s, %d GC cycles\n",
+			formatBytes(int64(after.TotalAlloc-before.TotalAlloc)/generated),
+			(after.Mallocs-before.Mallocs)/uint64(generated),
+			formatBytes(int64(peakHeap)), after.NumGC-before.NumGC)
+	}
+
+	failed := formatFailed > 0 || (*eps > 0 && ctx.Err() == nil && achieved < *eps*(1-loadTestShortfall))
+	for _, sink := range sinks {
+		load := loads[sink.Name()]
+		p50, p99 := percentile(load.latencies, 0.5), percentile(load.latencies, 0.99)
+		fmt.Printf("output %s (%s): %d events, %s, %.1f events/sec, write p50 %s p99 %s, %d failed writes, %d dropped, %d truncated",
+			sink.Name(), sink.Type(), load.events, formatBytes(load.bytes), float64(load.events)/elapsed.Seconds(),
+			p50.Round(time.Microsecond), p99.Round(time.Microsecond), load.errors, load.dropped.Load(), load.truncated.Load())
+		if n := undelivered[sink.Name()]; n > 0 {
+			fmt.Printf(", %d undelivered after %s", n, deliveryTimeout)
+		}
+		fmt.Println()
+		failed = failed || load.errors > 0 || load.dropped.Load() > 0 || undelivered[sink.Name()] > 0
+	}
+
+	if failed {
+		return 1
+	}
+	return 0
+}
@@ -5,22 +5,56 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
+	"cato-logger/internal/admin"
 	"cato-logger/internal/api"
-	"cato-logger/internal/cef"
 	"cato-logger/internal/config"
+	"cato-logger/internal/dlq"
 	"cato-logger/internal/logging"
-	"cato-logger/internal/marker"
+	"cato-logger/internal/output"
 	"cato-logger/internal/preflight"
 	"cato-logger/internal/processor"
-	"cato-logger/internal/syslog"
 )
 
-const version = "3.2"
-
 func main() {
+	// Dispatch management subcommands; anything else runs the service
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "version", "--version", "-version":
+			os.Exit(runVersionCommand(os.Args[2:]))
+		case "config":
+			os.Exit(runConfigCommand(os.Args[2:]))
+		case "preflight":
+			os.Exit(runPreflightCommand(os.Args[2:]))
+		case "marker":
+			os.Exit(runMarkerCommand(os.Args[2:]))
+		case "dlq":
+			os.Exit(runDLQCommand(os.Args[2:]))
+		case "test-cef":
+			os.Exit(runTestCEFCommand(os.Args[2:]))
+		case "test-syslog":
+			os.Exit(runTestSyslogCommand(os.Args[2:]))
+		case "mockapi":
+			os.Exit(runMockAPICommand(os.Args[2:]))
+		case "export":
+			os.Exit(runExportCommand(os.Args[2:]))
+		case "loadtest":
+			os.Exit(runLoadTestCommand(os.Args[2:]))
+		case "fetch":
+			// "fetch" names the service explicitly, as in "fetch --once"
+			os.Args = append(os.Args[:1], os.Args[2:]...)
+		}
+	}
+
+	os.Exit(runService())
+}
+
+// runService runs the forwarding service until shutdown, or for a single
+// cycle with --once, and returns the exit code
+func runService() int {
 	// Create cancellable context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -33,24 +67,45 @@ func main() {
 	}
 
 	// Initialize structured logger
-	logger, err := logging.New(cfg.LogLevel, cfg.LogFormat, cfg.LogOutput)
+	logger, err := logging.New(logging.Options{
+		Level:    cfg.LogLevel,
+		Format:   cfg.LogFormat,
+		Output:   cfg.LogOutput,
+		Facility: cfg.LogFacility,
+		Rotation: logging.RotationOptions{
+			MaxSizeMB:  cfg.LogRotation.MaxSizeMB,
+			MaxBackups: cfg.LogRotation.MaxBackups,
+			MaxAgeDays: cfg.LogRotation.MaxAgeDays,
+			Compress:   cfg.LogRotation.Compress,
+		},
+		DedupWindow: time.Duration(cfg.LogDedupWindow) * time.Second,
+	})
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "FATAL: Failed to initialize logger: %v\n", err)
 		os.Exit(1)
 	}
 	defer logger.Close()
 
+	if err := logger.SetComponentLevels(cfg.LogComponentLevels); err != nil {
+		fmt.Fprintf(os.Stderr, "FATAL: Invalid component log levels: %v\n", err)
+		os.Exit(1)
+	}
+
+	api.SetIdentity(api.Identity{Version: version, DeploymentID: cfg.DeploymentID})
+
 	// Startup banner
 	logger.Info("starting Cato Networks CEF Forwarder",
 		"version", version,
+		"commit", buildCommit(),
 		"pid", os.Getpid(),
+		"user_agent", api.UserAgent(),
 		"config_file", cfg.ConfigPath)
 
 	logger.Info("configuration loaded",
 		"api_url", cfg.CatoAPIURL,
-		"account_id", cfg.CatoAccountID,
-		"syslog_server", cfg.SyslogAddress(),
-		"syslog_protocol", cfg.SyslogProtocol,
+		"account_ids", cfg.AccountIDs(),
+		"outputs", outputNames(cfg.EffectiveOutputs()),
+		"feeds", feedNames(cfg.EffectiveFeeds()),
 		"fetch_interval_sec", cfg.FetchInterval,
 		"max_events", cfg.MaxEvents,
 		"max_pagination", cfg.MaxPagination,
@@ -62,21 +117,15 @@ func main() {
 		logger.Error("configuration validation failed", "error", err.Error())
 		os.Exit(1)
 	}
+	logConfigWarnings(cfg, logger)
+	applyMemorySettings(cfg, logger)
 
 	// Run pre-flight checks
 	logger.Info("running pre-flight checks")
-	preflightChecker := preflight.New(logger)
-	preflightResults := preflightChecker.RunAll(
-		cfg.CatoAPIURL,
-		cfg.CatoAPIKey,
-		cfg.CatoAccountID,
-		cfg.SyslogProtocol,
-		cfg.SyslogAddress(),
-		cfg.MarkerFile,
-		time.Duration(cfg.ConnTimeout)*time.Second,
-	)
+	preflightResults := runStartupPreflight(ctx, cfg, logger)
 
 	if preflight.HasFailures(preflightResults) {
+		writeStartupReport(ctx, cfg, "preflight_failed", nil, preflightResults, logger)
 		logger.Error("pre-flight checks failed, cannot start service")
 		fmt.Fprintf(os.Stderr, "\n%s\n", preflight.FormatFailures(preflightResults))
 		os.Exit(1)
@@ -84,59 +133,117 @@ func main() {
 
 	logger.Info("all pre-flight checks passed")
 
-	// Initialize marker manager
-	markerMgr, err := marker.New(cfg.MarkerFile, logger)
+	// Initialize pre-formatting stages
+	stages, err := buildStages(cfg, logger)
 	if err != nil {
-		logger.Error("failed to initialize marker manager", "error", err.Error())
+		logger.Error("failed to initialize event pipeline", "error", err.Error())
 		os.Exit(1)
 	}
 
-	// Initialize CEF formatter
-	cefFormatter := cef.NewFormatter(
-		cfg.CEFVendor,
-		cfg.CEFProduct,
-		cfg.CEFVersion,
-		cfg.FieldMappings,
-		cfg.OrderedFields,
-	)
-	logger.Info("CEF formatter initialized",
-		"vendor", cfg.CEFVendor,
-		"product", cfg.CEFProduct,
-		"field_mappings", len(cfg.FieldMappings))
-
 	// Initialize API client
-	apiClient := api.NewClient(
-		cfg.CatoAPIURL,
-		cfg.CatoAPIKey,
-		cfg.CatoAccountID,
-		time.Duration(cfg.ConnTimeout)*time.Second,
-		logger,
-	)
-
-	// Initialize syslog writer
-	syslogWriter, err := syslog.NewWriter(
-		cfg.SyslogProtocol,
-		cfg.SyslogAddress(),
-		time.Duration(cfg.ConnTimeout)*time.Second,
-		logger,
-	)
+	apiClient, err := newAPIClient(cfg, logger)
 	if err != nil {
-		logger.Error("failed to initialize syslog connection", "error", err.Error())
+		logger.Error("failed to initialize API client", "error", err.Error())
 		os.Exit(1)
 	}
-	defer syslogWriter.Close()
 
 	// Initialize stats tracker
 	stats := processor.NewStats()
 
-	// Initialize processor
-	proc := processor.New(cfg, apiClient, syslogWriter, cefFormatter, markerMgr, stats, logger)
+	// Switch for pausing fetching during SIEM maintenance
+	pause := newPauseState()
+
+	// Optional admin/metrics endpoint
+	if cfg.AdminListen != "" {
+		adminServer := admin.New(stats, logger.Component("admin"))
+		adminServer.EnablePause(pause, cfg.AdminToken)
+		if err := adminServer.Start(ctx, cfg.AdminListen); err != nil {
+			logger.Error("failed to start admin server", "error", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	// Initialize outputs, each formatting records in its own format
+	formats := newFormats(cfg)
+	sinks, err := output.Build(cfg.EffectiveOutputs(), output.Options{
+		ConnTimeout:   time.Duration(cfg.ConnTimeout) * time.Second,
+		Logger:        logger,
+		OnReconnect:   stats.RecordReconnect,
+		OnDrop:        stats.IncrementDropped,
+		OnTruncate:    func(string) { stats.IncrementTruncated() },
+		Formats:       formats,
+		FormatWorkers: cfg.FormatWorkers,
+	})
+	if err != nil {
+		logger.Error("failed to initialize outputs", "error", err.Error())
+		os.Exit(1)
+	}
+	stats.TrackOutputs(sinks)
+	closeSinks := sync.OnceFunc(func() { output.CloseAll(sinks) })
+	defer closeSinks()
+
+	// Optional dead-letter queue for events that cannot be formatted or delivered
+	var deadLetter *dlq.Queue
+	if cfg.DeadLetterFile != "" {
+		deadLetter, err = dlq.Open(cfg.DeadLetterFile, int64(cfg.DeadLetterMaxMB)<<20)
+		if err != nil {
+			logger.Error("failed to initialize dead-letter queue", "error", err.Error())
+			os.Exit(1)
+		}
+		logger.Info("dead-letter queue enabled",
+			"file", cfg.DeadLetterFile,
+			"max_delivery_attempts", cfg.DeadLetterMaxAttempts,
+			"max_size_mb", cfg.DeadLetterMaxMB)
+	}
+
+	// Optional encryption of marker and history files
+	cipher, err := stateCipher(cfg)
+	if err != nil {
+		logger.Error("failed to initialize state encryption", "error", err.Error())
+		os.Exit(1)
+	}
+	if cipher != nil {
+		logger.Info("marker and history files are encrypted")
+	}
+
+	// Resolve the polled accounts, including discovered sub-accounts
+	feeds := &feedSet{
+		apiClient:  apiClient,
+		sinks:      sinks,
+		formats:    formats,
+		stages:     stages,
+		stats:      stats,
+		deadLetter: deadLetter,
+		cipher:     cipher,
+		logger:     logger,
+	}
+	accountIDs, err := discoverAccounts(ctx, cfg, feeds, logger.Component("discovery"))
+	if err != nil {
+		logger.Error("failed to resolve accounts", "error", err.Error())
+		os.Exit(1)
+	}
+	if len(accountIDs) == 0 {
+		logger.Error("no accounts to poll, check cato.account_ids and cato.discovery")
+		os.Exit(1)
+	}
+
+	// Initialize a processor per feed and account
+	if err := feeds.add(cfg, accountIDs); err != nil {
+		logger.Error("failed to initialize feeds", "error", err.Error())
+		os.Exit(1)
+	}
 
 	logger.Info("all components initialized successfully")
+	writeStartupReport(ctx, cfg, "started", accountIDs, preflightResults, logger)
+
+	if cfg.Once {
+		return runOnce(ctx, cfg, feeds, stats, closeSinks, logger)
+	}
 
 	// Setup signal handling for graceful shutdown
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGHUP)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGHUP,
+		syscall.SIGUSR1, syscall.SIGUSR2, syscall.SIGTSTP, syscall.SIGCONT)
 
 	// Main service loop with exponential backoff
 	ticker := time.NewTicker(time.Duration(cfg.FetchInterval) * time.Second)
@@ -145,22 +252,100 @@ func main() {
 	backoffDelay := 1 * time.Second
 	maxBackoff := time.Duration(cfg.MaxBackoffDelay) * time.Second
 
+	// Optional periodic stats report, independent of per-cycle logging
+	var statsTicker *time.Ticker
+	var statsReport <-chan time.Time
+	setStatsInterval := func(minutes int) {
+		if statsTicker != nil {
+			statsTicker.Stop()
+			statsTicker, statsReport = nil, nil
+		}
+		if minutes > 0 {
+			statsTicker = time.NewTicker(time.Duration(minutes) * time.Minute)
+			statsReport = statsTicker.C
+		}
+	}
+	setStatsInterval(cfg.StatsInterval)
+	defer setStatsInterval(0)
+
+	// Optional delivery latency SLO, evaluated once per window
+	var sloTicker *time.Ticker
+	var sloCheck <-chan time.Time
+	setSLOWindow := func(latency, minutes int) {
+		if sloTicker != nil {
+			sloTicker.Stop()
+			sloTicker, sloCheck = nil, nil
+		}
+		if latency > 0 {
+			sloTicker = time.NewTicker(time.Duration(minutes) * time.Minute)
+			sloCheck = sloTicker.C
+		}
+	}
+	applySLO(cfg, stats)
+	setSLOWindow(cfg.SLOLatency, cfg.SLOWindow)
+	defer setSLOWindow(0, 0)
+
+	// applyConfig adopts a successfully reloaded configuration in the main loop
+	applyConfig := func(newCfg *config.Config) {
+		if newCfg == nil {
+			return
+		}
+		if newCfg.StatsInterval != cfg.StatsInterval {
+			setStatsInterval(newCfg.StatsInterval)
+		}
+		if newCfg.SLOLatency != cfg.SLOLatency || newCfg.SLOTarget != cfg.SLOTarget || newCfg.SLOWindow != cfg.SLOWindow {
+			applySLO(newCfg, stats)
+			setSLOWindow(newCfg.SLOLatency, newCfg.SLOWindow)
+		}
+		cfg = newCfg
+		maxBackoff = time.Duration(cfg.MaxBackoffDelay) * time.Second
+		backoffDelay = 1 * time.Second
+		ticker.Reset(time.Duration(cfg.FetchInterval) * time.Second)
+	}
+
+	// Optional periodic sub-account discovery (discovery settings are restart-only)
+	var discoveryRefresh <-chan time.Time
+	if cfg.DiscoveryParentID != "" && cfg.DiscoveryInterval > 0 {
+		discoveryTicker := time.NewTicker(time.Duration(cfg.DiscoveryInterval) * time.Minute)
+		defer discoveryTicker.Stop()
+		discoveryRefresh = discoveryTicker.C
+	}
+
+	// Optional config file watcher (SIGHUP reloads regardless)
+	var configChanged <-chan struct{}
+	if cfg.WatchConfig {
+		configChanged = config.Watch(ctx, cfg.ConfigPath, time.Duration(cfg.WatchInterval)*time.Second)
+		logger.Info("watching configuration file for changes",
+			"config_file", cfg.ConfigPath,
+			"poll_interval_sec", cfg.WatchInterval)
+	}
+
 	logger.Info("starting main processing loop")
 
 	// Process initial events immediately
-	success := proc.ProcessWithRecovery(ctx)
+	success := feeds.process(ctx, cfg.MaxConcurrency)
 	if !success {
 		logger.Warn("initial processing cycle failed, will retry")
 	}
+	feeds.checkStaleness(time.Duration(cfg.StaleMarker) * time.Minute)
 
 	for {
 		select {
 		case <-ctx.Done():
 			logger.Info("context cancelled, shutting down")
-			return
+			closeSinks()
+			feeds.saveDelivered(context.Background())
+			return 0
 
 		case <-ticker.C:
-			success := proc.ProcessWithRecovery(ctx)
+			if pause.isPaused() {
+				// Keep saving the markers of what queued outputs deliver
+				feeds.saveDelivered(ctx)
+				continue
+			}
+
+			success := feeds.process(ctx, cfg.MaxConcurrency)
+			feeds.checkStaleness(time.Duration(cfg.StaleMarker) * time.Minute)
 
 			if success {
 				// Reset backoff on success
@@ -181,27 +366,106 @@ func main() {
 				}
 			}
 
+		case <-statsReport:
+			report := stats.Report()
+			logger.Info("periodic stats report",
+				"window_sec", int(report.Window.Seconds()),
+				"events_forwarded", report.EventsForwarded,
+				"events_per_second", fmt.Sprintf("%.2f", report.EventsPerSecond),
+				"bytes_fetched", report.BytesFetched,
+				"bytes_sent", report.BytesSent,
+				"api_requests", report.APIRequests,
+				"api_latency_p50_ms", report.APILatencyP50.Milliseconds(),
+				"api_latency_p90_ms", report.APILatencyP90.Milliseconds(),
+				"api_latency_p99_ms", report.APILatencyP99.Milliseconds(),
+				"reconnects", report.Reconnects,
+				"cycles", report.Cycles,
+				"pages_per_cycle", fmt.Sprintf("%.1f", report.PagesPerCycle),
+				"max_drain_ms", report.MaxDrainTime.Milliseconds(),
+				"top_event_types", processor.FormatEventTypes(report.TopEventTypes),
+				"outputs", processor.FormatOutputs(report.Outputs, time.Now()),
+				"marker_age_sec", int(report.MarkerAge.Seconds()),
+				"total_events", report.TotalEvents,
+				"last_cycle_id", report.LastCycleID)
+
+		case <-sloCheck:
+			checkSLO(cfg, stats, logger)
+
+		case <-pause.changed:
+			status := pause.PauseStatus()
+			if status.Paused {
+				feeds.saveDelivered(ctx)
+				logger.Info("processing paused, fetching stops until resumed", "reason", status.Reason)
+				continue
+			}
+			// Run a cycle right away; the tick resets the interval after it
+			logger.Info("processing resumed")
+			backoffDelay = 1 * time.Second
+			ticker.Reset(time.Millisecond)
+
+		case <-discoveryRefresh:
+			refreshAccounts(ctx, cfg, feeds, logger.Component("discovery"))
+
+		case <-configChanged:
+			logger.Info("configuration file changed on disk")
+			applyConfig(reloadConfig(cfg, feeds, logger))
+
 		case sig := <-sigChan:
 			logger.Info("received signal", "signal", sig.String())
 
-			if sig == syscall.SIGHUP {
-				logger.Info("SIGHUP received - configuration reload not yet implemented")
-				// Note: With JSON config, we could reload the entire config here
-				// For now, just log it
+			switch sig {
+			case syscall.SIGHUP:
+				applyConfig(reloadConfig(cfg, feeds, logger))
+				continue
+			case syscall.SIGUSR1:
+				dumpRuntimeStats(logger, stats, feeds, sinks)
+				continue
+			case syscall.SIGUSR2:
+				toggleDebugLogging(logger, cfg.LogLevel)
+				continue
+			case syscall.SIGTSTP:
+				pause.Pause("SIGTSTP")
+				continue
+			case syscall.SIGCONT:
+				pause.Resume()
 				continue
 			}
 
 			// Save final state and shutdown
 			logger.Info("initiating graceful shutdown")
 
-			// Log final statistics
-			logger.Info("final statistics",
-				"total_events_forwarded", stats.GetTotalEvents(),
-				"total_api_requests", stats.GetTotalAPIRequests(),
-				"failed_api_requests", stats.GetFailedAPIRequests())
+			logFinalStats(logger, stats)
+
+			// Let queued outputs drain, then save the markers of what they delivered
+			closeSinks()
+			feeds.saveDelivered(context.Background())
 
 			cancel()
-			return
+			return 0
 		}
 	}
 }
+
+// logConfigWarnings logs the settings that pass validation but are likely
+// to cause trouble
+func logConfigWarnings(cfg *config.Config, logger *logging.Logger) {
+	for _, warning := range cfg.Warnings() {
+		logger.Warn("configuration warning", "setting", warning.Setting, "warning", warning.Message, "hint", warning.Hint)
+	}
+}
+
+// logFinalStats logs the lifetime totals at shutdown
+func logFinalStats(logger *logging.Logger, stats *processor.Stats) {
+	snapshot := stats.Snapshot()
+	logger.Info("final statistics",
+		"total_events_forwarded", snapshot.TotalEventsForwarded,
+		"total_api_requests", snapshot.TotalAPIRequests,
+		"failed_api_requests", snapshot.FailedAPIRequests,
+		"total_bytes_fetched", snapshot.TotalBytesFetched,
+		"total_bytes_sent", snapshot.TotalBytesSent,
+		"total_dead_lettered", snapshot.TotalDeadLettered,
+		"total_dropped", snapshot.TotalDropped,
+		"total_truncated", snapshot.TotalTruncated,
+		"total_sampled_out", snapshot.TotalSampledOut,
+		"total_aggregated", snapshot.TotalAggregated)
+}
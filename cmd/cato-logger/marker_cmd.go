@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"cato-logger/internal/config"
+	"cato-logger/internal/logging"
+	"cato-logger/internal/marker"
+)
+
+// runMarkerCommand handles "cato-logger marker <subcommand>"
+func runMarkerCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: cato-logger marker <show|set|reset|list|rollback> [flags]")
+		return 2
+	}
+
+	switch args[0] {
+	case "show":
+		return runMarkerShow(args[1:])
+	case "set":
+		return runMarkerSet(args[1:])
+	case "reset":
+		return runMarkerReset(args[1:])
+	case "list":
+		return runMarkerList(args[1:])
+	case "rollback":
+		return runMarkerRollback(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown marker subcommand: %s\n", args[0])
+		return 2
+	}
+}
+
+// markerFlags selects the marker file a marker subcommand works on
+type markerFlags struct {
+	configPath *string
+	feed       *string
+	account    *string
+	file       *string
+}
+
+func addMarkerFlags(fs *flag.FlagSet) *markerFlags {
+	return &markerFlags{
+		configPath: fs.String("config", "", "Path to config.json file"),
+		feed:       fs.String("feed", "", "Feed name, required when several feeds are configured"),
+		account:    fs.String("account", "", "Account ID, required when several accounts are polled"),
+		file:       fs.String("file", "", "Marker file path, instead of resolving it from the config"),
+	}
+}
+
+// resolve returns the marker file selected by the flags and the cipher for
+// its contents. With --file the config is only read when --config is given.
+func (f *markerFlags) resolve() (string, *marker.Cipher, error) {
+	if *f.file != "" && *f.configPath == "" {
+		return *f.file, nil, nil
+	}
+
+	cfg, err := config.LoadFile(*f.configPath)
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	cipher, err := stateCipher(cfg)
+	if err != nil {
+		return "", nil, err
+	}
+	if *f.file != "" {
+		return *f.file, cipher, nil
+	}
+
+	feeds := cfg.EffectiveFeeds()
+	var selected *config.Feed
+	for i := range feeds {
+		if feeds[i].Name == *f.feed || (*f.feed == "" && len(feeds) == 1) {
+			selected = &feeds[i]
+		}
+	}
+	if selected == nil {
+		if *f.feed == "" {
+			return "", nil, fmt.Errorf("several feeds are configured, select one with --feed: %v", feedNames(feeds))
+		}
+		return "", nil, fmt.Errorf("feed %s is not configured", *f.feed)
+	}
+
+	accountID := *f.account
+	if accountID == "" && cfg.MultiAccount() {
+		return "", nil, fmt.Errorf("several accounts are polled, select one with --account")
+	}
+	return cfg.AccountMarkerFile(*selected, accountID), cipher, nil
+}
+
+// runMarkerShow prints the current marker of one feed
+func runMarkerShow(args []string) int {
+	fs := flag.NewFlagSet("marker show", flag.ContinueOnError)
+	selection := addMarkerFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	markerFile, cipher, err := selection.resolve()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 2
+	}
+
+	saved, err := marker.Modified(markerFile)
+	if os.IsNotExist(err) {
+		fmt.Printf("%s does not exist, the feed starts fresh\n", markerFile)
+		return 0
+	}
+	var current string
+	if err == nil {
+		current, err = marker.Read(markerFile, cipher)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("file:    %s\n", markerFile)
+	fmt.Printf("marker:  %s\n", current)
+	fmt.Printf("saved:   %s\n", saved.UTC().Format(time.RFC3339))
+	if t, ok := marker.DecodeTime(current); ok {
+		fmt.Printf("decoded: %s (timestamp found in the marker)\n", t.UTC().Format(time.RFC3339))
+	}
+	return 0
+}
+
+// runMarkerSet writes an explicit marker
+func runMarkerSet(args []string) int {
+	fs := flag.NewFlagSet("marker set", flag.ContinueOnError)
+	selection := addMarkerFlags(fs)
+	value := fs.String("value", "", "Marker to write")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *value == "" {
+		fmt.Fprintln(os.Stderr, "ERROR: --value is required (use marker reset to start fresh)")
+		return 2
+	}
+
+	markerFile, cipher, err := selection.resolve()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 2
+	}
+
+	previous, err := saveMarker(markerFile, *value, cipher)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("set the marker in %s\n", markerFile)
+	printPreviousMarker(previous)
+	return 0
+}
+
+// runMarkerReset removes a marker file so the feed starts fresh. The history
+// is kept, so a reset can be rolled back.
+func runMarkerReset(args []string) int {
+	fs := flag.NewFlagSet("marker reset", flag.ContinueOnError)
+	selection := addMarkerFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	markerFile, cipher, err := selection.resolve()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 2
+	}
+
+	previous, err := marker.Read(markerFile, cipher)
+	if os.IsNotExist(err) {
+		fmt.Printf("%s does not exist, the feed already starts fresh\n", markerFile)
+		return 0
+	}
+	if err != nil {
+		// A marker that cannot be decrypted can still be reset
+		fmt.Fprintf(os.Stderr, "WARNING: cannot read the current marker: %v\n", err)
+	}
+	if err := marker.Remove(markerFile); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	if err := marker.ClearJournal(markerFile); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("removed %s, the feed starts fresh\n", markerFile)
+	printPreviousMarker(previous)
+	return 0
+}
+
+// saveMarker writes a marker file and returns the marker it replaced. The
+// journal is removed, so the new position is forwarded to every output.
+func saveMarker(markerFile, value string, cipher *marker.Cipher) (string, error) {
+	logger, err := logging.New(logging.Options{Level: "error", Format: "text", Output: "stderr"})
+	if err != nil {
+		return "", err
+	}
+	defer logger.Close()
+
+	manager, err := marker.New(markerFile, cipher, logger)
+	if err != nil {
+		return "", err
+	}
+	previous := manager.Get()
+	if err := manager.Save(context.Background(), value); err != nil {
+		return "", err
+	}
+	if err := marker.ClearJournal(markerFile); err != nil {
+		return "", err
+	}
+	return previous, nil
+}
+
+// printPreviousMarker prints the replaced marker so a change can be undone,
+// with the reminder that the service must be stopped
+func printPreviousMarker(previous string) {
+	if previous != "" {
+		fmt.Printf("previous marker: %s\n", previous)
+	}
+	fmt.Println("run this while the service is stopped; a running service overwrites the marker on its next update")
+}
+
+// runMarkerList prints the marker history of one feed, newest first
+func runMarkerList(args []string) int {
+	fs := flag.NewFlagSet("marker list", flag.ContinueOnError)
+	selection := addMarkerFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	markerFile, cipher, err := selection.resolve()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 2
+	}
+
+	history, err := marker.ReadHistory(markerFile, cipher)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	if len(history) == 0 {
+		fmt.Printf("no marker history recorded for %s\n", markerFile)
+		return 0
+	}
+
+	current, _ := marker.Read(markerFile, cipher)
+	fmt.Printf("%-6s %-20s %8s  %s\n", "INDEX", "SAVED", "EVENTS", "MARKER")
+	for i := len(history) - 1; i >= 0; i-- {
+		entry := history[i]
+		line := fmt.Sprintf("%-6d %-20s %8d  %s", len(history)-1-i, entry.Time.Format(time.RFC3339), entry.Events, entry.Marker)
+		if entry.Marker == current {
+			line += "  (current)"
+		}
+		fmt.Println(line)
+	}
+	return 0
+}
+
+// runMarkerRollback moves a marker back to an earlier history entry so the
+// events after it are forwarded again
+func runMarkerRollback(args []string) int {
+	fs := flag.NewFlagSet("marker rollback", flag.ContinueOnError)
+	selection := addMarkerFlags(fs)
+	to := fs.String("to", "", "History index (0 is the newest) or RFC 3339 time to roll back to")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *to == "" {
+		fmt.Fprintln(os.Stderr, "ERROR: --to is required")
+		return 2
+	}
+
+	markerFile, cipher, err := selection.resolve()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 2
+	}
+
+	history, err := marker.ReadHistory(markerFile, cipher)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	entry, err := marker.FindEntry(history, *to)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+
+	// Events recorded after the chosen entry are forwarded again
+	replayed := 0
+	for _, later := range history {
+		if later.Time.After(entry.Time) {
+			replayed += later.Events
+		}
+	}
+
+	previous, err := saveMarker(markerFile, entry.Marker, cipher)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("rolled %s back to the marker saved at %s\n", markerFile, entry.Time.Format(time.RFC3339))
+	fmt.Printf("about %d events will be forwarded again\n", replayed)
+	printPreviousMarker(previous)
+	return 0
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"cato-logger/internal/output"
+)
+
+// deliveryTimeout bounds the wait of the loadtest and test-syslog commands
+// for queued outputs to deliver what they accepted
+const deliveryTimeout = 30 * time.Second
+
+// waitDelivered waits up to timeout for queued outputs to deliver the
+// records they accepted and returns the number still undelivered per output
+func waitDelivered(sinks []output.Sink, timeout time.Duration) map[string]int64 {
+	undelivered := make(map[string]int64)
+	deadline := time.Now().Add(timeout)
+	for _, sink := range sinks {
+		acker, ok := sink.(output.Acknowledger)
+		if !ok {
+			continue
+		}
+		for acker.Acked() < acker.Sent() && time.Now().Before(deadline) {
+			time.Sleep(50 * time.Millisecond)
+		}
+		if n := acker.Sent() - acker.Acked(); n > 0 {
+			undelivered[sink.Name()] = n
+		}
+	}
+	return undelivered
+}
+
+// percentile returns the p-th percentile of durations
+func percentile(durations []time.Duration, p float64) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[int(float64(len(sorted)-1)*p)]
+}
+
+// formatBytes formats a byte count for a command's report
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GiB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"os"
+	"runtime/debug"
+
+	"cato-logger/internal/config"
+	"cato-logger/internal/logging"
+)
+
+// applyMemorySettings sets the garbage collector target and the soft memory
+// limit from runtime.gogc and runtime.memory_limit_mb. GOGC and GOMEMLIMIT
+// in the environment take precedence, so an operator can override the
+// config file without editing it.
+func applyMemorySettings(cfg *config.Config, logger *logging.Logger) {
+	if cfg.GCPercent != nil {
+		if env := os.Getenv("GOGC"); env != "" {
+			logger.Info("GOGC is set in the environment, ignoring runtime.gogc", "gogc", env)
+		} else {
+			debug.SetGCPercent(*cfg.GCPercent)
+			logger.Info("garbage collector target set", "gogc", *cfg.GCPercent)
+		}
+	}
+	if cfg.MemoryLimitMB > 0 {
+		if env := os.Getenv("GOMEMLIMIT"); env != "" {
+			logger.Info("GOMEMLIMIT is set in the environment, ignoring runtime.memory_limit_mb", "gomemlimit", env)
+		} else {
+			debug.SetMemoryLimit(int64(cfg.MemoryLimitMB) << 20)
+			logger.Info("soft memory limit set", "memory_limit_mb", cfg.MemoryLimitMB)
+		}
+	}
+}
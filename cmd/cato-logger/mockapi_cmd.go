@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"cato-logger/internal/apitest"
+)
+
+// runMockAPICommand handles "cato-logger mockapi": it serves a mock Cato
+// API with generated events until interrupted, so the forwarder can be run
+// end to end without a Cato account
+func runMockAPICommand(args []string) int {
+	fs := flag.NewFlagSet("mockapi", flag.ContinueOnError)
+	listen := fs.String("listen", "127.0.0.1:8080", "Address to listen on")
+	apiKey := fs.String("api-key", "", "API key requests must carry (default: accept any)")
+	backlog := fs.Int64("backlog", 10000, "Events available at startup")
+	rate := fs.Float64("rate", 10, "Events per second added after startup")
+	pageSize := fs.Int("page-size", 1000, "Events per eventsFeed page")
+	latency := fs.Duration("latency", 0, "Delay before each response")
+	rateLimitEvery := fs.Int("rate-limit-every", 0, "Answer every Nth request with HTTP 429 (0 never)")
+	retryAfter := fs.Duration("retry-after", 5*time.Second, "Retry-After of HTTP 429 responses")
+	errorEvery := fs.Int("error-every", 0, "Answer every Nth request with a GraphQL error (0 never)")
+	errorCode := fs.String("error-code", "INTERNAL_SERVER_ERROR", "extensions.code of injected GraphQL errors")
+	fixtureFile := fs.String("events", "", "JSON array of event field maps served in turn instead of generated events")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	opts := apitest.Options{
+		APIKey:         *apiKey,
+		Backlog:        *backlog,
+		Rate:           *rate,
+		PageSize:       *pageSize,
+		Latency:        *latency,
+		RateLimitEvery: *rateLimitEvery,
+		RetryAfter:     *retryAfter,
+		ErrorEvery:     *errorEvery,
+		ErrorCode:      *errorCode,
+	}
+	if *fixtureFile != "" {
+		data, err := os.ReadFile(*fixtureFile)
+		if err == nil {
+			err = json.Unmarshal(data, &opts.Fixture)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: failed to read events: %v\n", err)
+			return 2
+		}
+		if len(opts.Fixture) == 0 {
+			fmt.Fprintf(os.Stderr, "ERROR: %s holds no events\n", *fixtureFile)
+			return 2
+		}
+	}
+
+	listener, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	mock := apitest.New(opts)
+	server := &http.Server{Handler: mock, ReadHeaderTimeout: 10 * time.Second}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Printf("serving a mock Cato API at http://%s/ (backlog %d events, %.1f events/sec)\n",
+		listener.Addr(), *backlog, *rate)
+	fmt.Println(`point cato.api_url at it; any account ID is accepted. Press Ctrl-C to stop.`)
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+
+	requests, events := mock.Stats()
+	fmt.Printf("answered %d requests, served %d events\n", requests, events)
+	return 0
+}
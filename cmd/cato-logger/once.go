@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"cato-logger/internal/config"
+	"cato-logger/internal/logging"
+	"cato-logger/internal/processor"
+)
+
+// runOnce runs a single processing cycle, paginating as a service cycle
+// does, for cron jobs and other schedulers that start the forwarder on
+// their own. It returns 0 when every feed succeeded and 1 otherwise;
+// SIGINT or SIGTERM cut the cycle short and count as a failure.
+func runOnce(ctx context.Context, cfg *config.Config, feeds *feedSet, stats *processor.Stats, closeSinks func(), logger *logging.Logger) int {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	logger.Info("running a single processing cycle")
+	success := feeds.process(ctx, cfg.MaxConcurrency)
+	interrupted := ctx.Err() != nil
+
+	// Let queued outputs drain, then save the markers of what they delivered
+	closeSinks()
+	feeds.saveDelivered(context.Background())
+	logFinalStats(logger, stats)
+
+	switch {
+	case interrupted:
+		logger.Warn("processing cycle interrupted")
+		return 1
+	case !success:
+		logger.Error("processing cycle failed")
+		return 1
+	}
+	logger.Info("processing cycle complete")
+	return 0
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"cato-logger/internal/admin"
+)
+
+// pauseState is the switch that pauses fetching, flipped by signals and the
+// admin endpoint and read by the main loop. Every change is announced on
+// changed so the main loop acts on it between cycles.
+type pauseState struct {
+	mu      sync.Mutex
+	paused  bool
+	since   time.Time
+	reason  string
+	changed chan struct{}
+}
+
+// newPauseState creates a pause switch that starts unpaused
+func newPauseState() *pauseState {
+	return &pauseState{changed: make(chan struct{}, 1)}
+}
+
+// Pause stops fetching after the cycle in progress
+func (p *pauseState) Pause(reason string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.paused {
+		return false
+	}
+	p.paused, p.since, p.reason = true, time.Now(), reason
+	p.notify()
+	return true
+}
+
+// Resume starts fetching again
+func (p *pauseState) Resume() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.paused {
+		return false
+	}
+	p.paused, p.since, p.reason = false, time.Time{}, ""
+	p.notify()
+	return true
+}
+
+// PauseStatus returns the current state
+func (p *pauseState) PauseStatus() admin.PauseStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	status := admin.PauseStatus{Paused: p.paused, Reason: p.reason}
+	if p.paused {
+		since := p.since
+		status.Since = &since
+	}
+	return status
+}
+
+// isPaused reports whether fetching is paused
+func (p *pauseState) isPaused() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.paused
+}
+
+// notify wakes the main loop without blocking; one pending wake-up is enough
+// since it reads the current state
+func (p *pauseState) notify() {
+	select {
+	case p.changed <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+
+	"cato-logger/internal/aggregate"
+	"cato-logger/internal/cef"
+	"cato-logger/internal/config"
+	"cato-logger/internal/ecs"
+	"cato-logger/internal/enrich"
+	"cato-logger/internal/logging"
+	"cato-logger/internal/ocsf"
+	"cato-logger/internal/output"
+	"cato-logger/internal/processor"
+	"cato-logger/internal/redact"
+	"cato-logger/internal/sample"
+	"cato-logger/internal/tmpl"
+	"cato-logger/internal/transform"
+)
+
+// newCEFFormatter builds the CEF formatter for a feed's mapping profile and
+// the event type profiles
+func newCEFFormatter(cfg *config.Config, feed config.Feed) *cef.Formatter {
+	profiles := make(map[string]cef.Profile, len(cfg.CEFProfiles))
+	for eventType, profile := range cfg.CEFProfiles {
+		profiles[eventType] = cef.Profile{
+			FieldMappings: profile.FieldMappings,
+			OrderedFields: profile.OrderedFields,
+		}
+	}
+
+	return cef.NewFormatter(
+		cfg.CEFVendor,
+		cfg.CEFProduct,
+		cfg.CEFVersion,
+		feed.FieldMappings,
+		feed.OrderedFields,
+		cef.Options{
+			Limits:     cfg.CEFLimits,
+			Timestamps: cfg.CEFTimestamps,
+			Unmapped:   cfg.CEFUnmapped,
+			Severity:   cfg.CEFSeverity,
+			Profiles:   profiles,
+		},
+	)
+}
+
+// newFormats builds the formatting state shared by the outputs, with the
+// CEF mapping profile of every feed. Templates were checked by validation.
+func newFormats(cfg *config.Config) *output.Formats {
+	templates := make(map[string]*tmpl.Template, len(cfg.Templates))
+	for name, text := range cfg.Templates {
+		if t, err := tmpl.New(name, text); err == nil {
+			templates[name] = t
+		}
+	}
+
+	formats := output.NewFormats(
+		ocsf.NewFormatter(cfg.CEFVendor, cfg.CEFProduct, cfg.OCSFClasses),
+		ecs.NewFormatter(cfg.CEFVendor, cfg.CEFProduct, cfg.ECSFieldMappings),
+		templates,
+	)
+	for _, feed := range cfg.EffectiveFeeds() {
+		formats.SetCEF(feed.Name, newCEFFormatter(cfg, feed))
+	}
+	return formats
+}
+
+// buildStages constructs the pre-formatting stages in pipeline order:
+// sampling, then aggregation, then transform, then enrichment, then redaction
+func buildStages(cfg *config.Config, logger *logging.Logger) ([]processor.Stage, error) {
+	var stages []processor.Stage
+
+	// Sampling (decided on the events as fetched)
+	sampler, err := sample.New(cfg.Sampling)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize sampling: %w", err)
+	}
+	if !sampler.Empty() {
+		stages = append(stages, sampler)
+		logger.Info("event sampling enabled", "rates", len(cfg.Sampling.Rates))
+	}
+
+	// Rollup of repetitive events (keyed on fields as fetched)
+	aggregator, err := aggregate.New(cfg.Aggregation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize aggregation: %w", err)
+	}
+	if !aggregator.Empty() {
+		stages = append(stages, aggregator)
+		logger.Info("event aggregation enabled",
+			"event_types", len(cfg.Aggregation.EventTypes),
+			"window_seconds", cfg.Aggregation.WindowSeconds)
+	}
+
+	// Field transform pipeline
+	transformer, err := transform.New(cfg.Transform)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize transform pipeline: %w", err)
+	}
+	if !transformer.Empty() {
+		stages = append(stages, transformer)
+		logger.Info("field transform pipeline enabled",
+			"drop_fields", len(cfg.Transform.DropFields),
+			"rename_fields", len(cfg.Transform.RenameFields),
+			"replace_rules", len(cfg.Transform.Replace),
+			"add_fields", len(cfg.Transform.AddFields),
+			"lowercase_fields", len(cfg.Transform.LowercaseFields))
+	}
+
+	// Lookup-table enrichment (joins on transformed, unredacted values)
+	enricher, err := enrich.New(cfg.LookupTables, logger.Component("enrich"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize lookup tables: %w", err)
+	}
+	if !enricher.Empty() {
+		stages = append(stages, enricher)
+		logger.Info("lookup-table enrichment enabled", "tables", len(cfg.LookupTables))
+	}
+
+	// PII redaction (runs last so nothing downstream sees raw values)
+	redactor, err := redact.New(cfg.Redaction)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize redaction rules: %w", err)
+	}
+	if !redactor.Empty() {
+		stages = append(stages, redactor)
+		logger.Info("PII redaction enabled", "rules", len(cfg.Redaction.Rules))
+	}
+
+	return stages, nil
+}
+
+// outputNames lists output names for logging
+func outputNames(outputs []config.Output) []string {
+	names := make([]string, len(outputs))
+	for i, out := range outputs {
+		names[i] = out.Name
+	}
+	return names
+}
+
+// feedNames lists feed names for logging
+func feedNames(feeds []config.Feed) []string {
+	names := make([]string, len(feeds))
+	for i, feed := range feeds {
+		names[i] = feed.Name
+	}
+	return names
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"cato-logger/internal/api"
+	"cato-logger/internal/config"
+	"cato-logger/internal/logging"
+	"cato-logger/internal/output"
+	"cato-logger/internal/preflight"
+)
+
+// preflightOptions builds the pre-flight checker settings from configuration
+func preflightOptions(cfg *config.Config) preflight.Options {
+	checks := make(map[string]preflight.CheckOptions, len(cfg.PreflightChecks))
+	for id, check := range cfg.PreflightChecks {
+		checks[id] = preflight.CheckOptions{
+			Disabled: check.Enabled != nil && !*check.Enabled,
+			WarnOnly: check.WarnOnly,
+			Timeout:  time.Duration(check.TimeoutSeconds) * time.Second,
+		}
+	}
+
+	var outputs []preflight.OutputTarget
+	for _, out := range cfg.EffectiveOutputs() {
+		out := out
+		outputs = append(outputs, preflight.OutputTarget{
+			Name: out.Name,
+			Type: out.Type,
+			Host: out.Host(),
+			Probe: func(ctx context.Context, timeout time.Duration) (string, error) {
+				return output.Probe(ctx, out, timeout)
+			},
+		})
+	}
+
+	// With discovery only, check the API key against the parent account
+	accountIDs := cfg.AccountIDs()
+	if len(accountIDs) == 0 {
+		accountIDs = []string{cfg.DiscoveryParentID}
+	}
+
+	stateFiles := cfg.MarkerFiles()
+	if cfg.DeadLetterFile != "" {
+		stateFiles = append(stateFiles, cfg.DeadLetterFile)
+	}
+	for _, out := range cfg.Outputs {
+		if out.File != nil {
+			stateFiles = append(stateFiles, out.File.Path)
+		}
+	}
+
+	return preflight.Options{
+		APIURL:        cfg.CatoAPIURL,
+		APIKey:        cfg.CatoAPIKey,
+		AccountIDs:    accountIDs,
+		Outputs:       outputs,
+		MarkerFiles:   cfg.MarkerFiles(),
+		DiskPaths:     preflight.DiskPaths(stateFiles, cfg.LogOutput),
+		MinFreeDiskMB: cfg.MinFreeDiskMB,
+		ClockSkewWarn: time.Duration(cfg.ClockSkewWarn) * time.Second,
+		ClockSkewFail: time.Duration(cfg.ClockSkewFail) * time.Second,
+		Timeout:       time.Duration(cfg.ConnTimeout) * time.Second,
+		Checks:        checks,
+	}
+}
+
+// runStartupPreflight runs the pre-flight checks before the service starts.
+// With preflight.retry_on_failure set, failures are retried with exponential
+// backoff until they pass, the retry window ends, or the process is stopped.
+func runStartupPreflight(ctx context.Context, cfg *config.Config, logger *logging.Logger) []preflight.CheckResult {
+	checker := preflight.New(logger.Component("preflight"))
+	results := checker.RunAll(preflightOptions(cfg))
+	if !cfg.PreflightRetry || !preflight.HasFailures(results) {
+		return results
+	}
+
+	// Allow a clean stop while waiting for dependencies to come up
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var deadline <-chan time.Time
+	if cfg.PreflightRetryTimeout > 0 {
+		timer := time.NewTimer(time.Duration(cfg.PreflightRetryTimeout) * time.Second)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	delay := 1 * time.Second
+	maxDelay := time.Duration(cfg.PreflightRetryMaxDelay) * time.Second
+	for attempt := 2; preflight.HasFailures(results); attempt++ {
+		logger.Warn("pre-flight checks failed, retrying",
+			"next_attempt", attempt,
+			"retry_in", delay.String(),
+			"failures", preflight.FailedNames(results))
+
+		select {
+		case <-ctx.Done():
+			logger.Info("stopped while waiting for pre-flight checks")
+			return results
+		case <-deadline:
+			logger.Error("pre-flight retry window exhausted",
+				"retry_timeout_sec", cfg.PreflightRetryTimeout)
+			return results
+		case <-time.After(delay):
+		}
+
+		results = checker.RunAll(preflightOptions(cfg))
+
+		delay *= 2
+		if delay > maxDelay {
+			delay = maxDelay
+		}
+	}
+
+	logger.Info("pre-flight checks recovered after retrying")
+	return results
+}
+
+// Exit codes of "cato-logger preflight", one per failure class
+const (
+	exitPreflightOK         = 0
+	exitPreflightOther      = 1
+	exitPreflightUsage      = 2
+	exitPreflightConfig     = 3
+	exitPreflightAuth       = 4
+	exitPreflightNetwork    = 5
+	exitPreflightFilesystem = 6
+	exitPreflightClock      = 7
+)
+
+// preflightExitCodes maps failure classes to exit codes
+var preflightExitCodes = map[string]int{
+	preflight.ClassAuth:       exitPreflightAuth,
+	preflight.ClassNetwork:    exitPreflightNetwork,
+	preflight.ClassFilesystem: exitPreflightFilesystem,
+	preflight.ClassClock:      exitPreflightClock,
+}
+
+// preflightCheckReport is the JSON form of a single check result
+type preflightCheckReport struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Status  string `json:"status"` // pass, warn, fail, or skip
+	Class   string `json:"class,omitempty"`
+	Message string `json:"message"`
+	Error   string `json:"error,omitempty"`
+}
+
+// newPreflightCheckReport returns the JSON form of a check result
+func newPreflightCheckReport(result preflight.CheckResult) preflightCheckReport {
+	check := preflightCheckReport{
+		ID:      result.ID,
+		Name:    result.Name,
+		Message: result.Message,
+	}
+	if result.Error != nil {
+		check.Error = result.Error.Error()
+	}
+
+	switch {
+	case result.Skipped:
+		check.Status = "skip"
+	case !result.Passed:
+		check.Status = "fail"
+		check.Class = result.Class
+	case result.Warning:
+		check.Status = "warn"
+	default:
+		check.Status = "pass"
+	}
+	return check
+}
+
+// preflightReport is the JSON document written by "cato-logger preflight --json"
+type preflightReport struct {
+	Passed   bool                   `json:"passed"`
+	ExitCode int                    `json:"exit_code"`
+	Error    string                 `json:"error,omitempty"`
+	Checks   []preflightCheckReport `json:"checks"`
+}
+
+// runPreflightCommand handles "cato-logger preflight": it runs the pre-flight
+// checks without starting the service and exits with a code per failure class
+func runPreflightCommand(args []string) int {
+	fs := flag.NewFlagSet("preflight", flag.ContinueOnError)
+	configPath := fs.String("config", "", "Path to config.json file")
+	jsonOutput := fs.Bool("json", false, "Write machine-readable JSON results to stdout")
+	verbose := fs.Bool("verbose", false, "Log check progress to stderr")
+	if err := fs.Parse(args); err != nil {
+		return exitPreflightUsage
+	}
+
+	report := preflightReport{Checks: []preflightCheckReport{}}
+
+	cfg, err := config.LoadFile(*configPath)
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		report.ExitCode = exitPreflightConfig
+		report.Error = fmt.Sprintf("invalid configuration: %v", err)
+		return writePreflightReport(report, *jsonOutput)
+	}
+
+	api.SetIdentity(api.Identity{Version: version, DeploymentID: cfg.DeploymentID})
+
+	// Progress goes to stderr so stdout stays parseable
+	level := "error"
+	if *verbose {
+		level = "debug"
+	}
+	logger, err := logging.New(logging.Options{Level: level, Format: "text", Output: "stderr"})
+	if err != nil {
+		report.ExitCode = exitPreflightOther
+		report.Error = err.Error()
+		return writePreflightReport(report, *jsonOutput)
+	}
+	defer logger.Close()
+
+	results := preflight.New(logger.Component("preflight")).RunAll(preflightOptions(cfg))
+
+	report.Passed = true
+	for _, result := range results {
+		// The first failing check decides the exit code
+		if !result.Skipped && !result.Passed && report.Passed {
+			report.Passed = false
+			report.ExitCode = exitPreflightOther
+			if code, ok := preflightExitCodes[result.Class]; ok {
+				report.ExitCode = code
+			}
+		}
+		report.Checks = append(report.Checks, newPreflightCheckReport(result))
+	}
+
+	return writePreflightReport(report, *jsonOutput)
+}
+
+// writePreflightReport prints the report as JSON or text and returns its exit code
+func writePreflightReport(report preflightReport, asJSON bool) int {
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: failed to write report: %v\n", err)
+			return exitPreflightOther
+		}
+		return report.ExitCode
+	}
+
+	if report.Error != "" {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", report.Error)
+		return report.ExitCode
+	}
+
+	for _, check := range report.Checks {
+		line := fmt.Sprintf("[%s] %s: %s", strings.ToUpper(check.Status), check.Name, check.Message)
+		if check.Status == "fail" && check.Error != "" && !strings.Contains(check.Message, check.Error) {
+			line += " (" + check.Error + ")"
+		}
+		fmt.Println(line)
+	}
+
+	if report.Passed {
+		fmt.Println("all pre-flight checks passed")
+	} else {
+		fmt.Printf("pre-flight checks failed (exit code %d)\n", report.ExitCode)
+	}
+	return report.ExitCode
+}
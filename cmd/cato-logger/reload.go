@@ -0,0 +1,56 @@
+package main
+
+import (
+	"cato-logger/internal/config"
+	"cato-logger/internal/logging"
+)
+
+// reloadConfig re-reads the config file and applies hot-reloadable settings.
+// On any error the running configuration is kept and nil is returned.
+func reloadConfig(running *config.Config, feeds *feedSet, logger *logging.Logger) *config.Config {
+	logger.Info("reloading configuration", "config_file", running.ConfigPath)
+
+	newCfg, err := config.Reload(running)
+	if err != nil {
+		logger.Error("configuration reload failed, keeping current configuration", "error", err.Error())
+		return nil
+	}
+
+	changes := config.Diff(running, newCfg)
+	if len(changes) == 0 {
+		logger.Info("configuration unchanged")
+		return nil
+	}
+
+	for _, change := range changes {
+		if change.RequiresRestart {
+			logger.Warn("configuration change requires restart, ignoring",
+				"field", change.Field, "old", change.Old, "new", change.New)
+		} else {
+			logger.Info("configuration changed",
+				"field", change.Field, "old", change.Old, "new", change.New)
+		}
+	}
+
+	// Restart-only settings keep their running values
+	newCfg.KeepRestartOnly(running)
+
+	stages, err := buildStages(newCfg, logger)
+	if err != nil {
+		logger.Error("configuration reload failed, keeping current configuration", "error", err.Error())
+		return nil
+	}
+
+	if level, err := logging.ParseLevel(newCfg.LogLevel); err == nil {
+		logger.SetLevel(level)
+	}
+	if err := logger.SetComponentLevels(newCfg.LogComponentLevels); err != nil {
+		logger.Warn("ignoring invalid component log levels", "error", err.Error())
+	}
+	feeds.apiClient.SetAPIKeys(newCfg.CatoAPIKey, newCfg.CatoAPIKeyNext)
+	feeds.reconfigure(newCfg, stages)
+
+	logConfigWarnings(newCfg, logger)
+	logger.Info("configuration reloaded", "changes", len(changes))
+	return newCfg
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"runtime"
+	"strings"
+	"time"
+
+	"cato-logger/internal/logging"
+	"cato-logger/internal/output"
+	"cato-logger/internal/processor"
+)
+
+// dumpRuntimeStats logs the full runtime statistics and current markers (SIGUSR1)
+func dumpRuntimeStats(logger *logging.Logger, stats *processor.Stats, feeds *feedSet, sinks []output.Sink) {
+	snapshot := stats.Snapshot()
+
+	lastMarkerUpdate := "never"
+	if !snapshot.LastMarkerUpdate.IsZero() {
+		lastMarkerUpdate = snapshot.LastMarkerUpdate.UTC().Format(time.RFC3339)
+	}
+
+	// Pending reconnect attempts per connection-oriented output, and
+	// messages waiting in output queues
+	reconnects := make(map[string]int)
+	queued := make(map[string]int)
+	for _, sink := range sinks {
+		if r, ok := sink.(output.Reconnector); ok {
+			reconnects[sink.Name()] = r.ReconnectCount()
+		}
+		if q, ok := sink.(output.Queuer); ok {
+			queued[sink.Name()] = q.QueueLen()
+		}
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	logger.Info("runtime statistics dump",
+		"uptime_sec", int(snapshot.Uptime.Seconds()),
+		"total_events_forwarded", snapshot.TotalEventsForwarded,
+		"total_api_requests", snapshot.TotalAPIRequests,
+		"failed_api_requests", snapshot.FailedAPIRequests,
+		"total_bytes_fetched", snapshot.TotalBytesFetched,
+		"total_bytes_sent", snapshot.TotalBytesSent,
+		"total_reconnects", snapshot.TotalReconnects,
+		"reconnect_failures", snapshot.ReconnectFailures,
+		"reconnecting_outputs", snapshot.Reconnecting,
+		"total_dead_lettered", snapshot.TotalDeadLettered,
+		"total_dropped", snapshot.TotalDropped,
+		"total_truncated", snapshot.TotalTruncated,
+		"total_sampled_out", snapshot.TotalSampledOut,
+		"total_aggregated", snapshot.TotalAggregated,
+		"stale_feeds", snapshot.StaleFeeds,
+		"pending_reconnect_attempts", reconnects,
+		"queued_messages", queued,
+		"outputs", processor.FormatOutputs(stats.Outputs(), time.Now()),
+		"current_marker", feeds.markers(),
+		"last_marker_update", lastMarkerUpdate,
+		"last_cycle_id", snapshot.LastCycleID,
+		"last_cycle_pages", snapshot.LastCyclePages,
+		"last_cycle_drain_ms", snapshot.LastDrainTime.Milliseconds(),
+		"api_request_p99_ms", stats.APIDuration.Snapshot().Quantile(0.99).Milliseconds(),
+		"output_write_p99_ms", stats.WriteDuration.Snapshot().Quantile(0.99).Milliseconds(),
+		"event_latency_p50_sec", int(stats.EventLatency.Snapshot().Quantile(0.50).Seconds()),
+		"event_latency_p99_sec", int(stats.EventLatency.Snapshot().Quantile(0.99).Seconds()),
+		"goroutines", runtime.NumGoroutine(),
+		"heap_alloc_bytes", mem.HeapAlloc,
+		"log_level", strings.ToLower(logger.Level().String()))
+}
+
+// toggleDebugLogging switches between debug and the configured log level (SIGUSR2)
+func toggleDebugLogging(logger *logging.Logger, configuredLevel string) {
+	if logger.Level() != logging.DEBUG {
+		logger.SetLevel(logging.DEBUG)
+		logger.Info("debug logging enabled via SIGUSR2")
+		return
+	}
+
+	level, err := logging.ParseLevel(configuredLevel)
+	if err != nil || level == logging.DEBUG {
+		level = logging.INFO
+	}
+	logger.Info("debug logging disabled via SIGUSR2", "log_level", strings.ToLower(level.String()))
+	logger.SetLevel(level)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"cato-logger/internal/config"
+	"cato-logger/internal/logging"
+	"cato-logger/internal/processor"
+)
+
+// sloErrorWindows is the number of violated SLO windows in a row after which
+// violations are logged as errors instead of warnings
+const sloErrorWindows = 3
+
+// applySLO sets the delivery latency SLO from the configuration
+func applySLO(cfg *config.Config, stats *processor.Stats) {
+	stats.SetSLO(time.Duration(cfg.SLOLatency)*time.Second, cfg.SLOTarget)
+}
+
+// checkSLO evaluates the delivery latency SLO window that just ended and
+// logs a violation, or the recovery from one
+func checkSLO(cfg *config.Config, stats *processor.Stats, logger *logging.Logger) {
+	window := stats.EvaluateSLO()
+	attrs := []any{
+		"compliance_percent", fmt.Sprintf("%.2f", window.Compliance),
+		"target_percent", cfg.SLOTarget,
+		"latency_sec", cfg.SLOLatency,
+		"window_minutes", cfg.SLOWindow,
+		"events", window.Events,
+		"late_events", window.Late,
+	}
+
+	switch {
+	case window.Events == 0:
+		logger.Debug("no events delivered in the SLO window", attrs...)
+	case window.Violated && window.Consecutive >= sloErrorWindows:
+		logger.Error("delivery latency SLO violated", append(attrs, "consecutive_windows", window.Consecutive)...)
+	case window.Violated:
+		logger.Warn("delivery latency SLO violated", append(attrs, "consecutive_windows", window.Consecutive)...)
+	case window.Recovered:
+		logger.Info("delivery latency SLO met again", attrs...)
+	default:
+		logger.Debug("delivery latency SLO met", attrs...)
+	}
+}
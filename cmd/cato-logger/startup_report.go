@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/url"
+	"os"
+	"runtime"
+	"time"
+
+	"cato-logger/internal/config"
+	"cato-logger/internal/logging"
+	"cato-logger/internal/preflight"
+)
+
+// resolveTimeout bounds the DNS lookups of the startup report
+const resolveTimeout = 2 * time.Second
+
+// startupReport is the JSON summary of a service start, for auditing a fleet
+// of forwarders
+type startupReport struct {
+	Time         string                 `json:"time"`
+	Status       string                 `json:"status"` // started or preflight_failed
+	Version      string                 `json:"version"`
+	Commit       string                 `json:"commit"`
+	GoVersion    string                 `json:"go_version"`
+	Hostname     string                 `json:"hostname"`
+	PID          int                    `json:"pid"`
+	DeploymentID string                 `json:"deployment_id,omitempty"`
+	ConfigFiles  []string               `json:"config_files"` // The config file, then its includes
+	Overrides    []string               `json:"overrides,omitempty"`
+	API          startupEndpoint        `json:"api"`
+	Accounts     []string               `json:"accounts,omitempty"`
+	Feeds        []startupFeed          `json:"feeds"`
+	Outputs      []startupOutput        `json:"outputs"`
+	Features     []string               `json:"features"`
+	Warnings     []string               `json:"warnings,omitempty"`
+	Preflight    []preflightCheckReport `json:"preflight"`
+}
+
+// startupEndpoint is a network endpoint and the addresses its host resolved to
+type startupEndpoint struct {
+	URL       string   `json:"url,omitempty"`
+	Host      string   `json:"host,omitempty"`
+	Addresses []string `json:"addresses,omitempty"`
+	Error     string   `json:"resolve_error,omitempty"`
+}
+
+// startupFeed is a feed in the startup report
+type startupFeed struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	MarkerFile string `json:"marker_file"`
+}
+
+// startupOutput is an output in the startup report
+type startupOutput struct {
+	Name     string          `json:"name"`
+	Type     string          `json:"type"`
+	Format   string          `json:"format,omitempty"`
+	Endpoint startupEndpoint `json:"endpoint"`
+}
+
+// rawJSON logs as a JSON object with the JSON log format and as a JSON
+// string with the text format
+type rawJSON []byte
+
+func (r rawJSON) MarshalJSON() ([]byte, error) { return r, nil }
+func (r rawJSON) MarshalText() ([]byte, error) { return r, nil }
+
+// writeStartupReport logs the startup report and writes it to
+// logging.startup_report_file when set. accounts is nil when startup stopped
+// before they were resolved.
+func writeStartupReport(ctx context.Context, cfg *config.Config, status string, accounts []string,
+	results []preflight.CheckResult, logger *logging.Logger) {
+	report := newStartupReport(ctx, cfg, status, accounts, results)
+	data, err := json.Marshal(report)
+	if err != nil {
+		logger.Warn("failed to encode startup report", "error", err.Error())
+		return
+	}
+	logger.Info("startup report", "report", rawJSON(data))
+
+	if cfg.StartupReportFile == "" {
+		return
+	}
+	indented, _ := json.MarshalIndent(report, "", "  ")
+	if err := os.WriteFile(cfg.StartupReportFile, append(indented, '\n'), 0o644); err != nil {
+		logger.Warn("failed to write startup report", "file", cfg.StartupReportFile, "error", err.Error())
+	}
+}
+
+// newStartupReport collects the startup report
+func newStartupReport(ctx context.Context, cfg *config.Config, status string, accounts []string,
+	results []preflight.CheckResult) startupReport {
+	hostname, _ := os.Hostname()
+	report := startupReport{
+		Time:         time.Now().UTC().Format(time.RFC3339),
+		Status:       status,
+		Version:      version,
+		Commit:       buildCommit(),
+		GoVersion:    runtime.Version(),
+		Hostname:     hostname,
+		PID:          os.Getpid(),
+		DeploymentID: cfg.DeploymentID,
+		ConfigFiles:  config.ConfigFiles(cfg.ConfigPath),
+		Overrides:    overrideNames(cfg.Overrides),
+		API:          resolveEndpoint(ctx, cfg.CatoAPIURL, urlHostname(cfg.CatoAPIURL)),
+		Accounts:     accounts,
+		Features:     activeFeatures(cfg),
+		Preflight:    []preflightCheckReport{},
+	}
+
+	for _, feed := range cfg.EffectiveFeeds() {
+		report.Feeds = append(report.Feeds, startupFeed{Name: feed.Name, Type: feed.Type, MarkerFile: feed.MarkerFile})
+	}
+	for _, out := range cfg.EffectiveOutputs() {
+		entry := startupOutput{Name: out.Name, Type: out.Type}
+		switch {
+		case out.Syslog != nil && out.Syslog.UsesSocket():
+			entry.Format = out.Syslog.Format
+			entry.Endpoint = startupEndpoint{URL: out.Syslog.Protocol + "://" + out.Syslog.Socket}
+		case out.Syslog != nil:
+			entry.Format = out.Syslog.Format
+			entry.Endpoint = resolveEndpoint(ctx, out.Syslog.Protocol+"://"+out.Syslog.Address(), out.Syslog.Server)
+		case out.File != nil:
+			entry.Endpoint = startupEndpoint{URL: out.File.Path}
+		default:
+			entry.Endpoint = resolveEndpoint(ctx, "", out.Host())
+		}
+		report.Outputs = append(report.Outputs, entry)
+	}
+	for _, warning := range cfg.Warnings() {
+		report.Warnings = append(report.Warnings, warning.Message)
+	}
+	for _, result := range results {
+		report.Preflight = append(report.Preflight, newPreflightCheckReport(result))
+	}
+	return report
+}
+
+// overrideNames lists the command-line overrides that were set
+func overrideNames(o config.Overrides) []string {
+	var names []string
+	add := func(set bool, name string) {
+		if set {
+			names = append(names, name)
+		}
+	}
+	add(o.AccountID != "", "account-id")
+	add(o.SyslogServer != "", "syslog-server")
+	add(o.SyslogPort != 0, "syslog-port")
+	add(o.SyslogProtocol != "", "syslog-protocol")
+	add(o.FetchInterval != 0, "fetch-interval")
+	add(o.MarkerFile != "", "marker-file")
+	add(o.LogLevel != "", "log-level")
+	add(o.LogFormat != "", "log-format")
+	return names
+}
+
+// urlHostname returns the host name of a URL, empty if it does not parse
+func urlHostname(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		return u.Hostname()
+	}
+	return ""
+}
+
+// resolveEndpoint looks up the addresses of host
+func resolveEndpoint(ctx context.Context, endpointURL, host string) startupEndpoint {
+	endpoint := startupEndpoint{URL: endpointURL, Host: host}
+	if host == "" {
+		return endpoint
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		endpoint.Addresses = []string{ip.String()}
+		return endpoint
+	}
+	ctx, cancel := context.WithTimeout(ctx, resolveTimeout)
+	defer cancel()
+	addresses, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		endpoint.Error = err.Error()
+	}
+	endpoint.Addresses = addresses
+	return endpoint
+}
+
+// activeFeatures lists the optional features the configuration enables
+func activeFeatures(cfg *config.Config) []string {
+	features := []string{}
+	add := func(enabled bool, name string) {
+		if enabled {
+			features = append(features, name)
+		}
+	}
+	add(cfg.DiscoveryParentID != "", "account_discovery")
+	add(cfg.CatoQueryFile != "", "custom_query")
+	add(len(cfg.CEFProfiles) > 0, "cef_profiles")
+	add(len(cfg.Transform.DropFields)+len(cfg.Transform.RenameFields)+len(cfg.Transform.Replace)+
+		len(cfg.Transform.AddFields)+len(cfg.Transform.LowercaseFields) > 0, "transform")
+	add(len(cfg.Redaction.Rules) > 0, "redaction")
+	add(len(cfg.Sampling.Rates) > 0, "sampling")
+	add(len(cfg.Aggregation.EventTypes) > 0, "aggregation")
+	add(len(cfg.LookupTables) > 0, "enrichment")
+	add(len(cfg.Labels) > 0 || cfg.DeploymentIDField != "", "labels")
+	add(cfg.DeadLetterFile != "", "dead_letter")
+	add(cfg.StateKey != "", "state_encryption")
+	add(cfg.MarkerJournal, "marker_journal")
+	add(cfg.WatchConfig, "config_watch")
+	add(cfg.StatsInterval > 0, "stats_report")
+	add(cfg.SLOLatency > 0, "latency_slo")
+	add(cfg.AdminListen != "", "admin")
+	add(cfg.APICaptureDir != "", "api_capture")
+	add(cfg.PreflightRetry, "preflight_retry")
+	add(cfg.GCPercent != nil || cfg.MemoryLimitMB > 0, "memory_tuning")
+	add(cfg.Once, "once")
+	return features
+}
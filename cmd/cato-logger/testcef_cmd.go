@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"cato-logger/internal/config"
+	"cato-logger/internal/logging"
+	"cato-logger/internal/processor"
+)
+
+// cefFixture is one test case of a test-cef event file: an event, the feed
+// whose mapping profile formats it, and optionally the CEF line expected
+type cefFixture struct {
+	Name   string                 `json:"name"`
+	Feed   string                 `json:"feed"`
+	Event  map[string]interface{} `json:"event"`
+	Expect string                 `json:"expect"`
+}
+
+// runTestCEFCommand handles "cato-logger test-cef": it runs events through
+// the configured stages and CEF mappings, prints the resulting lines with
+// any warnings, and compares them with the expected lines of fixtures
+func runTestCEFCommand(args []string) int {
+	fs := flag.NewFlagSet("test-cef", flag.ContinueOnError)
+	configPath := fs.String("config", "", "Path to config.json file")
+	eventFile := fs.String("event", "", "Event or fixture file (JSON object or array)")
+	feedName := fs.String("feed", "", "Feed whose mapping profile to use when a fixture names none (defaults to the first feed)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *eventFile == "" {
+		fmt.Fprintln(os.Stderr, "usage: cato-logger test-cef --event <file> [--config <file>] [--feed <name>]")
+		return 2
+	}
+
+	cfg, err := config.LoadFile(*configPath)
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: invalid configuration: %v\n", err)
+		return 2
+	}
+
+	fixtures, err := readCEFFixtures(*eventFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 2
+	}
+
+	feeds := make(map[string]config.Feed)
+	for _, feed := range cfg.EffectiveFeeds() {
+		feeds[feed.Name] = feed
+	}
+	defaultFeed := *feedName
+	if defaultFeed == "" {
+		defaultFeed = cfg.EffectiveFeeds()[0].Name
+	}
+
+	logger, err := logging.New(logging.Options{Level: "error", Format: "text", Output: "stderr"})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	defer logger.Close()
+
+	stages, err := buildStages(cfg, logger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+
+	maxSize := 0
+	for _, out := range cfg.EffectiveOutputs() {
+		if out.Type == "syslog" && out.Syslog.Format == "cef" && out.Syslog.MaxMessageSize > 0 {
+			if maxSize == 0 || out.Syslog.MaxMessageSize < maxSize {
+				maxSize = out.Syslog.MaxMessageSize
+			}
+		}
+	}
+
+	failed := 0
+	for i, fixture := range fixtures {
+		name := fixture.Name
+		if name == "" {
+			name = fmt.Sprintf("event %d", i+1)
+		}
+		if fixture.Feed == "" {
+			fixture.Feed = defaultFeed
+		}
+		feed, ok := feeds[fixture.Feed]
+		if !ok {
+			fmt.Printf("FAIL %s: feed %s is not configured\n", name, fixture.Feed)
+			failed++
+			continue
+		}
+
+		record := processor.Format(cfg, feed.Name, stages, eventFields(fixture.Event))
+		formatter := newCEFFormatter(cfg, feed)
+		line := formatter.Format(record.Fields)
+
+		warnings := formatter.Check(record.Fields)
+		if maxSize > 0 && len(line) > maxSize {
+			warnings = append(warnings, fmt.Sprintf("line is %d bytes, syslog outputs cut it to max_message_size %d", len(line), maxSize))
+		}
+
+		status := "OK"
+		if fixture.Expect != "" && line != fixture.Expect {
+			status = "FAIL"
+			failed++
+		}
+		label := "feed " + feed.Name
+		if eventType := formatter.Profile(record.Fields); eventType != "" {
+			label += ", profile " + eventType
+		}
+		fmt.Printf("%s %s (%s)\n  %s\n", status, name, label, line)
+		if status == "FAIL" {
+			fmt.Printf("  expected:\n  %s\n", fixture.Expect)
+		}
+		for _, warning := range warnings {
+			fmt.Printf("  warning: %s\n", warning)
+		}
+	}
+
+	if failed > 0 {
+		fmt.Printf("%d of %d events did not match\n", failed, len(fixtures))
+		return 1
+	}
+	return 0
+}
+
+// readCEFFixtures reads a fixture, an array of fixtures, or a bare event
+// object, which becomes a fixture without an expected line
+func readCEFFixtures(path string) ([]cefFixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read event file: %w", err)
+	}
+
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("[")) {
+		var fixtures []cefFixture
+		if err := json.Unmarshal(data, &fixtures); err != nil {
+			return nil, fmt.Errorf("failed to parse event file: %w", err)
+		}
+		for i, fixture := range fixtures {
+			if fixture.Event == nil {
+				return nil, fmt.Errorf("fixture %d in %s has no event", i+1, path)
+			}
+		}
+		return fixtures, nil
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, fmt.Errorf("failed to parse event file: %w", err)
+	}
+	var fixture cefFixture
+	if _, ok := object["event"]; ok {
+		err = json.Unmarshal(data, &fixture)
+	} else {
+		err = json.Unmarshal(data, &fixture.Event)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse event file: %w", err)
+	}
+	return []cefFixture{fixture}, nil
+}
+
+// eventFields converts a fixture event to the string fields the API
+// returns; numbers and booleans keep their JSON text
+func eventFields(event map[string]interface{}) map[string]string {
+	fields := make(map[string]string, len(event))
+	for name, value := range event {
+		switch v := value.(type) {
+		case nil:
+			// Left out, like an empty API field
+		case string:
+			fields[name] = v
+		default:
+			data, _ := json.Marshal(v)
+			fields[name] = string(data)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"cato-logger/internal/apitest"
+	"cato-logger/internal/config"
+	"cato-logger/internal/logging"
+	"cato-logger/internal/output"
+	"cato-logger/internal/processor"
+	"cato-logger/internal/syslog"
+)
+
+// maxUDPPayload is the largest UDP datagram, which carries one message
+const maxUDPPayload = 65507
+
+// testMessageField marks the test messages so they can be found at the
+// receiving end
+const testMessageField = "test_message"
+
+// testPaddingField pads a test message to the size limit
+const testPaddingField = "test_padding"
+
+// syslogTest is the outcome of testing one syslog output
+type syslogTest struct {
+	sent      int
+	failed    int
+	bytes     int64
+	latencies []time.Duration
+	elapsed   time.Duration
+	framing   int // Messages with a line break that newline framing would split
+	truncated atomic.Int64
+	warnings  []string
+	problems  []string
+	size      string // Result of the size check
+}
+
+// runTestSyslogCommand handles "cato-logger test-syslog": it sends test
+// messages to the syslog outputs and reports write latency, errors, and
+// framing and size limit problems, so output problems can be told apart
+// from API problems
+func runTestSyslogCommand(args []string) int {
+	fs := flag.NewFlagSet("test-syslog", flag.ContinueOnError)
+	configPath := fs.String("config", "", "Path to config.json file")
+	count := fs.Int("count", 100, "Test messages to send to each output")
+	rate := fs.Float64("rate", 0, "Messages per second (0 as fast as the output takes them)")
+	outputName := fs.String("output", "", "Only test this syslog output (default: all syslog outputs)")
+	feedName := fs.String("feed", "", "Feed whose mapping profile formats the messages (defaults to the first feed)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *count <= 0 || *rate < 0 {
+		fmt.Fprintln(os.Stderr, "usage: cato-logger test-syslog [--config <file>] [--count N] [--rate N] [--output <name>] [--feed <name>]")
+		return 2
+	}
+
+	cfg, err := config.LoadFile(*configPath)
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: invalid configuration: %v\n", err)
+		return 2
+	}
+
+	feed := *feedName
+	if feed == "" {
+		feed = cfg.EffectiveFeeds()[0].Name
+	}
+
+	var outputs []config.Output
+	for _, out := range cfg.EffectiveOutputs() {
+		if out.Type == "syslog" && (*outputName == "" || out.Name == *outputName) {
+			outputs = append(outputs, out)
+		}
+	}
+	if len(outputs) == 0 {
+		if *outputName != "" {
+			fmt.Fprintf(os.Stderr, "ERROR: no syslog output named %s is configured\n", *outputName)
+		} else {
+			fmt.Fprintln(os.Stderr, "ERROR: no syslog outputs are configured")
+		}
+		return 2
+	}
+
+	logger, err := logging.New(logging.Options{Level: "error", Format: "text", Output: "stderr"})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	defer logger.Close()
+
+	stages, err := buildStages(cfg, logger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	formats := newFormats(cfg)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	failed := false
+	for _, out := range outputs {
+		fmt.Printf("output %s (%s://%s, %s, max %d bytes)\n", out.Name, out.Syslog.Protocol,
+			out.Syslog.Address(), syslogFormat(out.Syslog), out.Syslog.MaxMessageSize)
+		result := testSyslogOutput(ctx, cfg, out, feed, stages, formats, *count, *rate, logger)
+		printSyslogTest(out, result)
+		failed = failed || result.failed > 0 || result.framing > 0 || len(result.problems) > 0
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// testSyslogOutput sends count test messages and the size check messages
+// to one output
+func testSyslogOutput(ctx context.Context, cfg *config.Config, out config.Output, feed string, stages []processor.Stage,
+	formats *output.Formats, count int, rate float64, logger *logging.Logger) *syslogTest {
+	result := &syslogTest{}
+	maxSize := out.Syslog.MaxMessageSize
+	if out.Syslog.Protocol == "udp" {
+		if maxSize > maxUDPPayload {
+			result.problems = append(result.problems, fmt.Sprintf(
+				"max_message_size %d does not fit the largest UDP datagram (%d bytes), larger messages fail", maxSize, maxUDPPayload))
+		} else if maxSize > syslog.DefaultMaxUDPMessageSize {
+			result.warnings = append(result.warnings, fmt.Sprintf(
+				"messages over %d bytes are sent as fragmented datagrams, which firewalls often drop", syslog.DefaultMaxUDPMessageSize))
+		}
+	}
+
+	sinks, err := output.Build([]config.Output{out}, output.Options{
+		ConnTimeout: time.Duration(cfg.ConnTimeout) * time.Second,
+		Logger:      logger,
+		OnTruncate:  func(string) { result.truncated.Add(1) },
+		Formats:     formats,
+	})
+	if err != nil {
+		result.problems = append(result.problems, err.Error())
+		return result
+	}
+	defer output.CloseAll(sinks)
+	sink := sinks[0]
+	formatter := formats.Formatter(syslogFormat(out.Syslog))
+	newlineFramed := out.Syslog.Protocol == "tcp" || out.Syslog.Protocol == "unix"
+
+	start := time.Now()
+	for i := 0; i < count && ctx.Err() == nil; i++ {
+		if rate > 0 {
+			if wait := time.Until(start.Add(time.Duration(float64(i) / rate * float64(time.Second)))); wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-ctx.Done():
+					continue
+				}
+			}
+		}
+
+		fields := apitest.Event("test-syslog", int64(i), time.Now())
+		fields[testMessageField] = fmt.Sprintf("cato-logger test-syslog %d/%d", i+1, count)
+		record, err := replayFormat(cfg, feed, stages, fields)
+		if err != nil {
+			result.problems = append(result.problems, err.Error())
+			return result
+		}
+		if text, err := formatter.Format(record); err == nil && newlineFramed && bytes.ContainsAny(text, "\r\n") {
+			result.framing++
+		}
+
+		writeStart := time.Now()
+		n, err := sink.Write(ctx, []output.Record{record})
+		result.latencies = append(result.latencies, time.Since(writeStart))
+		result.sent++
+		if err != nil {
+			result.failed++
+			if result.failed == 1 {
+				result.problems = append(result.problems, "first failed write: "+err.Error())
+			}
+			continue
+		}
+		result.bytes += n
+	}
+	result.elapsed = time.Since(start)
+
+	if ctx.Err() == nil {
+		result.size = testSyslogSize(ctx, cfg, sink, formatter, feed, stages, maxSize, result)
+	}
+	if n := waitDelivered(sinks, deliveryTimeout)[sink.Name()]; n > 0 {
+		result.problems = append(result.problems, fmt.Sprintf("%d queued messages undelivered after %s", n, deliveryTimeout))
+	}
+	return result
+}
+
+// testSyslogSize sends a message of exactly the size limit, which must go
+// out whole, and a larger one, which must be truncated to the limit
+func testSyslogSize(ctx context.Context, cfg *config.Config, sink output.Sink, formatter output.Formatter, feed string,
+	stages []processor.Stage, maxSize int, result *syslogTest) string {
+	if maxSize <= 0 {
+		return "no size limit configured"
+	}
+
+	fields := apitest.Event("test-syslog", 0, time.Now())
+	fields[testMessageField] = "cato-logger test-syslog size check"
+	fields[testPaddingField] = "x"
+	size := func() int {
+		record, err := replayFormat(cfg, feed, stages, fields)
+		if err != nil {
+			return 0
+		}
+		text, err := formatter.Format(record)
+		if err != nil {
+			return 0
+		}
+		return len(syslog.FormatMessage(record.Hostname, string(text)))
+	}
+
+	// Padding grows the message byte for byte in every format that keeps
+	// the field, so one measurement finds the padding needed
+	base := size()
+	fields[testPaddingField] = "xx"
+	if size() != base+1 {
+		return "skipped, the message format does not carry the padding field"
+	}
+	if base > maxSize {
+		result.problems = append(result.problems, fmt.Sprintf(
+			"a typical event formats to %d bytes, over max_message_size, so every message is truncated", base))
+		return "skipped, typical events already exceed the limit"
+	}
+	fields[testPaddingField] = strings.Repeat("x", 1+maxSize-base)
+
+	record, _ := replayFormat(cfg, feed, stages, fields)
+	before := result.truncated.Load()
+	if _, err := sink.Write(ctx, []output.Record{record}); err != nil {
+		result.problems = append(result.problems, fmt.Sprintf("a %d-byte message failed: %v", maxSize, err))
+		return "failed"
+	}
+	if result.truncated.Load() != before {
+		result.problems = append(result.problems, fmt.Sprintf("a %d-byte message was truncated", maxSize))
+		return "failed"
+	}
+
+	fields[testPaddingField] += strings.Repeat("x", 100)
+	record, _ = replayFormat(cfg, feed, stages, fields)
+	if _, err := sink.Write(ctx, []output.Record{record}); err != nil {
+		result.problems = append(result.problems, fmt.Sprintf("a message over max_message_size failed: %v", err))
+		return "failed"
+	}
+	if result.truncated.Load() == before {
+		result.problems = append(result.problems, "a message over max_message_size was not truncated")
+		return "failed"
+	}
+	return fmt.Sprintf("%d-byte message sent whole, larger message truncated to %d bytes", maxSize, maxSize)
+}
+
+// printSyslogTest prints the outcome of testing one output
+func printSyslogTest(out config.Output, result *syslogTest) {
+	if result.sent > 0 {
+		errorRate := float64(result.failed) / float64(result.sent) * 100
+		fmt.Printf("  sent %d messages, %d failed (%.1f%% errors), %s, %.1f messages/sec\n",
+			result.sent, result.failed, errorRate, formatBytes(result.bytes),
+			float64(result.sent)/result.elapsed.Seconds())
+		fmt.Printf("  write latency p50 %s p95 %s p99 %s max %s\n",
+			percentile(result.latencies, 0.5).Round(time.Microsecond),
+			percentile(result.latencies, 0.95).Round(time.Microsecond),
+			percentile(result.latencies, 0.99).Round(time.Microsecond),
+			percentile(result.latencies, 1).Round(time.Microsecond))
+
+		switch out.Syslog.Protocol {
+		case "tcp", "unix":
+			fmt.Printf("  framing: newline-delimited, %d messages with line breaks\n", result.framing)
+		case "relp":
+			fmt.Println("  framing: RELP frames, every message acknowledged")
+		default:
+			fmt.Println("  framing: one datagram per message")
+		}
+	}
+	if result.size != "" {
+		fmt.Printf("  size: %s\n", result.size)
+	}
+	if result.framing > 0 {
+		result.problems = append(result.problems, fmt.Sprintf(
+			"%d messages contain line breaks, which the receiver splits into separate events", result.framing))
+	}
+	for _, warning := range result.warnings {
+		fmt.Printf("  WARNING: %s\n", warning)
+	}
+	for _, problem := range result.problems {
+		fmt.Printf("  PROBLEM: %s\n", problem)
+	}
+}
+
+// syslogFormat returns the message format of a syslog output
+func syslogFormat(out *config.SyslogOutput) string {
+	if out.Format == "" {
+		return "cef"
+	}
+	return out.Format
+}
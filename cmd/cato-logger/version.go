@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"cato-logger/internal/config"
+)
+
+// Build metadata, set at build time with
+//
+//	go build -ldflags "-X main.commit=$(git rev-parse --short HEAD) -X main.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Without them, the VCS details Go embeds when building from a checkout
+// are used.
+var (
+	version   = "3.2"
+	commit    = ""
+	buildDate = ""
+)
+
+// buildCommit returns the commit the binary was built from
+func buildCommit() string {
+	if commit != "" {
+		return commit
+	}
+	if value := buildSetting("vcs.revision"); value != "" {
+		if len(value) > 12 {
+			value = value[:12]
+		}
+		if buildSetting("vcs.modified") == "true" {
+			value += "-dirty"
+		}
+		return value
+	}
+	return "unknown"
+}
+
+// buildTime returns when the binary was built, or for a build without
+// ldflags the time of its commit
+func buildTime() string {
+	if buildDate != "" {
+		return buildDate
+	}
+	if value := buildSetting("vcs.time"); value != "" {
+		return value + " (commit time)"
+	}
+	return "unknown"
+}
+
+// buildSetting returns a setting Go recorded in the binary, empty if none
+func buildSetting(key string) string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == key {
+			return setting.Value
+		}
+	}
+	return ""
+}
+
+// runVersionCommand handles "cato-logger version" and --version: it prints
+// the build details support needs to identify a binary
+func runVersionCommand(args []string) int {
+	fs := flag.NewFlagSet("version", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	fmt.Printf("cato-logger %s\n", version)
+	fmt.Printf("  commit:     %s\n", buildCommit())
+	fmt.Printf("  built:      %s\n", buildTime())
+	fmt.Printf("  go:         %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Printf("  outputs:    %s\n", strings.Join(config.OutputTypes, ", "))
+	fmt.Printf("  formats:    %s, %s<name>\n", strings.Join(config.OutputFormats, ", "), config.TemplateFormatPrefix)
+	return 0
+}
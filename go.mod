@@ -1,3 +1,3 @@
 module cato-logger
 
-go 1.18
+go 1.21
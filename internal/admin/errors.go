@@ -0,0 +1,58 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"cato-logger/internal/processor"
+)
+
+// errorCategories are the categories /errors can be filtered by
+var errorCategories = []string{
+	processor.ErrorAPI,
+	processor.ErrorOutput,
+	processor.ErrorMarker,
+	processor.ErrorFormat,
+	processor.ErrorProcessing,
+}
+
+// handleErrors serves the most recent errors, newest first, limited to one
+// category with ?category= and to the first N with ?limit=N
+func (s *Server) handleErrors(w http.ResponseWriter, r *http.Request) {
+	recent := s.stats.Errors()
+
+	if category := r.URL.Query().Get("category"); category != "" {
+		known := false
+		for _, c := range errorCategories {
+			known = known || c == category
+		}
+		if !known {
+			http.Error(w, "category must be one of api, output, marker, format, processing", http.StatusBadRequest)
+			return
+		}
+		filtered := make([]processor.ErrorRecord, 0, len(recent))
+		for _, record := range recent {
+			if record.Category == category {
+				filtered = append(filtered, record)
+			}
+		}
+		recent = filtered
+	}
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			http.Error(w, "limit must be a positive number", http.StatusBadRequest)
+			return
+		}
+		if n < len(recent) {
+			recent = recent[:n]
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Errors []processor.ErrorRecord `json:"errors"`
+		Totals map[string]int64        `json:"totals"` // Errors since startup by category
+	}{recent, s.stats.ErrorCounts()})
+}
@@ -0,0 +1,186 @@
+package admin
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"cato-logger/internal/processor"
+)
+
+// metricPrefix starts every exported metric name
+const metricPrefix = "cato_logger_"
+
+// handleMetrics serves the stats in the Prometheus text exposition format
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	snapshot := s.stats.Snapshot()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	out := bufio.NewWriter(w)
+	defer out.Flush()
+
+	counter := func(name, help string, value int64) {
+		fmt.Fprintf(out, "# HELP %s%s %s\n# TYPE %s%s counter\n%s%s %d\n",
+			metricPrefix, name, help, metricPrefix, name, metricPrefix, name, value)
+	}
+	gauge := func(name, help string, value float64) {
+		fmt.Fprintf(out, "# HELP %s%s %s\n# TYPE %s%s gauge\n%s%s %s\n",
+			metricPrefix, name, help, metricPrefix, name, metricPrefix, name, formatFloat(value))
+	}
+
+	counter("events_forwarded_total", "Events written to every output.", snapshot.TotalEventsForwarded)
+	writeEventTypes(out, s.stats.EventTypes())
+	counter("api_cycles_total", "Processing cycles started.", snapshot.TotalAPIRequests)
+	counter("api_cycles_failed_total", "Processing cycles that failed.", snapshot.FailedAPIRequests)
+	counter("api_bytes_fetched_total", "API response bytes received on the wire.", snapshot.TotalBytesFetched)
+	counter("output_bytes_sent_total", "Bytes written to all outputs.", snapshot.TotalBytesSent)
+	counter("output_reconnects_total", "Output reconnect attempts.", snapshot.TotalReconnects)
+	counter("output_reconnect_failures_total", "Output reconnect attempts that failed.", snapshot.ReconnectFailures)
+	gauge("outputs_reconnecting", "Outputs whose last reconnect attempt failed.", float64(len(snapshot.Reconnecting)))
+	counter("output_messages_dropped_total", "Messages discarded by full output queues.", snapshot.TotalDropped)
+	counter("output_messages_truncated_total", "Messages cut to an output's max_message_size.", snapshot.TotalTruncated)
+	counter("events_sampled_out_total", "Events sampling did not forward.", snapshot.TotalSampledOut)
+	counter("events_aggregated_total", "Events rolled up into another event by aggregation.", snapshot.TotalAggregated)
+	counter("dead_letter_entries_total", "Entries written to the dead-letter file.", snapshot.TotalDeadLettered)
+	counter("marker_stale_alarms_total", "Times a feed's marker went stale.", snapshot.StaleMarkerAlarms)
+	gauge("stale_feeds", "Feeds whose marker is currently stale.", float64(len(snapshot.StaleFeeds)))
+	writeErrorCounts(out, s.stats.ErrorCounts())
+	writeOutputs(out, s.stats.Outputs())
+	if s.pauser != nil {
+		paused := 0.0
+		if s.pauser.PauseStatus().Paused {
+			paused = 1
+		}
+		gauge("paused", "1 while fetching is paused.", paused)
+	}
+	if slo := s.stats.SLO(); slo.Enabled {
+		violated := 0.0
+		if slo.Consecutive > 0 {
+			violated = 1
+		}
+		gauge("slo_latency_seconds", "Delivery latency the SLO allows.", slo.Latency.Seconds())
+		gauge("slo_target_ratio", "Share of events the SLO requires delivered in time.", slo.Target/100)
+		if slo.Evaluated > 0 {
+			gauge("slo_compliance_ratio", "Share of events delivered in time in the last SLO window.", slo.Compliance/100)
+		}
+		gauge("slo_violated", "1 while the last SLO window with events missed the target.", violated)
+		counter("slo_windows_total", "SLO windows evaluated with events.", slo.Evaluated)
+		counter("slo_violations_total", "SLO windows that missed the target.", slo.Violations)
+	}
+	gauge("last_cycle_pages", "Pages fetched by the last finished cycle.", float64(snapshot.LastCyclePages))
+	gauge("last_cycle_drain_seconds", "Time the last cycle took to catch up with the feed, 0 if it did not.",
+		snapshot.LastDrainTime.Seconds())
+	gauge("uptime_seconds", "Seconds since the service started.", snapshot.Uptime.Seconds())
+	if !snapshot.LastMarkerUpdate.IsZero() {
+		gauge("marker_last_update_timestamp_seconds", "Unix time the marker last advanced.",
+			float64(snapshot.LastMarkerUpdate.UnixNano())/1e9)
+	}
+
+	writeHistogram(out, "api_request_duration_seconds", "API request round trip.", s.stats.APIDuration.Snapshot())
+	writeHistogram(out, "output_write_duration_seconds", "Batch write to a single output.", s.stats.WriteDuration.Snapshot())
+	writeHistogram(out, "event_latency_seconds", "Event time until written to every output.", s.stats.EventLatency.Snapshot())
+}
+
+// writeErrorCounts writes the errors counter labelled by category, with
+// every category present so the series exist before the first error
+func writeErrorCounts(out *bufio.Writer, counts map[string]int64) {
+	name := metricPrefix + "errors_total"
+	fmt.Fprintf(out, "# HELP %s Errors recorded by category.\n# TYPE %s counter\n", name, name)
+	for _, category := range errorCategories {
+		fmt.Fprintf(out, "%s{category=\"%s\"} %d\n", name, category, counts[category])
+	}
+}
+
+// writeEventTypes writes the forwarded events counter labelled by event type
+func writeEventTypes(out *bufio.Writer, counts []processor.EventTypeCount) {
+	name := metricPrefix + "events_forwarded_by_type_total"
+	fmt.Fprintf(out, "# HELP %s Events written to every output by event_type and event_sub_type.\n# TYPE %s counter\n", name, name)
+	for _, c := range counts {
+		fmt.Fprintf(out, "%s{event_type=\"%s\",event_sub_type=\"%s\"} %d\n",
+			name, labelEscaper.Replace(c.Type), labelEscaper.Replace(c.SubType), c.Count)
+	}
+}
+
+// writeOutputs writes the delivery metrics of every output, labelled by
+// output name
+func writeOutputs(out *bufio.Writer, outputs []processor.OutputStats) {
+	metric := func(name, kind, help string, value func(processor.OutputStats) (float64, bool)) {
+		name = metricPrefix + name
+		fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+		for _, o := range outputs {
+			if v, ok := value(o); ok {
+				fmt.Fprintf(out, "%s{output=\"%s\"} %s\n", name, labelEscaper.Replace(o.Name), formatFloat(v))
+			}
+		}
+	}
+	metric("output_events_total", "counter", "Events an output took.",
+		func(o processor.OutputStats) (float64, bool) { return float64(o.Events), true })
+	metric("output_bytes_total", "counter", "Bytes an output took.",
+		func(o processor.OutputStats) (float64, bool) { return float64(o.Bytes), true })
+	metric("output_write_failures_total", "counter", "Batch writes an output failed.",
+		func(o processor.OutputStats) (float64, bool) { return float64(o.Failures), true })
+	metric("output_dropped_total", "counter", "Messages an output's queue discarded.",
+		func(o processor.OutputStats) (float64, bool) { return float64(o.Dropped), true })
+	metric("output_queue_depth", "gauge", "Messages waiting in an output's queue.",
+		func(o processor.OutputStats) (float64, bool) { return float64(o.QueueDepth), true })
+	metric("output_last_success_timestamp_seconds", "gauge", "Unix time of an output's last successful write.",
+		func(o processor.OutputStats) (float64, bool) {
+			return float64(o.LastSuccess.UnixNano()) / 1e9, !o.LastSuccess.IsZero()
+		})
+}
+
+// labelEscaper escapes a Prometheus label value
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// writeHistogram writes one histogram with cumulative buckets
+func writeHistogram(out *bufio.Writer, name, help string, h processor.HistogramSnapshot) {
+	name = metricPrefix + name
+	fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+
+	var cumulative uint64
+	for i, bound := range h.Bounds {
+		cumulative += h.Counts[i]
+		fmt.Fprintf(out, "%s_bucket{le=\"%s\"} %d\n", name, formatFloat(bound), cumulative)
+	}
+	fmt.Fprintf(out, "%s_bucket{le=\"+Inf\"} %d\n", name, h.Count)
+	fmt.Fprintf(out, "%s_sum %s\n%s_count %d\n", name, formatFloat(h.Sum), name, h.Count)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// latencySummary is the JSON form of one histogram on /latency
+type latencySummary struct {
+	Count      uint64  `json:"count"`
+	AvgSeconds float64 `json:"avg_seconds"`
+	P50Seconds float64 `json:"p50_seconds"`
+	P90Seconds float64 `json:"p90_seconds"`
+	P99Seconds float64 `json:"p99_seconds"`
+}
+
+func summarize(h processor.HistogramSnapshot) latencySummary {
+	summary := latencySummary{
+		Count:      h.Count,
+		P50Seconds: h.Quantile(0.50).Seconds(),
+		P90Seconds: h.Quantile(0.90).Seconds(),
+		P99Seconds: h.Quantile(0.99).Seconds(),
+	}
+	if h.Count > 0 {
+		summary.AvgSeconds = h.Sum / float64(h.Count)
+	}
+	return summary
+}
+
+// handleLatency serves estimated percentiles of the latency histograms as JSON
+func (s *Server) handleLatency(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]latencySummary{
+		"api_request":  summarize(s.stats.APIDuration.Snapshot()),
+		"output_write": summarize(s.stats.WriteDuration.Snapshot()),
+		"event":        summarize(s.stats.EventLatency.Snapshot()),
+	})
+}
@@ -0,0 +1,106 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// controlHeader must be sent with pause and resume requests when no admin
+// token is configured. Browsers only send custom headers cross-site after
+// a CORS preflight, which the endpoint never approves, so a web page cannot
+// pause fetching through a browser on the same host.
+const controlHeader = "X-Cato-Logger-Admin"
+
+// PauseStatus describes whether fetching is paused
+type PauseStatus struct {
+	Paused bool       `json:"paused"`
+	Since  *time.Time `json:"since,omitempty"` // Set while paused
+	Reason string     `json:"reason,omitempty"`
+}
+
+// Pauser pauses and resumes fetching. Pause and Resume report whether the
+// state changed.
+type Pauser interface {
+	Pause(reason string) bool
+	Resume() bool
+	PauseStatus() PauseStatus
+}
+
+// EnablePause serves /pause and /resume, which switch fetching off and on
+// with a POST, and reports the state on GET /pause and in /metrics. POSTs
+// must carry token as a bearer token, or the control header when token is
+// empty.
+func (s *Server) EnablePause(p Pauser, token string) {
+	s.pauser = p
+	s.controlToken = token
+	s.mux.HandleFunc("/pause", s.handlePause)
+	s.mux.HandleFunc("/resume", s.handleResume)
+}
+
+// handlePause pauses fetching on POST, with an optional reason parameter,
+// and reports the pause state
+func (s *Server) handlePause(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if !s.authorizeControl(w, r) {
+			return
+		}
+		reason := r.URL.Query().Get("reason")
+		if s.pauser.Pause(reason) {
+			s.logger.Info("pause requested via admin endpoint", "remote", r.RemoteAddr, "reason", reason)
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.writePauseStatus(w)
+}
+
+// handleResume resumes fetching on POST and reports the pause state
+func (s *Server) handleResume(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authorizeControl(w, r) {
+		return
+	}
+	if s.pauser.Resume() {
+		s.logger.Info("resume requested via admin endpoint", "remote", r.RemoteAddr)
+	}
+	s.writePauseStatus(w)
+}
+
+// authorizeControl checks a request that changes the pause state carries
+// the admin token, or the control header when there is no token, and
+// answers it otherwise
+func (s *Server) authorizeControl(w http.ResponseWriter, r *http.Request) bool {
+	if s.controlToken == "" {
+		if r.Header.Get(controlHeader) == "1" {
+			return true
+		}
+		http.Error(w, controlHeader+": 1 header required", http.StatusForbidden)
+		return false
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if ok && subtle.ConstantTimeCompare([]byte(token), []byte(s.controlToken)) == 1 {
+		return true
+	}
+	s.logger.Warn("rejected admin request without a valid token", "path", r.URL.Path, "remote", r.RemoteAddr)
+	w.Header().Set("WWW-Authenticate", `Bearer realm="cato-logger"`)
+	http.Error(w, "unauthorized", http.StatusUnauthorized)
+	return false
+}
+
+// writePauseStatus writes the pause state as JSON
+func (s *Server) writePauseStatus(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.pauser.PauseStatus())
+}
@@ -0,0 +1,109 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"cato-logger/internal/logging"
+	"cato-logger/internal/processor"
+)
+
+// Server is the optional local HTTP endpoint for metrics and runtime inspection
+type Server struct {
+	mux    *http.ServeMux
+	stats  *processor.Stats
+	pauser Pauser // nil until EnablePause
+	logger *logging.Logger
+
+	controlToken string // Admin token pausing and resuming requires, empty for none
+}
+
+// New creates an admin server exposing stats
+func New(stats *processor.Stats, logger *logging.Logger) *Server {
+	s := &Server{
+		mux:    http.NewServeMux(),
+		stats:  stats,
+		logger: logger,
+	}
+	s.mux.HandleFunc("/metrics", s.handleMetrics)
+	s.mux.HandleFunc("/latency", s.handleLatency)
+	s.mux.HandleFunc("/stats", s.handleStats)
+	s.mux.HandleFunc("/errors", s.handleErrors)
+	s.mux.HandleFunc("/ready", s.handleReady)
+	s.mux.HandleFunc("/event-types", s.handleEventTypes)
+	return s
+}
+
+// handleEventTypes reports the events forwarded by type, largest first,
+// limited to the top N with ?top=N
+func (s *Server) handleEventTypes(w http.ResponseWriter, r *http.Request) {
+	counts := s.stats.EventTypes()
+	if top := r.URL.Query().Get("top"); top != "" {
+		n, err := strconv.Atoi(top)
+		if err != nil || n < 1 {
+			http.Error(w, "top must be a positive number", http.StatusBadRequest)
+			return
+		}
+		if n < len(counts) {
+			counts = counts[:n]
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		EventTypes []processor.EventTypeCount `json:"event_types"`
+	}{counts})
+}
+
+// handleReady reports not ready (503) while any feed's marker is stale
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	stale := s.stats.Snapshot().StaleFeeds
+	w.Header().Set("Content-Type", "application/json")
+	if len(stale) > 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(struct {
+		Ready      bool     `json:"ready"`
+		StaleFeeds []string `json:"stale_feeds,omitempty"`
+	}{len(stale) == 0, stale})
+}
+
+// Handle registers an additional endpoint
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// Start listens on addr and serves until ctx is done. Listen errors are
+// returned so an unavailable address fails startup.
+func (s *Server) Start(ctx context.Context, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	server := &http.Server{
+		Handler:           s.mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("admin server stopped", "error", err.Error())
+		}
+	}()
+
+	s.logger.Info("admin server listening", "address", listener.Addr().String())
+	return nil
+}
@@ -0,0 +1,202 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"cato-logger/internal/processor"
+)
+
+// statsErrors is the number of recent errors included in /stats
+const statsErrors = 10
+
+// statsCounters are the lifetime counters reported by /stats
+type statsCounters struct {
+	EventsForwarded   int64            `json:"events_forwarded"`
+	APICycles         int64            `json:"api_cycles"`
+	APICyclesFailed   int64            `json:"api_cycles_failed"`
+	BytesFetched      int64            `json:"bytes_fetched"`
+	BytesSent         int64            `json:"bytes_sent"`
+	Reconnects        int64            `json:"reconnects"`
+	ReconnectFailures int64            `json:"reconnect_failures"`
+	DeadLettered      int64            `json:"dead_lettered"`
+	Dropped           int64            `json:"dropped"`
+	Truncated         int64            `json:"truncated"`
+	SampledOut        int64            `json:"sampled_out"`
+	Aggregated        int64            `json:"aggregated"`
+	StaleMarkerAlarms int64            `json:"stale_marker_alarms"`
+	Errors            map[string]int64 `json:"errors"` // By category
+}
+
+// statsRates are the per-second rates reported by /stats, averaged since
+// startup and over the current reporting window
+type statsRates struct {
+	EventsPerSecond       float64 `json:"events_per_second"`
+	BytesFetchedPerSecond float64 `json:"bytes_fetched_per_second"`
+	BytesSentPerSecond    float64 `json:"bytes_sent_per_second"`
+	WindowEventsPerSecond float64 `json:"window_events_per_second"`
+	WindowSeconds         float64 `json:"window_seconds"`
+}
+
+// statsLastCycle describes the last processing cycle
+type statsLastCycle struct {
+	ID           string  `json:"id,omitempty"`
+	Pages        int     `json:"pages"`
+	DrainSeconds float64 `json:"drain_seconds"`
+}
+
+// statsOutput is the delivery state of one output
+type statsOutput struct {
+	Name        string     `json:"name"`
+	Events      int64      `json:"events"`
+	Bytes       int64      `json:"bytes"`
+	Failures    int64      `json:"failures"`
+	Dropped     int64      `json:"dropped"`
+	QueueDepth  int        `json:"queue_depth"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+}
+
+// statsResponse is the /stats document
+type statsResponse struct {
+	Time                time.Time                 `json:"time"`
+	StartTime           time.Time                 `json:"start_time"`
+	UptimeSeconds       float64                   `json:"uptime_seconds"`
+	Counters            statsCounters             `json:"counters"`
+	Rates               statsRates                `json:"rates"`
+	LastCycle           statsLastCycle            `json:"last_cycle"`
+	LastMarkerUpdate    *time.Time                `json:"last_marker_update,omitempty"`
+	Markers             []processor.MarkerInfo    `json:"markers"`
+	StaleFeeds          []string                  `json:"stale_feeds"`
+	ReconnectingOutputs []string                  `json:"reconnecting_outputs"`
+	Outputs             []statsOutput             `json:"outputs"`
+	LastErrors          []processor.ErrorRecord   `json:"last_errors"`
+	Latency             map[string]latencySummary `json:"latency"`
+	Pause               *PauseStatus              `json:"pause,omitempty"`
+	SLO                 *statsSLO                 `json:"slo,omitempty"`
+}
+
+// statsSLO is the delivery latency SLO state
+type statsSLO struct {
+	LatencySeconds      float64  `json:"latency_seconds"`
+	TargetPercent       float64  `json:"target_percent"`
+	CompliancePercent   *float64 `json:"compliance_percent,omitempty"` // Last window, absent before the first
+	Windows             int64    `json:"windows"`
+	Violations          int64    `json:"violations"`
+	ConsecutiveViolated int      `json:"consecutive_violated"`
+}
+
+// handleStats serves a JSON snapshot of all runtime statistics, for
+// monitoring scripts that would otherwise parse the stats log lines
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	snapshot := s.stats.Snapshot()
+	now := time.Now()
+	lastErrors := s.stats.Errors()
+	if len(lastErrors) > statsErrors {
+		lastErrors = lastErrors[:statsErrors]
+	}
+
+	response := statsResponse{
+		Time:          now.UTC(),
+		StartTime:     now.Add(-snapshot.Uptime).UTC(),
+		UptimeSeconds: snapshot.Uptime.Seconds(),
+		Counters: statsCounters{
+			EventsForwarded:   snapshot.TotalEventsForwarded,
+			APICycles:         snapshot.TotalAPIRequests,
+			APICyclesFailed:   snapshot.FailedAPIRequests,
+			BytesFetched:      snapshot.TotalBytesFetched,
+			BytesSent:         snapshot.TotalBytesSent,
+			Reconnects:        snapshot.TotalReconnects,
+			ReconnectFailures: snapshot.ReconnectFailures,
+			DeadLettered:      snapshot.TotalDeadLettered,
+			Dropped:           snapshot.TotalDropped,
+			Truncated:         snapshot.TotalTruncated,
+			SampledOut:        snapshot.TotalSampledOut,
+			Aggregated:        snapshot.TotalAggregated,
+			StaleMarkerAlarms: snapshot.StaleMarkerAlarms,
+			Errors:            s.stats.ErrorCounts(),
+		},
+		Rates: statsRates{
+			EventsPerSecond:       perSecond(snapshot.TotalEventsForwarded, snapshot.Uptime),
+			BytesFetchedPerSecond: perSecond(snapshot.TotalBytesFetched, snapshot.Uptime),
+			BytesSentPerSecond:    perSecond(snapshot.TotalBytesSent, snapshot.Uptime),
+			WindowEventsPerSecond: perSecond(snapshot.WindowEvents, snapshot.Window),
+			WindowSeconds:         snapshot.Window.Seconds(),
+		},
+		LastCycle: statsLastCycle{
+			ID:           snapshot.LastCycleID,
+			Pages:        snapshot.LastCyclePages,
+			DrainSeconds: snapshot.LastDrainTime.Seconds(),
+		},
+		Markers:             emptyIfNil(snapshot.Markers),
+		StaleFeeds:          emptyIfNil(snapshot.StaleFeeds),
+		ReconnectingOutputs: emptyIfNil(snapshot.Reconnecting),
+		Outputs:             statsOutputs(s.stats.Outputs()),
+		LastErrors:          lastErrors,
+		Latency: map[string]latencySummary{
+			"api_request":  summarize(s.stats.APIDuration.Snapshot()),
+			"output_write": summarize(s.stats.WriteDuration.Snapshot()),
+			"event":        summarize(s.stats.EventLatency.Snapshot()),
+		},
+	}
+	if !snapshot.LastMarkerUpdate.IsZero() {
+		lastUpdate := snapshot.LastMarkerUpdate.UTC()
+		response.LastMarkerUpdate = &lastUpdate
+	}
+	if slo := s.stats.SLO(); slo.Enabled {
+		response.SLO = &statsSLO{
+			LatencySeconds:      slo.Latency.Seconds(),
+			TargetPercent:       slo.Target,
+			Windows:             slo.Evaluated,
+			Violations:          slo.Violations,
+			ConsecutiveViolated: slo.Consecutive,
+		}
+		if slo.Evaluated > 0 {
+			response.SLO.CompliancePercent = &slo.Compliance
+		}
+	}
+	if s.pauser != nil {
+		status := s.pauser.PauseStatus()
+		response.Pause = &status
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// statsOutputs converts the delivery state of the outputs to its JSON form
+func statsOutputs(outputs []processor.OutputStats) []statsOutput {
+	converted := make([]statsOutput, len(outputs))
+	for i, o := range outputs {
+		converted[i] = statsOutput{
+			Name:       o.Name,
+			Events:     o.Events,
+			Bytes:      o.Bytes,
+			Failures:   o.Failures,
+			Dropped:    o.Dropped,
+			QueueDepth: o.QueueDepth,
+		}
+		if !o.LastSuccess.IsZero() {
+			lastSuccess := o.LastSuccess.UTC()
+			converted[i].LastSuccess = &lastSuccess
+		}
+	}
+	return converted
+}
+
+// perSecond returns count averaged over d, 0 for an empty interval
+func perSecond(count int64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(count) / d.Seconds()
+}
+
+// emptyIfNil returns an empty slice for nil, so it encodes as [] rather
+// than null
+func emptyIfNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
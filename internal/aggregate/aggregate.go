@@ -0,0 +1,151 @@
+package aggregate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"cato-logger/internal/config"
+)
+
+// Fields added to a rolled-up event
+const (
+	CountField = "aggregated_count" // Events rolled up
+	EndField   = "aggregated_end"   // Time of the last event rolled up
+)
+
+// Aggregator rolls up events of the configured types that share the key
+// fields within a time window into one event. Only the events of one page
+// are rolled up together, so a saved marker never covers events still
+// waiting to be rolled up.
+type Aggregator struct {
+	types     map[string]bool // Event types or "type/sub type" rolled up
+	keyFields []string
+	sumFields []string
+	window    time.Duration
+}
+
+// New builds an aggregator from configuration
+func New(ac config.AggregationConfig) (*Aggregator, error) {
+	if len(ac.EventTypes) > 0 && len(ac.KeyFields) == 0 {
+		return nil, fmt.Errorf("aggregation.key_fields must list at least one field")
+	}
+	a := &Aggregator{
+		types:     make(map[string]bool, len(ac.EventTypes)),
+		keyFields: ac.KeyFields,
+		sumFields: ac.SumFields,
+		window:    time.Duration(ac.WindowSeconds) * time.Second,
+	}
+	for _, t := range ac.EventTypes {
+		a.types[t] = true
+	}
+	return a, nil
+}
+
+// Empty reports whether no event types are rolled up
+func (a *Aggregator) Empty() bool {
+	return len(a.types) == 0
+}
+
+// Apply returns the event unchanged; events are rolled up by Aggregate
+func (a *Aggregator) Apply(event map[string]string) map[string]string {
+	return event
+}
+
+// group is one rolled-up event being built
+type group struct {
+	event  map[string]string
+	count  int
+	sums   []float64
+	summed []bool // A value of the sum field was found
+	end    string
+	last   int // Index of the last event rolled up
+}
+
+// Aggregate rolls up a page of events. It returns the events in their
+// original order, each rolled-up event taking the place of the first event
+// of its group, and for every returned event the index of the last input
+// event it covers. Events of other types, and those without a valid time,
+// pass through unchanged. A window of 0 rolls up across the whole page.
+func (a *Aggregator) Aggregate(events []map[string]string) ([]map[string]string, []int) {
+	out := make([]map[string]string, 0, len(events))
+	last := make([]int, 0, len(events))
+	groups := make(map[string]*group)
+	positions := make(map[string]int) // Position of each group in out
+
+	for i, event := range events {
+		key, ok := a.key(event)
+		if !ok {
+			out = append(out, event)
+			last = append(last, i)
+			continue
+		}
+
+		g, exists := groups[key]
+		if !exists {
+			g = &group{event: event, sums: make([]float64, len(a.sumFields)), summed: make([]bool, len(a.sumFields))}
+			groups[key] = g
+			positions[key] = len(out)
+			out = append(out, event)
+			last = append(last, i)
+		}
+		g.count++
+		g.end = event["time"]
+		g.last = i
+		for j, field := range a.sumFields {
+			if v, err := strconv.ParseFloat(event[field], 64); err == nil {
+				g.sums[j] += v
+				g.summed[j] = true
+			}
+		}
+	}
+
+	for key, g := range groups {
+		pos := positions[key]
+		last[pos] = g.last
+		if g.count == 1 {
+			continue
+		}
+		// Copy so the first event's map is not shared with the caller's page
+		rolled := make(map[string]string, len(g.event)+2)
+		for k, v := range g.event {
+			rolled[k] = v
+		}
+		rolled[CountField] = strconv.Itoa(g.count)
+		rolled[EndField] = g.end
+		for j, field := range a.sumFields {
+			if g.summed[j] {
+				rolled[field] = strconv.FormatFloat(g.sums[j], 'f', -1, 64)
+			}
+		}
+		out[pos] = rolled
+	}
+	return out, last
+}
+
+// key returns the group key of an event, false when it is not rolled up
+func (a *Aggregator) key(event map[string]string) (string, bool) {
+	eventType := event["event_type"]
+	if !a.types[eventType] && !a.types[eventType+"/"+event["event_sub_type"]] {
+		return "", false
+	}
+
+	var b strings.Builder
+	b.WriteString(eventType)
+	b.WriteByte(0)
+	b.WriteString(event["event_sub_type"])
+	if a.window > 0 {
+		t, err := time.Parse(time.RFC3339Nano, event["time"])
+		if err != nil {
+			return "", false
+		}
+		b.WriteByte(0)
+		b.WriteString(strconv.FormatInt(t.Truncate(a.window).Unix(), 10))
+	}
+	for _, field := range a.keyFields {
+		b.WriteByte(0)
+		b.WriteString(event[field])
+	}
+	return b.String(), true
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// accountPageSize is the number of accounts requested per entityLookup page
+const accountPageSize = 100
+
+const queryAccountLookup = `query entityLookup($accountID: ID!, $type: EntityType!, $limit: Int, $from: Int) {
+		entityLookup(accountID: $accountID, type: $type, limit: $limit, from: $from) {
+			items {
+				entity { id name }
+			}
+			total
+		}
+	}`
+
+// Account is a Cato account found by ListSubAccounts
+type Account struct {
+	ID   string
+	Name string
+}
+
+// accountLookupResponse represents the entityLookup API response structure
+type accountLookupResponse struct {
+	Data struct {
+		EntityLookup struct {
+			Items []struct {
+				Entity struct {
+					ID   string `json:"id"`
+					Name string `json:"name"`
+				} `json:"entity"`
+			} `json:"items"`
+			Total int `json:"total"`
+		} `json:"entityLookup"`
+	} `json:"data"`
+	Errors []GraphQLError `json:"errors,omitempty"`
+}
+
+// ListSubAccounts returns the child accounts of a reseller/partner account
+func (c *Client) ListSubAccounts(ctx context.Context, parentID string) ([]Account, error) {
+	var accounts []Account
+
+	for from := 0; ; from += accountPageSize {
+		reqBody, err := json.Marshal(Request{
+			Query: queryAccountLookup,
+			Variables: map[string]interface{}{
+				"accountID": parentID,
+				"type":      "account",
+				"limit":     accountPageSize,
+				"from":      from,
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to build request: %w", err)
+		}
+
+		c.logger.DebugContext(ctx, "looking up sub-accounts", "parent_account_id", parentID, "from", from)
+
+		var response accountLookupResponse
+		err = c.withKeyRotation(ctx, func(apiKey string) error {
+			body, _, _, err := c.post(ctx, apiKey, reqBody)
+			if err != nil {
+				return err
+			}
+			if err := json.Unmarshal(body, &response); err != nil {
+				return fmt.Errorf("failed to parse JSON response: %w", err)
+			}
+			if len(response.Errors) > 0 {
+				return classifyGraphQLError(response.Errors, parentID)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		lookup := response.Data.EntityLookup
+		for _, item := range lookup.Items {
+			accounts = append(accounts, Account{ID: item.Entity.ID, Name: item.Entity.Name})
+		}
+
+		if len(lookup.Items) < accountPageSize || len(accounts) >= lookup.Total {
+			return accounts, nil
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// capturePrefix starts every capture file name so pruning never touches other files
+const capturePrefix = "api-capture-"
+
+// Capture writes raw API requests and responses to a directory, keeping only
+// the most recent calls. The API key is scrubbed before anything is written.
+type Capture struct {
+	dir  string
+	keep int
+
+	mu  sync.Mutex
+	seq int
+}
+
+// NewCapture creates the capture directory and returns a capture that keeps
+// the last keep calls
+func NewCapture(dir string, keep int) (*Capture, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create capture directory: %w", err)
+	}
+	return &Capture{dir: dir, keep: keep}, nil
+}
+
+// WithCapture returns a copy of the client that records every API call to capture
+func (c *Client) WithCapture(capture *Capture) *Client {
+	captured := *c
+	captured.capture = capture
+	return &captured
+}
+
+// record writes one API call. Failures are returned for logging only; a
+// capture problem never fails the request.
+func (cp *Capture) record(feed, apiKey string, req *http.Request, reqBody []byte, resp *http.Response, respBody []byte, callErr error) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# feed: %s\n# time: %s\n\n", feed, time.Now().UTC().Format(time.RFC3339Nano))
+
+	fmt.Fprintf(&buf, "%s %s\n", req.Method, req.URL)
+	writeHeaders(&buf, req.Header)
+	buf.WriteString("\n")
+	buf.Write(reqBody)
+	buf.WriteString("\n\n")
+
+	switch {
+	case callErr != nil:
+		fmt.Fprintf(&buf, "# error: %v\n", callErr)
+	case resp != nil:
+		fmt.Fprintf(&buf, "%s\n", resp.Status)
+		writeHeaders(&buf, resp.Header)
+		buf.WriteString("\n")
+		buf.Write(respBody)
+		buf.WriteString("\n")
+	}
+
+	// Headers are redacted above; also scrub the key wherever a body echoes
+	// it. Very short keys are skipped, they would mangle unrelated text.
+	data := buf.Bytes()
+	if len(apiKey) >= 8 {
+		data = bytes.ReplaceAll(data, []byte(apiKey), []byte("[redacted]"))
+	}
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	cp.seq++
+	name := fmt.Sprintf("%s%s-%06d-%s.txt", capturePrefix, time.Now().UTC().Format("20060102T150405.000"), cp.seq, feed)
+	if err := os.WriteFile(filepath.Join(cp.dir, name), data, 0600); err != nil {
+		return fmt.Errorf("failed to write API capture: %w", err)
+	}
+
+	return cp.prune()
+}
+
+// prune removes the oldest capture files beyond the configured count
+func (cp *Capture) prune() error {
+	matches, err := filepath.Glob(filepath.Join(cp.dir, capturePrefix+"*.txt"))
+	if err != nil {
+		return err
+	}
+	if len(matches) <= cp.keep {
+		return nil
+	}
+
+	// Names start with a UTC timestamp, so lexical order is age order
+	sort.Strings(matches)
+	for _, old := range matches[:len(matches)-cp.keep] {
+		if err := os.Remove(old); err != nil {
+			return fmt.Errorf("failed to remove old API capture: %w", err)
+		}
+	}
+	return nil
+}
+
+// writeHeaders writes headers sorted by name, with credentials redacted
+func writeHeaders(buf *bytes.Buffer, header http.Header) {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value := strings.Join(header[name], ", ")
+		switch strings.ToLower(name) {
+		case "x-api-key", "authorization", "cookie", "set-cookie":
+			value = "[redacted]"
+		}
+		fmt.Fprintf(buf, "%s: %s\n", name, value)
+	}
+}
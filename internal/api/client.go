@@ -2,18 +2,22 @@ package api
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"strings"
 	"time"
 
 	"cato-logger/internal/logging"
 )
 
 const (
-	queryEventsFeed = `query eventsFeed($accountIDs: [ID!]!, $marker: String) {
-		eventsFeed(accountIDs: $accountIDs, marker: $marker) {
+	queryEventsFeed = `query eventsFeed($accountIDs: [ID!]!, $marker: String, $filters: [EventFeedFieldFilterInput!]) {
+		eventsFeed(accountIDs: $accountIDs, marker: $marker, filters: $filters) {
 			marker
 			fetchedCount
 			accounts {
@@ -25,96 +29,421 @@ const (
 			}
 		}
 	}`
+
+	queryAuditFeed = `query auditFeed($accountIDs: [ID!], $timeFrame: TimeFrame!, $marker: String) {
+		auditFeed(accountIDs: $accountIDs, timeFrame: $timeFrame, marker: $marker) {
+			marker
+			fetchedCount
+			hasMore
+			accounts {
+				id
+				records {
+					admin { id name }
+					apiKey { id name }
+					object { id name }
+					time
+					modelName
+					modelType
+					type
+					fieldsMap
+				}
+			}
+		}
+	}`
+)
+
+// Feed types
+const (
+	FeedEvents = "events"
+	FeedAudit  = "audit"
 )
 
+// Timeouts bounds the phases of an API call separately, so a slow multi-MB
+// page is not cut off by the limit meant for a hung connect
+type Timeouts struct {
+	Dial           time.Duration // TCP connect
+	TLSHandshake   time.Duration
+	ResponseHeader time.Duration // From request sent to response headers received
+	BodyRead       time.Duration // Reading the full response body
+}
+
+// Limits guards against pathological responses that could exhaust memory.
+// A zero limit is disabled.
+type Limits struct {
+	MaxResponseBytes int64 // Decompressed response body size
+	MaxPageEvents    int   // Events in a single page
+	WarnOnly         bool  // Log exceeded limits instead of failing the request
+}
+
 // Client handles communication with the Cato Networks API
 type Client struct {
-	apiURL    string
-	apiKey    string
-	accountID string
-	timeout   time.Duration
-	logger    *logging.Logger
+	apiURL     string
+	keys       *apiKeys // Shared by all copies of the client
+	accountID  string
+	timeouts   Timeouts
+	limits     Limits
+	httpClient *http.Client
+	feed       string
+	timeFrame  string
+	query      string
+	filters    []EventFilter
+	capture    *Capture
+	logger     *logging.Logger
 }
 
 // NewClient creates a new API client
-func NewClient(apiURL, apiKey, accountID string, timeout time.Duration, logger *logging.Logger) *Client {
+func NewClient(apiURL, apiKey, accountID string, timeouts Timeouts, logger *logging.Logger) *Client {
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: timeouts.Dial, KeepAlive: 30 * time.Second}).DialContext,
+		TLSHandshakeTimeout:   timeouts.TLSHandshake,
+		ResponseHeaderTimeout: timeouts.ResponseHeader,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConnsPerHost:   4,
+	}
+
 	return &Client{
-		apiURL:    apiURL,
-		apiKey:    apiKey,
-		accountID: accountID,
-		timeout:   timeout,
-		logger:    logger,
+		apiURL:     apiURL,
+		keys:       &apiKeys{current: apiKey},
+		accountID:  accountID,
+		timeouts:   timeouts,
+		httpClient: &http.Client{Transport: transport},
+		feed:       FeedEvents,
+		query:      queryEventsFeed,
+		logger:     logger,
 	}
 }
 
-// FetchEventsPage retrieves a single page of events from the API
-func (c *Client) FetchEventsPage(marker string) (*EventsPage, error) {
+// ForAudit returns a copy of the client that reads the audit trail (auditFeed)
+// over timeFrame (e.g. "last.P1D") instead of eventsFeed
+func (c *Client) ForAudit(timeFrame string, logger *logging.Logger) *Client {
+	audit := *c
+	audit.feed = FeedAudit
+	audit.timeFrame = timeFrame
+	audit.logger = logger
+	return &audit
+}
+
+// WithAccount returns a copy of the client that polls accountID
+func (c *Client) WithAccount(accountID string, logger *logging.Logger) *Client {
+	account := *c
+	account.accountID = accountID
+	account.logger = logger
+	return &account
+}
+
+// WithQuery returns a copy of the client that sends query instead of the
+// built-in eventsFeed query, see LoadQuery
+func (c *Client) WithQuery(query string) *Client {
+	custom := *c
+	custom.query = query
+	return &custom
+}
+
+// WithFilters returns a copy of the client that passes filters to eventsFeed,
+// so the API drops non-matching events before they are sent
+func (c *Client) WithFilters(filters []EventFilter, logger *logging.Logger) *Client {
+	filtered := *c
+	filtered.filters = filters
+	filtered.logger = logger
+	return &filtered
+}
+
+// WithLimits returns a copy of the client that enforces limits on responses
+func (c *Client) WithLimits(limits Limits) *Client {
+	limited := *c
+	limited.limits = limits
+	return &limited
+}
+
+// FetchEventsPage retrieves a single page of records from the client's feed
+func (c *Client) FetchEventsPage(ctx context.Context, marker string) (*EventsPage, error) {
 	reqBody, err := c.buildRequest(marker)
 	if err != nil {
 		return nil, fmt.Errorf("failed to build request: %w", err)
 	}
 
-	httpReq, err := http.NewRequest("POST", c.apiURL, bytes.NewBuffer(reqBody))
+	c.logger.DebugContext(ctx, "sending API request", "url", c.apiURL, "has_marker", marker != "")
+
+	var page *EventsPage
+	err = c.withKeyRotation(ctx, func(apiKey string) error {
+		var latency time.Duration
+		var wireBytes int64
+		var err error
+		if c.feed == FeedAudit {
+			var body []byte
+			body, latency, wireBytes, err = c.post(ctx, apiKey, reqBody)
+			if err == nil {
+				page, err = c.parseAuditFeed(ctx, body)
+			}
+		} else {
+			// eventsFeed pages run to thousands of records; decode as they arrive
+			latency, wireBytes, err = c.exchange(ctx, apiKey, reqBody, func(r io.Reader) error {
+				var err error
+				page, err = c.parseEventsFeed(ctx, r)
+				return err
+			})
+		}
+		if err != nil {
+			return err
+		}
+		page.Latency = latency
+		page.WireBytes = wireBytes
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
 	}
 
-	// Set required headers
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("x-api-key", c.apiKey)
-	httpReq.Header.Set("User-Agent", "Cato-CEF-Forwarder/3.2")
+	if max := c.limits.MaxPageEvents; max > 0 && len(page.Events) > max {
+		if !c.limits.WarnOnly {
+			return nil, &ResponseLimitError{Limit: "page events", Max: int64(max)}
+		}
+		c.logger.WarnContext(ctx, "API page exceeds event limit", "event_count", len(page.Events), "max_events_per_page", max)
+	}
+
+	c.logger.DebugContext(ctx, "parsed API response",
+		"event_count", len(page.Events),
+		"has_more", page.HasMore,
+		"new_marker", page.NewMarker != "")
+
+	return page, nil
+}
+
+// post sends a GraphQL request body with apiKey and returns the decoded
+// response body of a 200 response, the latency, and the bytes received
+func (c *Client) post(ctx context.Context, apiKey string, reqBody []byte) ([]byte, time.Duration, int64, error) {
+	var body []byte
+	latency, wireBytes, err := c.exchange(ctx, apiKey, reqBody, func(r io.Reader) error {
+		var err error
+		if body, err = io.ReadAll(r); err != nil {
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return body, latency, wireBytes, nil
+}
 
-	client := &http.Client{Timeout: c.timeout}
+// exchange sends a GraphQL request body with apiKey and hands the decoded body
+// of a 200 response to decode as a stream. Error responses, and every
+// response while debug capture is on, are buffered first. Errors returned by
+// decode are passed through unwrapped. It returns the latency and the bytes
+// received on the wire.
+func (c *Client) exchange(ctx context.Context, apiKey string, reqBody []byte, decode func(io.Reader) error) (time.Duration, int64, error) {
+	// Cancelled when the body read deadline passes, see below
+	reqCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	c.logger.Debug("sending API request", "url", c.apiURL, "has_marker", marker != "")
+	httpReq, err := http.NewRequestWithContext(reqCtx, "POST", c.apiURL, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to create request: %w", err)
+	}
 
-	resp, err := client.Do(httpReq)
+	// Set required headers
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", apiKey)
+	SetHeaders(httpReq.Header, "api")
+	// Large pages compress ~10x; set explicitly so the body is decoded here
+	// and wire size can be logged
+	httpReq.Header.Set("Accept-Encoding", "gzip")
+
+	requestStart := time.Now()
+	resp, err := c.httpClient.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("HTTP request failed: %w", err)
+		c.captureCall(ctx, apiKey, httpReq, reqBody, nil, nil, err)
+		return 0, 0, fmt.Errorf("HTTP request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	var bodyDeadline *time.Timer
+	if c.timeouts.BodyRead > 0 {
+		bodyDeadline = time.AfterFunc(c.timeouts.BodyRead, cancel)
+	}
+	// In warn-only mode the body is read in full and checked afterwards
+	maxBytes := c.limits.MaxResponseBytes
+	if c.limits.WarnOnly {
+		maxBytes = 0
+	}
+	body, err := newResponseBody(resp, maxBytes)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return 0, 0, fmt.Errorf("failed to read response: %w", err)
 	}
+	defer body.Close()
 
-	c.logger.Debug("received API response", "status", resp.StatusCode, "body_size", len(body))
+	buffered := resp.StatusCode != http.StatusOK || c.capture != nil
+	var data []byte
+	if buffered {
+		data, err = io.ReadAll(body)
+		c.captureCall(ctx, apiKey, httpReq, reqBody, resp, data, err)
+		if err != nil {
+			err = fmt.Errorf("failed to read response: %w", err)
+		}
+	} else if err = decode(body); err == nil {
+		// Drain trailing whitespace so the connection can be reused
+		_, err = io.Copy(io.Discard, body)
+	}
+	if bodyDeadline != nil && !bodyDeadline.Stop() && err != nil {
+		err = fmt.Errorf("body read exceeded %s after %d bytes: %w", c.timeouts.BodyRead, body.wire.n, err)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	latency := time.Since(requestStart)
+
+	if max := c.limits.MaxResponseBytes; c.limits.WarnOnly && max > 0 && body.decoded.n > max {
+		c.logger.WarnContext(ctx, "API response exceeds size limit", "body_size", body.decoded.n, "max_bytes", max)
+	}
+
+	c.logger.DebugContext(ctx, "received API response",
+		"status", resp.StatusCode,
+		"body_size", body.decoded.n,
+		"wire_size", body.wire.n,
+		"streamed", !buffered,
+		"content_encoding", resp.Header.Get("Content-Encoding"))
 
 	// Handle HTTP errors
 	if resp.StatusCode != http.StatusOK {
-		return nil, c.handleHTTPError(resp.StatusCode, body)
+		return 0, 0, c.handleHTTPError(ctx, resp.StatusCode, data, resp.Header.Get("Retry-After"))
 	}
 
-	var response EventsFeedResponse
-	if err := json.Unmarshal(body, &response); err != nil {
+	if buffered {
+		if err := decode(bytes.NewReader(data)); err != nil {
+			return 0, 0, err
+		}
+	}
+	return latency, body.wire.n, nil
+}
+
+// responseBody reads a response body, decompressing gzip-encoded responses and
+// failing with a ResponseLimitError once the decompressed body passes maxBytes
+// (if non-zero). It counts bytes received on the wire and after decompression.
+type responseBody struct {
+	wire     countingReader
+	decoded  countingReader
+	gz       *gzip.Reader
+	maxBytes int64
+}
+
+func newResponseBody(resp *http.Response, maxBytes int64) (*responseBody, error) {
+	b := &responseBody{maxBytes: maxBytes}
+	b.wire.r = resp.Body
+	b.decoded.r = &b.wire
+
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(&b.wire)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip response: %w", err)
+		}
+		b.gz = gz
+		b.decoded.r = gz
+	}
+	return b, nil
+}
+
+func (b *responseBody) Read(p []byte) (int, error) {
+	n, err := b.decoded.Read(p)
+	if b.maxBytes > 0 && b.decoded.n > b.maxBytes {
+		return n, &ResponseLimitError{Limit: "response size", Max: b.maxBytes}
+	}
+	if err != nil && err != io.EOF && b.gz != nil {
+		err = fmt.Errorf("invalid gzip response: %w", err)
+	}
+	return n, err
+}
+
+// Close releases the gzip reader; the HTTP body is closed by the caller
+func (b *responseBody) Close() error {
+	if b.gz != nil {
+		return b.gz.Close()
+	}
+	return nil
+}
+
+// countingReader counts bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// captureCall records the call when debug capture is enabled
+func (c *Client) captureCall(ctx context.Context, apiKey string, req *http.Request, reqBody []byte, resp *http.Response, respBody []byte, callErr error) {
+	if c.capture == nil {
+		return
+	}
+	if err := c.capture.record(c.feed, apiKey, req, reqBody, resp, respBody, callErr); err != nil {
+		c.logger.WarnContext(ctx, "API debug capture failed", "error", err.Error())
+	}
+}
+
+// parseEventsFeed decodes an eventsFeed response stream into a page
+func (c *Client) parseEventsFeed(ctx context.Context, r io.Reader) (*EventsPage, error) {
+	response, err := decodeEventsFeed(r)
+	if err != nil {
 		return nil, fmt.Errorf("failed to parse JSON response: %w", err)
 	}
 
 	// Handle GraphQL errors
 	if len(response.Errors) > 0 {
-		c.logger.Error("GraphQL error received", "error", response.Errors[0].Message)
-		return nil, fmt.Errorf("GraphQL error: %s", response.Errors[0].Message)
+		err := classifyGraphQLError(response.Errors, c.accountID)
+		c.logger.ErrorContext(ctx, "GraphQL error received", "error", err.Error(), "error_class", ErrorClass(err))
+		return nil, err
 	}
 
 	// Extract events and marker
-	events := c.extractEvents(&response)
+	events, err := c.extractEvents(ctx, response.Accounts)
+	if err != nil {
+		return nil, err
+	}
 	page := &EventsPage{
 		Events: events,
 	}
 
-	if response.Data.EventsFeed.Marker != nil {
-		page.NewMarker = *response.Data.EventsFeed.Marker
+	if response.Marker != nil {
+		page.NewMarker = *response.Marker
 		// If we got a new marker and events, there might be more data
 		page.HasMore = len(events) > 0 && page.NewMarker != ""
 	} else {
 		page.HasMore = false
 	}
 
-	c.logger.Debug("parsed API response",
-		"event_count", len(page.Events),
-		"has_more", page.HasMore,
-		"new_marker", page.NewMarker != "")
+	return page, nil
+}
+
+// parseAuditFeed decodes an auditFeed response into a page of flattened records
+func (c *Client) parseAuditFeed(ctx context.Context, body []byte) (*EventsPage, error) {
+	var response AuditFeedResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON response: %w", err)
+	}
+
+	if len(response.Errors) > 0 {
+		err := classifyGraphQLError(response.Errors, c.accountID)
+		c.logger.ErrorContext(ctx, "GraphQL error received", "error", err.Error(), "error_class", ErrorClass(err))
+		return nil, err
+	}
+
+	feed := response.Data.AuditFeed
+	page := &EventsPage{
+		HasMore: feed.HasMore,
+	}
+	if feed.Marker != nil {
+		page.NewMarker = *feed.Marker
+	}
+
+	for _, account := range feed.Accounts {
+		for _, record := range account.Records {
+			page.Events = append(page.Events, record.flatten(account.ID))
+		}
+	}
 
 	return page, nil
 }
@@ -128,43 +457,58 @@ func (c *Client) buildRequest(marker string) ([]byte, error) {
 		variables["marker"] = marker
 	}
 
+	query := c.query
+	if c.feed == FeedAudit {
+		query = queryAuditFeed
+		variables["timeFrame"] = c.timeFrame
+	} else if len(c.filters) > 0 {
+		variables["filters"] = c.filters
+	}
+
 	req := Request{
-		Query:     queryEventsFeed,
+		Query:     query,
 		Variables: variables,
 	}
 
 	return json.Marshal(req)
 }
 
-// extractEvents extracts event records from all accounts in the response
-func (c *Client) extractEvents(response *EventsFeedResponse) []map[string]string {
+// extractEvents collects the events of all accounts in the response
+func (c *Client) extractEvents(ctx context.Context, accounts []eventsFeedAccount) ([]map[string]string, error) {
 	var allRecords []map[string]string
+	var accountErr *AccountError
+	failedAccounts := 0
 
-	for _, account := range response.Data.EventsFeed.Accounts {
+	for _, account := range accounts {
 		if account.ErrorString != "" {
-			c.logger.Warn("account error in response", "account_id", account.ID, "error", account.ErrorString)
+			c.logger.WarnContext(ctx, "account error in response", "account_id", account.ID, "error", account.ErrorString)
+			accountErr = &AccountError{AccountID: account.ID, Message: account.ErrorString}
+			failedAccounts++
 			continue
 		}
 
-		for _, record := range account.Records {
-			allRecords = append(allRecords, record.FieldsMap)
-		}
+		allRecords = append(allRecords, account.Events...)
+	}
+
+	// Only fail the page when no account returned data
+	if accountErr != nil && failedAccounts == len(accounts) {
+		return nil, accountErr
 	}
 
-	return allRecords
+	return allRecords, nil
 }
 
 // handleHTTPError provides detailed error messages for different HTTP status codes
-func (c *Client) handleHTTPError(statusCode int, body []byte) error {
-	c.logger.Error("API HTTP error", "status", statusCode, "body", string(body))
+func (c *Client) handleHTTPError(ctx context.Context, statusCode int, body []byte, retryAfter string) error {
+	c.logger.ErrorContext(ctx, "API HTTP error", "status", statusCode, "body", string(body))
 
 	switch statusCode {
 	case 401:
-		return fmt.Errorf("authentication failed (401) - check your API key")
+		return &AuthError{Code: "HTTP 401", Message: "check your API key"}
 	case 403:
-		return fmt.Errorf("access forbidden (403) - ensure Events Integration is enabled and API key has eventsFeed permissions")
+		return &AuthError{Code: "HTTP 403", Message: "ensure Events Integration is enabled and API key has eventsFeed permissions"}
 	case 429:
-		return fmt.Errorf("rate limit exceeded (429) - reduce polling frequency or maxEvents")
+		return &RateLimitError{Message: "HTTP 429 - reduce polling frequency or maxEvents", RetryAfter: retryAfterHeader(retryAfter)}
 	case 500, 502, 503, 504:
 		return fmt.Errorf("server error (%d) - Cato API experiencing issues", statusCode)
 	default:
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AuthError reports a rejected or under-privileged API key. Retrying does not help.
+type AuthError struct {
+	Code    string
+	Message string
+}
+
+func (e *AuthError) Error() string {
+	return fmt.Sprintf("authentication failed (%s): %s", e.Code, e.Message)
+}
+
+// RateLimitError reports that the API throttled the request. RetryAfter is
+// the wait the API asked for, zero when it gave none.
+type RateLimitError struct {
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("rate limit exceeded, retry after %s: %s", e.RetryAfter, e.Message)
+	}
+	return fmt.Sprintf("rate limit exceeded: %s", e.Message)
+}
+
+// SchemaError reports a query the API rejected as invalid, e.g. after an API
+// schema change or a bad custom query file. Retrying does not help.
+type SchemaError struct {
+	Code    string
+	Message string
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("query rejected by API (%s): %s", e.Code, e.Message)
+}
+
+// AccountError reports a problem with a specific account, such as an unknown
+// account ID or a disabled events integration
+type AccountError struct {
+	AccountID string
+	Message   string
+}
+
+func (e *AccountError) Error() string {
+	return fmt.Sprintf("account %s: %s", e.AccountID, e.Message)
+}
+
+// ResponseLimitError reports a response beyond a configured guardrail, see
+// Limits. The API returns the same page on retry, so retrying does not help.
+type ResponseLimitError struct {
+	Limit string // "response size" or "page events"
+	Max   int64
+}
+
+func (e *ResponseLimitError) Error() string {
+	return fmt.Sprintf("response exceeds %s limit of %d", e.Limit, e.Max)
+}
+
+// Error classes, as reported by ErrorClass
+const (
+	ClassAuth      = "auth"
+	ClassRateLimit = "rate_limit"
+	ClassSchema    = "schema"
+	ClassAccount   = "account"
+	ClassLimit     = "response_limit"
+	ClassOther     = "other"
+)
+
+// ErrorClass returns the class of a (possibly wrapped) API error for logging
+func ErrorClass(err error) string {
+	var authErr *AuthError
+	var rateErr *RateLimitError
+	var schemaErr *SchemaError
+	var accountErr *AccountError
+	var limitErr *ResponseLimitError
+	switch {
+	case errors.As(err, &authErr):
+		return ClassAuth
+	case errors.As(err, &rateErr):
+		return ClassRateLimit
+	case errors.As(err, &schemaErr):
+		return ClassSchema
+	case errors.As(err, &accountErr):
+		return ClassAccount
+	case errors.As(err, &limitErr):
+		return ClassLimit
+	default:
+		return ClassOther
+	}
+}
+
+// Permanent reports whether retrying the same request cannot succeed
+func Permanent(err error) bool {
+	switch ErrorClass(err) {
+	case ClassAuth, ClassSchema, ClassLimit:
+		return true
+	}
+	return false
+}
+
+// classifyGraphQLError turns the first GraphQL error into a typed error using
+// its extensions block, falling back to a plain error for unknown codes
+func classifyGraphQLError(errs []GraphQLError, accountID string) error {
+	gqlErr := errs[0]
+	code, _ := gqlErr.Extensions["code"].(string)
+
+	switch strings.ToUpper(code) {
+	case "UNAUTHENTICATED", "UNAUTHORIZED", "FORBIDDEN", "PERMISSION_DENIED":
+		return &AuthError{Code: code, Message: gqlErr.Message}
+	case "RATE_LIMITED", "RATE_LIMIT_EXCEEDED", "TOO_MANY_REQUESTS", "THROTTLED":
+		return &RateLimitError{Message: gqlErr.Message, RetryAfter: extensionDuration(gqlErr.Extensions)}
+	case "GRAPHQL_VALIDATION_FAILED", "GRAPHQL_PARSE_FAILED", "BAD_USER_INPUT", "VALIDATION_ERROR":
+		return &SchemaError{Code: code, Message: gqlErr.Message}
+	case "ACCOUNT_NOT_FOUND", "ACCOUNT_DISABLED", "INVALID_ACCOUNT":
+		return &AccountError{AccountID: accountID, Message: gqlErr.Message}
+	}
+
+	if code != "" {
+		return fmt.Errorf("GraphQL error (%s): %s", code, gqlErr.Message)
+	}
+	return fmt.Errorf("GraphQL error: %s", gqlErr.Message)
+}
+
+// extensionDuration reads a retry hint (seconds) from GraphQL error extensions
+func extensionDuration(extensions map[string]interface{}) time.Duration {
+	for _, key := range []string{"retryAfter", "retry_after", "retryAfterSeconds"} {
+		switch v := extensions[key].(type) {
+		case float64:
+			return time.Duration(v * float64(time.Second))
+		case string:
+			if seconds, err := strconv.ParseFloat(v, 64); err == nil {
+				return time.Duration(seconds * float64(time.Second))
+			}
+		}
+	}
+	return 0
+}
+
+// retryAfterHeader parses an HTTP Retry-After header given in seconds or as a date
+func retryAfterHeader(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := time.Parse(time.RFC1123, value); err == nil {
+		if wait := time.Until(at); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
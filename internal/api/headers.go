@@ -0,0 +1,49 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+	"strings"
+)
+
+// productName starts the User-Agent of every request the forwarder sends
+const productName = "Cato-CEF-Forwarder"
+
+// Identity describes this forwarder in the User-Agent and telemetry headers
+type Identity struct {
+	Version      string
+	DeploymentID string // Optional, tells deployments apart in Cato's API logs
+}
+
+// identity is set once at startup, before any request is sent
+var identity = Identity{Version: "dev"}
+
+// SetIdentity sets the version and deployment ID sent with every request.
+// Call it at startup, before any request is sent.
+func SetIdentity(id Identity) {
+	if id.Version == "" {
+		id.Version = "dev"
+	}
+	identity = id
+}
+
+// UserAgent returns the User-Agent header value, e.g.
+// "Cato-CEF-Forwarder/3.2 (linux; amd64; deployment=eu-1)"
+func UserAgent() string {
+	details := []string{runtime.GOOS, runtime.GOARCH}
+	if identity.DeploymentID != "" {
+		details = append(details, "deployment="+identity.DeploymentID)
+	}
+	return fmt.Sprintf("%s/%s (%s)", productName, identity.Version, strings.Join(details, "; "))
+}
+
+// SetHeaders sets the identification headers on a request to the Cato API.
+// component names the sender, e.g. "api" or "preflight".
+func SetHeaders(header http.Header, component string) {
+	header.Set("User-Agent", UserAgent())
+	header.Set("X-Client-Component", component)
+	if identity.DeploymentID != "" {
+		header.Set("X-Deployment-ID", identity.DeploymentID)
+	}
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"cato-logger/internal/logging"
+)
+
+// keyRefreshInterval limits how often a rejected key triggers a re-read of
+// the key source, so a revoked key does not hammer a secret store
+const keyRefreshInterval = time.Minute
+
+// KeySource re-reads the API keys, e.g. from the config file and its secret
+// references. It returns the current key and the optional next key.
+type KeySource func(ctx context.Context) (current, next string, err error)
+
+// apiKeys holds the API keys shared by every copy of a client, so a rotation
+// applies to all feeds and accounts at once
+type apiKeys struct {
+	mu          sync.Mutex
+	current     string
+	next        string // Tried when current is rejected
+	rejected    string // Last key the API rejected
+	source      KeySource
+	lastRefresh time.Time
+}
+
+// get returns the key to send with the next request
+func (k *apiKeys) get() string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.current
+}
+
+// SetAPIKeys replaces the API keys of the client and all its copies. A current
+// key that was already rejected is skipped in favor of next.
+func (c *Client) SetAPIKeys(current, next string) {
+	k := c.keys
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if current == k.rejected && next != "" {
+		current, next = next, ""
+	}
+	k.current = current
+	k.next = next
+}
+
+// SetKeySource sets where the client re-reads its keys when the API rejects
+// the current key and no next key is configured
+func (c *Client) SetKeySource(source KeySource) {
+	c.keys.mu.Lock()
+	defer c.keys.mu.Unlock()
+	c.keys.source = source
+}
+
+// rotate switches away from a rejected key, to the next key or to a key
+// re-read from the source. It reports whether a different key is now current.
+func (k *apiKeys) rotate(ctx context.Context, rejected string, logger *logging.Logger) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	// Another feed already rotated away from this key
+	if k.current != rejected {
+		return true
+	}
+	k.rejected = rejected
+
+	if k.next != "" && k.next != rejected {
+		k.current, k.next = k.next, ""
+		logger.WarnContext(ctx, "API key rejected, switched to next API key")
+		return true
+	}
+
+	if k.source == nil || time.Since(k.lastRefresh) < keyRefreshInterval {
+		return false
+	}
+	k.lastRefresh = time.Now()
+
+	current, next, err := k.source(ctx)
+	if err != nil {
+		logger.ErrorContext(ctx, "API key rejected, failed to re-read API key", "error", err.Error())
+		return false
+	}
+	switch {
+	case current != "" && current != rejected:
+		k.current, k.next = current, next
+	case next != "" && next != rejected:
+		k.current, k.next = next, ""
+	default:
+		logger.WarnContext(ctx, "API key rejected, re-read API key is unchanged")
+		return false
+	}
+
+	logger.WarnContext(ctx, "API key rejected, switched to re-read API key")
+	return true
+}
+
+// withKeyRotation runs call with the current API key and, if the key is
+// rejected and a replacement is available, once more with the new key
+func (c *Client) withKeyRotation(ctx context.Context, call func(apiKey string) error) error {
+	apiKey := c.keys.get()
+	err := call(apiKey)
+
+	var authErr *AuthError
+	if errors.As(err, &authErr) && c.keys.rotate(ctx, apiKey, c.logger) {
+		err = call(c.keys.get())
+	}
+	return err
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// LoadQuery reads a custom eventsFeed query from path and checks that it
+// still provides what the forwarder relies on
+func LoadQuery(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read query file: %w", err)
+	}
+
+	query := strings.TrimSpace(string(data))
+	if err := ValidateQuery(query); err != nil {
+		return "", fmt.Errorf("invalid query file %s: %w", path, err)
+	}
+	return query, nil
+}
+
+// ValidateQuery checks a custom eventsFeed query: it must be a query operation
+// calling eventsFeed with the account and marker variables and select the
+// marker and fieldsMap of each record
+func ValidateQuery(query string) error {
+	if !strings.HasPrefix(query, "query") {
+		return fmt.Errorf("must be a GraphQL query operation starting with 'query'")
+	}
+	if strings.Count(query, "{") != strings.Count(query, "}") {
+		return fmt.Errorf("unbalanced braces")
+	}
+
+	required := []struct{ token, why string }{
+		{"eventsFeed", "must call eventsFeed"},
+		{"$accountIDs", "must declare and pass $accountIDs"},
+		{"$marker", "must declare and pass $marker"},
+		{"marker", "must select marker"},
+		{"accounts", "must select accounts"},
+		{"records", "must select records"},
+		{"fieldsMap", "must select fieldsMap on records"},
+	}
+	for _, r := range required {
+		if !strings.Contains(query, r.token) {
+			return fmt.Errorf("%s", r.why)
+		}
+	}
+	return nil
+}
@@ -1,35 +1,56 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"time"
 )
 
-// FetchWithRetry attempts to fetch events with retry logic
-func (c *Client) FetchWithRetry(marker string, maxAttempts int, retryDelay time.Duration) (*EventsPage, error) {
+// FetchWithRetry attempts to fetch events with retry logic. Auth and schema
+// errors are returned without retrying; rate limits wait at least as long as
+// the API asked. A request is always attempted once, even when maxAttempts
+// is 0 to disable retries.
+func (c *Client) FetchWithRetry(ctx context.Context, marker string, maxAttempts int, retryDelay time.Duration) (*EventsPage, error) {
 	var lastErr error
+	maxAttempts = max(maxAttempts, 1)
 
 	for attempt := 0; attempt < maxAttempts; attempt++ {
 		if attempt > 0 {
-			c.logger.Info("retrying API request",
+			delay := retryDelay
+			var rateErr *RateLimitError
+			if errors.As(lastErr, &rateErr) && rateErr.RetryAfter > delay {
+				delay = rateErr.RetryAfter
+			}
+
+			c.logger.InfoContext(ctx, "retrying API request",
 				"attempt", attempt+1,
 				"max_attempts", maxAttempts,
-				"delay", retryDelay.String())
-			time.Sleep(retryDelay)
+				"delay", delay.String())
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(delay):
+			}
 		}
 
-		page, err := c.FetchEventsPage(marker)
+		page, err := c.FetchEventsPage(ctx, marker)
 		if err == nil {
 			if attempt > 0 {
-				c.logger.Info("API request recovered", "retries", attempt)
+				c.logger.InfoContext(ctx, "API request recovered", "retries", attempt)
 			}
 			return page, nil
 		}
 
 		lastErr = err
-		c.logger.Warn("API request failed",
+		c.logger.WarnContext(ctx, "API request failed",
 			"attempt", attempt+1,
-			"error", err.Error())
+			"error", err.Error(),
+			"error_class", ErrorClass(err))
+
+		if Permanent(err) {
+			return nil, fmt.Errorf("not retrying: %w", err)
+		}
 	}
 
 	return nil, fmt.Errorf("all %d retry attempts failed, last error: %w", maxAttempts, lastErr)
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// eventsFeedResult is a decoded eventsFeed response with records already
+// flattened to events
+type eventsFeedResult struct {
+	Marker   *string
+	Accounts []eventsFeedAccount
+	Errors   []GraphQLError
+}
+
+// eventsFeedAccount is one account entry of an eventsFeed response
+type eventsFeedAccount struct {
+	ID          string
+	ErrorString string
+	Events      []map[string]string
+}
+
+// decodeEventsFeed decodes an eventsFeed response as it is read. Each record
+// is flattened as soon as it is decoded, so neither the raw body nor the
+// decoded records are held in memory next to the events.
+func decodeEventsFeed(r io.Reader) (*eventsFeedResult, error) {
+	d := &streamDecoder{dec: json.NewDecoder(r)}
+	result := &eventsFeedResult{}
+
+	err := d.object(func(key string) error {
+		switch key {
+		case "errors":
+			return d.dec.Decode(&result.Errors)
+		case "data":
+			return d.object(func(key string) error {
+				if key != "eventsFeed" {
+					return d.skip()
+				}
+				return d.object(func(key string) error {
+					switch key {
+					case "marker":
+						return d.dec.Decode(&result.Marker)
+					case "accounts":
+						return d.array(func() error {
+							account, err := d.account()
+							if err != nil {
+								return err
+							}
+							result.Accounts = append(result.Accounts, account)
+							return nil
+						})
+					}
+					return d.skip()
+				})
+			})
+		}
+		return d.skip()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// streamDecoder walks a JSON document token by token
+type streamDecoder struct {
+	dec *json.Decoder
+}
+
+// account decodes one eventsFeed account entry
+func (d *streamDecoder) account() (eventsFeedAccount, error) {
+	var account eventsFeedAccount
+	err := d.object(func(key string) error {
+		switch key {
+		case "id":
+			return d.dec.Decode(&account.ID)
+		case "errorString":
+			return d.dec.Decode(&account.ErrorString)
+		case "records":
+			return d.array(func() error {
+				var record EventRecord
+				if err := d.dec.Decode(&record); err != nil {
+					return err
+				}
+				account.Events = append(account.Events, record.Fields())
+				return nil
+			})
+		}
+		return d.skip()
+	})
+	return account, err
+}
+
+// object calls field for every key of the object at the current position,
+// which must consume the key's value. null counts as an empty object.
+func (d *streamDecoder) object(field func(key string) error) error {
+	if ok, err := d.open('{'); !ok || err != nil {
+		return err
+	}
+	for d.dec.More() {
+		tok, err := d.dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := tok.(string)
+		if err := field(key); err != nil {
+			return err
+		}
+	}
+	_, err := d.dec.Token()
+	return err
+}
+
+// array calls elem for every element of the array at the current position,
+// which must consume the element. null counts as an empty array.
+func (d *streamDecoder) array(elem func() error) error {
+	if ok, err := d.open('['); !ok || err != nil {
+		return err
+	}
+	for d.dec.More() {
+		if err := elem(); err != nil {
+			return err
+		}
+	}
+	_, err := d.dec.Token()
+	return err
+}
+
+// open reads the opening delimiter of an object or array. It reports false
+// without error for null.
+func (d *streamDecoder) open(delim json.Delim) (bool, error) {
+	tok, err := d.dec.Token()
+	if err != nil {
+		return false, err
+	}
+	if tok == nil {
+		return false, nil
+	}
+	if got, ok := tok.(json.Delim); !ok || got != delim {
+		return false, fmt.Errorf("expected %q at offset %d, got %v", delim, d.dec.InputOffset(), tok)
+	}
+	return true, nil
+}
+
+// skip discards the value at the current position
+func (d *streamDecoder) skip() error {
+	var discard json.RawMessage
+	return d.dec.Decode(&discard)
+}
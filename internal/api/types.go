@@ -1,35 +1,154 @@
 package api
 
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
 // Request represents a GraphQL API request
 type Request struct {
 	Query     string                 `json:"query"`
 	Variables map[string]interface{} `json:"variables,omitempty"`
 }
 
-// EventsFeedResponse represents the API response structure
-type EventsFeedResponse struct {
+// EventFilter is an eventsFeed field filter (EventFeedFieldFilterInput)
+type EventFilter struct {
+	FieldName string   `json:"fieldName"`
+	Operator  string   `json:"operator"`
+	Values    []string `json:"values"`
+}
+
+// EventRecord is one eventsFeed record. Fields requested next to fieldsMap
+// by a custom query are kept in Extra.
+type EventRecord struct {
+	FieldsMap map[string]string
+	Extra     map[string]string
+}
+
+// UnmarshalJSON decodes fieldsMap and renders any other record fields as strings
+func (r *EventRecord) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	for key, value := range raw {
+		if key == "fieldsMap" {
+			if err := json.Unmarshal(value, &r.FieldsMap); err != nil {
+				return fmt.Errorf("invalid fieldsMap: %w", err)
+			}
+			continue
+		}
+
+		if r.Extra == nil {
+			r.Extra = make(map[string]string)
+		}
+		var str string
+		if err := json.Unmarshal(value, &str); err == nil {
+			r.Extra[key] = str
+		} else if string(value) != "null" {
+			var compact bytes.Buffer
+			if err := json.Compact(&compact, value); err != nil {
+				return err
+			}
+			r.Extra[key] = compact.String()
+		}
+	}
+	return nil
+}
+
+// Fields returns the record as a flat field map. fieldsMap wins over extra
+// fields of the same name.
+func (r EventRecord) Fields() map[string]string {
+	if len(r.Extra) == 0 {
+		return r.FieldsMap
+	}
+	fields := make(map[string]string, len(r.FieldsMap)+len(r.Extra))
+	for k, v := range r.Extra {
+		fields[k] = v
+	}
+	for k, v := range r.FieldsMap {
+		fields[k] = v
+	}
+	return fields
+}
+
+// GraphQLError is one entry of a GraphQL errors array
+type GraphQLError struct {
+	Message   string `json:"message"`
+	Locations []struct {
+		Line   int `json:"line"`
+		Column int `json:"column"`
+	} `json:"locations,omitempty"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// AuditFeedResponse represents the auditFeed API response structure
+type AuditFeedResponse struct {
 	Data struct {
-		EventsFeed struct {
+		AuditFeed struct {
 			Marker       *string `json:"marker"`
 			FetchedCount int     `json:"fetchedCount"`
+			HasMore      bool    `json:"hasMore"`
 			Accounts     []struct {
-				ID          string `json:"id"`
-				ErrorString string `json:"errorString"`
-				Records     []struct {
-					FieldsMap map[string]string `json:"fieldsMap"`
-				} `json:"records"`
+				ID      string        `json:"id"`
+				Records []AuditRecord `json:"records"`
 			} `json:"accounts"`
-		} `json:"eventsFeed"`
+		} `json:"auditFeed"`
 	} `json:"data"`
-	Errors []struct {
-		Message   string `json:"message"`
-		Locations []struct {
-			Line   int `json:"line"`
-			Column int `json:"column"`
-		} `json:"locations,omitempty"`
-		Path       []string               `json:"path,omitempty"`
-		Extensions map[string]interface{} `json:"extensions,omitempty"`
-	} `json:"errors,omitempty"`
+	Errors []GraphQLError `json:"errors,omitempty"`
+}
+
+// AuditEntity is an admin, API key, or object referenced by an audit record
+type AuditEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// AuditRecord is one admin action from the audit trail
+type AuditRecord struct {
+	Admin     *AuditEntity      `json:"admin"`
+	APIKey    *AuditEntity      `json:"apiKey"`
+	Object    *AuditEntity      `json:"object"`
+	Time      string            `json:"time"`
+	ModelName string            `json:"modelName"`
+	ModelType string            `json:"modelType"`
+	Type      string            `json:"type"`
+	FieldsMap map[string]string `json:"fieldsMap"`
+}
+
+// flatten converts an audit record to the flat field map used by the pipeline.
+// event_type is "Audit" and event_sub_type the change type, so CEF headers read
+// e.g. "Audit - MODIFY".
+func (r AuditRecord) flatten(accountID string) map[string]string {
+	fields := make(map[string]string, len(r.FieldsMap)+12)
+	for k, v := range r.FieldsMap {
+		fields[k] = v
+	}
+
+	fields["event_type"] = "Audit"
+	fields["event_sub_type"] = r.Type
+	fields["change_type"] = r.Type
+	fields["account_id"] = accountID
+	fields["time"] = r.Time
+	fields["model_name"] = r.ModelName
+	fields["model_type"] = r.ModelType
+	if r.Admin != nil {
+		fields["admin"] = r.Admin.Name
+		fields["admin_id"] = r.Admin.ID
+	}
+	if r.APIKey != nil {
+		fields["api_key_name"] = r.APIKey.Name
+		fields["api_key_id"] = r.APIKey.ID
+	}
+	if r.Object != nil {
+		fields["object_name"] = r.Object.Name
+		fields["object_id"] = r.Object.ID
+	}
+	return fields
 }
 
 // EventsPage represents a page of events from the API
@@ -37,4 +156,6 @@ type EventsPage struct {
 	Events    []map[string]string
 	NewMarker string
 	HasMore   bool
+	Latency   time.Duration // Duration of the HTTP request that produced this page
+	WireBytes int64         // Response bytes received for this page, before decompression
 }
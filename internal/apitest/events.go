@@ -0,0 +1,90 @@
+package apitest
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// eventKind is one event type the generator produces, with its share of
+// every 20 events
+type eventKind struct {
+	eventType string
+	subType   string
+	action    string
+	share     int
+}
+
+// eventMix resembles the volume of a typical account: mostly connectivity
+// and internet firewall events, some WAN firewall, few IPS and remote users
+var eventMix = []eventKind{
+	{"Connectivity", "Connected", "", 8},
+	{"Security", "Internet Firewall", "Allow", 5},
+	{"Security", "Internet Firewall", "Block", 2},
+	{"Security", "WAN Firewall", "Allow", 2},
+	{"Security", "IPS", "Block", 1},
+	{"Connectivity", "Disconnected", "", 2},
+}
+
+// kinds expands eventMix to one entry per event of a cycle of 20
+var kinds = func() []eventKind {
+	var expanded []eventKind
+	for _, kind := range eventMix {
+		for i := 0; i < kind.share; i++ {
+			expanded = append(expanded, kind)
+		}
+	}
+	return expanded
+}()
+
+var (
+	sites        = []string{"HQ", "London", "Tel-Aviv", "Singapore", "Remote Users"}
+	applications = []string{"HTTPS", "Microsoft 365", "Salesforce", "Zoom", "DNS", "SSH", "Slack"}
+	rules        = []string{"Allow corporate SaaS", "Block gambling", "Default allow", "Block P2P", "Guest internet"}
+	ports        = []string{"443", "443", "443", "80", "53", "22", "3389"}
+	users        = []string{"alice@example.com", "bob@example.com", "carol@example.com", "dave@example.com"}
+)
+
+// Event returns the i-th generated event of an account. The same i always
+// yields the same event, so a marker replays the same events.
+func Event(accountID string, i int64, at time.Time) map[string]string {
+	kind := kinds[i%int64(len(kinds))]
+	// Mix the index so neighbouring events differ in more than one field
+	h := uint64(i)*0x9E3779B97F4A7C15 + 1
+	pick := func(n int) int {
+		h ^= h >> 29
+		h *= 0xBF58476D1CE4E5B9
+		return int(h % uint64(n))
+	}
+
+	fields := map[string]string{
+		"event_type":     kind.eventType,
+		"event_sub_type": kind.subType,
+		"time":           at.UTC().Format(time.RFC3339Nano),
+		"internalId":     fmt.Sprintf("%s-%d", accountID, i),
+		"account_id":     accountID,
+		"src_site_name":  sites[pick(len(sites))],
+		"src_ip":         fmt.Sprintf("10.%d.%d.%d", pick(8), pick(256), 1+pick(254)),
+		"dest_ip":        fmt.Sprintf("%d.%d.%d.%d", 20+pick(200), pick(256), pick(256), 1+pick(254)),
+		"dest_port":      ports[pick(len(ports))],
+		"ip_protocol":    "TCP",
+		"application":    applications[pick(len(applications))],
+		"user_name":      users[pick(len(users))],
+		"os_type":        "OS_WINDOWS",
+	}
+	if kind.action != "" {
+		fields["action"] = kind.action
+		fields["rule"] = rules[pick(len(rules))]
+		fields["bytes_upload"] = strconv.Itoa(200 + pick(50000))
+		fields["bytes_download"] = strconv.Itoa(500 + pick(500000))
+	}
+	if kind.subType == "IPS" {
+		fields["threat_name"] = "Suspicious outbound connection"
+		fields["signature_id"] = strconv.Itoa(100000 + pick(900000))
+	}
+	if kind.eventType == "Connectivity" {
+		fields["tunnel_protocol"] = "DTLS"
+		fields["link_type"] = "Cato"
+	}
+	return fields
+}
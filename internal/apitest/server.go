@@ -0,0 +1,221 @@
+package apitest
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// markerPrefix starts every marker the mock hands out; the rest is the
+// offset of the next event
+const markerPrefix = "mock:"
+
+// backlogSpacing is the time between generated backlog events
+const backlogSpacing = 10 * time.Millisecond
+
+// Options configure a mock API
+type Options struct {
+	APIKey         string              // Key the x-api-key header must carry, empty accepts any
+	Backlog        int64               // Events available when the server starts
+	Rate           float64             // Events per second added after the start, 0 adds none
+	PageSize       int                 // Events per eventsFeed page, defaults to 1000
+	Latency        time.Duration       // Delay before each response
+	RateLimitEvery int                 // Every Nth request gets HTTP 429, 0 never
+	RetryAfter     time.Duration       // Retry-After of those responses
+	ErrorEvery     int                 // Every Nth request gets a GraphQL error, 0 never
+	ErrorCode      string              // extensions.code of that error, defaults to INTERNAL_SERVER_ERROR
+	Fixture        []map[string]string // Events served in turn instead of generated ones
+}
+
+// Server is a mock Cato GraphQL API. It serves eventsFeed from a stream of
+// generated events whose markers are offsets into the stream, so paging,
+// marker handling and retries can be exercised without a Cato account.
+// auditFeed and entityLookup answer with empty results.
+type Server struct {
+	opts  Options
+	start time.Time
+
+	mu       sync.Mutex
+	requests int
+	served   int64
+}
+
+// New creates a mock API starting now
+func New(opts Options) *Server {
+	if opts.PageSize <= 0 {
+		opts.PageSize = 1000
+	}
+	if opts.ErrorCode == "" {
+		opts.ErrorCode = "INTERNAL_SERVER_ERROR"
+	}
+	return &Server{opts: opts, start: time.Now()}
+}
+
+// Stats returns the requests answered and the events served so far
+func (s *Server) Stats() (requests int, events int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests, s.served
+}
+
+// request is a GraphQL request body
+type request struct {
+	Query     string `json:"query"`
+	Variables struct {
+		AccountIDs []string `json:"accountIDs"`
+		Marker     string   `json:"marker"`
+	} `json:"variables"`
+}
+
+// ServeHTTP answers one GraphQL request
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.opts.APIKey != "" && r.Header.Get("x-api-key") != s.opts.APIKey {
+		http.Error(w, `{"errors":[{"message":"unauthorized"}]}`, http.StatusUnauthorized)
+		return
+	}
+
+	var req request
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.requests++
+	n := s.requests
+	s.mu.Unlock()
+
+	if s.opts.Latency > 0 {
+		select {
+		case <-time.After(s.opts.Latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if s.opts.RateLimitEvery > 0 && n%s.opts.RateLimitEvery == 0 {
+		if s.opts.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(s.opts.RetryAfter.Seconds())))
+		}
+		http.Error(w, `{"errors":[{"message":"too many requests"}]}`, http.StatusTooManyRequests)
+		return
+	}
+	if s.opts.ErrorEvery > 0 && n%s.opts.ErrorEvery == 0 {
+		s.writeJSON(w, r, map[string]any{"errors": []map[string]any{{
+			"message":    "mock error",
+			"extensions": map[string]string{"code": s.opts.ErrorCode},
+		}}})
+		return
+	}
+
+	switch {
+	case strings.Contains(req.Query, "eventsFeed"):
+		s.eventsFeed(w, r, req)
+	case strings.Contains(req.Query, "auditFeed"):
+		marker := req.Variables.Marker
+		s.writeJSON(w, r, map[string]any{"data": map[string]any{"auditFeed": map[string]any{
+			"marker": marker, "fetchedCount": 0, "hasMore": false, "accounts": []any{},
+		}}})
+	case strings.Contains(req.Query, "entityLookup"):
+		s.writeJSON(w, r, map[string]any{"data": map[string]any{"entityLookup": map[string]any{
+			"items": []any{}, "total": 0,
+		}}})
+	default:
+		s.writeJSON(w, r, map[string]any{"errors": []map[string]any{{
+			"message":    "query not supported by the mock API",
+			"extensions": map[string]string{"code": "GRAPHQL_VALIDATION_FAILED"},
+		}}})
+	}
+}
+
+// eventsFeed serves the page of events after the request's marker
+func (s *Server) eventsFeed(w http.ResponseWriter, r *http.Request, req request) {
+	var offset int64
+	if req.Variables.Marker != "" {
+		var err error
+		offset, err = strconv.ParseInt(strings.TrimPrefix(req.Variables.Marker, markerPrefix), 10, 64)
+		if err != nil || !strings.HasPrefix(req.Variables.Marker, markerPrefix) || offset < 0 {
+			s.writeJSON(w, r, map[string]any{"errors": []map[string]any{{
+				"message":    fmt.Sprintf("invalid marker '%s'", req.Variables.Marker),
+				"extensions": map[string]string{"code": "BAD_USER_INPUT"},
+			}}})
+			return
+		}
+	}
+
+	end := min(s.available(), offset+int64(s.opts.PageSize))
+	if end < offset {
+		end = offset
+	}
+
+	accounts := make([]map[string]any, 0, len(req.Variables.AccountIDs))
+	var served int64
+	for _, accountID := range req.Variables.AccountIDs {
+		records := make([]map[string]any, 0, end-offset)
+		for i := offset; i < end; i++ {
+			records = append(records, map[string]any{"fieldsMap": s.event(accountID, i)})
+		}
+		served += end - offset
+		accounts = append(accounts, map[string]any{"id": accountID, "errorString": "", "records": records})
+	}
+
+	s.mu.Lock()
+	s.served += served
+	s.mu.Unlock()
+
+	s.writeJSON(w, r, map[string]any{"data": map[string]any{"eventsFeed": map[string]any{
+		"marker":       markerPrefix + strconv.FormatInt(end, 10),
+		"fetchedCount": end - offset,
+		"accounts":     accounts,
+	}}})
+}
+
+// available returns the number of events generated so far
+func (s *Server) available() int64 {
+	return s.opts.Backlog + int64(s.opts.Rate*time.Since(s.start).Seconds())
+}
+
+// event returns the i-th event of an account, timed as if it arrived at its
+// place in the stream: backlog events before the start, later ones at Rate
+func (s *Server) event(accountID string, i int64) map[string]string {
+	at := s.start.Add(-time.Duration(s.opts.Backlog-i) * backlogSpacing)
+	if i >= s.opts.Backlog && s.opts.Rate > 0 {
+		at = s.start.Add(time.Duration(float64(i-s.opts.Backlog) / s.opts.Rate * float64(time.Second)))
+	}
+
+	if len(s.opts.Fixture) == 0 {
+		return Event(accountID, i, at)
+	}
+	fixture := s.opts.Fixture[i%int64(len(s.opts.Fixture))]
+	fields := make(map[string]string, len(fixture)+2)
+	for k, v := range fixture {
+		fields[k] = v
+	}
+	fields["time"] = at.UTC().Format(time.RFC3339Nano)
+	fields["internalId"] = fmt.Sprintf("%s-%d", accountID, i)
+	return fields
+}
+
+// writeJSON writes a 200 response, gzip-encoded when the client accepts it
+func (s *Server) writeJSON(w http.ResponseWriter, r *http.Request, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	var out io.Writer = w
+	if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		out = gz
+	}
+	json.NewEncoder(out).Encode(body)
+}
@@ -0,0 +1,162 @@
+package awsauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	imdsEndpoint      = "http://169.254.169.254"
+	ecsCredentialHost = "http://169.254.170.2"
+)
+
+// Credentials holds an AWS access key pair and optional session token
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      time.Time
+}
+
+// Expired reports whether temporary credentials are within a minute of expiring
+func (c *Credentials) Expired() bool {
+	return !c.Expiration.IsZero() && time.Now().Add(time.Minute).After(c.Expiration)
+}
+
+// LoadCredentials resolves credentials from the standard sources in order:
+// environment variables, ECS/Fargate container credentials, then EC2 instance metadata (IMDSv2)
+func LoadCredentials(ctx context.Context, client *http.Client) (*Credentials, error) {
+	if id := os.Getenv("AWS_ACCESS_KEY_ID"); id != "" {
+		return &Credentials{
+			AccessKeyID:     id,
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}, nil
+	}
+
+	if uri := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); uri != "" {
+		return fetchContainerCredentials(ctx, client, ecsCredentialHost+uri, "")
+	}
+	if uri := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI"); uri != "" {
+		return fetchContainerCredentials(ctx, client, uri, os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN"))
+	}
+
+	creds, err := fetchInstanceCredentials(ctx, client)
+	if err != nil {
+		return nil, fmt.Errorf("no AWS credentials found (env, container, or instance metadata): %w", err)
+	}
+	return creds, nil
+}
+
+// Region returns the region from AWS_REGION or AWS_DEFAULT_REGION
+func Region() string {
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		return region
+	}
+	return os.Getenv("AWS_DEFAULT_REGION")
+}
+
+// credentialResponse is the JSON shape used by both the container and instance endpoints
+type credentialResponse struct {
+	AccessKeyID     string    `json:"AccessKeyId"`
+	SecretAccessKey string    `json:"SecretAccessKey"`
+	Token           string    `json:"Token"`
+	Expiration      time.Time `json:"Expiration"`
+}
+
+func (r credentialResponse) credentials() *Credentials {
+	return &Credentials{
+		AccessKeyID:     r.AccessKeyID,
+		SecretAccessKey: r.SecretAccessKey,
+		SessionToken:    r.Token,
+		Expiration:      r.Expiration,
+	}
+}
+
+// fetchContainerCredentials reads task role credentials from the ECS credential endpoint
+func fetchContainerCredentials(ctx context.Context, client *http.Client, url, authToken string) (*Credentials, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if authToken != "" {
+		req.Header.Set("Authorization", authToken)
+	}
+
+	body, err := doMetadataRequest(client, req)
+	if err != nil {
+		return nil, fmt.Errorf("container credentials: %w", err)
+	}
+
+	var cr credentialResponse
+	if err := json.Unmarshal(body, &cr); err != nil {
+		return nil, fmt.Errorf("container credentials: invalid response: %w", err)
+	}
+	return cr.credentials(), nil
+}
+
+// fetchInstanceCredentials reads instance profile credentials via IMDSv2
+func fetchInstanceCredentials(ctx context.Context, client *http.Client) (*Credentials, error) {
+	tokenReq, err := http.NewRequestWithContext(ctx, "PUT", imdsEndpoint+"/latest/api/token", nil)
+	if err != nil {
+		return nil, err
+	}
+	tokenReq.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "21600")
+	token, err := doMetadataRequest(client, tokenReq)
+	if err != nil {
+		return nil, fmt.Errorf("instance metadata token: %w", err)
+	}
+
+	get := func(path string) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", imdsEndpoint+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("X-aws-ec2-metadata-token", string(token))
+		return doMetadataRequest(client, req)
+	}
+
+	roles, err := get("/latest/meta-data/iam/security-credentials/")
+	if err != nil {
+		return nil, fmt.Errorf("instance role lookup: %w", err)
+	}
+	role := strings.TrimSpace(strings.SplitN(string(roles), "\n", 2)[0])
+	if role == "" {
+		return nil, fmt.Errorf("no instance role attached")
+	}
+
+	body, err := get("/latest/meta-data/iam/security-credentials/" + role)
+	if err != nil {
+		return nil, fmt.Errorf("instance role credentials: %w", err)
+	}
+
+	var cr credentialResponse
+	if err := json.Unmarshal(body, &cr); err != nil {
+		return nil, fmt.Errorf("instance role credentials: invalid response: %w", err)
+	}
+	return cr.credentials(), nil
+}
+
+// doMetadataRequest executes a metadata request and returns the body of a 200 response
+func doMetadataRequest(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return body, nil
+}
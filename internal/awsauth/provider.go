@@ -0,0 +1,143 @@
+package awsauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// assumedRoleSession names the sessions of assumed roles in CloudTrail
+const assumedRoleSession = "cato-logger"
+
+// Provider supplies credentials for repeated requests, caching them until
+// they expire. Base credentials are a static key pair or, without one, the
+// standard sources of LoadCredentials; with a role ARN they are exchanged
+// for the role's credentials through STS.
+type Provider struct {
+	client  *http.Client
+	static  *Credentials
+	roleARN string
+	region  string // STS region for the role
+
+	mu    sync.Mutex
+	creds *Credentials
+}
+
+// NewProvider creates a provider. static may be nil and roleARN empty.
+func NewProvider(client *http.Client, static *Credentials, roleARN, region string) *Provider {
+	return &Provider{
+		client:  client,
+		static:  static,
+		roleARN: roleARN,
+		region:  region,
+	}
+}
+
+// Credentials returns cached credentials, reloading them before they expire
+func (p *Provider) Credentials(ctx context.Context) (*Credentials, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.creds != nil && !p.creds.Expired() {
+		return p.creds, nil
+	}
+
+	base := p.static
+	if base == nil {
+		creds, err := LoadCredentials(ctx, p.client)
+		if err != nil {
+			return nil, err
+		}
+		base = creds
+	}
+	if p.roleARN == "" {
+		p.creds = base
+		return base, nil
+	}
+
+	creds, err := AssumeRole(ctx, p.client, base, p.roleARN, p.region)
+	if err != nil {
+		return nil, err
+	}
+	p.creds = creds
+	return creds, nil
+}
+
+// assumeRoleResponse is the part of the STS AssumeRole response used
+type assumeRoleResponse struct {
+	Credentials struct {
+		AccessKeyID     string    `xml:"AccessKeyId"`
+		SecretAccessKey string    `xml:"SecretAccessKey"`
+		SessionToken    string    `xml:"SessionToken"`
+		Expiration      time.Time `xml:"Expiration"`
+	} `xml:"AssumeRoleResult>Credentials"`
+}
+
+// stsError is the error document returned by STS
+type stsError struct {
+	Code    string `xml:"Error>Code"`
+	Message string `xml:"Error>Message"`
+}
+
+// AssumeRole exchanges base credentials for temporary credentials of
+// roleARN through the regional STS endpoint (AWS_ENDPOINT_URL_STS overrides it)
+func AssumeRole(ctx context.Context, client *http.Client, base *Credentials, roleARN, region string) (*Credentials, error) {
+	if region == "" {
+		region = "us-east-1"
+	}
+	endpoint := fmt.Sprintf("https://sts.%s.amazonaws.com/", region)
+	if override := os.Getenv("AWS_ENDPOINT_URL_STS"); override != "" {
+		endpoint = strings.TrimRight(override, "/") + "/"
+	}
+
+	body := []byte(url.Values{
+		"Action":          {"AssumeRole"},
+		"Version":         {"2011-06-15"},
+		"RoleArn":         {roleARN},
+		"RoleSessionName": {assumedRoleSession},
+		"DurationSeconds": {"3600"},
+	}.Encode())
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	SignRequest(req, body, base, region, "sts", time.Now())
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("sts request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var stsErr stsError
+		if xml.Unmarshal(respBody, &stsErr) == nil && stsErr.Code != "" {
+			return nil, fmt.Errorf("cannot assume role %s: %s: %s", roleARN, stsErr.Code, stsErr.Message)
+		}
+		return nil, fmt.Errorf("cannot assume role %s: status %d", roleARN, resp.StatusCode)
+	}
+
+	var result assumeRoleResponse
+	if err := xml.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("invalid AssumeRole response: %w", err)
+	}
+	return &Credentials{
+		AccessKeyID:     result.Credentials.AccessKeyID,
+		SecretAccessKey: result.Credentials.SecretAccessKey,
+		SessionToken:    result.Credentials.SessionToken,
+		Expiration:      result.Credentials.Expiration,
+	}, nil
+}
@@ -0,0 +1,148 @@
+package azureauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	imdsTokenEndpoint = "http://169.254.169.254/metadata/identity/oauth2/token"
+	loginEndpoint     = "https://login.microsoftonline.com"
+)
+
+// TokenSource obtains and caches Azure AD access tokens for a single resource.
+// It uses client credentials when AZURE_TENANT_ID, AZURE_CLIENT_ID and
+// AZURE_CLIENT_SECRET are set, and managed identity otherwise.
+type TokenSource struct {
+	resource string
+	client   *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// NewTokenSource creates a token source for a resource such as https://vault.azure.net
+func NewTokenSource(resource string, client *http.Client) *TokenSource {
+	return &TokenSource{
+		resource: strings.TrimSuffix(resource, "/"),
+		client:   client,
+	}
+}
+
+// Token returns a valid access token, refreshing it shortly before expiry
+func (t *TokenSource) Token(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.token != "" && time.Now().Add(2*time.Minute).Before(t.expires) {
+		return t.token, nil
+	}
+
+	var token string
+	var expiresIn time.Duration
+	var err error
+	if os.Getenv("AZURE_CLIENT_SECRET") != "" {
+		token, expiresIn, err = t.clientCredentials(ctx)
+	} else {
+		token, expiresIn, err = t.managedIdentity(ctx)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	t.token = token
+	t.expires = time.Now().Add(expiresIn)
+	return t.token, nil
+}
+
+// tokenResponse covers both the AAD v2 and IMDS response shapes.
+// IMDS returns expires_in as a string, AAD as a number.
+type tokenResponse struct {
+	AccessToken string          `json:"access_token"`
+	ExpiresIn   json.RawMessage `json:"expires_in"`
+	Error       string          `json:"error"`
+	Description string          `json:"error_description"`
+}
+
+// clientCredentials performs the OAuth2 client credentials grant against Azure AD
+func (t *TokenSource) clientCredentials(ctx context.Context) (string, time.Duration, error) {
+	tenant := os.Getenv("AZURE_TENANT_ID")
+	if tenant == "" {
+		return "", 0, fmt.Errorf("AZURE_TENANT_ID is required with AZURE_CLIENT_SECRET")
+	}
+
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {os.Getenv("AZURE_CLIENT_ID")},
+		"client_secret": {os.Getenv("AZURE_CLIENT_SECRET")},
+		"scope":         {t.resource + "/.default"},
+	}
+
+	endpoint := fmt.Sprintf("%s/%s/oauth2/v2.0/token", loginEndpoint, url.PathEscape(tenant))
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return t.doTokenRequest(req, "client credentials")
+}
+
+// managedIdentity requests a token from the instance metadata service
+func (t *TokenSource) managedIdentity(ctx context.Context) (string, time.Duration, error) {
+	query := url.Values{
+		"api-version": {"2018-02-01"},
+		"resource":    {t.resource},
+	}
+	if clientID := os.Getenv("AZURE_CLIENT_ID"); clientID != "" {
+		// User-assigned identity
+		query.Set("client_id", clientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", imdsTokenEndpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Metadata", "true")
+
+	return t.doTokenRequest(req, "managed identity")
+}
+
+// doTokenRequest executes a token request and parses the response
+func (t *TokenSource) doTokenRequest(req *http.Request, method string) (string, time.Duration, error) {
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("azure %s token request failed: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", 0, fmt.Errorf("azure %s token response unreadable: %w", method, err)
+	}
+
+	var tr tokenResponse
+	if err := json.Unmarshal(body, &tr); err != nil {
+		return "", 0, fmt.Errorf("azure %s token response invalid (status %d)", method, resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK || tr.AccessToken == "" {
+		return "", 0, fmt.Errorf("azure %s token request rejected (status %d): %s %s",
+			method, resp.StatusCode, tr.Error, tr.Description)
+	}
+
+	seconds, _ := strconv.Atoi(strings.Trim(string(tr.ExpiresIn), `"`))
+	if seconds <= 0 {
+		seconds = 300
+	}
+	return tr.AccessToken, time.Duration(seconds) * time.Second, nil
+}
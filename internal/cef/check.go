@@ -0,0 +1,98 @@
+package cef
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// extensionKeyPattern matches extension keys SIEM parsers accept
+var extensionKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_.]+$`)
+
+// Check returns warnings about how an event formats: header fields that
+// fall back to Unknown, severities not recognized, extension keys parsers
+// reject, fields lost to another field mapped to the same key, and time
+// keys that are not epoch milliseconds.
+func (f *Formatter) Check(fieldsMap map[string]string) []string {
+	if p := f.profiles[fieldsMap["event_type"]]; p != nil {
+		return p.Check(fieldsMap)
+	}
+
+	var warnings []string
+
+	for _, field := range []string{"event_type", "event_sub_type"} {
+		if fieldsMap[field] == "" {
+			warnings = append(warnings, fmt.Sprintf("event has no %s, the header uses Unknown", field))
+		}
+	}
+
+	for _, field := range f.severity {
+		if value := fieldsMap[field]; value != "" {
+			if _, ok := f.level(value); !ok {
+				warnings = append(warnings, fmt.Sprintf("%s value %q is not a known severity, set cef.severity.levels", field, value))
+			}
+		}
+	}
+
+	// Time keys set from fields that parse; the rest warn
+	consumed := make(map[string]bool)
+	for _, key := range TimestampKeys {
+		field, ok := f.timestamps[key]
+		if !ok || fieldsMap[field] == "" {
+			continue
+		}
+		if _, ok := ParseTime(fieldsMap[field], f.location); ok {
+			consumed[field] = true
+		} else {
+			warnings = append(warnings, fmt.Sprintf("%s is not a time, so %s is not sent as epoch milliseconds", field, key))
+		}
+	}
+
+	// Sources of each extension key present in the event
+	sources := make(map[string][]string)
+	for source, target := range f.fieldMappings {
+		if fieldsMap[source] != "" && !consumed[source] {
+			sources[target] = append(sources[target], source)
+		}
+	}
+	for field, value := range fieldsMap {
+		if value != "" && !consumed[field] && !isMappedField(field, f.fieldMappings) && f.keepUnmapped(field) && f.unmapped.PackKey == "" {
+			sources[field] = append(sources[field], field)
+		}
+	}
+
+	keys := make([]string, 0, len(sources))
+	for key := range sources {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fields := sources[key]
+		sort.Strings(fields)
+		if !extensionKeyPattern.MatchString(key) {
+			warnings = append(warnings, fmt.Sprintf("extension key %q has characters CEF parsers reject", key))
+		}
+		if len(fields) > 1 {
+			warnings = append(warnings, fmt.Sprintf("fields %v all map to %s, only one is sent", fields, key))
+		}
+		if _, set := f.timestamps[key]; !set && isTimeKey(key) {
+			if _, err := strconv.ParseInt(fieldsMap[fields[0]], 10, 64); err != nil {
+				warnings = append(warnings, fmt.Sprintf("%s is sent as %s but is not epoch milliseconds; set cef.timestamp_fields", fields[0], key))
+			}
+		}
+	}
+
+	return warnings
+}
+
+// isTimeKey checks if an extension key is one of TimestampKeys
+func isTimeKey(key string) bool {
+	for _, k := range TimestampKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
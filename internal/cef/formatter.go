@@ -1,9 +1,16 @@
 package cef
 
 import (
-	"fmt"
-	"sort"
+	"bytes"
+	"encoding/json"
+	"math"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 // Formatter handles CEF message formatting
@@ -13,80 +20,391 @@ type Formatter struct {
 	version       string
 	fieldMappings map[string]string
 	orderedFields []string
+	limits        Limits
+	timestamps    map[string]string // CEF key -> event field
+	location      *time.Location
+	unmapped      Unmapped
+	allowlist     map[string]bool
+	profiles      map[string]*Formatter // By event_type
+	severity      []string              // Event fields the severity is read from
+	levels        map[string]int        // Lowercase severity names to 0-10
+
+	// Precomputed by NewFormatter so Format does not rebuild them per event
+	header     []byte             // Escaped "CEF:0|vendor|product|version|"
+	targets    map[string]*target // Extension keys set by mappings or times
+	ordered    []string           // orderedFields without duplicates
+	orderedSet map[string]bool
+	tail       []*target // Targets not in ordered, by key
+	stamps     []*target // Targets that are time keys
+}
+
+// target is an extension key set from event fields
+type target struct {
+	key       string
+	sources   []string // Mapped event fields in name order; the first present is sent
+	timestamp string   // Event field of a time key, empty for other keys
 }
 
+// scratch is the per-event working memory Format reuses
+type scratch struct {
+	buf    bytes.Buffer
+	keys   []string
+	stamps [3]stamp
+}
+
+// stamp is a time key's event field and its time, when it parsed
+type stamp struct {
+	field  string
+	millis int64
+	ok     bool
+}
+
+var scratchPool = sync.Pool{New: func() interface{} { return new(scratch) }}
+
 // NewFormatter creates a new CEF formatter
-func NewFormatter(vendor, product, version string, fieldMappings map[string]string, orderedFields []string) *Formatter {
-	return &Formatter{
+func NewFormatter(vendor, product, version string, fieldMappings map[string]string, orderedFields []string, opts Options) *Formatter {
+	allowlist := make(map[string]bool, len(opts.Unmapped.Allowlist))
+	for _, field := range opts.Unmapped.Allowlist {
+		allowlist[field] = true
+	}
+	f := &Formatter{
 		vendor:        vendor,
 		product:       product,
 		version:       version,
 		fieldMappings: fieldMappings,
 		orderedFields: orderedFields,
+		limits:        opts.Limits,
+		timestamps:    opts.Timestamps.Fields,
+		location:      opts.Timestamps.location(),
+		unmapped:      opts.Unmapped,
+		allowlist:     allowlist,
+		targets:       make(map[string]*target),
+		severity:      opts.Severity.Fields,
+		levels:        make(map[string]int, len(DefaultSeverityLevels)+len(opts.Severity.Levels)),
+	}
+	for name, level := range DefaultSeverityLevels {
+		f.levels[name] = level
+	}
+	for name, level := range opts.Severity.Levels {
+		f.levels[strings.ToLower(name)] = level
+	}
+
+	if len(opts.Profiles) > 0 {
+		shared := opts
+		shared.Profiles = nil
+		f.profiles = make(map[string]*Formatter, len(opts.Profiles))
+		for eventType, profile := range opts.Profiles {
+			ordered := profile.OrderedFields
+			if len(ordered) == 0 {
+				ordered = orderedFields
+			}
+			f.profiles[eventType] = NewFormatter(vendor, product, version, profile.FieldMappings, ordered, shared)
+		}
+	}
+
+	var header bytes.Buffer
+	header.WriteString("CEF:0|")
+	for _, field := range []string{vendor, product, version} {
+		writeHeader(&header, field)
+		header.WriteByte('|')
+	}
+	f.header = header.Bytes()
+
+	targetOf := func(key string) *target {
+		t := f.targets[key]
+		if t == nil {
+			t = &target{key: key}
+			f.targets[key] = t
+		}
+		return t
+	}
+	for source, key := range fieldMappings {
+		t := targetOf(key)
+		t.sources = append(t.sources, source)
+	}
+	for _, key := range TimestampKeys {
+		if field, ok := f.timestamps[key]; ok {
+			t := targetOf(key)
+			t.timestamp = field
+			f.stamps = append(f.stamps, t)
+		}
 	}
+
+	f.orderedSet = make(map[string]bool, len(orderedFields))
+	for _, key := range orderedFields {
+		if !f.orderedSet[key] {
+			f.orderedSet[key] = true
+			f.ordered = append(f.ordered, key)
+		}
+	}
+	for key, t := range f.targets {
+		slices.Sort(t.sources)
+		if !f.orderedSet[key] {
+			f.tail = append(f.tail, t)
+		}
+	}
+	slices.SortFunc(f.tail, func(a, b *target) int {
+		switch {
+		case a.key < b.key:
+			return -1
+		case a.key > b.key:
+			return 1
+		}
+		return 0
+	})
+	return f
 }
 
-// Format converts an event to CEF format
+// Format converts an event to CEF format, with the mapping profile of its
+// event type if there is one. Ordered keys come first, the rest follow
+// alphabetically. When several mapped fields share a key, the first by name
+// is sent; an unmapped field named like a mapped key replaces it.
 func (f *Formatter) Format(fieldsMap map[string]string) string {
-	signature := getMapValue(fieldsMap, "event_type", "Unknown")
-	name := fmt.Sprintf("%s - %s",
-		signature,
-		getMapValue(fieldsMap, "event_sub_type", "Unknown"))
-
-	severity := mapEventTypeToSeverity(signature)
+	if p := f.profiles[fieldsMap["event_type"]]; p != nil {
+		return p.Format(fieldsMap)
+	}
 
-	header := fmt.Sprintf("CEF:0|%s|%s|%s|%s|%s|%d|",
-		f.vendor, f.product, f.version,
-		signature, name, severity)
+	s := scratchPool.Get().(*scratch)
+	defer scratchPool.Put(s)
+	buf := &s.buf
+	buf.Reset()
 
-	extensions := make(map[string]string)
+	signature := getMapValue(fieldsMap, "event_type", "Unknown")
+	buf.Write(f.header)
+	writeHeader(buf, signature)
+	buf.WriteByte('|')
+	writeHeader(buf, signature)
+	buf.WriteString(" - ")
+	writeHeader(buf, getMapValue(fieldsMap, "event_sub_type", "Unknown"))
+	buf.WriteByte('|')
+	buf.Write(strconv.AppendInt(buf.AvailableBuffer(), int64(f.severityOf(fieldsMap, signature)), 10))
+	buf.WriteByte('|')
 
-	// Apply field mappings
-	for sourceKey, targetKey := range f.fieldMappings {
-		if value, exists := fieldsMap[sourceKey]; exists && value != "" {
-			extensions[targetKey] = sanitizeValue(value)
+	// Set event times; fields that parse are not emitted otherwise
+	stamps := s.stamps[:len(f.stamps)]
+	for i, t := range f.stamps {
+		parsed, ok := ParseTime(fieldsMap[t.timestamp], f.location)
+		stamps[i] = stamp{field: t.timestamp, millis: parsed.UnixMilli(), ok: ok}
+	}
+	consumed := func(field string) bool {
+		for _, st := range stamps {
+			if st.ok && st.field == field {
+				return true
+			}
 		}
+		return false
 	}
 
-	// Add unmapped fields
+	// Unmapped fields the mode keeps, packed into one key if set
+	var packed map[string]string
+	keys := s.keys[:0]
 	for k, v := range fieldsMap {
-		if !isMappedField(k, f.fieldMappings) && v != "" {
-			extensions[k] = sanitizeValue(v)
+		if v == "" || isMappedField(k, f.fieldMappings) || consumed(k) || !f.keepUnmapped(k) {
+			continue
 		}
+		if f.unmapped.PackKey != "" {
+			if packed == nil {
+				packed = make(map[string]string)
+			}
+			packed[k] = v
+			continue
+		}
+		keys = append(keys, k)
+	}
+	var packValue string
+	if len(packed) > 0 {
+		data, _ := json.Marshal(packed)
+		packValue = string(data)
+		keys = append(keys, f.unmapped.PackKey)
+	}
+	isUnmapped := func(key string) bool {
+		if f.unmapped.PackKey != "" {
+			return key == f.unmapped.PackKey && packValue != ""
+		}
+		return fieldsMap[key] != "" && !isMappedField(key, f.fieldMappings) && !consumed(key) && f.keepUnmapped(key)
+	}
+	unmappedValue := func(key string) string {
+		if key == f.unmapped.PackKey && packValue != "" {
+			return packValue
+		}
+		return fieldsMap[key]
 	}
 
-	// Format extensions in order
-	var parts []string
+	first := true
+	writeKey := func(key string) {
+		if !first {
+			buf.WriteByte(' ')
+		}
+		first = false
+		buf.WriteString(key)
+		buf.WriteByte('=')
+	}
+	write := func(key string, value string) {
+		writeKey(key)
+		writeValue(buf, truncate(value, f.limits.maxLength(key)))
+	}
+	writeTarget := func(t *target) {
+		for _, st := range stamps {
+			if st.ok && st.field == t.timestamp {
+				writeKey(t.key)
+				buf.Write(strconv.AppendInt(buf.AvailableBuffer(), st.millis, 10))
+				return
+			}
+		}
+		for _, source := range t.sources {
+			if value := fieldsMap[source]; value != "" && !consumed(source) {
+				write(t.key, value)
+				return
+			}
+		}
+	}
 
 	// Ordered fields first
-	for _, field := range f.orderedFields {
-		if value, exists := extensions[field]; exists {
-			parts = append(parts, fmt.Sprintf("%s=%s", field, value))
-			delete(extensions, field)
+	for _, key := range f.ordered {
+		if isUnmapped(key) {
+			write(key, unmappedValue(key))
+		} else if t := f.targets[key]; t != nil {
+			writeTarget(t)
+		}
+	}
+
+	// Remaining fields alphabetically, merging unmapped keys into the
+	// precomputed mapped ones
+	remaining := keys[:0]
+	for _, k := range keys {
+		if !f.orderedSet[k] {
+			remaining = append(remaining, k)
 		}
 	}
+	slices.Sort(remaining)
+	s.keys = remaining
+
+	j := 0
+	for _, k := range remaining {
+		for j < len(f.tail) && f.tail[j].key < k {
+			writeTarget(f.tail[j])
+			j++
+		}
+		if j < len(f.tail) && f.tail[j].key == k {
+			j++
+		}
+		write(k, unmappedValue(k))
+	}
+	for ; j < len(f.tail); j++ {
+		writeTarget(f.tail[j])
+	}
+
+	return buf.String()
+}
+
+// Profile returns the name of the mapping profile an event is formatted
+// with: its event type, or empty for the default
+func (f *Formatter) Profile(fieldsMap map[string]string) string {
+	if _, ok := f.profiles[fieldsMap["event_type"]]; ok {
+		return fieldsMap["event_type"]
+	}
+	return ""
+}
 
-	// Remaining fields alphabetically
-	var remaining []string
-	for k := range extensions {
-		remaining = append(remaining, k)
+// SanitizeValue escapes special CEF characters. Invalid UTF-8 becomes
+// U+FFFD, tabs become spaces and other control characters, NUL among them,
+// are dropped.
+func SanitizeValue(value string) string {
+	var b bytes.Buffer
+	b.Grow(len(value))
+	writeValue(&b, value)
+	return b.String()
+}
+
+// writeValue writes a value escaped the way SanitizeValue does
+func writeValue(b *bytes.Buffer, value string) {
+	if plain(value, "\\=|") {
+		b.WriteString(value)
+		return
 	}
-	sort.Strings(remaining)
+	forEachRune(value, func(r rune) {
+		switch {
+		case r == '\\' || r == '=' || r == '|':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString("\\n")
+		case r == '\r':
+			b.WriteString("\\r")
+		case r == '\t':
+			b.WriteByte(' ')
+		case unicode.IsControl(r):
+			// Dropped
+		default:
+			b.WriteRune(r)
+		}
+	})
+}
+
+// writeHeader writes a header field escaped: pipes and backslashes get a
+// backslash, and line breaks, which cannot be escaped in the header, become
+// spaces like the other control characters
+func writeHeader(b *bytes.Buffer, value string) {
+	if plain(value, "\\|") {
+		b.WriteString(value)
+		return
+	}
+	forEachRune(value, func(r rune) {
+		switch {
+		case r == '\\' || r == '|':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case unicode.IsControl(r):
+			b.WriteByte(' ')
+		default:
+			b.WriteRune(r)
+		}
+	})
+}
 
-	for _, field := range remaining {
-		parts = append(parts, fmt.Sprintf("%s=%s", field, extensions[field]))
+// plain reports whether a value is printable ASCII without any of the
+// special characters, so it can be written as is
+func plain(value, special string) bool {
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c < 0x20 || c > 0x7e || strings.IndexByte(special, c) >= 0 {
+			return false
+		}
 	}
+	return true
+}
 
-	return header + strings.Join(parts, " ")
+// forEachRune calls fn for each rune of a value, with each run of invalid
+// UTF-8 replaced by one U+FFFD
+func forEachRune(value string, fn func(r rune)) {
+	invalid := false
+	for i := 0; i < len(value); {
+		r, size := utf8.DecodeRuneInString(value[i:])
+		i += size
+		if r == utf8.RuneError && size == 1 {
+			if !invalid {
+				fn(utf8.RuneError)
+			}
+			invalid = true
+			continue
+		}
+		invalid = false
+		fn(r)
+	}
 }
 
-// sanitizeValue escapes special CEF characters
-func sanitizeValue(value string) string {
-	value = strings.ReplaceAll(value, "\\", "\\\\")
-	value = strings.ReplaceAll(value, "=", "\\=")
-	value = strings.ReplaceAll(value, "|", "\\|")
-	value = strings.ReplaceAll(value, "\n", "\\n")
-	value = strings.ReplaceAll(value, "\r", "\\r")
+// truncate cuts a value to at most max characters; 0 means no limit
+func truncate(value string, max int) string {
+	if max <= 0 || len(value) <= max {
+		return value
+	}
+	n := 0
+	for i := range value {
+		if n == max {
+			return value[:i]
+		}
+		n++
+	}
 	return value
 }
 
@@ -96,24 +414,60 @@ func isMappedField(fieldName string, fieldMappings map[string]string) bool {
 	return exists
 }
 
-// mapEventTypeToSeverity converts event types to CEF severity levels
-func mapEventTypeToSeverity(eventType string) int {
-	severityMap := map[string]int{
-		"Threat":           10,
-		"Malware":          10,
-		"Attack":           9,
-		"Intrusion":        9,
-		"Security":         8,
-		"Policy Violation": 7,
-		"Warning":          6,
-		"Alert":            6,
-		"Connectivity":     5,
-		"Network":          4,
-		"Traffic":          3,
-		"Info":             2,
-		"Debug":            1,
+// keepUnmapped checks if the unmapped field mode sends a field
+func (f *Formatter) keepUnmapped(field string) bool {
+	switch f.unmapped.Mode {
+	case UnmappedDrop:
+		return false
+	case UnmappedAllowlist:
+		return f.allowlist[field]
+	}
+	return true
+}
+
+// severityOf returns the severity of an event: the first recognized value
+// of the severity fields, or the severity of its event type
+func (f *Formatter) severityOf(fieldsMap map[string]string, eventType string) int {
+	for _, field := range f.severity {
+		if level, ok := f.level(fieldsMap[field]); ok {
+			return level
+		}
 	}
+	return mapEventTypeToSeverity(eventType)
+}
 
+// level converts a severity value, a name or a number, to 0-10. Numbers
+// outside the range are clamped.
+func (f *Formatter) level(value string) (int, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if n, err := strconv.ParseFloat(value, 64); err == nil && !math.IsNaN(n) {
+		return int(math.Round(math.Max(0, math.Min(10, n)))), true
+	}
+	level, ok := f.levels[strings.ToLower(strings.TrimSpace(value))]
+	return level, ok
+}
+
+// severityMap maps event types to CEF severity levels
+var severityMap = map[string]int{
+	"Threat":           10,
+	"Malware":          10,
+	"Attack":           9,
+	"Intrusion":        9,
+	"Security":         8,
+	"Policy Violation": 7,
+	"Warning":          6,
+	"Alert":            6,
+	"Connectivity":     5,
+	"Network":          4,
+	"Traffic":          3,
+	"Info":             2,
+	"Debug":            1,
+}
+
+// mapEventTypeToSeverity converts event types to CEF severity levels
+func mapEventTypeToSeverity(eventType string) int {
 	if severity, exists := severityMap[eventType]; exists {
 		return severity
 	}
@@ -0,0 +1,120 @@
+package cef
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// headerFields splits a CEF record at its unescaped pipes into the seven
+// header fields and the extension
+func headerFields(record string) []string {
+	var fields []string
+	start := 0
+	for i := 0; i < len(record) && len(fields) < 7; i++ {
+		switch record[i] {
+		case '\\':
+			i++ // Skip the escaped character
+		case '|':
+			fields = append(fields, record[start:i])
+			start = i + 1
+		}
+	}
+	return append(fields, record[start:])
+}
+
+func TestFormatEscapesHeader(t *testing.T) {
+	tests := []struct {
+		name                     string
+		vendor, product, version string
+		eventType, subType       string
+		want                     []string // Vendor, product, version, signature and name as written
+	}{
+		{
+			name:   "plain",
+			vendor: "Cato Networks", product: "SASE Platform", version: "1.0",
+			eventType: "Security", subType: "Internet Firewall",
+			want: []string{"Cato Networks", "SASE Platform", "1.0", "Security", "Security - Internet Firewall"},
+		},
+		{
+			name:   "pipes",
+			vendor: "Cato|Networks", product: "SASE|", version: "|1.0",
+			eventType: "Sec|urity", subType: "a|b|c",
+			want: []string{`Cato\|Networks`, `SASE\|`, `\|1.0`, `Sec\|urity`, `Sec\|urity - a\|b\|c`},
+		},
+		{
+			name:   "backslashes",
+			vendor: `Cato\Networks`, product: `SASE\`, version: `\|`,
+			eventType: `Security\`, subType: `a\|b`,
+			want: []string{`Cato\\Networks`, `SASE\\`, `\\\|`, `Security\\`, `Security\\ - a\\\|b`},
+		},
+		{
+			name:   "line breaks",
+			vendor: "Cato\r\nNetworks", product: "SASE\nPlatform", version: "1.0\r",
+			eventType: "Security\n", subType: "Internet\r\nFirewall|x",
+			want: []string{"Cato  Networks", "SASE Platform", "1.0 ", "Security ", `Security  - Internet  Firewall\|x`},
+		},
+		{
+			name:   "control characters",
+			vendor: "Cato\x00Networks", product: "SASE\tPlatform", version: "1.0\x7f",
+			eventType: "Security", subType: "Internet\x1bFirewall",
+			want: []string{"Cato Networks", "SASE Platform", "1.0 ", "Security", "Security - Internet Firewall"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFormatter(tt.vendor, tt.product, tt.version, map[string]string{"src_ip": "src"}, nil,
+				Options{Unmapped: Unmapped{Mode: UnmappedDrop}})
+			record := f.Format(map[string]string{
+				"event_type":     tt.eventType,
+				"event_sub_type": tt.subType,
+				"src_ip":         "10.0.0.1",
+			})
+
+			if strings.ContainsAny(record, "\r\n") {
+				t.Fatalf("record contains a line break: %q", record)
+			}
+			fields := headerFields(record)
+			if len(fields) != 8 {
+				t.Fatalf("got %d fields, want 7 header fields and the extension: %q", len(fields), record)
+			}
+			want := append([]string{"CEF:0"}, tt.want...)
+			want = append(want, strconv.Itoa(mapEventTypeToSeverity(tt.eventType)), "src=10.0.0.1")
+			for i := range want {
+				if fields[i] != want[i] {
+					t.Errorf("field %d = %q, want %q", i, fields[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkFormat formats a typical connectivity event with the default
+// field mappings and time key
+func BenchmarkFormat(b *testing.B) {
+	mappings := map[string]string{
+		"account_id": "aid", "action": "action_details", "bytes_in": "in", "bytes_out": "out",
+		"dest_country_code": "dst_country", "dest_ip": "dst", "dest_port": "dpt", "dest_site_name": "outzone",
+		"device_name": "client_name", "ip_protocol": "tunnel_protocol", "protocol": "proto",
+		"src_country_code": "src_country", "src_ip": "src", "src_port": "spt", "src_site_name": "inzone",
+		"time": "rt", "vpn_user_email": "logged_on_user",
+	}
+	ordered := []string{"rt", "src", "spt", "dst", "dpt", "proto", "in", "out", "aid"}
+	f := NewFormatter("Cato Networks", "SASE Platform", "1.0", mappings, ordered, Options{
+		Timestamps: Timestamps{Fields: map[string]string{"rt": "time"}},
+	})
+	event := map[string]string{
+		"account_id": "12345", "action": "Allow", "application": "Slack", "bytes_in": "10432",
+		"bytes_out": "2211", "dest_country_code": "US", "dest_ip": "93.68.89.125", "dest_port": "443",
+		"device_name": "LAPTOP-7F3K", "event_sub_type": "Internet Firewall", "event_type": "Security",
+		"internalId": "12345-0", "ip_protocol": "TCP", "os_type": "OS_WINDOWS", "protocol": "HTTPS",
+		"rule": "Allow SaaS", "src_country_code": "DE", "src_ip": "10.1.178.2", "src_port": "51544",
+		"src_site_name": "Berlin HQ", "time": "2026-10-16T20:48:45Z", "vpn_user_email": "jane@example.com",
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		f.Format(event)
+	}
+}
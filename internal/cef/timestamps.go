@@ -0,0 +1,66 @@
+package cef
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimestampKeys are the CEF extension keys that carry event times
+var TimestampKeys = []string{"rt", "start", "end"}
+
+// Timestamps selects the event fields the rt, start and end keys are set
+// from. Their values are sent as epoch milliseconds, the form ArcSight and
+// Sentinel read as event time.
+type Timestamps struct {
+	Fields   map[string]string // CEF key (rt, start or end) -> event field
+	Timezone string            // IANA zone for times without an offset, defaults to UTC
+}
+
+// layouts are the time formats accepted besides epoch numbers. The zoneless
+// ones are read in the configured timezone.
+var layouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+}
+
+// location returns the zone for times without an offset
+func (t Timestamps) location() *time.Location {
+	if t.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(t.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// ParseTime reads an ISO 8601 time or an epoch number. Epoch numbers are
+// taken as seconds, milliseconds, microseconds or nanoseconds by magnitude,
+// and times without an offset are read in loc.
+func ParseTime(value string, loc *time.Location) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		switch {
+		case n < 1e11:
+			return time.Unix(n, 0), true
+		case n < 1e14:
+			return time.UnixMilli(n), true
+		case n < 1e17:
+			return time.UnixMicro(n), true
+		}
+		return time.Unix(0, n), true
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil && f >= 0 && f < 1e11 {
+		return time.UnixMilli(int64(f * 1000)), true
+	}
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
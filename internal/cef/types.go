@@ -1,3 +1,67 @@
 package cef
 
-// No types needed - field mappings come from config
+// Options are the formatting settings shared by every mapping profile
+type Options struct {
+	Limits     Limits
+	Timestamps Timestamps
+	Unmapped   Unmapped
+	Severity   Severity
+	Profiles   map[string]Profile // Mapping profiles by event_type
+}
+
+// Severity selects the event fields the CEF severity is read from. Events
+// without a recognized value get the severity of their event type.
+type Severity struct {
+	Fields []string       // Checked in order; the first recognized value wins
+	Levels map[string]int // Value names to 0-10, added to DefaultSeverityLevels
+}
+
+// DefaultSeverityLevels maps severity names, compared case-insensitively,
+// to CEF severities. Numeric values 0-10 are used as they are.
+var DefaultSeverityLevels = map[string]int{
+	"info":          1,
+	"informational": 1,
+	"low":           3,
+	"medium":        5,
+	"high":          8,
+	"critical":      10,
+}
+
+// Profile is the field mapping of one event type, replacing the formatter's
+// own for events of that type
+type Profile struct {
+	FieldMappings map[string]string
+	OrderedFields []string // Defaults to the formatter's ordered fields
+}
+
+// Unmapped field modes
+const (
+	UnmappedInclude   = "include"   // Send every unmapped field under its own name
+	UnmappedDrop      = "drop"      // Send mapped fields only
+	UnmappedAllowlist = "allowlist" // Send the listed unmapped fields only
+)
+
+// UnmappedModes lists the accepted unmapped field modes
+var UnmappedModes = []string{UnmappedInclude, UnmappedDrop, UnmappedAllowlist}
+
+// Unmapped decides which fields without a mapping are sent, and how
+type Unmapped struct {
+	Mode      string   // One of UnmappedModes, defaults to include
+	Allowlist []string // Fields sent in allowlist mode
+	PackKey   string   // When set, the sent fields go as one JSON object under this key
+}
+
+// Limits caps the length of extension values, in characters, before they
+// are escaped
+type Limits struct {
+	MaxValueLength int            // Cap for every extension value, 0 for none
+	FieldMaxLength map[string]int // Caps by extension key, overriding MaxValueLength
+}
+
+// maxLength returns the cap for an extension key, 0 for none
+func (l Limits) maxLength(key string) int {
+	if max, ok := l.FieldMaxLength[key]; ok {
+		return max
+	}
+	return l.MaxValueLength
+}
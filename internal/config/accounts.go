@@ -0,0 +1,50 @@
+package config
+
+import "fmt"
+
+// AccountIDs returns the configured accounts to poll: cato.account_ids when
+// set, otherwise cato.account_id. Accounts found by discovery come on top.
+func (c *Config) AccountIDs() []string {
+	if len(c.CatoAccountIDs) > 0 {
+		return c.CatoAccountIDs
+	}
+	if c.CatoAccountID == "" {
+		return nil
+	}
+	return []string{c.CatoAccountID}
+}
+
+// MultiAccount reports whether more than one account may be polled, in which
+// case every account keeps its own marker file
+func (c *Config) MultiAccount() bool {
+	return c.DiscoveryParentID != "" || len(c.AccountIDs()) > 1
+}
+
+// AccountMarkerFile returns the marker file for one account of a feed. With a
+// single account this is the feed's marker file; with several, each account
+// gets its own file suffixed with the account ID.
+func (c *Config) AccountMarkerFile(feed Feed, accountID string) string {
+	if !c.MultiAccount() {
+		return feed.MarkerFile
+	}
+	return feed.MarkerFile + "." + accountID
+}
+
+// validateAccounts checks the account list and discovery settings
+func (c *Config) validateAccounts() error {
+	seen := make(map[string]bool)
+	for i, id := range c.CatoAccountIDs {
+		if id == "" {
+			return fmt.Errorf("cato.account_ids[%d] is empty", i)
+		}
+		if seen[id] {
+			return fmt.Errorf("cato.account_ids lists account %s twice", id)
+		}
+		seen[id] = true
+	}
+
+	if c.DiscoveryInterval < 0 {
+		return fmt.Errorf("cato.discovery.refresh_interval_minutes cannot be negative, got %d", c.DiscoveryInterval)
+	}
+	return nil
+}
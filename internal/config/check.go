@@ -0,0 +1,180 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"net"
+
+	"cato-logger/internal/syslog"
+)
+
+// apiRequestsPerMinute is the average eventsFeed and auditFeed request rate
+// above which a forwarder regularly runs into HTTP 429 responses
+const apiRequestsPerMinute = 60
+
+// Finding is one problem found by Check
+type Finding struct {
+	Setting string // Config key the finding is about, empty when it spans several
+	Message string
+	Hint    string // What to change, empty when the message says it
+}
+
+// Report is the outcome of Check: errors stop the service from starting,
+// warnings flag settings that work but are likely to cause trouble
+type Report struct {
+	Errors   []Finding
+	Warnings []Finding
+}
+
+// Check validates the configuration and looks for settings that pass
+// validation but are likely to misbehave in production
+func (c *Config) Check() Report {
+	var report Report
+	if err := c.Validate(); err != nil {
+		report.Errors = append(report.Errors, Finding{Message: err.Error()})
+	}
+	report.Warnings = c.Warnings()
+	return report
+}
+
+// Warnings returns the findings for settings that pass validation but are
+// likely to misbehave in production
+func (c *Config) Warnings() []Finding {
+	var warnings []Finding
+
+	// A datagram carries the message alone, so max_message_size is its size
+	for _, out := range c.EffectiveOutputs() {
+		if out.Syslog == nil || out.Syslog.Protocol != "udp" || out.Syslog.MaxMessageSize <= syslog.DefaultMaxUDPMessageSize {
+			continue
+		}
+		warnings = append(warnings, Finding{
+			Setting: outputSetting(c, out, "max_message_size"),
+			Message: fmt.Sprintf("output %s sends UDP messages of up to %d bytes, over the %d bytes of one unfragmented datagram; fragments are often dropped by firewalls and receivers",
+				out.Name, out.Syslog.MaxMessageSize, syslog.DefaultMaxUDPMessageSize),
+			Hint: fmt.Sprintf("lower max_message_size to %d or switch the output to tcp or relp", syslog.DefaultMaxUDPMessageSize),
+		})
+	}
+
+	if c.FetchInterval > 0 {
+		feeds := len(c.EffectiveFeeds())
+		accounts := max(len(c.AccountIDs()), 1)
+		perMinute := feeds * accounts * c.MaxPagination * 60 / c.FetchInterval
+		if perMinute > apiRequestsPerMinute {
+			warnings = append(warnings, Finding{
+				Setting: "processing.fetch_interval_seconds",
+				Message: fmt.Sprintf("%d feed(s) x %d account(s) x %d pages every %d seconds can reach %d API requests a minute, enough to be rate limited",
+					feeds, accounts, c.MaxPagination, c.FetchInterval, perMinute),
+				Hint: fmt.Sprintf("raise fetch_interval_seconds to at least %d or lower max_pagination_requests",
+					feeds*accounts*c.MaxPagination*60/apiRequestsPerMinute),
+			})
+		}
+	}
+
+	if c.SLOLatency > 0 && c.SLOLatency < c.FetchInterval {
+		warnings = append(warnings, Finding{
+			Setting: "stats.slo.latency_seconds",
+			Message: fmt.Sprintf("the SLO allows %d seconds of delivery latency, less than the %d-second fetch interval, so events that wait for the next poll always count as late",
+				c.SLOLatency, c.FetchInterval),
+			Hint: "raise latency_seconds well above fetch_interval_seconds",
+		})
+	}
+
+	if c.MemoryLimitMB > 0 {
+		// Without a byte cap a queue may fill with messages of the largest size
+		queuedMB := 0
+		for _, out := range c.EffectiveOutputs() {
+			switch {
+			case out.Syslog == nil:
+			case out.Syslog.QueueMaxMB > 0:
+				queuedMB += out.Syslog.QueueMaxMB
+			default:
+				queuedMB += out.Syslog.QueueSize * out.Syslog.MaxMessageSize >> 20
+			}
+		}
+		if queuedMB > c.MemoryLimitMB/2 {
+			warnings = append(warnings, Finding{
+				Setting: "runtime.memory_limit_mb",
+				Message: fmt.Sprintf("the syslog output queues may hold %d MB, over half of the %d MB memory limit, leaving little room for event pages",
+					queuedMB, c.MemoryLimitMB),
+				Hint: "set or lower queue_max_mb on the syslog outputs, or raise memory_limit_mb",
+			})
+		}
+	}
+
+	if c.CustomSourceIP != "" {
+		if c.UseEventIP {
+			warnings = append(warnings, Finding{
+				Setting: "syslog.custom_source_ip",
+				Message: "custom_source_ip is ignored while use_event_ip_as_source is enabled",
+				Hint:    "remove custom_source_ip or disable use_event_ip_as_source",
+			})
+		} else if net.ParseIP(c.CustomSourceIP) == nil {
+			warnings = append(warnings, Finding{
+				Setting: "syslog.custom_source_ip",
+				Message: fmt.Sprintf("custom_source_ip '%s' is not an IP address; it is sent as the syslog hostname as is", c.CustomSourceIP),
+				Hint:    "set an IPv4 or IPv6 address, or leave it empty to send the local hostname",
+			})
+		}
+	}
+
+	if c.AdminListen != "" && !loopbackAddress(c.AdminListen) {
+		finding := Finding{
+			Setting: "admin.listen",
+			Message: fmt.Sprintf("the admin endpoint on %s accepts connections from other hosts, which can read runtime stats and recent errors without authentication", c.AdminListen),
+			Hint:    "bind admin.listen to 127.0.0.1 or a management network only",
+		}
+		if c.AdminToken == "" {
+			finding.Message = fmt.Sprintf("the admin endpoint on %s accepts connections from other hosts, which can read runtime stats and pause fetching without a token", c.AdminListen)
+			finding.Hint = "bind admin.listen to 127.0.0.1, or set admin.token so pausing requires it"
+		}
+		warnings = append(warnings, finding)
+	}
+
+	return warnings
+}
+
+// loopbackAddress reports whether a host:port listen address only accepts
+// connections from this host
+func loopbackAddress(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// outputSetting names a setting of an output as it appears in the config
+func outputSetting(c *Config, out Output, key string) string {
+	if len(c.Outputs) == 0 {
+		return "syslog." + key
+	}
+	for i := range c.Outputs {
+		if c.Outputs[i].Name == out.Name {
+			return fmt.Sprintf("outputs[%d].syslog.%s", i, key)
+		}
+	}
+	return key
+}
+
+// Write prints the report for an operator, errors first
+func (r Report) Write(w io.Writer) {
+	write := func(label string, findings []Finding) {
+		for _, finding := range findings {
+			if finding.Setting != "" {
+				fmt.Fprintf(w, "%s %s: %s\n", label, finding.Setting, finding.Message)
+			} else {
+				fmt.Fprintf(w, "%s %s\n", label, finding.Message)
+			}
+			if finding.Hint != "" {
+				fmt.Fprintf(w, "  hint: %s\n", finding.Hint)
+			}
+		}
+	}
+	write("ERROR", r.Errors)
+	write("WARNING", r.Warnings)
+	fmt.Fprintf(w, "%d error(s), %d warning(s)\n", len(r.Errors), len(r.Warnings))
+}
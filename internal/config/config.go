@@ -1,103 +1,466 @@
 package config
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"net"
 	"os"
+	"strconv"
+	"time"
+
+	"cato-logger/internal/cef"
+	"cato-logger/internal/secrets"
 )
 
 // Config holds all the program configuration
 type Config struct {
 	// Cato API
-	CatoAPIURL    string
-	CatoAPIKey    string
-	CatoAccountID string
+	CatoAPIURL     string
+	CatoAPIKey     string
+	CatoAPIKeyNext string // Tried when CatoAPIKey is rejected, for key rotation
+	CatoAccountID  string
+	CatoAccountIDs []string // Polled in parallel instead of CatoAccountID when set
+	CatoQueryFile  string   // Optional custom eventsFeed query
+	DeploymentID   string   // Optional, sent in the User-Agent to tell deployments apart
+
+	// Sub-account discovery
+	DiscoveryParentID string   // Reseller account whose child accounts are polled
+	DiscoveryInterval int      // Minutes between re-discovery, 0 only discovers at startup
+	DiscoveryExclude  []string // Child account IDs never polled
 
 	// Syslog
 	SyslogServer   string
 	SyslogPort     int
 	SyslogProtocol string
+	SyslogBindAddr string // Local IP syslog connections leave from, empty lets the OS choose
+	SyslogIPPref   string // Address family tried first when the server name has both: ipv4 or ipv6
 	MaxMsgSize     int
 	UseEventIP     bool
 	CustomSourceIP string
 
+	// Outputs, in addition to or instead of the syslog section (see EffectiveOutputs)
+	Outputs []Output
+
+	// Feeds to poll, defaults to eventsFeed only (see EffectiveFeeds)
+	Feeds []Feed
+
 	// CEF
 	CEFVendor     string
 	CEFProduct    string
 	CEFVersion    string
 	FieldMappings map[string]string
 	OrderedFields []string
+	CEFLimits     cef.Limits            // Extension value length caps
+	CEFTimestamps cef.Timestamps        // Event fields rt/start/end are set from
+	CEFUnmapped   cef.Unmapped          // Which unmapped fields are sent
+	CEFSeverity   cef.Severity          // Event fields the severity is read from
+	CEFProfiles   map[string]CEFProfile // Mapping profiles by event_type, replacing a feed's mapping
+
+	// OCSF
+	OCSFClasses map[string]int // Class overrides keyed by event type or "type/sub type"
+
+	// ECS
+	ECSFieldMappings map[string]string // Overrides of ecs.DefaultFieldMappings, empty targets remove one
+
+	// Templates
+	Templates map[string]string // text/template sources by name, for template:<name> formats
+
+	// Transform
+	Transform TransformConfig
+
+	// Labels
+	Labels            map[string]string // Static fields set on every outgoing event
+	DeploymentIDField string            // Field carrying DeploymentID on every outgoing event, empty leaves it out
+
+	// Redaction
+	Redaction RedactionConfig
+
+	// Sampling
+	Sampling SamplingConfig
+
+	// Aggregation
+	Aggregation AggregationConfig
+
+	// Enrichment
+	LookupTables []LookupTable
 
 	// Processing
 	FetchInterval   int
 	MaxEvents       int
 	MaxPagination   int
+	CatchUpBudget   int // Seconds a cycle keeps paginating past MaxPagination while the feed has more, 0 disables
 	RetryAttempts   int
 	RetryDelay      int
 	MaxBackoffDelay int
 	ConnTimeout     int
+	MaxConcurrency  int // Accounts fetched in parallel
+	FormatWorkers   int // Goroutines formatting a large batch for each output
+	PageDelay       int // Milliseconds between page fetches of a cycle
+	DrainLag        int // Seconds of event lag above which pages are fetched without PageDelay, 0 disables
+	// API call phases; dial, TLS, and header timeouts default to ConnTimeout
+	DialTimeout           int
+	TLSHandshakeTimeout   int
+	ResponseHeaderTimeout int
+	BodyReadTimeout       int
+	// Response guardrails, see api.Limits
+	MaxResponseMB       int    // Decompressed response body size, 0 disables
+	MaxPageEvents       int    // Events in a single page, 0 disables
+	ResponseLimitAction string // abort or warn
 
 	// State
-	MarkerFile string
+	MarkerFile    string
+	MarkerHistory int    // Saved markers kept next to each marker file for rollback, 0 disables
+	MarkerJournal bool   // Keep each page's per-output progress next to the marker file
+	JournalChunk  int    // Events an output takes between journal writes
+	StateKey      string // Base64 AES-256 key encrypting marker and history files, empty disables
+	StaleMarker   int    // Minutes without a marker advance before a feed is reported stuck, 0 disables
+
+	// Dead-letter queue
+	DeadLetterFile        string // JSONL file for events that cannot be formatted or delivered, empty disables
+	DeadLetterMaxAttempts int    // Consecutive failed deliveries of a page before its events are dead-lettered
+	DeadLetterMaxMB       int    // Size at which the file stops accepting entries, 0 for unlimited
 
 	// Logging
-	LogLevel  string
-	LogFormat string
-	LogOutput string
+	LogLevel           string
+	LogFormat          string
+	LogOutput          string
+	LogComponentLevels map[string]string
+	LogRotation        LogRotation
+	LogFacility        string
+	StartupReportFile  string // JSON startup report written here as well as to the log, empty logs it only
+	LogDedupWindow     int    // Seconds to collapse repeated warnings/errors, 0 disables
+
+	// Preflight
+	MinFreeDiskMB          int // Free space required in the marker/log directories, 0 disables
+	ClockSkewWarn          int // Seconds of clock skew against the API that log a warning, 0 disables
+	ClockSkewFail          int // Seconds of clock skew against the API that fail startup, 0 disables
+	PreflightChecks        map[string]PreflightCheck
+	PreflightRetry         bool // Retry failed checks at startup instead of exiting
+	PreflightRetryMaxDelay int  // Seconds, cap for the retry backoff
+	PreflightRetryTimeout  int  // Seconds to keep retrying, 0 retries until stopped
+
+	// Reload
+	WatchConfig   bool
+	WatchInterval int
+
+	// Stats
+	StatsInterval int     // Minutes between periodic stats reports, 0 disables
+	SLOLatency    int     // Seconds from event time to delivery the SLO allows, 0 disables
+	SLOTarget     float64 // Percent of events that must be delivered within SLOLatency
+	SLOWindow     int     // Minutes per SLO evaluation window
+
+	// Admin
+	AdminListen string // Address of the admin/metrics HTTP endpoint, empty disables
+	AdminToken  string // Bearer token required to pause and resume, empty requires the X-Cato-Logger-Admin header
+
+	// Runtime
+	GCPercent     *int // Garbage collector target (GOGC), nil keeps the Go default
+	MemoryLimitMB int  // Soft memory limit of the process (GOMEMLIMIT), 0 for none
+
+	// Debug
+	APICaptureDir   string // Directory for raw API request/response captures, empty disables
+	APICaptureCount int    // Number of most recent API calls kept
 
 	// Runtime (not from JSON)
 	Verbose    bool
+	Once       bool // Run a single processing cycle and exit
 	ConfigPath string
+	Overrides  Overrides
+}
+
+// Overrides holds command-line values that take precedence over the config file.
+// Zero values mean "not set".
+type Overrides struct {
+	AccountID      string
+	SyslogServer   string
+	SyslogPort     int
+	SyslogProtocol string
+	FetchInterval  int
+	MarkerFile     string
+	LogLevel       string
+	LogFormat      string
+}
+
+// apply copies every set override onto the config
+func (o Overrides) apply(c *Config) {
+	if o.AccountID != "" {
+		c.CatoAccountID = o.AccountID
+	}
+	if o.SyslogServer != "" {
+		c.SyslogServer = o.SyslogServer
+	}
+	if o.SyslogPort != 0 {
+		c.SyslogPort = o.SyslogPort
+	}
+	if o.SyslogProtocol != "" {
+		c.SyslogProtocol = o.SyslogProtocol
+	}
+	if o.FetchInterval != 0 {
+		c.FetchInterval = o.FetchInterval
+	}
+	if o.MarkerFile != "" {
+		c.MarkerFile = o.MarkerFile
+	}
+	if o.LogLevel != "" {
+		c.LogLevel = o.LogLevel
+	}
+	if o.LogFormat != "" {
+		c.LogFormat = o.LogFormat
+	}
+}
+
+// CEFProfile is the CEF field mapping of one event type
+type CEFProfile struct {
+	FieldMappings map[string]string `json:"field_mappings"`
+	OrderedFields []string          `json:"ordered_fields"` // Defaults to the feed's ordered fields
+}
+
+// TransformConfig holds the field transformations applied to events before formatting
+type TransformConfig struct {
+	DropFields      []string          `json:"drop_fields"`
+	RenameFields    map[string]string `json:"rename_fields"`
+	Replace         []ReplaceRule     `json:"replace"`
+	AddFields       map[string]string `json:"add_fields"`
+	LowercaseFields []string          `json:"lowercase_fields"`
+}
+
+// ReplaceRule is a regex find/replace applied to a single field's value
+type ReplaceRule struct {
+	Field       string `json:"field"`
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement"`
+}
+
+// RedactionConfig holds the PII masking rules applied before events leave the forwarder
+type RedactionConfig struct {
+	Salt  string          `json:"salt"`
+	Rules []RedactionRule `json:"rules"`
+}
+
+// RedactionRule hashes or masks the listed fields, optionally only for IPs inside CIDRs
+type RedactionRule struct {
+	Fields []string `json:"fields"`
+	Action string   `json:"action"`
+	CIDRs  []string `json:"cidrs"`
+}
+
+// SamplingConfig holds the share of events forwarded for high-volume event types
+type SamplingConfig struct {
+	Rates   map[string]int `json:"rates"`    // Forward 1 in N events, keyed by event type or "type/sub type"
+	IDField string         `json:"id_field"` // Field identifying an event, empty hashes every field
+}
+
+// AggregationConfig holds the event types rolled up into one event per key
+// and time window
+type AggregationConfig struct {
+	EventTypes    []string `json:"event_types"`    // Event types or "type/sub type" rolled up
+	KeyFields     []string `json:"key_fields"`     // Fields events must share to be rolled up
+	SumFields     []string `json:"sum_fields"`     // Numeric fields summed over the rolled-up events
+	WindowSeconds int      `json:"window_seconds"` // Event time window, defaults to 60
+}
+
+// LookupTable describes a CSV/JSON file joined against events during enrichment
+type LookupTable struct {
+	Name         string            `json:"name"`
+	Path         string            `json:"path"`
+	Format       string            `json:"format"`
+	EventField   string            `json:"event_field"`
+	KeyColumn    string            `json:"key_column"`
+	OutputFields map[string]string `json:"output_fields"`
+}
+
+// LogRotation controls rotation when logging.output is a file
+type LogRotation struct {
+	MaxSizeMB  int  `json:"max_size_mb"`
+	MaxBackups int  `json:"max_backups"`
+	MaxAgeDays int  `json:"max_age_days"`
+	Compress   bool `json:"compress"`
+}
+
+// PreflightCheck adjusts a single pre-flight check
+type PreflightCheck struct {
+	Enabled        *bool `json:"enabled"`   // Defaults to true
+	WarnOnly       bool  `json:"warn_only"` // Log failures as warnings instead of blocking startup
+	TimeoutSeconds int   `json:"timeout_seconds"`
 }
 
 // jsonConfig represents the JSON structure
 type jsonConfig struct {
-	Cato struct {
-		APIURL    string `json:"api_url"`
-		APIKey    string `json:"api_key"`
-		AccountID string `json:"account_id"`
+	Schema   string   `json:"$schema"`  // Optional, lets editors attach the exported schema
+	Includes []string `json:"includes"` // Files merged in before this one, see readConfigDocument
+	Cato     struct {
+		APIURL     string   `json:"api_url"`
+		APIKey     string   `json:"api_key"`
+		APIKeyNext string   `json:"api_key_next"`
+		AccountID  string   `json:"account_id"`
+		AccountIDs []string `json:"account_ids"`
+		QueryFile  string   `json:"query_file"`
+		Deployment string   `json:"deployment_id"`
+		Discovery  struct {
+			ParentAccountID        string   `json:"parent_account_id"`
+			RefreshIntervalMinutes *int     `json:"refresh_interval_minutes"`
+			Exclude                []string `json:"exclude"`
+		} `json:"discovery"`
 	} `json:"cato"`
 	Syslog struct {
 		Server             string `json:"server"`
 		Port               int    `json:"port"`
 		Protocol           string `json:"protocol"`
+		BindAddress        string `json:"bind_address"`
+		IPPreference       string `json:"ip_preference"`
 		MaxMessageSize     int    `json:"max_message_size"`
 		UseEventIPAsSource bool   `json:"use_event_ip_as_source"`
 		CustomSourceIP     string `json:"custom_source_ip"`
 	} `json:"syslog"`
-	CEF struct {
-		Vendor        string            `json:"vendor"`
-		Product       string            `json:"product"`
-		Version       string            `json:"version"`
-		FieldMappings map[string]string `json:"field_mappings"`
-		OrderedFields []string          `json:"ordered_fields"`
+	Outputs []Output `json:"outputs"`
+	Feeds   []Feed   `json:"feeds"`
+	CEF     struct {
+		Vendor          string            `json:"vendor"`
+		Product         string            `json:"product"`
+		Version         string            `json:"version"`
+		FieldMappings   map[string]string `json:"field_mappings"`
+		OrderedFields   []string          `json:"ordered_fields"`
+		MaxValueLength  int               `json:"max_value_length"`
+		FieldMaxLength  map[string]int    `json:"field_max_length"`
+		TimestampFields map[string]string `json:"timestamp_fields"`
+		Timezone        string            `json:"timezone"`
+		Unmapped        struct {
+			Mode      string   `json:"mode"`
+			Allowlist []string `json:"allowlist"`
+			PackKey   string   `json:"pack_key"`
+		} `json:"unmapped"`
+		Severity struct {
+			Fields []string       `json:"fields"`
+			Levels map[string]int `json:"levels"`
+		} `json:"severity"`
+		Profiles map[string]CEFProfile `json:"profiles"`
 	} `json:"cef"`
+	OCSF struct {
+		Classes map[string]int `json:"classes"`
+	} `json:"ocsf"`
+	ECS struct {
+		FieldMappings map[string]string `json:"field_mappings"`
+	} `json:"ecs"`
+	Templates map[string]string `json:"templates"`
+	Transform TransformConfig   `json:"transform"`
+	Labels    struct {
+		Fields            map[string]string `json:"fields"`
+		DeploymentIDField string            `json:"deployment_id_field"`
+	} `json:"labels"`
+	Redaction   RedactionConfig   `json:"redaction"`
+	Sampling    SamplingConfig    `json:"sampling"`
+	Aggregation AggregationConfig `json:"aggregation"`
+	Enrichment  struct {
+		LookupTables []LookupTable `json:"lookup_tables"`
+	} `json:"enrichment"`
 	Processing struct {
-		FetchIntervalSeconds     int `json:"fetch_interval_seconds"`
-		MaxEventsPerRequest      int `json:"max_events_per_request"`
-		MaxPaginationRequests    int `json:"max_pagination_requests"`
-		RetryAttempts            int `json:"retry_attempts"`
-		RetryDelaySeconds        int `json:"retry_delay_seconds"`
-		MaxBackoffDelaySeconds   int `json:"max_backoff_delay_seconds"`
-		ConnectionTimeoutSeconds int `json:"connection_timeout_seconds"`
+		FetchIntervalSeconds     int    `json:"fetch_interval_seconds"`
+		MaxEventsPerRequest      int    `json:"max_events_per_request"`
+		MaxPaginationRequests    int    `json:"max_pagination_requests"`
+		CatchUpBudgetSeconds     int    `json:"catch_up_budget_seconds"`
+		RetryAttempts            *int   `json:"retry_attempts"`
+		RetryDelaySeconds        *int   `json:"retry_delay_seconds"`
+		MaxBackoffDelaySeconds   int    `json:"max_backoff_delay_seconds"`
+		ConnectionTimeoutSeconds int    `json:"connection_timeout_seconds"`
+		MaxConcurrentAccounts    int    `json:"max_concurrent_accounts"`
+		FormatWorkers            int    `json:"format_workers"`
+		PageDelayMS              int    `json:"page_delay_ms"`
+		DrainLagSeconds          int    `json:"drain_lag_seconds"`
+		DialTimeoutSeconds       int    `json:"dial_timeout_seconds"`
+		TLSHandshakeSeconds      int    `json:"tls_handshake_timeout_seconds"`
+		ResponseHeaderSeconds    int    `json:"response_header_timeout_seconds"`
+		BodyReadSeconds          int    `json:"body_read_timeout_seconds"`
+		MaxResponseSizeMB        *int   `json:"max_response_size_mb"`
+		MaxEventsPerPage         *int   `json:"max_events_per_page"`
+		ResponseLimitAction      string `json:"response_limit_action"`
 	} `json:"processing"`
 	State struct {
-		MarkerFile string `json:"marker_file"`
+		MarkerFile    string `json:"marker_file"`
+		HistorySize   *int   `json:"history_size"`
+		Journal       bool   `json:"journal"`
+		JournalChunk  int    `json:"journal_chunk_events"`
+		EncryptionKey string `json:"encryption_key"`
+		StaleMinutes  int    `json:"stale_after_minutes"`
 	} `json:"state"`
+	DeadLetter struct {
+		File                string `json:"file"`
+		MaxDeliveryAttempts int    `json:"max_delivery_attempts"`
+		MaxSizeMB           *int   `json:"max_size_mb"`
+	} `json:"dead_letter"`
 	Logging struct {
-		Level  string `json:"level"`
-		Format string `json:"format"`
-		Output string `json:"output"`
+		Level           string            `json:"level"`
+		Format          string            `json:"format"`
+		Output          string            `json:"output"`
+		ComponentLevels map[string]string `json:"component_levels"`
+		Rotation        LogRotation       `json:"rotation"`
+		SyslogFacility  string            `json:"syslog_facility"`
+		DedupWindowSecs *int              `json:"dedup_window_seconds"`
+		StartupReport   string            `json:"startup_report_file"`
 	} `json:"logging"`
+	Preflight struct {
+		MinFreeDiskMB        *int                      `json:"min_free_disk_mb"`
+		ClockSkewWarnSeconds *int                      `json:"clock_skew_warn_seconds"`
+		ClockSkewFailSeconds *int                      `json:"clock_skew_fail_seconds"`
+		Checks               map[string]PreflightCheck `json:"checks"`
+		RetryOnFailure       bool                      `json:"retry_on_failure"`
+		RetryMaxDelaySeconds int                       `json:"retry_max_delay_seconds"`
+		RetryTimeoutSeconds  int                       `json:"retry_timeout_seconds"`
+	} `json:"preflight"`
+	Reload struct {
+		WatchConfig         bool `json:"watch_config"`
+		PollIntervalSeconds int  `json:"poll_interval_seconds"`
+	} `json:"reload"`
+	Stats struct {
+		ReportIntervalMinutes int `json:"report_interval_minutes"`
+		SLO                   struct {
+			LatencySeconds int     `json:"latency_seconds"`
+			TargetPercent  float64 `json:"target_percent"`
+			WindowMinutes  int     `json:"window_minutes"`
+		} `json:"slo"`
+	} `json:"stats"`
+	Admin struct {
+		Listen string `json:"listen"`
+		Token  string `json:"token"`
+	} `json:"admin"`
+	Runtime struct {
+		GOGC          *int `json:"gogc"`
+		MemoryLimitMB int  `json:"memory_limit_mb"`
+	} `json:"runtime"`
+	Debug struct {
+		APICaptureDir   string `json:"api_capture_dir"`
+		APICaptureCount int    `json:"api_capture_count"`
+	} `json:"debug"`
 }
 
-// Load reads configuration from JSON file
+// Load reads configuration from JSON file, with the service's command-line
+// flags
 func Load() (*Config, error) {
+	return LoadArgs(flag.CommandLine, os.Args[1:])
+}
+
+// LoadArgs reads configuration like Load, parsing the service's flags from
+// args into fs
+func LoadArgs(fs *flag.FlagSet, args []string) (*Config, error) {
 	// Parse minimal CLI flags
-	configPath := flag.String("config", "", "Path to config.json file")
-	verbose := flag.Bool("verbose", false, "Enable verbose debug output")
-	flag.Parse()
+	configPath := fs.String("config", "", "Path to config.json file")
+	verbose := fs.Bool("verbose", false, "Enable verbose debug output")
+	once := fs.Bool("once", false, "Run a single processing cycle and exit")
+
+	// Overrides for common settings (take precedence over the config file)
+	var overrides Overrides
+	fs.StringVar(&overrides.AccountID, "account-id", "", "Override cato.account_id")
+	fs.StringVar(&overrides.SyslogServer, "syslog-server", "", "Override syslog.server")
+	fs.IntVar(&overrides.SyslogPort, "syslog-port", 0, "Override syslog.port")
+	fs.StringVar(&overrides.SyslogProtocol, "syslog-protocol", "", "Override syslog.protocol (tcp, udp or relp)")
+	fs.IntVar(&overrides.FetchInterval, "fetch-interval", 0, "Override processing.fetch_interval_seconds")
+	fs.StringVar(&overrides.MarkerFile, "marker-file", "", "Override state.marker_file")
+	fs.StringVar(&overrides.LogLevel, "log-level", "", "Override logging.level")
+	fs.StringVar(&overrides.LogFormat, "log-format", "", "Override logging.format")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
 
 	// Find config file
 	path, err := findConfigFile(*configPath)
@@ -113,7 +476,15 @@ func Load() (*Config, error) {
 
 	// Set runtime flags
 	cfg.Verbose = *verbose
+	cfg.Once = *once
 	cfg.ConfigPath = path
+	cfg.Overrides = overrides
+	cfg.Overrides.apply(cfg)
+
+	// Resolve secret references (vault:, aws-sm:, azure-kv:, env:, file:)
+	if err := cfg.resolveSecrets(); err != nil {
+		return nil, err
+	}
 
 	// Override log level to debug if verbose flag is set
 	if cfg.Verbose {
@@ -123,6 +494,27 @@ func Load() (*Config, error) {
 	return cfg, nil
 }
 
+// LoadFile reads configuration for management commands: no CLI overrides are
+// applied. An empty path uses the standard search order.
+func LoadFile(path string) (*Config, error) {
+	path, err := findConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := loadFromJSON(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg.ConfigPath = path
+
+	if err := cfg.resolveSecrets(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
 // findConfigFile searches for config file in order of precedence
 func findConfigFile(explicitPath string) (string, error) {
 	// 1. Explicit path from --config flag (highest precedence)
@@ -150,9 +542,9 @@ func findConfigFile(explicitPath string) (string, error) {
 
 // loadFromJSON reads and parses the JSON config file
 func loadFromJSON(path string) (*Config, error) {
-	data, err := os.ReadFile(path)
+	data, err := readConfigDocument(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
+		return nil, err
 	}
 
 	var jc jsonConfig
@@ -163,52 +555,213 @@ func loadFromJSON(path string) (*Config, error) {
 	// Flatten nested structure into Config struct
 	cfg := &Config{
 		// Cato
-		CatoAPIURL:    jc.Cato.APIURL,
-		CatoAPIKey:    jc.Cato.APIKey,
-		CatoAccountID: jc.Cato.AccountID,
+		CatoAPIURL:     jc.Cato.APIURL,
+		CatoAPIKey:     jc.Cato.APIKey,
+		CatoAPIKeyNext: jc.Cato.APIKeyNext,
+		CatoAccountID:  jc.Cato.AccountID,
+		CatoAccountIDs: jc.Cato.AccountIDs,
+		CatoQueryFile:  jc.Cato.QueryFile,
+		DeploymentID:   jc.Cato.Deployment,
+
+		// Sub-account discovery
+		DiscoveryParentID: jc.Cato.Discovery.ParentAccountID,
+		DiscoveryExclude:  jc.Cato.Discovery.Exclude,
 
 		// Syslog
 		SyslogServer:   jc.Syslog.Server,
 		SyslogPort:     jc.Syslog.Port,
 		SyslogProtocol: jc.Syslog.Protocol,
+		SyslogBindAddr: jc.Syslog.BindAddress,
+		SyslogIPPref:   jc.Syslog.IPPreference,
 		MaxMsgSize:     jc.Syslog.MaxMessageSize,
 		UseEventIP:     jc.Syslog.UseEventIPAsSource,
 		CustomSourceIP: jc.Syslog.CustomSourceIP,
 
+		// Outputs
+		Outputs: jc.Outputs,
+
+		// Feeds
+		Feeds: jc.Feeds,
+
 		// CEF
 		CEFVendor:     jc.CEF.Vendor,
 		CEFProduct:    jc.CEF.Product,
 		CEFVersion:    jc.CEF.Version,
-		FieldMappings: jc.CEF.FieldMappings,
+		FieldMappings: mergeFieldMappings(DefaultFieldMappings, jc.CEF.FieldMappings), // Overrides of the built-in mapping
 		OrderedFields: jc.CEF.OrderedFields,
+		CEFLimits: cef.Limits{
+			MaxValueLength: jc.CEF.MaxValueLength,
+			FieldMaxLength: jc.CEF.FieldMaxLength,
+		},
+		CEFTimestamps: cef.Timestamps{
+			Fields:   jc.CEF.TimestampFields,
+			Timezone: jc.CEF.Timezone,
+		},
+		CEFUnmapped: cef.Unmapped{
+			Mode:      jc.CEF.Unmapped.Mode,
+			Allowlist: jc.CEF.Unmapped.Allowlist,
+			PackKey:   jc.CEF.Unmapped.PackKey,
+		},
+		CEFSeverity: cef.Severity{
+			Fields: jc.CEF.Severity.Fields,
+			Levels: jc.CEF.Severity.Levels,
+		},
+		CEFProfiles: jc.CEF.Profiles,
+
+		// OCSF
+		OCSFClasses: jc.OCSF.Classes,
+
+		// ECS
+		ECSFieldMappings: jc.ECS.FieldMappings,
+
+		// Templates
+		Templates: jc.Templates,
+
+		// Transform
+		Transform: jc.Transform,
+
+		// Labels
+		Labels:            jc.Labels.Fields,
+		DeploymentIDField: jc.Labels.DeploymentIDField,
+
+		// Redaction
+		Redaction: jc.Redaction,
+
+		// Sampling
+		Sampling: jc.Sampling,
+
+		// Aggregation
+		Aggregation: jc.Aggregation,
+
+		// Enrichment
+		LookupTables: jc.Enrichment.LookupTables,
 
 		// Processing
 		FetchInterval:   jc.Processing.FetchIntervalSeconds,
 		MaxEvents:       jc.Processing.MaxEventsPerRequest,
 		MaxPagination:   jc.Processing.MaxPaginationRequests,
-		RetryAttempts:   jc.Processing.RetryAttempts,
-		RetryDelay:      jc.Processing.RetryDelaySeconds,
+		CatchUpBudget:   jc.Processing.CatchUpBudgetSeconds,
 		MaxBackoffDelay: jc.Processing.MaxBackoffDelaySeconds,
 		ConnTimeout:     jc.Processing.ConnectionTimeoutSeconds,
+		MaxConcurrency:  jc.Processing.MaxConcurrentAccounts,
+		FormatWorkers:   jc.Processing.FormatWorkers,
+		PageDelay:       jc.Processing.PageDelayMS,
+		DrainLag:        jc.Processing.DrainLagSeconds,
+
+		DialTimeout:           jc.Processing.DialTimeoutSeconds,
+		TLSHandshakeTimeout:   jc.Processing.TLSHandshakeSeconds,
+		ResponseHeaderTimeout: jc.Processing.ResponseHeaderSeconds,
+		BodyReadTimeout:       jc.Processing.BodyReadSeconds,
+		ResponseLimitAction:   jc.Processing.ResponseLimitAction,
 
 		// State
-		MarkerFile: jc.State.MarkerFile,
+		MarkerFile:    jc.State.MarkerFile,
+		StateKey:      jc.State.EncryptionKey,
+		MarkerJournal: jc.State.Journal,
+		JournalChunk:  jc.State.JournalChunk,
+		StaleMarker:   jc.State.StaleMinutes,
+
+		// Dead-letter queue
+		DeadLetterFile:        jc.DeadLetter.File,
+		DeadLetterMaxAttempts: jc.DeadLetter.MaxDeliveryAttempts,
 
 		// Logging
-		LogLevel:  jc.Logging.Level,
-		LogFormat: jc.Logging.Format,
-		LogOutput: jc.Logging.Output,
-	}
+		LogLevel:           jc.Logging.Level,
+		LogFormat:          jc.Logging.Format,
+		LogOutput:          jc.Logging.Output,
+		LogComponentLevels: jc.Logging.ComponentLevels,
+		LogRotation:        jc.Logging.Rotation,
+		LogFacility:        jc.Logging.SyslogFacility,
+		StartupReportFile:  jc.Logging.StartupReport,
+
+		// Preflight
+		PreflightChecks:        jc.Preflight.Checks,
+		PreflightRetry:         jc.Preflight.RetryOnFailure,
+		PreflightRetryMaxDelay: jc.Preflight.RetryMaxDelaySeconds,
+		PreflightRetryTimeout:  jc.Preflight.RetryTimeoutSeconds,
 
-	// Enforce max events limit
-	if cfg.MaxEvents > 5000 {
-		cfg.MaxEvents = 5000
+		// Reload
+		WatchConfig:   jc.Reload.WatchConfig,
+		WatchInterval: jc.Reload.PollIntervalSeconds,
+
+		// Stats
+		StatsInterval: jc.Stats.ReportIntervalMinutes,
+		SLOLatency:    jc.Stats.SLO.LatencySeconds,
+		SLOTarget:     jc.Stats.SLO.TargetPercent,
+		SLOWindow:     jc.Stats.SLO.WindowMinutes,
+
+		// Admin
+		AdminListen: jc.Admin.Listen,
+		AdminToken:  jc.Admin.Token,
+
+		// Runtime
+		GCPercent:     jc.Runtime.GOGC,
+		MemoryLimitMB: jc.Runtime.MemoryLimitMB,
+
+		// Debug
+		APICaptureDir:   jc.Debug.APICaptureDir,
+		APICaptureCount: jc.Debug.APICaptureCount,
 	}
 
+	applyDefaults(cfg, &jc)
+
 	return cfg, nil
 }
 
 // SyslogAddress returns the formatted syslog server address
 func (c *Config) SyslogAddress() string {
-	return fmt.Sprintf("%s:%d", c.SyslogServer, c.SyslogPort)
+	return net.JoinHostPort(c.SyslogServer, strconv.Itoa(c.SyslogPort))
+}
+
+// secretTargets returns the settings that may hold secret references, keyed by config path
+func (c *Config) secretTargets() map[string]*string {
+	targets := map[string]*string{
+		"cato.api_key":         &c.CatoAPIKey,
+		"cato.api_key_next":    &c.CatoAPIKeyNext,
+		"redaction.salt":       &c.Redaction.Salt,
+		"state.encryption_key": &c.StateKey,
+		"admin.token":          &c.AdminToken,
+	}
+	for i := range c.Outputs {
+		if c.Outputs[i].Sentinel != nil {
+			targets[fmt.Sprintf("outputs[%d].sentinel.shared_key", i)] = &c.Outputs[i].Sentinel.SharedKey
+		}
+		if c.Outputs[i].NATS != nil {
+			targets[fmt.Sprintf("outputs[%d].nats.password", i)] = &c.Outputs[i].NATS.Password
+			targets[fmt.Sprintf("outputs[%d].nats.token", i)] = &c.Outputs[i].NATS.Token
+		}
+		if c.Outputs[i].AMQP != nil {
+			targets[fmt.Sprintf("outputs[%d].amqp.password", i)] = &c.Outputs[i].AMQP.Password
+		}
+		if c.Outputs[i].Firehose != nil {
+			targets[fmt.Sprintf("outputs[%d].firehose.credentials.secret_access_key", i)] = &c.Outputs[i].Firehose.Credentials.SecretAccessKey
+		}
+		if c.Outputs[i].S3 != nil {
+			targets[fmt.Sprintf("outputs[%d].s3.credentials.secret_access_key", i)] = &c.Outputs[i].S3.Credentials.SecretAccessKey
+		}
+		if c.Outputs[i].GRPC != nil {
+			targets[fmt.Sprintf("outputs[%d].grpc.token", i)] = &c.Outputs[i].GRPC.Token
+		}
+		if c.Outputs[i].Loki != nil {
+			targets[fmt.Sprintf("outputs[%d].loki.password", i)] = &c.Outputs[i].Loki.Password
+			targets[fmt.Sprintf("outputs[%d].loki.token", i)] = &c.Outputs[i].Loki.Token
+		}
+		if c.Outputs[i].Datadog != nil {
+			targets[fmt.Sprintf("outputs[%d].datadog.api_key", i)] = &c.Outputs[i].Datadog.APIKey
+		}
+	}
+	return targets
+}
+
+// resolveSecrets replaces secret references with the values they point to
+func (c *Config) resolveSecrets() error {
+	timeout := time.Duration(c.ConnTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return secrets.NewResolver(timeout).ResolveAll(ctx, c.secretTargets())
 }
@@ -0,0 +1,311 @@
+package config
+
+import (
+	"runtime"
+
+	"cato-logger/internal/cef"
+)
+
+// Defaults for settings a config leaves out
+const (
+	DefaultAPIURL          = "https://api.catonetworks.com/api/v1/graphql2"
+	DefaultFetchInterval   = 60 // Seconds between polls
+	DefaultMaxEvents       = 1000
+	DefaultMaxPagination   = 10
+	DefaultRetryAttempts   = 3
+	DefaultRetryDelay      = 2   // Seconds
+	DefaultMaxBackoffDelay = 300 // Seconds
+	DefaultConnTimeout     = 30  // Seconds
+	DefaultLogLevel        = "info"
+	DefaultLogFormat       = "json"
+	DefaultLogOutput       = "stdout"
+	DefaultCEFVendor       = "Cato Networks"
+	DefaultCEFProduct      = "SASE Platform"
+	DefaultCEFVersion      = "1.0"
+
+	DefaultMaxConcurrency         = 4     // Accounts fetched in parallel
+	DefaultBodyReadTimeout        = 300   // Seconds
+	DefaultMaxResponseMB          = 256   // 0 disables the limit
+	DefaultMaxPageEvents          = 10000 // 0 disables the limit
+	DefaultResponseLimitAction    = "abort"
+	DefaultDiscoveryInterval      = 60  // Minutes, 0 discovers at startup only
+	DefaultLogDedupWindow         = 60  // Seconds, 0 disables
+	DefaultMinFreeDiskMB          = 100 // 0 disables the check
+	DefaultClockSkewWarn          = 30  // Seconds
+	DefaultClockSkewFail          = 300 // Seconds
+	DefaultPreflightRetryMaxDelay = 60  // Seconds
+	DefaultAggregationWindow      = 60  // Seconds
+	DefaultJournalChunk           = 100 // Events per journal write
+	DefaultMarkerHistory          = 50
+	DefaultDeadLetterMaxAttempts  = 3
+	DefaultDeadLetterMaxMB        = 100 // 0 for no limit
+	DefaultAPICaptureCount        = 20
+	DefaultSLOTarget              = 95 // Percent
+	DefaultSLOWindow              = 15 // Minutes
+	DefaultWatchInterval          = 5  // Seconds
+)
+
+// MaxEventsLimit is the largest page the eventsFeed API returns
+const MaxEventsLimit = 5000
+
+// applyDefaults fills in the settings a config left out. jc is the config
+// as read, for settings where an explicit 0 differs from leaving them out.
+func applyDefaults(cfg *Config, jc *jsonConfig) {
+	// Settings left out take the defaults, so a minimal config only needs
+	// credentials and a destination; retries may be explicitly disabled with 0
+	if cfg.CatoAPIURL == "" {
+		cfg.CatoAPIURL = DefaultAPIURL
+	}
+	if cfg.FetchInterval == 0 {
+		cfg.FetchInterval = DefaultFetchInterval
+	}
+	if cfg.MaxEvents == 0 {
+		cfg.MaxEvents = DefaultMaxEvents
+	}
+	if cfg.MaxPagination == 0 {
+		cfg.MaxPagination = DefaultMaxPagination
+	}
+	cfg.RetryAttempts = DefaultRetryAttempts
+	if jc.Processing.RetryAttempts != nil {
+		cfg.RetryAttempts = *jc.Processing.RetryAttempts
+	}
+	cfg.RetryDelay = DefaultRetryDelay
+	if jc.Processing.RetryDelaySeconds != nil {
+		cfg.RetryDelay = *jc.Processing.RetryDelaySeconds
+	}
+	if cfg.MaxBackoffDelay == 0 {
+		cfg.MaxBackoffDelay = DefaultMaxBackoffDelay
+	}
+	if cfg.ConnTimeout == 0 {
+		cfg.ConnTimeout = DefaultConnTimeout
+	}
+	if cfg.LogLevel == "" {
+		cfg.LogLevel = DefaultLogLevel
+	}
+	if cfg.LogFormat == "" {
+		cfg.LogFormat = DefaultLogFormat
+	}
+	if cfg.LogOutput == "" {
+		cfg.LogOutput = DefaultLogOutput
+	}
+	if cfg.CEFVendor == "" {
+		cfg.CEFVendor = DefaultCEFVendor
+	}
+	if cfg.CEFProduct == "" {
+		cfg.CEFProduct = DefaultCEFProduct
+	}
+	if cfg.CEFVersion == "" {
+		cfg.CEFVersion = DefaultCEFVersion
+	}
+
+	// Enforce max events limit
+	if cfg.MaxEvents > MaxEventsLimit {
+		cfg.MaxEvents = MaxEventsLimit
+	}
+
+	// The built-in key order applies unless ordered fields are set; [] orders all keys alphabetically
+	if cfg.OrderedFields == nil {
+		cfg.OrderedFields = DefaultOrderedFields
+	}
+
+	// rt carries the event time unless timestamp fields are set; {} sends none
+	if cfg.CEFTimestamps.Fields == nil {
+		cfg.CEFTimestamps.Fields = map[string]string{"rt": "time"}
+	}
+
+	// Severity comes from the event's own fields first; [] keeps the type-based map only
+	if cfg.CEFSeverity.Fields == nil {
+		cfg.CEFSeverity.Fields = []string{"severity", "risk_level"}
+	}
+	if cfg.CEFUnmapped.Mode == "" {
+		cfg.CEFUnmapped.Mode = cef.UnmappedInclude
+	}
+
+	// Repeated warnings/errors are collapsed by default; an explicit 0 disables it
+	cfg.LogDedupWindow = DefaultLogDedupWindow
+	if jc.Logging.DedupWindowSecs != nil {
+		cfg.LogDedupWindow = *jc.Logging.DedupWindowSecs
+	}
+
+	// Require some headroom on the state/log disks unless explicitly disabled
+	cfg.MinFreeDiskMB = DefaultMinFreeDiskMB
+	if jc.Preflight.MinFreeDiskMB != nil {
+		cfg.MinFreeDiskMB = *jc.Preflight.MinFreeDiskMB
+	}
+	cfg.ClockSkewWarn = DefaultClockSkewWarn
+	if jc.Preflight.ClockSkewWarnSeconds != nil {
+		cfg.ClockSkewWarn = *jc.Preflight.ClockSkewWarnSeconds
+	}
+	cfg.ClockSkewFail = DefaultClockSkewFail
+	if jc.Preflight.ClockSkewFailSeconds != nil {
+		cfg.ClockSkewFail = *jc.Preflight.ClockSkewFailSeconds
+	}
+
+	// Re-discover sub-accounts periodically unless set; an explicit 0 only discovers at startup
+	cfg.DiscoveryInterval = DefaultDiscoveryInterval
+	if jc.Cato.Discovery.RefreshIntervalMinutes != nil {
+		cfg.DiscoveryInterval = *jc.Cato.Discovery.RefreshIntervalMinutes
+	}
+
+	// Default parallel account fetches
+	if cfg.MaxConcurrency <= 0 {
+		cfg.MaxConcurrency = DefaultMaxConcurrency
+	}
+
+	// Default formatting goroutines, one per CPU
+	if cfg.FormatWorkers <= 0 {
+		cfg.FormatWorkers = runtime.NumCPU()
+	}
+
+	// Split API timeouts fall back to the single connection timeout; full pages
+	// of 5000 events get a generous body read deadline
+	if cfg.DialTimeout <= 0 {
+		cfg.DialTimeout = cfg.ConnTimeout
+	}
+	if cfg.TLSHandshakeTimeout <= 0 {
+		cfg.TLSHandshakeTimeout = cfg.ConnTimeout
+	}
+	if cfg.ResponseHeaderTimeout <= 0 {
+		cfg.ResponseHeaderTimeout = cfg.ConnTimeout
+	}
+	if cfg.BodyReadTimeout <= 0 {
+		cfg.BodyReadTimeout = DefaultBodyReadTimeout
+	}
+
+	// Response guardrails, far above any legitimate page (the API caps pages
+	// at 5000 events), unless explicitly disabled
+	cfg.MaxResponseMB = DefaultMaxResponseMB
+	if jc.Processing.MaxResponseSizeMB != nil {
+		cfg.MaxResponseMB = *jc.Processing.MaxResponseSizeMB
+	}
+	cfg.MaxPageEvents = DefaultMaxPageEvents
+	if jc.Processing.MaxEventsPerPage != nil {
+		cfg.MaxPageEvents = *jc.Processing.MaxEventsPerPage
+	}
+	if cfg.ResponseLimitAction == "" {
+		cfg.ResponseLimitAction = DefaultResponseLimitAction
+	}
+
+	if len(cfg.Aggregation.EventTypes) > 0 && cfg.Aggregation.WindowSeconds == 0 {
+		cfg.Aggregation.WindowSeconds = DefaultAggregationWindow
+	}
+
+	// Journal progress every few events, so a crash resends at most one
+	// chunk of a page to an output
+	if cfg.JournalChunk <= 0 {
+		cfg.JournalChunk = DefaultJournalChunk
+	}
+
+	// Keep enough marker history to roll back a few hours of polling
+	cfg.MarkerHistory = DefaultMarkerHistory
+	if jc.State.HistorySize != nil {
+		cfg.MarkerHistory = *jc.State.HistorySize
+	}
+
+	// Give a failing output a few cycles to recover before dead-lettering,
+	// and cap the file unless explicitly unlimited
+	if cfg.DeadLetterMaxAttempts <= 0 {
+		cfg.DeadLetterMaxAttempts = DefaultDeadLetterMaxAttempts
+	}
+	cfg.DeadLetterMaxMB = DefaultDeadLetterMaxMB
+	if jc.DeadLetter.MaxSizeMB != nil {
+		cfg.DeadLetterMaxMB = *jc.DeadLetter.MaxSizeMB
+	}
+
+	// Keep the last few API calls when capture is enabled
+	if cfg.APICaptureCount == 0 {
+		cfg.APICaptureCount = DefaultAPICaptureCount
+	}
+
+	// Default startup retry backoff cap
+	if cfg.PreflightRetryMaxDelay <= 0 {
+		cfg.PreflightRetryMaxDelay = DefaultPreflightRetryMaxDelay
+	}
+
+	// A latency SLO holds most events to the limit over short windows
+	if cfg.SLOLatency > 0 {
+		if cfg.SLOTarget == 0 {
+			cfg.SLOTarget = DefaultSLOTarget
+		}
+		if cfg.SLOWindow == 0 {
+			cfg.SLOWindow = DefaultSLOWindow
+		}
+	}
+
+	// Default config watch polling interval
+	if cfg.WatchInterval <= 0 {
+		cfg.WatchInterval = DefaultWatchInterval
+	}
+}
+
+// DefaultFieldMappings maps Cato event fields to CEF extension keys
+var DefaultFieldMappings = map[string]string{
+	"account_id":          "aid",
+	"bytes_in":            "in",
+	"bytes_out":           "out",
+	"dest_country_code":   "dst_country",
+	"dest_ip":             "dst",
+	"dest_port":           "dpt",
+	"protocol":            "proto",
+	"src_country_code":    "src_country",
+	"src_ip":              "src",
+	"src_port":            "spt",
+	"time":                "rt",
+	"device_name":         "client_name",
+	"src_site_name":       "inzone",
+	"dest_site_name":      "outzone",
+	"ad_name":             "suid",
+	"ip_protocol":         "tunnel_protocol",
+	"action":              "action_details",
+	"vpn_user_email":      "logged_on_user",
+	"network_rule":        "rule_name",
+	"device_type":         "host_type",
+	"device_os_type":      "client_type_os",
+	"traffic_direction":   "server_outbound_interface",
+	"domain_name":         "sntdom",
+	"configure_host_name": "hostname",
+	"host_mac":            "dvcmac",
+	"src_is_site_or_vpn":  "inzone",
+}
+
+// DefaultOrderedFields lists CEF extension keys emitted first, in this order
+var DefaultOrderedFields = []string{
+	"rt", "src", "spt", "dst", "dpt", "proto",
+	"in", "out", "aid", "sco", "dco", "suid",
+}
+
+// DefaultAuditFieldMappings maps Cato audit trail fields to CEF extension keys
+var DefaultAuditFieldMappings = map[string]string{
+	"time":         "rt",
+	"account_id":   "aid",
+	"change_type":  "act",
+	"admin":        "suser",
+	"admin_id":     "suid",
+	"api_key_name": "api_key_name",
+	"model_type":   "cs1",
+	"model_name":   "cs2",
+	"object_name":  "cs3",
+	"object_id":    "cs4",
+}
+
+// DefaultAuditOrderedFields lists CEF extension keys emitted first for audit records
+var DefaultAuditOrderedFields = []string{
+	"rt", "act", "suser", "suid", "aid", "cs1", "cs2", "cs3", "cs4",
+}
+
+// mergeFieldMappings returns the defaults with overrides applied; an empty
+// override target removes a default mapping
+func mergeFieldMappings(defaults, overrides map[string]string) map[string]string {
+	merged := make(map[string]string, len(defaults)+len(overrides))
+	for field, target := range defaults {
+		merged[field] = target
+	}
+	for field, target := range overrides {
+		if target == "" {
+			delete(merged, field)
+			continue
+		}
+		merged[field] = target
+	}
+	return merged
+}
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"encoding/json"
+	"io"
+	"text/template"
+)
+
+// ExampleOptions holds the values substituted into a generated example config
+type ExampleOptions struct {
+	APIKey         string
+	AccountID      string
+	SyslogServer   string
+	SyslogPort     int
+	SyslogProtocol string
+	MarkerFile     string
+}
+
+// DefaultExampleOptions returns the values used when the user supplies none
+func DefaultExampleOptions() ExampleOptions {
+	return ExampleOptions{
+		SyslogServer:   "localhost",
+		SyslogPort:     514,
+		SyslogProtocol: "tcp",
+		MarkerFile:     "/etc/cato-logger/last_marker.txt",
+	}
+}
+
+const exampleTemplate = `// Cato Networks CEF Forwarder configuration
+//
+// Comments (// and /* */) are allowed anywhere in this file.
+{
+  "cato": {
+    // GraphQL endpoint for your Cato region
+    "api_url": "https://api.catonetworks.com/api/v1/graphql2",
+    // API key with eventsFeed permissions (Administration > API & Integrations)
+    "api_key": {{json .APIKey}},
+    // Numeric Cato account ID
+    "account_id": {{json .AccountID}}
+  },
+
+  "syslog": {
+    "server": {{json .SyslogServer}},
+    "port": {{.SyslogPort}},
+    // tcp, udp or relp
+    "protocol": {{json .SyslogProtocol}},
+    // Messages longer than this many bytes are truncated; 0 uses 1472 for udp and 8192 otherwise
+    "max_message_size": 0,
+    // Use the event's source IP as the syslog hostname
+    "use_event_ip_as_source": false,
+    // Fixed syslog hostname; empty means the local hostname
+    "custom_source_ip": ""
+  },
+
+  "cef": {
+    "vendor": "Cato Networks",
+    "product": "SASE Platform",
+    "version": "1.0",
+    // Cato field name -> CEF extension key, overriding the built-in mapping
+    // (cato-logger config show prints it); an empty key removes a built-in
+    // mapping. Unmapped fields are emitted under their own name.
+    "field_mappings": {},
+    // CEF extension keys emitted first, in this order; the rest follow alphabetically.
+    // Leave out for the built-in order.
+    // "ordered_fields": ["rt", "src", "spt", "dst", "dpt", "proto"],
+    // Longest extension value kept, in characters; 0 keeps values whole
+    "max_value_length": 0,
+    // Caps by extension key, overriding max_value_length (0 keeps that key whole)
+    "field_max_length": {},
+    // CEF time keys (rt, start, end) -> event field, sent as epoch milliseconds
+    "timestamp_fields": { "rt": "time" },
+    // IANA zone for event times without an offset
+    "timezone": "UTC",
+    "unmapped": {
+      // include (every unmapped field), drop, or allowlist
+      "mode": "include",
+      // Unmapped fields sent in allowlist mode
+      "allowlist": [],
+      // Extension key to send the unmapped fields as one JSON object under, e.g. cs6; empty sends them separately
+      "pack_key": ""
+    },
+    "severity": {
+      // Event fields the CEF severity is read from, before falling back to the event type
+      "fields": ["severity", "risk_level"],
+      // Extra severity names -> 0-10; low, medium, high and critical are built in
+      "levels": {}
+    },
+    // Mappings by event_type used instead of field_mappings, e.g.
+    // "Connectivity": { "field_mappings": { "src_ip": "src" }, "ordered_fields": ["src"] }
+    "profiles": {}
+  },
+
+  "processing": {
+    // How often to poll the eventsFeed (minimum 10)
+    "fetch_interval_seconds": 60,
+    // Events per API page (1-5000)
+    "max_events_per_request": 5000,
+    // Maximum pages fetched per cycle
+    "max_pagination_requests": 50,
+    // Seconds a cycle keeps fetching past max_pagination_requests until it
+    // catches up after an outage (0 disables)
+    "catch_up_budget_seconds": 0,
+    "retry_attempts": 3,
+    "retry_delay_seconds": 5,
+    // Upper bound for exponential backoff after failed cycles
+    "max_backoff_delay_seconds": 300,
+    "connection_timeout_seconds": 30,
+    // Reading a full page may take longer than connecting; see README "API Timeouts"
+    "body_read_timeout_seconds": 300
+  },
+
+  "state": {
+    // Stores the eventsFeed position so restarts resume without duplicates
+    "marker_file": {{json .MarkerFile}}
+  },
+
+  "stats": {
+    // Log a throughput/latency summary every N minutes (0 disables)
+    "report_interval_minutes": 15
+    // Warn when under target_percent of events reach the outputs within
+    // latency_seconds of their event time
+    // "slo": { "latency_seconds": 120, "target_percent": 95, "window_minutes": 15 }
+  },
+
+  "reload": {
+    // Poll this file and apply hot-reloadable changes automatically (SIGHUP always reloads)
+    "watch_config": false,
+    "poll_interval_seconds": 5
+  },
+
+  "logging": {
+    // debug, info, warn or error
+    "level": "info",
+    // json or text
+    "format": "text",
+    // stdout, stderr or a file path
+    "output": "stdout"
+  }
+}
+`
+
+// WriteExample writes a fully commented example configuration to w
+func WriteExample(w io.Writer, opts ExampleOptions) error {
+	tmpl, err := template.New("config").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Parse(exampleTemplate)
+	if err != nil {
+		return err
+	}
+
+	return tmpl.Execute(w, opts)
+}
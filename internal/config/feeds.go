@@ -0,0 +1,163 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// DefaultFeedName names the eventsFeed built when no feeds are listed
+const DefaultFeedName = "events"
+
+// DefaultAuditTimeFrame is the audit trail window polled when none is set
+const DefaultAuditTimeFrame = "last.P1D"
+
+// feedNamePattern keeps feed names safe for use in marker file names
+var feedNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Feed is one Cato API feed to poll. Each feed keeps its own marker and
+// CEF mapping profile.
+type Feed struct {
+	Name          string            `json:"name"`
+	Type          string            `json:"type"`           // events or audit
+	MarkerFile    string            `json:"marker_file"`    // Defaults to state.marker_file, suffixed with the feed name
+	TimeFrame     string            `json:"time_frame"`     // audit only, defaults to last.P1D
+	FieldMappings map[string]string `json:"field_mappings"` // Defaults to cef.field_mappings (events) or the audit profile
+	OrderedFields []string          `json:"ordered_fields"`
+	Filters       []FeedFilter      `json:"filters"` // events only, applied by the API
+}
+
+// FeedFilter is an eventsFeed filter evaluated server-side by the Cato API
+type FeedFilter struct {
+	Field    string   `json:"field"`    // Event field name, e.g. event_type
+	Operator string   `json:"operator"` // is, is_not, in, not_in, ...
+	Values   []string `json:"values"`
+}
+
+// filterOperators lists the operators accepted by the eventsFeed filter input
+var filterOperators = map[string]bool{
+	"is": true, "is_not": true, "in": true, "not_in": true,
+	"contains": true, "not_contains": true, "exists": true, "not_exists": true,
+	"gt": true, "gte": true, "lt": true, "lte": true, "between": true,
+}
+
+// EffectiveFeeds returns the configured feeds with defaults filled in, or a
+// single eventsFeed when no feeds are listed
+func (c *Config) EffectiveFeeds() []Feed {
+	feeds := c.Feeds
+	if len(feeds) == 0 {
+		feeds = []Feed{{Name: DefaultFeedName, Type: "events"}}
+	}
+
+	effective := make([]Feed, len(feeds))
+	for i, feed := range feeds {
+		if feed.MarkerFile == "" {
+			feed.MarkerFile = c.MarkerFile
+			if feed.Name != DefaultFeedName {
+				feed.MarkerFile = c.MarkerFile + "." + feed.Name
+			}
+		}
+
+		switch feed.Type {
+		case "audit":
+			if feed.TimeFrame == "" {
+				feed.TimeFrame = DefaultAuditTimeFrame
+			}
+			if len(feed.FieldMappings) == 0 {
+				feed.FieldMappings = DefaultAuditFieldMappings
+				if len(feed.OrderedFields) == 0 {
+					feed.OrderedFields = DefaultAuditOrderedFields
+				}
+			}
+		default:
+			if len(feed.FieldMappings) == 0 {
+				feed.FieldMappings = c.FieldMappings
+				if len(feed.OrderedFields) == 0 {
+					feed.OrderedFields = c.OrderedFields
+				}
+			}
+		}
+
+		effective[i] = feed
+	}
+	return effective
+}
+
+// MarkerFiles returns the marker file of every effective feed and configured
+// account. Accounts found by discovery are not known up front; their markers
+// sit next to the feed's marker file, which is listed instead.
+func (c *Config) MarkerFiles() []string {
+	var files []string
+	for _, feed := range c.EffectiveFeeds() {
+		if len(c.AccountIDs()) == 0 {
+			files = append(files, feed.MarkerFile)
+		}
+		for _, accountID := range c.AccountIDs() {
+			files = append(files, c.AccountMarkerFile(feed, accountID))
+		}
+	}
+	return files
+}
+
+// validateFeeds checks the feeds list
+func (c *Config) validateFeeds() error {
+	names := make(map[string]bool)
+	for i, feed := range c.Feeds {
+		if !feedNamePattern.MatchString(feed.Name) {
+			return fmt.Errorf("feeds[%d] needs a name made of letters, digits, '-' or '_', got '%s'", i, feed.Name)
+		}
+		if names[feed.Name] {
+			return fmt.Errorf("feeds[%d] reuses the name '%s', feed names must be unique", i, feed.Name)
+		}
+		names[feed.Name] = true
+
+		switch feed.Type {
+		case "events":
+			if feed.TimeFrame != "" {
+				return fmt.Errorf("feeds[%d] (%s): time_frame only applies to audit feeds", i, feed.Name)
+			}
+		case "audit":
+			if len(feed.Filters) > 0 {
+				return fmt.Errorf("feeds[%d] (%s): filters only apply to events feeds", i, feed.Name)
+			}
+		default:
+			return fmt.Errorf("feeds[%d] (%s) has invalid type '%s', must be events or audit", i, feed.Name, feed.Type)
+		}
+
+		for j, filter := range feed.Filters {
+			if err := filter.validate(); err != nil {
+				return fmt.Errorf("feeds[%d] (%s) filters[%d]: %w", i, feed.Name, j, err)
+			}
+		}
+	}
+
+	markers := make(map[string]string)
+	for _, feed := range c.EffectiveFeeds() {
+		if other, ok := markers[feed.MarkerFile]; ok {
+			return fmt.Errorf("feeds '%s' and '%s' share the marker file %s, each feed needs its own", other, feed.Name, feed.MarkerFile)
+		}
+		markers[feed.MarkerFile] = feed.Name
+	}
+	return nil
+}
+
+// validate checks an eventsFeed filter
+func (f FeedFilter) validate() error {
+	if f.Field == "" {
+		return fmt.Errorf("field is required")
+	}
+	if !filterOperators[f.Operator] {
+		return fmt.Errorf("invalid operator '%s', must be one of: is, is_not, in, not_in, contains, not_contains, exists, not_exists, gt, gte, lt, lte, between", f.Operator)
+	}
+	switch f.Operator {
+	case "exists", "not_exists":
+	case "between":
+		if len(f.Values) != 2 {
+			return fmt.Errorf("operator between needs exactly 2 values, got %d", len(f.Values))
+		}
+	default:
+		if len(f.Values) == 0 {
+			return fmt.Errorf("operator %s needs at least one value", f.Operator)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// readConfigDocument reads a config file with its includes merged in and
+// returns the result as JSON. Includes are read in order, each one
+// overriding the ones before it, and the including file overrides them all:
+// objects are merged key by key, any other value replaces the included one.
+func readConfigDocument(path string) ([]byte, error) {
+	doc, err := readIncluding(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+// readIncluding reads one config file and its includes; stack holds the
+// files being read, to catch include cycles
+func readIncluding(path string, stack []string) (map[string]interface{}, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, including := range stack {
+		if including == abs {
+			return nil, fmt.Errorf("include cycle: %s", strings.Join(append(stack, abs), " -> "))
+		}
+	}
+	stack = append(stack, abs)
+
+	doc, err := readDocument(path)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := includedFiles(path, doc)
+	if err != nil {
+		return nil, err
+	}
+	merged := make(map[string]interface{})
+	for _, file := range files {
+		included, err := readIncluding(file, stack)
+		if err != nil {
+			return nil, err
+		}
+		mergeDocument(merged, included)
+	}
+	delete(doc, "includes")
+	mergeDocument(merged, doc)
+	return merged, nil
+}
+
+// readDocument reads and checks a single config file, without its includes
+func readDocument(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var doc map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(stripComments(data)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse config JSON in %s: %w", path, err)
+	}
+
+	// Reject unknown keys so typos fail loudly instead of silently using zero values
+	if problems := checkUnknownKeys(doc, Schema(), ""); len(problems) > 0 {
+		return nil, fmt.Errorf("invalid config file %s: %s", path, strings.Join(problems, "; "))
+	}
+	return doc, nil
+}
+
+// includedFiles returns the files a config document includes, resolved
+// against its directory. A glob pattern expands to its matches in name
+// order and may match none; a plain path must exist.
+func includedFiles(path string, doc map[string]interface{}) ([]string, error) {
+	raw, ok := doc["includes"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	entries, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid config file %s: includes must be a list of file paths", path)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		pattern, ok := entry.(string)
+		if !ok || pattern == "" {
+			return nil, fmt.Errorf("invalid config file %s: includes must be a list of file paths", path)
+		}
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(path), pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid config file %s: include %s: %w", path, pattern, err)
+		}
+		if len(matches) == 0 && !strings.ContainsAny(pattern, "*?[") {
+			return nil, fmt.Errorf("config file %s includes %s, which does not exist", path, pattern)
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+// ConfigFiles returns a config file and every file it includes, for
+// watching; files that cannot be read are left out
+func ConfigFiles(path string) []string {
+	return appendConfigFiles(nil, path, make(map[string]bool))
+}
+
+// appendConfigFiles appends path and its includes to files, skipping the
+// ones already seen
+func appendConfigFiles(files []string, path string, seen map[string]bool) []string {
+	if abs, err := filepath.Abs(path); err == nil {
+		if seen[abs] {
+			return files
+		}
+		seen[abs] = true
+	}
+	files = append(files, path)
+	doc, err := readDocument(path)
+	if err != nil {
+		return files
+	}
+	included, err := includedFiles(path, doc)
+	if err != nil {
+		return files
+	}
+	for _, file := range included {
+		files = appendConfigFiles(files, file, seen)
+	}
+	return files
+}
+
+// mergeDocument merges src into dst: objects are merged key by key, any
+// other value replaces the one in dst
+func mergeDocument(dst, src map[string]interface{}) {
+	for key, value := range src {
+		srcObject, srcIsObject := value.(map[string]interface{})
+		dstObject, dstIsObject := dst[key].(map[string]interface{})
+		if srcIsObject && dstIsObject {
+			mergeDocument(dstObject, srcObject)
+			continue
+		}
+		dst[key] = value
+	}
+}
@@ -0,0 +1,56 @@
+package config
+
+// stripComments removes // line comments and /* */ block comments from JSON
+// so config files can be documented inline. String contents are left untouched.
+func stripComments(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	inString := false
+
+	for i := 0; i < len(data); i++ {
+		ch := data[i]
+
+		if inString {
+			out = append(out, ch)
+			if ch == '\\' && i+1 < len(data) {
+				i++
+				out = append(out, data[i])
+			} else if ch == '"' {
+				inString = false
+			}
+			continue
+		}
+
+		if ch == '"' {
+			inString = true
+			out = append(out, ch)
+			continue
+		}
+
+		if ch == '/' && i+1 < len(data) {
+			switch data[i+1] {
+			case '/':
+				for i < len(data) && data[i] != '\n' {
+					i++
+				}
+				if i < len(data) {
+					out = append(out, '\n')
+				}
+				continue
+			case '*':
+				i += 2
+				for i+1 < len(data) && !(data[i] == '*' && data[i+1] == '/') {
+					if data[i] == '\n' {
+						out = append(out, '\n')
+					}
+					i++
+				}
+				i++
+				continue
+			}
+		}
+
+		out = append(out, ch)
+	}
+
+	return out
+}
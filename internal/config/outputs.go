@@ -0,0 +1,1234 @@
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cato-logger/internal/awsauth"
+	"cato-logger/internal/syslog"
+)
+
+// DefaultOutputName names the output built from the legacy syslog section
+const DefaultOutputName = "syslog"
+
+// DefaultSyslogHealthCheck is the default number of seconds a TCP syslog
+// connection may sit idle before it is probed
+const DefaultSyslogHealthCheck = 30
+
+// DefaultSyslogKeepAlive is the default TCP keepalive period of syslog
+// connections in seconds
+const DefaultSyslogKeepAlive = 15
+
+// DefaultSentinelBatchSize is the number of events posted to Sentinel per request
+const DefaultSentinelBatchSize = 500
+
+// Chronicle defaults
+const (
+	DefaultChronicleLogType   = "CATO_NETWORKS"
+	DefaultChronicleBatchSize = 1000
+)
+
+// chronicleRegionPattern matches Chronicle region names such as europe-west2
+var chronicleRegionPattern = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// filePlaceholderPattern matches the placeholders of a file output path
+var filePlaceholderPattern = regexp.MustCompile(`\{[^{}]*\}`)
+
+// FilePlaceholders are the placeholders a file output's name may contain
+var FilePlaceholders = []string{"{date}", "{hour}", "{output}"}
+
+// OutputTypes are the destinations an output's type setting accepts
+var OutputTypes = []string{"syslog", "sentinel", "chronicle", "file", "nats", "amqp", "firehose", "s3", "grpc", "loki", "datadog"}
+
+// OutputFormats are the record formats an output's format setting accepts,
+// besides templates
+var OutputFormats = []string{"json", "cef", "ocsf", "ecs-json"}
+
+// TemplateFormatPrefix starts the format of an output rendered with one of
+// the named templates, e.g. template:leef
+const TemplateFormatPrefix = "template:"
+
+// routePattern matches the {field} placeholders of a message bus subject or
+// routing key
+var routePattern = regexp.MustCompile(`\{[A-Za-z0-9_]+\}`)
+
+// DefaultMaxPending is the number of unconfirmed messages a message bus
+// output keeps in flight
+const DefaultMaxPending = 256
+
+// AWS output defaults
+const (
+	DefaultFirehoseBatchSize = 500 // The PutRecordBatch maximum
+	DefaultS3Partition       = "account={account_id}/date={date}"
+)
+
+// gRPC output defaults
+const (
+	DefaultGRPCBatchSize  = 500
+	DefaultGRPCMaxPending = 8
+)
+
+// DefaultLokiBatchSize is the number of log lines per Loki push request
+const DefaultLokiBatchSize = 1000
+
+// DefaultLokiLabels are the stream labels of a Loki output without labels
+var DefaultLokiLabels = map[string]string{
+	"job":        "cato-logger",
+	"account":    "{account_id}",
+	"event_type": "{event_type}",
+}
+
+// Datadog output defaults
+const (
+	DefaultDatadogSite      = "datadoghq.com"
+	DefaultDatadogService   = "cato-logger"
+	DefaultDatadogSource    = "cato-networks"
+	DefaultDatadogBatchSize = 1000 // The logs intake maximum
+)
+
+// DatadogSites are the Datadog sites a datadog output's site setting accepts
+var DatadogSites = []string{"datadoghq.com", "us3.datadoghq.com", "us5.datadoghq.com", "datadoghq.eu", "ap1.datadoghq.com", "ap2.datadoghq.com", "ddog-gov.com"}
+
+// lokiLabelPattern matches Loki label names
+var lokiLabelPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// firehoseStreamPattern matches Firehose delivery stream names
+var firehoseStreamPattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,64}$`)
+
+// sentinelTablePattern matches Log Analytics custom table names (without _CL)
+var sentinelTablePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{0,99}$`)
+
+// Output is one delivery destination. Type selects which of the
+// type-specific sections applies.
+type Output struct {
+	Name      string           `json:"name"`
+	Type      string           `json:"type"`
+	Syslog    *SyslogOutput    `json:"syslog,omitempty"`
+	Sentinel  *SentinelOutput  `json:"sentinel,omitempty"`
+	Chronicle *ChronicleOutput `json:"chronicle,omitempty"`
+	File      *FileOutput      `json:"file,omitempty"`
+	NATS      *NATSOutput      `json:"nats,omitempty"`
+	AMQP      *AMQPOutput      `json:"amqp,omitempty"`
+	Firehose  *FirehoseOutput  `json:"firehose,omitempty"`
+	S3        *S3Output        `json:"s3,omitempty"`
+	GRPC      *GRPCOutput      `json:"grpc,omitempty"`
+	Loki      *LokiOutput      `json:"loki,omitempty"`
+	Datadog   *DatadogOutput   `json:"datadog,omitempty"`
+}
+
+// SyslogOutput configures a syslog destination
+type SyslogOutput struct {
+	Server            string `json:"server"`
+	Port              int    `json:"port"`
+	Protocol          string `json:"protocol"`                      // tcp, udp, relp, or unix/unixgram with socket
+	Socket            string `json:"socket"`                        // Local socket path for unix and unixgram, e.g. /dev/log
+	BindAddress       string `json:"bind_address"`                  // Local IP to connect from, defaults to syslog.bind_address
+	IPPreference      string `json:"ip_preference"`                 // Address family tried first: ipv4 or ipv6, defaults to syslog.ip_preference
+	MaxMessageSize    int    `json:"max_message_size"`              // Bytes, defaults to syslog.max_message_size, then 1472 for udp and 8192 otherwise
+	Format            string `json:"format"`                        // Message text: cef (default), json, ocsf, ecs-json or template:<name>
+	QueueSize         int    `json:"queue_size"`                    // Messages buffered for a background writer, 0 writes synchronously
+	QueuePolicy       string `json:"queue_policy"`                  // Full queue handling: block (default) or drop
+	QueueMaxMB        int    `json:"queue_max_mb"`                  // Soft cap on the bytes queued, 0 limits by queue_size only
+	WriteTimeout      int    `json:"write_timeout_seconds"`         // Defaults to processing.connection_timeout_seconds
+	HealthCheck       *int   `json:"health_check_interval_seconds"` // Idle TCP connections are probed this often, defaults to 30, 0 disables
+	KeepAlive         *int   `json:"tcp_keepalive_seconds"`         // TCP keepalive period, defaults to 15, 0 disables
+	MaxConnAge        int    `json:"max_connection_age_seconds"`    // Connections older than this are replaced, 0 disables
+	MaxReconnectDelay int    `json:"max_reconnect_delay_seconds"`   // Cap of the reconnect backoff, defaults to 60
+}
+
+// SentinelOutput configures a Microsoft Sentinel (Log Analytics) destination.
+// workspace_id and shared_key select the HTTP Data Collector API; endpoint
+// and rule_id select the Logs Ingestion API with Azure AD authentication.
+type SentinelOutput struct {
+	WorkspaceID string `json:"workspace_id"` // Data Collector API
+	SharedKey   string `json:"shared_key"`   // Data Collector API, may be a secret reference
+	Endpoint    string `json:"endpoint"`     // Logs Ingestion API: data collection endpoint URL
+	RuleID      string `json:"rule_id"`      // Logs Ingestion API: immutable DCR ID (dcr-...)
+	Stream      string `json:"stream"`       // Logs Ingestion API, defaults to Custom-<table>_CL
+	Table       string `json:"table"`        // Custom table name without the _CL suffix
+	BatchSize   int    `json:"batch_size"`   // Events per request, defaults to 500
+}
+
+// UsesIngestionAPI reports whether the output posts to the Logs Ingestion
+// API rather than the HTTP Data Collector API
+func (s *SentinelOutput) UsesIngestionAPI() bool {
+	return s.Endpoint != ""
+}
+
+// StreamName returns the DCR stream events are posted to
+func (s *SentinelOutput) StreamName() string {
+	if s.Stream != "" {
+		return s.Stream
+	}
+	return "Custom-" + s.Table + "_CL"
+}
+
+// Host returns the hostname events are posted to
+func (s *SentinelOutput) Host() string {
+	if s.UsesIngestionAPI() {
+		if u, err := url.Parse(s.Endpoint); err == nil {
+			return u.Hostname()
+		}
+		return ""
+	}
+	return s.WorkspaceID + ".ods.opinsights.azure.com"
+}
+
+// ChronicleOutput configures a Google SecOps (Chronicle) destination using
+// the unstructured log ingestion API
+type ChronicleOutput struct {
+	CustomerID      string `json:"customer_id"`      // Chronicle customer UUID
+	CredentialsFile string `json:"credentials_file"` // Service account key, defaults to GOOGLE_APPLICATION_CREDENTIALS
+	Region          string `json:"region"`           // us (default), europe, europe-west2, asia-southeast1, ...
+	Endpoint        string `json:"endpoint"`         // Overrides the regional endpoint
+	LogType         string `json:"log_type"`         // Defaults to CATO_NETWORKS
+	Format          string `json:"format"`           // Log text: json (default), cef, ocsf, ecs-json or template:<name>
+	BatchSize       int    `json:"batch_size"`       // Entries per request, defaults to 1000
+}
+
+// IngestionURL returns the base URL of the regional ingestion API
+func (c *ChronicleOutput) IngestionURL() string {
+	if c.Endpoint != "" {
+		return strings.TrimRight(c.Endpoint, "/")
+	}
+	if c.Region == "" || c.Region == "us" {
+		return "https://malachiteingestion-pa.googleapis.com"
+	}
+	return "https://" + c.Region + "-malachiteingestion-pa.googleapis.com"
+}
+
+// Host returns the hostname entries are posted to
+func (c *ChronicleOutput) Host() string {
+	if u, err := url.Parse(c.IngestionURL()); err == nil {
+		return u.Hostname()
+	}
+	return ""
+}
+
+// FileOutput configures a local file destination, one event per line. The
+// file name may contain placeholders: {date} (UTC YYYY-MM-DD) and {hour}
+// (UTC HH) start a new file each period, {output} is the output name.
+type FileOutput struct {
+	Path       string `json:"path"`         // File path, placeholders allowed in the file name only
+	Format     string `json:"format"`       // cef (default), json, ocsf, ecs-json or template:<name>
+	MaxSizeMB  int    `json:"max_size_mb"`  // Rotate when the file would exceed this size; 0 disables
+	MaxBackups int    `json:"max_backups"`  // Size-rotated files to keep per file; 0 keeps all
+	MaxAgeDays int    `json:"max_age_days"` // Delete finished and rotated files older than this; 0 keeps all
+	Compress   bool   `json:"compress"`     // Gzip finished and rotated files
+}
+
+// NATSOutput configures a NATS JetStream destination. Every message is
+// acknowledged by the stream before its page counts as delivered.
+type NATSOutput struct {
+	URL        string `json:"url"`         // nats://host:4222, or tls://host:4222 for TLS
+	Subject    string `json:"subject"`     // May contain {field} placeholders
+	Stream     string `json:"stream"`      // Optional, messages are rejected unless this stream stores them
+	User       string `json:"user"`        // Optional
+	Password   string `json:"password"`    // Optional, may be a secret reference
+	Token      string `json:"token"`       // Optional, may be a secret reference
+	Format     string `json:"format"`      // Message body: json (default), cef, ocsf, ecs-json or template:<name>
+	MaxPending int    `json:"max_pending"` // Unacknowledged messages in flight, defaults to 256
+}
+
+// AMQPOutput configures an AMQP 0.9.1 (RabbitMQ) destination. Messages are
+// published persistent on a channel in confirm mode.
+type AMQPOutput struct {
+	URL        string `json:"url"`         // amqp://host:5672/vhost, or amqps:// for TLS
+	Exchange   string `json:"exchange"`    // Empty publishes to the default exchange
+	RoutingKey string `json:"routing_key"` // May contain {field} placeholders
+	User       string `json:"user"`        // Defaults to guest
+	Password   string `json:"password"`    // Defaults to guest, may be a secret reference
+	Format     string `json:"format"`      // Message body: json (default), cef, ocsf, ecs-json or template:<name>
+	MaxPending int    `json:"max_pending"` // Unconfirmed messages in flight, defaults to 256
+}
+
+// AWSCredentials selects the credentials of an AWS output. Without a key
+// pair, credentials come from the environment, the container role or the
+// instance role.
+type AWSCredentials struct {
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"` // May be a secret reference
+	RoleARN         string `json:"role_arn"`          // Optional role assumed with the credentials
+}
+
+// FirehoseOutput configures an Amazon Data Firehose delivery stream
+type FirehoseOutput struct {
+	Stream      string         `json:"stream"`      // Delivery stream name
+	Region      string         `json:"region"`      // Defaults to AWS_REGION
+	Endpoint    string         `json:"endpoint"`    // Overrides the regional endpoint
+	Format      string         `json:"format"`      // Record data: json (default), cef, ocsf, ecs-json or template:<name>, newline-terminated
+	BatchSize   int            `json:"batch_size"`  // Records per request, defaults to 500
+	Credentials AWSCredentials `json:"credentials"` // Optional
+}
+
+// S3Output configures delivery of batches as objects in an S3 bucket
+type S3Output struct {
+	Bucket      string         `json:"bucket"`
+	Prefix      string         `json:"prefix"`      // Key prefix
+	Partition   string         `json:"partition"`   // Key path template, defaults to account={account_id}/date={date}
+	Region      string         `json:"region"`      // Defaults to AWS_REGION
+	Endpoint    string         `json:"endpoint"`    // Path-style endpoint, e.g. a VPC endpoint
+	Format      string         `json:"format"`      // Object lines: json (default), cef, ocsf, ecs-json or template:<name>
+	Compress    bool           `json:"compress"`    // Gzip objects
+	Credentials AWSCredentials `json:"credentials"` // Optional
+}
+
+// GRPCOutput configures a stream of event batches to a custom receiver
+// implementing the catologger.v1.EventStream service. Every batch is
+// acknowledged by the receiver before its page counts as delivered.
+type GRPCOutput struct {
+	Address           string `json:"address"`             // host:port of the receiver, always reached over TLS
+	ServerName        string `json:"server_name"`         // Name verified in the receiver's certificate, defaults to the address host
+	CAFile            string `json:"ca_file"`             // PEM CA bundle for the receiver's certificate, defaults to the system roots
+	CertFile          string `json:"cert_file"`           // Client certificate for mutual TLS
+	KeyFile           string `json:"key_file"`            // Key of cert_file
+	Token             string `json:"token"`               // Optional bearer token, may be a secret reference
+	Format            string `json:"format"`              // Event payload: json (default), cef, ocsf, ecs-json or template:<name>
+	BatchSize         int    `json:"batch_size"`          // Events per batch, defaults to 500
+	MaxPendingBatches int    `json:"max_pending_batches"` // Unacknowledged batches in flight, defaults to 8
+}
+
+// Host returns the hostname of the receiver
+func (g *GRPCOutput) Host() string {
+	host, _, err := net.SplitHostPort(g.Address)
+	if err != nil {
+		return ""
+	}
+	return host
+}
+
+// LokiOutput configures a Grafana Loki destination using the push API
+type LokiOutput struct {
+	URL       string            `json:"url"`        // Base URL, e.g. https://loki.example.com, or the full push URL
+	TenantID  string            `json:"tenant_id"`  // Sent as X-Scope-OrgID to a multi-tenant Loki
+	User      string            `json:"user"`       // Optional basic auth user, e.g. a Grafana Cloud instance ID
+	Password  string            `json:"password"`   // Optional, may be a secret reference
+	Token     string            `json:"token"`      // Optional bearer token, may be a secret reference
+	Labels    map[string]string `json:"labels"`     // Stream labels, values may contain {field} placeholders; defaults to job, account and event_type
+	Format    string            `json:"format"`     // Log line: json (default), cef, ocsf, ecs-json or template:<name>
+	BatchSize int               `json:"batch_size"` // Lines per push request, defaults to 1000
+}
+
+// PushURL returns the URL of the push API
+func (l *LokiOutput) PushURL() string {
+	base := strings.TrimRight(l.URL, "/")
+	if strings.HasSuffix(base, "/loki/api/v1/push") {
+		return base
+	}
+	return base + "/loki/api/v1/push"
+}
+
+// DatadogOutput configures delivery to the Datadog logs intake API
+type DatadogOutput struct {
+	Site      string   `json:"site"`       // Datadog site, e.g. datadoghq.eu, defaults to datadoghq.com
+	Endpoint  string   `json:"endpoint"`   // Overrides the site's intake URL, e.g. a proxy
+	APIKey    string   `json:"api_key"`    // May be a secret reference
+	Service   string   `json:"service"`    // Defaults to cato-logger
+	Source    string   `json:"source"`     // ddsource, selecting the log pipeline; defaults to cato-networks
+	Tags      []string `json:"tags"`       // key:value tags, values may contain {field} placeholders
+	Format    string   `json:"format"`     // Log message: json (default), cef, ocsf, ecs-json or template:<name>
+	BatchSize int      `json:"batch_size"` // Logs per request, defaults to 1000
+}
+
+// IntakeURL returns the URL logs are posted to
+func (d *DatadogOutput) IntakeURL() string {
+	if d.Endpoint != "" {
+		return strings.TrimRight(d.Endpoint, "/") + "/api/v2/logs"
+	}
+	return "https://http-intake.logs." + d.Site + "/api/v2/logs"
+}
+
+// FirehoseURL returns the endpoint PutRecordBatch requests are sent to
+func (f *FirehoseOutput) FirehoseURL() string {
+	if f.Endpoint != "" {
+		return strings.TrimRight(f.Endpoint, "/")
+	}
+	return "https://firehose." + f.Region + ".amazonaws.com"
+}
+
+// Host returns the hostname objects are written to
+func (s *S3Output) Host() string {
+	if s.Endpoint != "" {
+		return urlHost(s.Endpoint)
+	}
+	return s.Bucket + ".s3." + s.Region + ".amazonaws.com"
+}
+
+// urlHost returns the hostname of a URL
+func urlHost(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		return u.Hostname()
+	}
+	return ""
+}
+
+// UsesSocket reports whether the output writes to a local unix socket
+// rather than a network server
+func (s *SyslogOutput) UsesSocket() bool {
+	return s.Protocol == "unix" || s.Protocol == "unixgram"
+}
+
+// Address returns the host:port of the syslog server, or the socket path
+func (s *SyslogOutput) Address() string {
+	if s.UsesSocket() {
+		return s.Socket
+	}
+	return net.JoinHostPort(s.Server, strconv.Itoa(s.Port))
+}
+
+// Host returns the hostname the output connects to, if any
+func (o Output) Host() string {
+	switch o.Type {
+	case "syslog":
+		if o.Syslog != nil {
+			return o.Syslog.Server
+		}
+	case "sentinel":
+		if o.Sentinel != nil {
+			return o.Sentinel.Host()
+		}
+	case "chronicle":
+		if o.Chronicle != nil {
+			return o.Chronicle.Host()
+		}
+	case "nats":
+		if o.NATS != nil {
+			return urlHost(o.NATS.URL)
+		}
+	case "amqp":
+		if o.AMQP != nil {
+			return urlHost(o.AMQP.URL)
+		}
+	case "firehose":
+		if o.Firehose != nil {
+			return urlHost(o.Firehose.FirehoseURL())
+		}
+	case "s3":
+		if o.S3 != nil {
+			return o.S3.Host()
+		}
+	case "grpc":
+		if o.GRPC != nil {
+			return o.GRPC.Host()
+		}
+	case "loki":
+		if o.Loki != nil {
+			return urlHost(o.Loki.URL)
+		}
+	case "datadog":
+		if o.Datadog != nil {
+			return urlHost(o.Datadog.IntakeURL())
+		}
+	}
+	return ""
+}
+
+// Format returns the record format of the output, empty for the default
+// and for outputs without a format setting
+func (o Output) Format() string {
+	switch o.Type {
+	case "syslog":
+		if o.Syslog != nil {
+			return o.Syslog.Format
+		}
+	case "chronicle":
+		if o.Chronicle != nil {
+			return o.Chronicle.Format
+		}
+	case "file":
+		if o.File != nil {
+			return o.File.Format
+		}
+	case "nats":
+		if o.NATS != nil {
+			return o.NATS.Format
+		}
+	case "amqp":
+		if o.AMQP != nil {
+			return o.AMQP.Format
+		}
+	case "firehose":
+		if o.Firehose != nil {
+			return o.Firehose.Format
+		}
+	case "s3":
+		if o.S3 != nil {
+			return o.S3.Format
+		}
+	case "grpc":
+		if o.GRPC != nil {
+			return o.GRPC.Format
+		}
+	case "loki":
+		if o.Loki != nil {
+			return o.Loki.Format
+		}
+	case "datadog":
+		if o.Datadog != nil {
+			return o.Datadog.Format
+		}
+	}
+	return ""
+}
+
+// EffectiveOutputs returns the configured outputs, or a single syslog output
+// built from the legacy syslog section when no outputs are listed
+func (c *Config) EffectiveOutputs() []Output {
+	healthCheck, keepAlive := DefaultSyslogHealthCheck, DefaultSyslogKeepAlive
+
+	if len(c.Outputs) == 0 {
+		return []Output{{
+			Name: DefaultOutputName,
+			Type: "syslog",
+			Syslog: &SyslogOutput{
+				Server:         c.SyslogServer,
+				Port:           c.SyslogPort,
+				Protocol:       c.SyslogProtocol,
+				BindAddress:    c.SyslogBindAddr,
+				IPPreference:   c.SyslogIPPref,
+				MaxMessageSize: maxMessageSize(c.MaxMsgSize, c.SyslogProtocol),
+				Format:         "cef",
+				WriteTimeout:   c.ConnTimeout,
+				HealthCheck:    &healthCheck,
+				KeepAlive:      &keepAlive,
+			},
+		}}
+	}
+
+	outputs := make([]Output, len(c.Outputs))
+	for i, out := range c.Outputs {
+		outputs[i] = out
+		if out.Syslog != nil {
+			syslogOut := *out.Syslog
+			if syslogOut.MaxMessageSize == 0 {
+				syslogOut.MaxMessageSize = maxMessageSize(c.MaxMsgSize, syslogOut.Protocol)
+			}
+			if !syslogOut.UsesSocket() {
+				if syslogOut.BindAddress == "" {
+					syslogOut.BindAddress = c.SyslogBindAddr
+				}
+				if syslogOut.IPPreference == "" {
+					syslogOut.IPPreference = c.SyslogIPPref
+				}
+			}
+			if syslogOut.Format == "" {
+				syslogOut.Format = "cef"
+			}
+			if syslogOut.QueueSize > 0 && syslogOut.QueuePolicy == "" {
+				syslogOut.QueuePolicy = syslog.PolicyBlock
+			}
+			if syslogOut.WriteTimeout == 0 {
+				syslogOut.WriteTimeout = c.ConnTimeout
+			}
+			if syslogOut.HealthCheck == nil {
+				syslogOut.HealthCheck = &healthCheck
+			}
+			if syslogOut.KeepAlive == nil {
+				syslogOut.KeepAlive = &keepAlive
+			}
+			outputs[i].Syslog = &syslogOut
+		}
+		if out.Sentinel != nil && out.Sentinel.BatchSize == 0 {
+			sentinelOut := *out.Sentinel
+			sentinelOut.BatchSize = DefaultSentinelBatchSize
+			outputs[i].Sentinel = &sentinelOut
+		}
+		if out.Chronicle != nil {
+			chronicleOut := *out.Chronicle
+			if chronicleOut.LogType == "" {
+				chronicleOut.LogType = DefaultChronicleLogType
+			}
+			if chronicleOut.Format == "" {
+				chronicleOut.Format = "json"
+			}
+			if chronicleOut.BatchSize == 0 {
+				chronicleOut.BatchSize = DefaultChronicleBatchSize
+			}
+			outputs[i].Chronicle = &chronicleOut
+		}
+		if out.File != nil && out.File.Format == "" {
+			fileOut := *out.File
+			fileOut.Format = "cef"
+			outputs[i].File = &fileOut
+		}
+		if out.NATS != nil {
+			natsOut := *out.NATS
+			if natsOut.Format == "" {
+				natsOut.Format = "json"
+			}
+			if natsOut.MaxPending == 0 {
+				natsOut.MaxPending = DefaultMaxPending
+			}
+			outputs[i].NATS = &natsOut
+		}
+		if out.AMQP != nil {
+			amqpOut := *out.AMQP
+			if amqpOut.Format == "" {
+				amqpOut.Format = "json"
+			}
+			if amqpOut.MaxPending == 0 {
+				amqpOut.MaxPending = DefaultMaxPending
+			}
+			if amqpOut.User == "" && amqpOut.Password == "" {
+				amqpOut.User, amqpOut.Password = "guest", "guest"
+			}
+			outputs[i].AMQP = &amqpOut
+		}
+		if out.Firehose != nil {
+			firehoseOut := *out.Firehose
+			if firehoseOut.Region == "" {
+				firehoseOut.Region = awsauth.Region()
+			}
+			if firehoseOut.Format == "" {
+				firehoseOut.Format = "json"
+			}
+			if firehoseOut.BatchSize == 0 {
+				firehoseOut.BatchSize = DefaultFirehoseBatchSize
+			}
+			outputs[i].Firehose = &firehoseOut
+		}
+		if out.S3 != nil {
+			s3Out := *out.S3
+			if s3Out.Region == "" {
+				s3Out.Region = awsauth.Region()
+			}
+			if s3Out.Format == "" {
+				s3Out.Format = "json"
+			}
+			if s3Out.Partition == "" {
+				s3Out.Partition = DefaultS3Partition
+			}
+			outputs[i].S3 = &s3Out
+		}
+		if out.GRPC != nil {
+			grpcOut := *out.GRPC
+			if grpcOut.Format == "" {
+				grpcOut.Format = "json"
+			}
+			if grpcOut.BatchSize == 0 {
+				grpcOut.BatchSize = DefaultGRPCBatchSize
+			}
+			if grpcOut.MaxPendingBatches == 0 {
+				grpcOut.MaxPendingBatches = DefaultGRPCMaxPending
+			}
+			outputs[i].GRPC = &grpcOut
+		}
+		if out.Loki != nil {
+			lokiOut := *out.Loki
+			if len(lokiOut.Labels) == 0 {
+				lokiOut.Labels = DefaultLokiLabels
+			}
+			if lokiOut.Format == "" {
+				lokiOut.Format = "json"
+			}
+			if lokiOut.BatchSize == 0 {
+				lokiOut.BatchSize = DefaultLokiBatchSize
+			}
+			outputs[i].Loki = &lokiOut
+		}
+		if out.Datadog != nil {
+			datadogOut := *out.Datadog
+			if datadogOut.Site == "" {
+				datadogOut.Site = DefaultDatadogSite
+			}
+			if datadogOut.Service == "" {
+				datadogOut.Service = DefaultDatadogService
+			}
+			if datadogOut.Source == "" {
+				datadogOut.Source = DefaultDatadogSource
+			}
+			if datadogOut.Format == "" {
+				datadogOut.Format = "json"
+			}
+			if datadogOut.BatchSize == 0 {
+				datadogOut.BatchSize = DefaultDatadogBatchSize
+			}
+			outputs[i].Datadog = &datadogOut
+		}
+	}
+	return outputs
+}
+
+// validateOutputs checks the outputs list
+func (c *Config) validateOutputs() error {
+	names := make(map[string]bool)
+	for i, out := range c.Outputs {
+		if out.Name == "" {
+			return fmt.Errorf("outputs[%d] is missing a name", i)
+		}
+		if names[out.Name] {
+			return fmt.Errorf("outputs[%d] reuses the name '%s', output names must be unique", i, out.Name)
+		}
+		names[out.Name] = true
+
+		switch out.Type {
+		case "syslog":
+			if out.Syslog == nil {
+				return fmt.Errorf("outputs[%d] (%s) has type syslog but no syslog section", i, out.Name)
+			}
+			if err := out.Syslog.validate(); err != nil {
+				return fmt.Errorf("outputs[%d] (%s): %w", i, out.Name, err)
+			}
+		case "sentinel":
+			if out.Sentinel == nil {
+				return fmt.Errorf("outputs[%d] (%s) has type sentinel but no sentinel section", i, out.Name)
+			}
+			if err := out.Sentinel.validate(); err != nil {
+				return fmt.Errorf("outputs[%d] (%s): %w", i, out.Name, err)
+			}
+		case "chronicle":
+			if out.Chronicle == nil {
+				return fmt.Errorf("outputs[%d] (%s) has type chronicle but no chronicle section", i, out.Name)
+			}
+			if err := out.Chronicle.validate(); err != nil {
+				return fmt.Errorf("outputs[%d] (%s): %w", i, out.Name, err)
+			}
+		case "file":
+			if out.File == nil {
+				return fmt.Errorf("outputs[%d] (%s) has type file but no file section", i, out.Name)
+			}
+			if err := out.File.validate(); err != nil {
+				return fmt.Errorf("outputs[%d] (%s): %w", i, out.Name, err)
+			}
+		case "nats":
+			if out.NATS == nil {
+				return fmt.Errorf("outputs[%d] (%s) has type nats but no nats section", i, out.Name)
+			}
+			if err := out.NATS.validate(); err != nil {
+				return fmt.Errorf("outputs[%d] (%s): %w", i, out.Name, err)
+			}
+		case "amqp":
+			if out.AMQP == nil {
+				return fmt.Errorf("outputs[%d] (%s) has type amqp but no amqp section", i, out.Name)
+			}
+			if err := out.AMQP.validate(); err != nil {
+				return fmt.Errorf("outputs[%d] (%s): %w", i, out.Name, err)
+			}
+		case "firehose":
+			if out.Firehose == nil {
+				return fmt.Errorf("outputs[%d] (%s) has type firehose but no firehose section", i, out.Name)
+			}
+			if err := out.Firehose.validate(); err != nil {
+				return fmt.Errorf("outputs[%d] (%s): %w", i, out.Name, err)
+			}
+		case "s3":
+			if out.S3 == nil {
+				return fmt.Errorf("outputs[%d] (%s) has type s3 but no s3 section", i, out.Name)
+			}
+			if err := out.S3.validate(); err != nil {
+				return fmt.Errorf("outputs[%d] (%s): %w", i, out.Name, err)
+			}
+		case "grpc":
+			if out.GRPC == nil {
+				return fmt.Errorf("outputs[%d] (%s) has type grpc but no grpc section", i, out.Name)
+			}
+			if err := out.GRPC.validate(); err != nil {
+				return fmt.Errorf("outputs[%d] (%s): %w", i, out.Name, err)
+			}
+		case "loki":
+			if out.Loki == nil {
+				return fmt.Errorf("outputs[%d] (%s) has type loki but no loki section", i, out.Name)
+			}
+			if err := out.Loki.validate(); err != nil {
+				return fmt.Errorf("outputs[%d] (%s): %w", i, out.Name, err)
+			}
+		case "datadog":
+			if out.Datadog == nil {
+				return fmt.Errorf("outputs[%d] (%s) has type datadog but no datadog section", i, out.Name)
+			}
+			if err := out.Datadog.validate(); err != nil {
+				return fmt.Errorf("outputs[%d] (%s): %w", i, out.Name, err)
+			}
+		default:
+			return fmt.Errorf("outputs[%d] (%s) has invalid type '%s', must be one of: %s", i, out.Name, out.Type, strings.Join(OutputTypes, ", "))
+		}
+	}
+	return nil
+}
+
+// validate checks a syslog destination
+func (s *SyslogOutput) validate() error {
+	if s.UsesSocket() {
+		if s.Socket == "" {
+			return fmt.Errorf("syslog.socket is required with protocol %s", s.Protocol)
+		}
+		if !filepath.IsAbs(s.Socket) {
+			return fmt.Errorf("syslog.socket must be an absolute path, got '%s'", s.Socket)
+		}
+		if s.Server != "" || s.Port != 0 {
+			return fmt.Errorf("syslog.server and syslog.port cannot be combined with protocol %s", s.Protocol)
+		}
+		if s.BindAddress != "" {
+			return fmt.Errorf("syslog.bind_address cannot be combined with protocol %s", s.Protocol)
+		}
+		if s.IPPreference != "" {
+			return fmt.Errorf("syslog.ip_preference cannot be combined with protocol %s", s.Protocol)
+		}
+	} else {
+		if s.Protocol != "tcp" && s.Protocol != "udp" && s.Protocol != "relp" {
+			return fmt.Errorf("invalid syslog protocol '%s', must be tcp, udp, relp, unix or unixgram", s.Protocol)
+		}
+		if s.Socket != "" {
+			return fmt.Errorf("syslog.socket requires protocol unix or unixgram")
+		}
+		if s.Server == "" {
+			return fmt.Errorf("syslog.server is required")
+		}
+		if s.Port <= 0 || s.Port > 65535 {
+			return fmt.Errorf("syslog.port must be between 1 and 65535, got %d", s.Port)
+		}
+		if s.BindAddress != "" && net.ParseIP(s.BindAddress) == nil {
+			return fmt.Errorf("syslog.bind_address must be an IP address, got '%s'", s.BindAddress)
+		}
+		if err := validateIPPreference(s.IPPreference); err != nil {
+			return err
+		}
+	}
+	if s.MaxMessageSize < 0 {
+		return fmt.Errorf("syslog.max_message_size cannot be negative, got %d", s.MaxMessageSize)
+	}
+	if err := validateFormat("syslog.format", s.Format); err != nil {
+		return err
+	}
+	if s.WriteTimeout < 0 {
+		return fmt.Errorf("syslog.write_timeout_seconds cannot be negative, got %d", s.WriteTimeout)
+	}
+	if s.HealthCheck != nil && *s.HealthCheck < 0 {
+		return fmt.Errorf("syslog.health_check_interval_seconds cannot be negative, got %d", *s.HealthCheck)
+	}
+	if s.KeepAlive != nil && *s.KeepAlive < 0 {
+		return fmt.Errorf("syslog.tcp_keepalive_seconds cannot be negative, got %d", *s.KeepAlive)
+	}
+	if s.MaxConnAge < 0 {
+		return fmt.Errorf("syslog.max_connection_age_seconds cannot be negative, got %d", s.MaxConnAge)
+	}
+	if s.MaxReconnectDelay < 0 {
+		return fmt.Errorf("syslog.max_reconnect_delay_seconds cannot be negative, got %d", s.MaxReconnectDelay)
+	}
+	if s.QueueSize < 0 {
+		return fmt.Errorf("syslog.queue_size cannot be negative, got %d", s.QueueSize)
+	}
+	if s.QueueMaxMB < 0 {
+		return fmt.Errorf("syslog.queue_max_mb cannot be negative, got %d", s.QueueMaxMB)
+	}
+	if s.QueueMaxMB > 0 && s.QueueSize == 0 {
+		return fmt.Errorf("syslog.queue_max_mb requires syslog.queue_size")
+	}
+	if s.QueuePolicy != "" {
+		if s.QueueSize == 0 {
+			return fmt.Errorf("syslog.queue_policy requires syslog.queue_size")
+		}
+		if s.QueuePolicy != syslog.PolicyBlock && s.QueuePolicy != syslog.PolicyDrop {
+			return fmt.Errorf("invalid syslog.queue_policy '%s', must be one of: %s", s.QueuePolicy, strings.Join(syslog.QueuePolicies, ", "))
+		}
+	}
+	return nil
+}
+
+// maxMessageSize returns size, or the protocol's default limit if it is 0
+func maxMessageSize(size int, protocol string) int {
+	if size == 0 {
+		return syslog.DefaultMaxMessageSize(protocol)
+	}
+	return size
+}
+
+// validateIPPreference checks a syslog ip_preference
+func validateIPPreference(prefer string) error {
+	if prefer != "" && prefer != syslog.PreferIPv4 && prefer != syslog.PreferIPv6 {
+		return fmt.Errorf("invalid syslog.ip_preference '%s', must be one of: %s", prefer, strings.Join(syslog.IPPreferences, ", "))
+	}
+	return nil
+}
+
+// validate checks a Sentinel destination
+func (s *SentinelOutput) validate() error {
+	if s.UsesIngestionAPI() {
+		if s.WorkspaceID != "" || s.SharedKey != "" {
+			return fmt.Errorf("sentinel.endpoint (Logs Ingestion API) cannot be combined with workspace_id/shared_key (Data Collector API)")
+		}
+		u, err := url.Parse(s.Endpoint)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return fmt.Errorf("sentinel.endpoint must be an https:// URL, got '%s'", s.Endpoint)
+		}
+		if s.RuleID == "" {
+			return fmt.Errorf("sentinel.rule_id is required with sentinel.endpoint")
+		}
+		if s.Stream == "" && s.Table == "" {
+			return fmt.Errorf("sentinel.stream or sentinel.table is required with sentinel.endpoint")
+		}
+	} else {
+		if s.WorkspaceID == "" || s.SharedKey == "" {
+			return fmt.Errorf("sentinel needs workspace_id and shared_key, or endpoint and rule_id")
+		}
+		if _, err := base64.StdEncoding.DecodeString(s.SharedKey); err != nil {
+			return fmt.Errorf("sentinel.shared_key is not valid base64")
+		}
+		if s.Table == "" {
+			return fmt.Errorf("sentinel.table is required")
+		}
+	}
+	if s.Table != "" && !sentinelTablePattern.MatchString(s.Table) {
+		return fmt.Errorf("sentinel.table must start with a letter and contain only letters, digits or '_' (up to 100), got '%s'", s.Table)
+	}
+	if s.BatchSize < 0 {
+		return fmt.Errorf("sentinel.batch_size cannot be negative, got %d", s.BatchSize)
+	}
+	return nil
+}
+
+// validate checks a Chronicle destination
+func (c *ChronicleOutput) validate() error {
+	if c.CustomerID == "" {
+		return fmt.Errorf("chronicle.customer_id is required")
+	}
+	if c.Region != "" && !chronicleRegionPattern.MatchString(c.Region) {
+		return fmt.Errorf("invalid chronicle.region '%s'", c.Region)
+	}
+	if c.Endpoint != "" {
+		u, err := url.Parse(c.Endpoint)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return fmt.Errorf("chronicle.endpoint must be an https:// URL, got '%s'", c.Endpoint)
+		}
+	}
+	if err := validateFormat("chronicle.format", c.Format); err != nil {
+		return err
+	}
+	if c.BatchSize < 0 {
+		return fmt.Errorf("chronicle.batch_size cannot be negative, got %d", c.BatchSize)
+	}
+	return nil
+}
+
+// validate checks a file destination
+func (f *FileOutput) validate() error {
+	if f.Path == "" {
+		return fmt.Errorf("file.path is required")
+	}
+	if filePlaceholderPattern.MatchString(filepath.Dir(f.Path)) {
+		return fmt.Errorf("file.path placeholders are only allowed in the file name, got '%s'", f.Path)
+	}
+	for _, placeholder := range filePlaceholderPattern.FindAllString(filepath.Base(f.Path), -1) {
+		known := false
+		for _, p := range FilePlaceholders {
+			known = known || placeholder == p
+		}
+		if !known {
+			return fmt.Errorf("unknown file.path placeholder %s, must be one of: %s", placeholder, strings.Join(FilePlaceholders, ", "))
+		}
+	}
+	if err := validateFormat("file.format", f.Format); err != nil {
+		return err
+	}
+	if f.MaxSizeMB < 0 || f.MaxBackups < 0 || f.MaxAgeDays < 0 {
+		return fmt.Errorf("file rotation values cannot be negative")
+	}
+	return nil
+}
+
+// validate checks a NATS destination
+func (n *NATSOutput) validate() error {
+	u, err := url.Parse(n.URL)
+	if err != nil || (u.Scheme != "nats" && u.Scheme != "tls") || u.Host == "" {
+		return fmt.Errorf("nats.url must be a nats:// or tls:// URL, got '%s'", n.URL)
+	}
+	if u.User != nil {
+		return fmt.Errorf("nats.url cannot contain credentials, use nats.user/nats.password or nats.token")
+	}
+	if n.Subject == "" {
+		return fmt.Errorf("nats.subject is required")
+	}
+	if err := validateRoute("nats.subject", n.Subject); err != nil {
+		return err
+	}
+	if strings.ContainsAny(n.Subject, "*> \t") {
+		return fmt.Errorf("nats.subject cannot contain wildcards or whitespace, got '%s'", n.Subject)
+	}
+	if n.Token != "" && (n.User != "" || n.Password != "") {
+		return fmt.Errorf("nats.token cannot be combined with nats.user/password")
+	}
+	if err := validateFormat("nats.format", n.Format); err != nil {
+		return err
+	}
+	if n.MaxPending < 0 {
+		return fmt.Errorf("nats.max_pending cannot be negative, got %d", n.MaxPending)
+	}
+	return nil
+}
+
+// validate checks an AMQP destination
+func (a *AMQPOutput) validate() error {
+	u, err := url.Parse(a.URL)
+	if err != nil || (u.Scheme != "amqp" && u.Scheme != "amqps") || u.Host == "" {
+		return fmt.Errorf("amqp.url must be an amqp:// or amqps:// URL, got '%s'", a.URL)
+	}
+	if u.User != nil {
+		return fmt.Errorf("amqp.url cannot contain credentials, use amqp.user and amqp.password")
+	}
+	if a.Exchange == "" && a.RoutingKey == "" {
+		return fmt.Errorf("amqp.routing_key is required when publishing to the default exchange")
+	}
+	if err := validateRoute("amqp.routing_key", a.RoutingKey); err != nil {
+		return err
+	}
+	if err := validateFormat("amqp.format", a.Format); err != nil {
+		return err
+	}
+	if a.MaxPending < 0 {
+		return fmt.Errorf("amqp.max_pending cannot be negative, got %d", a.MaxPending)
+	}
+	return nil
+}
+
+// validate checks a Firehose destination
+func (f *FirehoseOutput) validate() error {
+	if !firehoseStreamPattern.MatchString(f.Stream) {
+		return fmt.Errorf("firehose.stream must be a delivery stream name (letters, digits, '_', '.', '-'), got '%s'", f.Stream)
+	}
+	if f.Region == "" && awsauth.Region() == "" {
+		return fmt.Errorf("firehose.region is required when AWS_REGION is not set")
+	}
+	if f.Endpoint != "" {
+		if u, err := url.Parse(f.Endpoint); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("firehose.endpoint must be an http(s):// URL, got '%s'", f.Endpoint)
+		}
+	}
+	if err := validateFormat("firehose.format", f.Format); err != nil {
+		return err
+	}
+	if f.BatchSize < 0 || f.BatchSize > DefaultFirehoseBatchSize {
+		return fmt.Errorf("firehose.batch_size must be between 1 and %d, got %d", DefaultFirehoseBatchSize, f.BatchSize)
+	}
+	return f.Credentials.validate("firehose")
+}
+
+// validate checks an S3 destination
+func (s *S3Output) validate() error {
+	if s.Bucket == "" || strings.ContainsAny(s.Bucket, "/:") {
+		return fmt.Errorf("s3.bucket must be a bucket name, got '%s'", s.Bucket)
+	}
+	if s.Region == "" && awsauth.Region() == "" {
+		return fmt.Errorf("s3.region is required when AWS_REGION is not set")
+	}
+	if s.Endpoint != "" {
+		if u, err := url.Parse(s.Endpoint); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("s3.endpoint must be an http(s):// URL, got '%s'", s.Endpoint)
+		}
+	}
+	if strings.HasPrefix(s.Prefix, "/") {
+		return fmt.Errorf("s3.prefix cannot start with '/'")
+	}
+	if err := validateRoute("s3.partition", s.Partition); err != nil {
+		return err
+	}
+	if err := validateFormat("s3.format", s.Format); err != nil {
+		return err
+	}
+	return s.Credentials.validate("s3")
+}
+
+// validate checks a gRPC destination
+func (g *GRPCOutput) validate() error {
+	host, port, err := net.SplitHostPort(g.Address)
+	if err != nil || host == "" {
+		return fmt.Errorf("grpc.address must be host:port, got '%s'", g.Address)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("grpc.address has an invalid port, got '%s'", g.Address)
+	}
+	if (g.CertFile == "") != (g.KeyFile == "") {
+		return fmt.Errorf("grpc.cert_file and grpc.key_file must be set together")
+	}
+	if err := validateFormat("grpc.format", g.Format); err != nil {
+		return err
+	}
+	if g.BatchSize < 0 {
+		return fmt.Errorf("grpc.batch_size cannot be negative, got %d", g.BatchSize)
+	}
+	if g.MaxPendingBatches < 0 {
+		return fmt.Errorf("grpc.max_pending_batches cannot be negative, got %d", g.MaxPendingBatches)
+	}
+	return nil
+}
+
+// validate checks a Loki destination
+func (l *LokiOutput) validate() error {
+	u, err := url.Parse(l.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("loki.url must be an http(s):// URL, got '%s'", l.URL)
+	}
+	if u.User != nil {
+		return fmt.Errorf("loki.url cannot contain credentials, use loki.user and loki.password")
+	}
+	if l.Token != "" && (l.User != "" || l.Password != "") {
+		return fmt.Errorf("loki.token cannot be combined with loki.user/password")
+	}
+	if (l.User == "") != (l.Password == "") {
+		return fmt.Errorf("loki.user and loki.password must be set together")
+	}
+	names := make([]string, 0, len(l.Labels))
+	for name := range l.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := l.Labels[name]
+		if !lokiLabelPattern.MatchString(name) || strings.HasPrefix(name, "__") {
+			return fmt.Errorf("invalid loki label name '%s', must be letters, digits and '_' and not start with a digit or '__'", name)
+		}
+		if value == "" {
+			return fmt.Errorf("loki label %s has an empty value", name)
+		}
+		if err := validateRoute("loki.labels."+name, value); err != nil {
+			return err
+		}
+	}
+	if err := validateFormat("loki.format", l.Format); err != nil {
+		return err
+	}
+	if l.BatchSize < 0 {
+		return fmt.Errorf("loki.batch_size cannot be negative, got %d", l.BatchSize)
+	}
+	return nil
+}
+
+// validate checks a Datadog destination
+func (d *DatadogOutput) validate() error {
+	if d.Site != "" {
+		known := false
+		for _, site := range DatadogSites {
+			known = known || d.Site == site
+		}
+		if !known {
+			return fmt.Errorf("invalid datadog.site '%s', must be one of: %s", d.Site, strings.Join(DatadogSites, ", "))
+		}
+	}
+	if d.Endpoint != "" {
+		if u, err := url.Parse(d.Endpoint); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("datadog.endpoint must be an http(s):// URL, got '%s'", d.Endpoint)
+		}
+	}
+	if d.APIKey == "" {
+		return fmt.Errorf("datadog.api_key is required")
+	}
+	for _, tag := range d.Tags {
+		if tag == "" || strings.Contains(tag, ",") {
+			return fmt.Errorf("invalid datadog tag '%s', tags must be non-empty and cannot contain ','", tag)
+		}
+		if err := validateRoute("datadog.tags", tag); err != nil {
+			return err
+		}
+	}
+	if err := validateFormat("datadog.format", d.Format); err != nil {
+		return err
+	}
+	if d.BatchSize < 0 || d.BatchSize > DefaultDatadogBatchSize {
+		return fmt.Errorf("datadog.batch_size must be between 1 and %d, got %d", DefaultDatadogBatchSize, d.BatchSize)
+	}
+	return nil
+}
+
+// validate checks the credentials of an AWS output
+func (c *AWSCredentials) validate(section string) error {
+	if (c.AccessKeyID == "") != (c.SecretAccessKey == "") {
+		return fmt.Errorf("%s.credentials needs both access_key_id and secret_access_key", section)
+	}
+	if c.RoleARN != "" && !strings.HasPrefix(c.RoleARN, "arn:") {
+		return fmt.Errorf("%s.credentials.role_arn must be a role ARN, got '%s'", section, c.RoleARN)
+	}
+	return nil
+}
+
+// validateRoute checks the braces of a subject or routing key template only
+// enclose {field} placeholders
+func validateRoute(name, route string) error {
+	if rest := routePattern.ReplaceAllString(route, ""); strings.ContainsAny(rest, "{}") {
+		return fmt.Errorf("%s has a malformed placeholder, use {field_name}: '%s'", name, route)
+	}
+	return nil
+}
+
+// validateFormat checks an output's format setting; empty selects the
+// output's default
+func validateFormat(name, format string) error {
+	if format == "" {
+		return nil
+	}
+	if template, ok := strings.CutPrefix(format, TemplateFormatPrefix); ok {
+		if template == "" {
+			return fmt.Errorf("invalid %s '%s', must name a template", name, format)
+		}
+		return nil
+	}
+	for _, known := range OutputFormats {
+		if format == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid %s '%s', must be one of: %s or %s<name>", name, format, strings.Join(OutputFormats, ", "), TemplateFormatPrefix)
+}
+
+// redactOutputs returns a copy of outputs with credentials replaced, for
+// change logs
+func redactOutputs(outputs []Output) []Output {
+	redacted := make([]Output, len(outputs))
+	for i, out := range outputs {
+		redacted[i] = out
+		if out.Sentinel != nil && out.Sentinel.SharedKey != "" {
+			sentinelOut := *out.Sentinel
+			sentinelOut.SharedKey = "[redacted]"
+			redacted[i].Sentinel = &sentinelOut
+		}
+		if out.NATS != nil && (out.NATS.Password != "" || out.NATS.Token != "") {
+			natsOut := *out.NATS
+			natsOut.Password, natsOut.Token = redactValue(natsOut.Password), redactValue(natsOut.Token)
+			redacted[i].NATS = &natsOut
+		}
+		if out.AMQP != nil && out.AMQP.Password != "" {
+			amqpOut := *out.AMQP
+			amqpOut.Password = redactValue(amqpOut.Password)
+			redacted[i].AMQP = &amqpOut
+		}
+		if out.Firehose != nil && out.Firehose.Credentials.SecretAccessKey != "" {
+			firehoseOut := *out.Firehose
+			firehoseOut.Credentials.SecretAccessKey = redactValue(firehoseOut.Credentials.SecretAccessKey)
+			redacted[i].Firehose = &firehoseOut
+		}
+		if out.S3 != nil && out.S3.Credentials.SecretAccessKey != "" {
+			s3Out := *out.S3
+			s3Out.Credentials.SecretAccessKey = redactValue(s3Out.Credentials.SecretAccessKey)
+			redacted[i].S3 = &s3Out
+		}
+		if out.GRPC != nil && out.GRPC.Token != "" {
+			grpcOut := *out.GRPC
+			grpcOut.Token = redactValue(grpcOut.Token)
+			redacted[i].GRPC = &grpcOut
+		}
+		if out.Loki != nil && (out.Loki.Password != "" || out.Loki.Token != "") {
+			lokiOut := *out.Loki
+			lokiOut.Password, lokiOut.Token = redactValue(lokiOut.Password), redactValue(lokiOut.Token)
+			redacted[i].Loki = &lokiOut
+		}
+		if out.Datadog != nil && out.Datadog.APIKey != "" {
+			datadogOut := *out.Datadog
+			datadogOut.APIKey = redactValue(datadogOut.APIKey)
+			redacted[i].Datadog = &datadogOut
+		}
+	}
+	return redacted
+}
+
+// redactValue replaces a non-empty credential
+func redactValue(value string) string {
+	if value == "" {
+		return ""
+	}
+	return "[redacted]"
+}
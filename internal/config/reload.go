@@ -0,0 +1,251 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// restartOnlyFields lists settings that cannot be applied to a running service
+var restartOnlyFields = map[string]bool{
+	"CatoAPIURL":             true,
+	"CatoAccountID":          true,
+	"CatoAccountIDs":         true,
+	"CatoQueryFile":          true,
+	"DeploymentID":           true,
+	"DiscoveryParentID":      true,
+	"DiscoveryInterval":      true,
+	"DiscoveryExclude":       true,
+	"SyslogServer":           true,
+	"SyslogPort":             true,
+	"SyslogProtocol":         true,
+	"SyslogBindAddr":         true,
+	"SyslogIPPref":           true,
+	"Outputs":                true,
+	"Feeds":                  true,
+	"OCSFClasses":            true,
+	"ECSFieldMappings":       true,
+	"Templates":              true,
+	"ConnTimeout":            true,
+	"FormatWorkers":          true,
+	"DialTimeout":            true,
+	"TLSHandshakeTimeout":    true,
+	"ResponseHeaderTimeout":  true,
+	"BodyReadTimeout":        true,
+	"MaxResponseMB":          true,
+	"MaxPageEvents":          true,
+	"ResponseLimitAction":    true,
+	"MarkerFile":             true,
+	"MarkerHistory":          true,
+	"MarkerJournal":          true,
+	"JournalChunk":           true,
+	"StateKey":               true,
+	"DeadLetterFile":         true,
+	"DeadLetterMaxMB":        true,
+	"LogFormat":              true,
+	"LogOutput":              true,
+	"LogRotation":            true,
+	"StartupReportFile":      true,
+	"LogFacility":            true,
+	"LogDedupWindow":         true,
+	"MinFreeDiskMB":          true,
+	"ClockSkewWarn":          true,
+	"ClockSkewFail":          true,
+	"PreflightChecks":        true,
+	"PreflightRetry":         true,
+	"PreflightRetryMaxDelay": true,
+	"PreflightRetryTimeout":  true,
+	"WatchConfig":            true,
+	"WatchInterval":          true,
+	"AdminListen":            true,
+	"AdminToken":             true,
+	"GCPercent":              true,
+	"MemoryLimitMB":          true,
+	"APICaptureDir":          true,
+	"APICaptureCount":        true,
+}
+
+// runtimeFields lists settings that do not come from the config file
+var runtimeFields = map[string]bool{
+	"Verbose":    true,
+	"Once":       true,
+	"ConfigPath": true,
+	"Overrides":  true,
+}
+
+// secretFields lists settings whose values must never be logged
+var secretFields = map[string]bool{
+	"CatoAPIKey":     true,
+	"CatoAPIKeyNext": true,
+	"Redaction":      true,
+	"StateKey":       true,
+	"AdminToken":     true,
+}
+
+// Change describes a single setting that differs between two configurations
+type Change struct {
+	Field           string
+	Old             string
+	New             string
+	RequiresRestart bool
+}
+
+// Reload re-reads the config file the running config was loaded from,
+// carries over runtime settings, and validates the result
+func Reload(running *Config) (*Config, error) {
+	cfg, err := loadFromJSON(running.ConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Verbose = running.Verbose
+	cfg.Once = running.Once
+	cfg.ConfigPath = running.ConfigPath
+	cfg.Overrides = running.Overrides
+	cfg.Overrides.apply(cfg)
+	if cfg.Verbose {
+		cfg.LogLevel = "debug"
+	}
+
+	if err := cfg.resolveSecrets(); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// Diff compares two configurations field by field. Secret values are redacted.
+func Diff(old, new *Config) []Change {
+	var changes []Change
+
+	oldValue := reflect.ValueOf(old).Elem()
+	newValue := reflect.ValueOf(new).Elem()
+	t := oldValue.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Name
+		if runtimeFields[name] {
+			continue
+		}
+
+		a := oldValue.Field(i).Interface()
+		b := newValue.Field(i).Interface()
+		if reflect.DeepEqual(a, b) {
+			continue
+		}
+		if name == "Outputs" {
+			a, b = redactOutputs(old.Outputs), redactOutputs(new.Outputs)
+		}
+
+		change := Change{
+			Field:           name,
+			Old:             formatValue(a),
+			New:             formatValue(b),
+			RequiresRestart: restartOnlyFields[name],
+		}
+		if secretFields[name] {
+			change.Old = "[redacted]"
+			change.New = "[redacted]"
+		}
+		changes = append(changes, change)
+	}
+
+	return changes
+}
+
+// Redacted returns a copy of the configuration for display: secret values
+// are replaced with [redacted], and the outputs and feeds are the effective
+// ones, including those derived from the legacy syslog and cef sections
+func (c *Config) Redacted() *Config {
+	r := *c
+	r.CatoAPIKey = redactValue(c.CatoAPIKey)
+	r.CatoAPIKeyNext = redactValue(c.CatoAPIKeyNext)
+	r.StateKey = redactValue(c.StateKey)
+	r.AdminToken = redactValue(c.AdminToken)
+	r.Redaction.Salt = redactValue(c.Redaction.Salt)
+	r.Outputs = redactOutputs(c.EffectiveOutputs())
+	r.Feeds = c.EffectiveFeeds()
+	return &r
+}
+
+// formatValue renders a config value for change logs; composite values as JSON
+func formatValue(v interface{}) string {
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Struct, reflect.Slice, reflect.Map, reflect.Ptr:
+		if data, err := json.Marshal(v); err == nil {
+			return string(data)
+		}
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// KeepRestartOnly copies settings that require a restart from the running config,
+// so a reloaded config only changes what can actually be applied live
+func (c *Config) KeepRestartOnly(running *Config) {
+	target := reflect.ValueOf(c).Elem()
+	source := reflect.ValueOf(running).Elem()
+
+	for name := range restartOnlyFields {
+		target.FieldByName(name).Set(source.FieldByName(name))
+	}
+}
+
+// Watch polls the config file and the files it includes and signals on the
+// returned channel whenever a modification time or size changes, or a file
+// is included or dropped. Rapid successive changes are coalesced.
+func Watch(ctx context.Context, path string, interval time.Duration) <-chan struct{} {
+	changed := make(chan struct{}, 1)
+
+	go func() {
+		last, _ := filesState(path)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				state, err := filesState(path)
+				if err != nil {
+					// File may be mid-replace by an editor; try again next tick
+					continue
+				}
+				if state == last {
+					continue
+				}
+				last = state
+
+				select {
+				case changed <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+
+	return changed
+}
+
+// filesState summarizes the name, modification time and size of a config
+// file and its includes
+func filesState(path string) (string, error) {
+	var state strings.Builder
+	for _, file := range ConfigFiles(path) {
+		info, err := os.Stat(file)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&state, "%s %d %d\n", file, info.ModTime().UnixNano(), info.Size())
+	}
+	return state.String(), nil
+}
@@ -0,0 +1,171 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Schema returns a JSON Schema (draft-07) describing the config file.
+// It is derived from the same struct the loader decodes into, so it cannot drift.
+func Schema() map[string]interface{} {
+	schema := schemaFor(reflect.TypeOf(jsonConfig{}))
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["title"] = "Cato Networks CEF Forwarder configuration"
+	return schema
+}
+
+// schemaFor builds the schema fragment for a Go type
+func schemaFor(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Struct:
+		properties := make(map[string]interface{})
+		for i := 0; i < t.NumField(); i++ {
+			name := jsonFieldName(t.Field(i))
+			if name == "" {
+				continue
+			}
+			properties[name] = schemaFor(t.Field(i).Type)
+		}
+		return map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": schemaFor(t.Elem()),
+		}
+	case reflect.Slice:
+		return map[string]interface{}{
+			"type":  "array",
+			"items": schemaFor(t.Elem()),
+		}
+	case reflect.Ptr:
+		return schemaFor(t.Elem())
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	default:
+		return map[string]interface{}{"type": "string"}
+	}
+}
+
+// jsonFieldName returns the JSON key for a struct field, or "" if it is not serialized
+func jsonFieldName(field reflect.StructField) string {
+	tag := field.Tag.Get("json")
+	if tag == "-" || field.PkgPath != "" {
+		return ""
+	}
+	name := strings.Split(tag, ",")[0]
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// checkUnknownKeys walks a decoded config document against the schema and
+// reports every key the schema does not define, with a suggestion when one is close.
+func checkUnknownKeys(doc interface{}, schema map[string]interface{}, path string) []string {
+	var problems []string
+
+	switch value := doc.(type) {
+	case map[string]interface{}:
+		properties, isStruct := schema["properties"].(map[string]interface{})
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			keyPath := joinPath(path, key)
+			if !isStruct {
+				// Free-form map: validate the values against the element schema
+				if elem, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+					problems = append(problems, checkUnknownKeys(value[key], elem, keyPath)...)
+				}
+				continue
+			}
+
+			child, known := properties[key].(map[string]interface{})
+			if !known {
+				msg := fmt.Sprintf("unknown key %s", keyPath)
+				if suggestion := closestKey(key, properties); suggestion != "" {
+					msg += fmt.Sprintf(" (did you mean %s?)", joinPath(path, suggestion))
+				}
+				problems = append(problems, msg)
+				continue
+			}
+			problems = append(problems, checkUnknownKeys(value[key], child, keyPath)...)
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range value {
+				problems = append(problems, checkUnknownKeys(item, items, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	}
+
+	return problems
+}
+
+// joinPath builds a dotted key path
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// closestKey returns the known key nearest to key by edit distance, if reasonably close
+func closestKey(key string, properties map[string]interface{}) string {
+	best := ""
+	bestDistance := len(key)/2 + 1
+	for candidate := range properties {
+		if d := editDistance(key, candidate); d < bestDistance || (d == bestDistance && candidate < best) {
+			best = candidate
+			bestDistance = d
+		}
+	}
+	return best
+}
+
+// editDistance computes the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	m := a
+	if b < m {
+		m = b
+	}
+	if c < m {
+		m = c
+	}
+	return m
+}
@@ -2,8 +2,26 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"cato-logger/internal/api"
+	"cato-logger/internal/cef"
+	"cato-logger/internal/marker"
+	"cato-logger/internal/objstore"
+	"cato-logger/internal/ocsf"
+	"cato-logger/internal/preflight"
+	"cato-logger/internal/tmpl"
 )
 
+// ecsFieldPattern matches dotted ECS field names
+var ecsFieldPattern = regexp.MustCompile(`^[a-z0-9_]+(\.[a-z0-9_]+)*$`)
+
+// deploymentIDPattern keeps the deployment ID safe to send in HTTP headers
+var deploymentIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
 // Validate checks if the configuration is valid
 func (c *Config) Validate() error {
 	missing := []string{}
@@ -12,24 +30,21 @@ func (c *Config) Validate() error {
 	if c.CatoAPIKey == "" {
 		missing = append(missing, "cato.api_key")
 	}
-	if c.CatoAccountID == "" {
+	if c.CatoAccountID == "" && len(c.CatoAccountIDs) == 0 && c.DiscoveryParentID == "" {
 		missing = append(missing, "cato.account_id")
 	}
 
-	// Required Syslog settings
-	if c.SyslogServer == "" {
-		missing = append(missing, "syslog.server")
-	}
-	if c.SyslogPort <= 0 {
-		missing = append(missing, "syslog.port")
-	}
-	if c.SyslogProtocol == "" {
-		missing = append(missing, "syslog.protocol")
-	}
-
-	// Required CEF settings
-	if len(c.FieldMappings) == 0 {
-		missing = append(missing, "cef.field_mappings")
+	// Required Syslog settings, unless destinations are listed under outputs
+	if len(c.Outputs) == 0 {
+		if c.SyslogServer == "" {
+			missing = append(missing, "syslog.server")
+		}
+		if c.SyslogPort <= 0 {
+			missing = append(missing, "syslog.port")
+		}
+		if c.SyslogProtocol == "" {
+			missing = append(missing, "syslog.protocol")
+		}
 	}
 
 	if len(missing) > 0 {
@@ -46,6 +61,11 @@ func (c *Config) Validate() error {
 	if !validLogLevels[c.LogLevel] {
 		return fmt.Errorf("invalid log level '%s', must be one of: debug, info, warn, error", c.LogLevel)
 	}
+	for component, level := range c.LogComponentLevels {
+		if !validLogLevels[level] {
+			return fmt.Errorf("invalid log level '%s' for component '%s', must be one of: debug, info, warn, error", level, component)
+		}
+	}
 
 	// Validate log format
 	validLogFormats := map[string]bool{
@@ -58,11 +78,57 @@ func (c *Config) Validate() error {
 
 	// Validate syslog protocol
 	validProtocols := map[string]bool{
-		"tcp": true,
-		"udp": true,
+		"tcp":  true,
+		"udp":  true,
+		"relp": true,
+	}
+	if len(c.Outputs) == 0 && !validProtocols[c.SyslogProtocol] {
+		return fmt.Errorf("invalid syslog protocol '%s', must be tcp, udp or relp", c.SyslogProtocol)
+	}
+	if c.SyslogBindAddr != "" && net.ParseIP(c.SyslogBindAddr) == nil {
+		return fmt.Errorf("syslog.bind_address must be an IP address, got '%s'", c.SyslogBindAddr)
+	}
+	if err := validateIPPreference(c.SyslogIPPref); err != nil {
+		return err
+	}
+
+	if err := c.validateOutputs(); err != nil {
+		return err
 	}
-	if !validProtocols[c.SyslogProtocol] {
-		return fmt.Errorf("invalid syslog protocol '%s', must be tcp or udp", c.SyslogProtocol)
+
+	if err := c.validateFeeds(); err != nil {
+		return err
+	}
+
+	if c.CatoQueryFile != "" {
+		query, err := api.LoadQuery(c.CatoQueryFile)
+		if err != nil {
+			return fmt.Errorf("cato.query_file: %w", err)
+		}
+		for _, feed := range c.Feeds {
+			if len(feed.Filters) > 0 && !strings.Contains(query, "$filters") {
+				return fmt.Errorf("cato.query_file must declare and pass $filters, feed '%s' uses filters", feed.Name)
+			}
+		}
+	}
+
+	if c.DeploymentID != "" && !deploymentIDPattern.MatchString(c.DeploymentID) {
+		return fmt.Errorf("cato.deployment_id must be 1-64 letters, digits, '.', '_' or '-', got '%s'", c.DeploymentID)
+	}
+	if c.DeploymentIDField != "" && c.DeploymentID == "" {
+		return fmt.Errorf("labels.deployment_id_field requires cato.deployment_id")
+	}
+	for name := range c.Labels {
+		if name == "" {
+			return fmt.Errorf("labels.fields has an empty field name")
+		}
+		if name == c.DeploymentIDField {
+			return fmt.Errorf("labels.fields[%q] is also labels.deployment_id_field", name)
+		}
+	}
+
+	if err := c.validateAccounts(); err != nil {
+		return err
 	}
 
 	// Validate processing settings
@@ -70,14 +136,26 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("fetch_interval_seconds must be at least 10 seconds, got %d", c.FetchInterval)
 	}
 
-	if c.MaxEvents < 1 || c.MaxEvents > 5000 {
-		return fmt.Errorf("max_events_per_request must be between 1 and 5000, got %d", c.MaxEvents)
+	if c.MaxEvents < 1 || c.MaxEvents > MaxEventsLimit {
+		return fmt.Errorf("max_events_per_request must be between 1 and %d, got %d", MaxEventsLimit, c.MaxEvents)
 	}
 
 	if c.MaxPagination < 1 {
 		return fmt.Errorf("max_pagination_requests must be at least 1, got %d", c.MaxPagination)
 	}
 
+	if c.CatchUpBudget < 0 {
+		return fmt.Errorf("catch_up_budget_seconds cannot be negative, got %d", c.CatchUpBudget)
+	}
+
+	if c.PageDelay < 0 {
+		return fmt.Errorf("page_delay_ms cannot be negative, got %d", c.PageDelay)
+	}
+
+	if c.DrainLag < 0 {
+		return fmt.Errorf("drain_lag_seconds cannot be negative, got %d", c.DrainLag)
+	}
+
 	if c.RetryAttempts < 0 {
 		return fmt.Errorf("retry_attempts cannot be negative, got %d", c.RetryAttempts)
 	}
@@ -86,5 +164,283 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("connection_timeout_seconds must be at least 1, got %d", c.ConnTimeout)
 	}
 
+	if c.MaxResponseMB < 0 {
+		return fmt.Errorf("max_response_size_mb cannot be negative, got %d", c.MaxResponseMB)
+	}
+	if c.MaxPageEvents < 0 {
+		return fmt.Errorf("max_events_per_page cannot be negative, got %d", c.MaxPageEvents)
+	}
+	if c.MaxPageEvents > 0 && c.MaxPageEvents < c.MaxEvents {
+		return fmt.Errorf("max_events_per_page (%d) cannot be below max_events_per_request (%d)", c.MaxPageEvents, c.MaxEvents)
+	}
+	if c.ResponseLimitAction != "abort" && c.ResponseLimitAction != "warn" {
+		return fmt.Errorf("invalid response_limit_action '%s', must be one of: abort, warn", c.ResponseLimitAction)
+	}
+
+	for _, markerFile := range c.MarkerFiles() {
+		if objstore.IsURL(markerFile) {
+			if _, _, _, err := objstore.Split(markerFile); err != nil {
+				return fmt.Errorf("invalid marker location: %v", err)
+			}
+		}
+	}
+
+	if c.MarkerHistory < 0 {
+		return fmt.Errorf("state.history_size cannot be negative, got %d", c.MarkerHistory)
+	}
+
+	if c.StaleMarker < 0 {
+		return fmt.Errorf("state.stale_after_minutes cannot be negative, got %d", c.StaleMarker)
+	}
+
+	if c.StateKey != "" {
+		if _, err := marker.NewCipher(c.StateKey); err != nil {
+			return fmt.Errorf("invalid state.encryption_key: %v", err)
+		}
+	}
+
+	if c.DeadLetterMaxMB < 0 {
+		return fmt.Errorf("dead_letter.max_size_mb cannot be negative, got %d", c.DeadLetterMaxMB)
+	}
+
+	// Validate facility for syslog/journald log output
+	validFacilities := map[string]bool{
+		"": true, "daemon": true, "user": true,
+		"local0": true, "local1": true, "local2": true, "local3": true,
+		"local4": true, "local5": true, "local6": true, "local7": true,
+	}
+	if !validFacilities[c.LogFacility] {
+		return fmt.Errorf("invalid logging.syslog_facility '%s', must be daemon, user, or local0-local7", c.LogFacility)
+	}
+
+	if c.MinFreeDiskMB < 0 {
+		return fmt.Errorf("preflight.min_free_disk_mb cannot be negative, got %d", c.MinFreeDiskMB)
+	}
+
+	if c.ClockSkewWarn < 0 || c.ClockSkewFail < 0 {
+		return fmt.Errorf("preflight clock skew thresholds cannot be negative")
+	}
+	if c.ClockSkewWarn > 0 && c.ClockSkewFail > 0 && c.ClockSkewWarn > c.ClockSkewFail {
+		return fmt.Errorf("preflight.clock_skew_warn_seconds (%d) cannot exceed clock_skew_fail_seconds (%d)", c.ClockSkewWarn, c.ClockSkewFail)
+	}
+
+	for id, check := range c.PreflightChecks {
+		known := false
+		for _, checkID := range preflight.CheckIDs {
+			if id == checkID {
+				known = true
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown pre-flight check '%s' in preflight.checks, must be one of: %s", id, strings.Join(preflight.CheckIDs, ", "))
+		}
+		if check.TimeoutSeconds < 0 {
+			return fmt.Errorf("preflight.checks.%s.timeout_seconds cannot be negative, got %d", id, check.TimeoutSeconds)
+		}
+	}
+
+	if c.PreflightRetryTimeout < 0 {
+		return fmt.Errorf("preflight.retry_timeout_seconds cannot be negative, got %d", c.PreflightRetryTimeout)
+	}
+
+	if c.LogDedupWindow < 0 {
+		return fmt.Errorf("logging.dedup_window_seconds cannot be negative, got %d", c.LogDedupWindow)
+	}
+
+	if c.LogRotation.MaxSizeMB < 0 || c.LogRotation.MaxBackups < 0 || c.LogRotation.MaxAgeDays < 0 {
+		return fmt.Errorf("logging.rotation values cannot be negative")
+	}
+
+	if c.APICaptureCount < 0 {
+		return fmt.Errorf("debug.api_capture_count cannot be negative, got %d", c.APICaptureCount)
+	}
+
+	if c.StatsInterval < 0 {
+		return fmt.Errorf("stats.report_interval_minutes cannot be negative, got %d", c.StatsInterval)
+	}
+	if c.SLOLatency < 0 {
+		return fmt.Errorf("stats.slo.latency_seconds cannot be negative, got %d", c.SLOLatency)
+	}
+	if c.SLOLatency > 0 {
+		if c.SLOTarget <= 0 || c.SLOTarget > 100 {
+			return fmt.Errorf("stats.slo.target_percent must be above 0 and at most 100, got %g", c.SLOTarget)
+		}
+		if c.SLOWindow < 1 {
+			return fmt.Errorf("stats.slo.window_minutes must be at least 1, got %d", c.SLOWindow)
+		}
+	}
+
+	if c.GCPercent != nil && *c.GCPercent < -1 {
+		return fmt.Errorf("runtime.gogc must be a percentage, or -1 to turn the garbage collector off, got %d", *c.GCPercent)
+	}
+	if c.MemoryLimitMB < 0 {
+		return fmt.Errorf("runtime.memory_limit_mb cannot be negative, got %d", c.MemoryLimitMB)
+	}
+
+	if c.AdminListen != "" {
+		if _, _, err := net.SplitHostPort(c.AdminListen); err != nil {
+			return fmt.Errorf("invalid admin.listen '%s', must be host:port: %v", c.AdminListen, err)
+		}
+	}
+
+	// Validate CEF value length caps
+	if c.CEFLimits.MaxValueLength < 0 {
+		return fmt.Errorf("cef.max_value_length cannot be negative, got %d", c.CEFLimits.MaxValueLength)
+	}
+	for key, max := range c.CEFLimits.FieldMaxLength {
+		if key == "" {
+			return fmt.Errorf("cef.field_max_length has an empty extension key")
+		}
+		if max < 0 {
+			return fmt.Errorf("cef.field_max_length[%q] cannot be negative, got %d", key, max)
+		}
+	}
+
+	// Validate CEF timestamp fields
+	for key, field := range c.CEFTimestamps.Fields {
+		if key != "rt" && key != "start" && key != "end" {
+			return fmt.Errorf("invalid cef.timestamp_fields key '%s', must be one of: %s", key, strings.Join(cef.TimestampKeys, ", "))
+		}
+		if field == "" {
+			return fmt.Errorf("cef.timestamp_fields[%q] is missing an event field", key)
+		}
+	}
+	if c.CEFTimestamps.Timezone != "" {
+		if _, err := time.LoadLocation(c.CEFTimestamps.Timezone); err != nil {
+			return fmt.Errorf("invalid cef.timezone '%s': %v", c.CEFTimestamps.Timezone, err)
+		}
+	}
+
+	// Validate CEF severity levels
+	for name, level := range c.CEFSeverity.Levels {
+		if level < 0 || level > 10 {
+			return fmt.Errorf("cef.severity.levels[%q] must be between 0 and 10, got %d", name, level)
+		}
+	}
+
+	// Validate CEF mapping profiles
+	for eventType, profile := range c.CEFProfiles {
+		if eventType == "" {
+			return fmt.Errorf("cef.profiles has an empty event type")
+		}
+		if len(profile.FieldMappings) == 0 {
+			return fmt.Errorf("cef.profiles[%q] is missing field_mappings", eventType)
+		}
+	}
+
+	// Validate CEF unmapped field handling
+	validMode := false
+	for _, mode := range cef.UnmappedModes {
+		validMode = validMode || c.CEFUnmapped.Mode == mode
+	}
+	if !validMode {
+		return fmt.Errorf("invalid cef.unmapped.mode '%s', must be one of: %s", c.CEFUnmapped.Mode, strings.Join(cef.UnmappedModes, ", "))
+	}
+	if c.CEFUnmapped.Mode == cef.UnmappedAllowlist && len(c.CEFUnmapped.Allowlist) == 0 {
+		return fmt.Errorf("cef.unmapped.allowlist is required when cef.unmapped.mode is allowlist")
+	}
+	if c.CEFUnmapped.Mode == cef.UnmappedDrop && c.CEFUnmapped.PackKey != "" {
+		return fmt.Errorf("cef.unmapped.pack_key has nothing to pack when cef.unmapped.mode is drop")
+	}
+	if key := c.CEFUnmapped.PackKey; key != "" && strings.ContainsAny(key, " =|\\") {
+		return fmt.Errorf("invalid cef.unmapped.pack_key '%s', must not contain spaces, '=', '|' or backslashes", key)
+	}
+
+	// Validate OCSF class overrides
+	for key, uid := range c.OCSFClasses {
+		if key == "" {
+			return fmt.Errorf("ocsf.classes has an empty event type")
+		}
+		if _, ok := ocsf.Classes[uid]; !ok {
+			return fmt.Errorf("ocsf.classes[%q] has unsupported class %d, must be one of: 0, 2004, 3004, 4001, 4002, 4003", key, uid)
+		}
+	}
+
+	// Validate ECS field mapping overrides
+	for field, target := range c.ECSFieldMappings {
+		if field == "" {
+			return fmt.Errorf("ecs.field_mappings has an empty field name")
+		}
+		if target != "" && !ecsFieldPattern.MatchString(target) {
+			return fmt.Errorf("ecs.field_mappings[%q] must be a dotted ECS field name such as source.ip, got '%s'", field, target)
+		}
+	}
+
+	// Validate output templates
+	for name, text := range c.Templates {
+		if name == "" {
+			return fmt.Errorf("templates has an empty template name")
+		}
+		if _, err := tmpl.New(name, text); err != nil {
+			return fmt.Errorf("invalid templates[%q]: %v", name, err)
+		}
+	}
+	for _, out := range c.EffectiveOutputs() {
+		if name, ok := strings.CutPrefix(out.Format(), TemplateFormatPrefix); ok {
+			if _, exists := c.Templates[name]; !exists {
+				return fmt.Errorf("output %s uses template %s, which is not defined under templates", out.Name, name)
+			}
+		}
+	}
+
+	// Validate transform rules
+	for i, rule := range c.Transform.Replace {
+		if rule.Field == "" {
+			return fmt.Errorf("transform.replace[%d] is missing a field name", i)
+		}
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return fmt.Errorf("transform.replace[%d] has an invalid pattern: %v", i, err)
+		}
+	}
+
+	// Validate redaction rules
+	for i, rule := range c.Redaction.Rules {
+		if len(rule.Fields) == 0 {
+			return fmt.Errorf("redaction.rules[%d] must list at least one field", i)
+		}
+		switch rule.Action {
+		case "hash":
+			if c.Redaction.Salt == "" {
+				return fmt.Errorf("redaction.rules[%d] uses hash but redaction.salt is empty", i)
+			}
+		case "mask":
+		default:
+			return fmt.Errorf("redaction.rules[%d] has invalid action '%s', must be hash or mask", i, rule.Action)
+		}
+		for _, cidr := range rule.CIDRs {
+			if _, _, err := net.ParseCIDR(cidr); err != nil {
+				return fmt.Errorf("redaction.rules[%d] has invalid CIDR '%s'", i, cidr)
+			}
+		}
+	}
+
+	// Validate sampling rates
+	for eventType, rate := range c.Sampling.Rates {
+		if rate < 1 {
+			return fmt.Errorf("sampling.rates[%s] must be at least 1, got %d", eventType, rate)
+		}
+	}
+
+	// Validate aggregation
+	if len(c.Aggregation.EventTypes) > 0 && len(c.Aggregation.KeyFields) == 0 {
+		return fmt.Errorf("aggregation.key_fields must list at least one field")
+	}
+	if c.Aggregation.WindowSeconds < 0 {
+		return fmt.Errorf("aggregation.window_seconds cannot be negative, got %d", c.Aggregation.WindowSeconds)
+	}
+
+	// Validate lookup tables
+	for i, table := range c.LookupTables {
+		if table.Path == "" || table.EventField == "" || table.KeyColumn == "" {
+			return fmt.Errorf("enrichment.lookup_tables[%d] requires path, event_field and key_column", i)
+		}
+		if len(table.OutputFields) == 0 {
+			return fmt.Errorf("enrichment.lookup_tables[%d] must define at least one output field", i)
+		}
+		if table.Format != "" && table.Format != "csv" && table.Format != "json" {
+			return fmt.Errorf("enrichment.lookup_tables[%d] has invalid format '%s', must be csv or json", i, table.Format)
+		}
+	}
+
 	return nil
 }
@@ -0,0 +1,121 @@
+package dlq
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Reasons an event was dead-lettered
+const (
+	ReasonFormat   = "format"   // The pre-formatting stages failed for the event
+	ReasonDelivery = "delivery" // An output kept rejecting the event's page
+)
+
+// Entry is one dead-lettered event, stored as a line of JSON
+type Entry struct {
+	Time     time.Time         `json:"time"`
+	Feed     string            `json:"feed"`
+	Account  string            `json:"account,omitempty"`
+	Reason   string            `json:"reason"`
+	Output   string            `json:"output,omitempty"` // Output that failed, for delivery failures
+	Error    string            `json:"error"`
+	Fields   map[string]string `json:"fields"`             // As received for format failures, after the stages for delivery failures
+	Hostname string            `json:"hostname,omitempty"` // Delivery failures only
+}
+
+// Queue appends entries to a dead-letter file
+type Queue struct {
+	path     string
+	maxBytes int64 // 0 means unlimited
+
+	mu sync.Mutex
+}
+
+// Open prepares a dead-letter file at path that stops accepting entries once
+// it reaches maxBytes (0 for no limit)
+func Open(path string, maxBytes int64) (*Queue, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create dead-letter directory: %w", err)
+	}
+	return &Queue{path: path, maxBytes: maxBytes}, nil
+}
+
+// Path returns the dead-letter file path
+func (q *Queue) Path() string {
+	return q.path
+}
+
+// Append writes entries to the end of the file. The file is opened per call
+// so a replay can move it aside at any time.
+func (q *Queue) Append(entries []Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.maxBytes > 0 {
+		if info, err := os.Stat(q.path); err == nil && info.Size() >= q.maxBytes {
+			return fmt.Errorf("dead-letter file %s is full (%d bytes), replay or remove it", q.path, info.Size())
+		}
+	}
+
+	file, err := os.OpenFile(q.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open dead-letter file: %w", err)
+	}
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to write dead-letter entry: %w", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write dead-letter file: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to sync dead-letter file: %w", err)
+	}
+	return file.Close()
+}
+
+// Read returns all entries of a dead-letter file; a missing file has none
+func Read(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dead-letter file: %w", err)
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read dead-letter file: %w", err)
+	}
+	return entries, nil
+}
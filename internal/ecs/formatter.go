@@ -0,0 +1,168 @@
+package ecs
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formatter converts events to ECS JSON documents
+type Formatter struct {
+	vendor   string
+	product  string
+	mappings map[string]string
+}
+
+// NewFormatter creates a formatter using DefaultFieldMappings with
+// overrides; an override with an empty target removes a default mapping
+func NewFormatter(vendor, product string, overrides map[string]string) *Formatter {
+	mappings := make(map[string]string, len(DefaultFieldMappings)+len(overrides))
+	for field, target := range DefaultFieldMappings {
+		mappings[field] = target
+	}
+	for field, target := range overrides {
+		if target == "" {
+			delete(mappings, field)
+			continue
+		}
+		mappings[field] = target
+	}
+	return &Formatter{vendor: vendor, product: product, mappings: mappings}
+}
+
+// Format converts an event to an ECS document. Mapped fields are applied in
+// field name order, so when two fields map to one ECS field the first
+// wins; the other, and every unmapped field, is kept under cato.
+func (f *Formatter) Format(fields map[string]string) ([]byte, error) {
+	eventCategories := category(fields)
+	kind := "event"
+	for _, c := range eventCategories {
+		if alertCategories[c] {
+			kind = "alert"
+		}
+	}
+	dataset := "cato.events"
+	if fields["event_type"] == "Audit" {
+		dataset = "cato.audit"
+	}
+
+	doc := map[string]interface{}{
+		"@timestamp": timestamp(fields["time"]),
+		"ecs":        map[string]string{"version": Version},
+		"message":    message(fields),
+		"event": map[string]interface{}{
+			"kind":    kind,
+			"module":  "cato",
+			"dataset": dataset,
+		},
+		"observer": map[string]string{
+			"vendor":  f.vendor,
+			"product": f.product,
+			"type":    "sase",
+		},
+	}
+	if len(eventCategories) > 0 {
+		setPath(doc, "event.category", eventCategories)
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	unmapped := make(map[string]string)
+	for _, name := range names {
+		value := fields[name]
+		if value == "" || name == "time" {
+			continue
+		}
+		target, ok := f.mappings[name]
+		if !ok || !setPath(doc, target, typedValue(target, value)) {
+			unmapped[name] = value
+		}
+	}
+	if len(unmapped) > 0 {
+		doc["cato"] = unmapped
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode ecs document: %w", err)
+	}
+	return data, nil
+}
+
+// typedValue converts a field value to the type of its ECS field. Values
+// that do not parse are kept as strings.
+func typedValue(target, value string) interface{} {
+	if numericFields[target] {
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+	}
+	if lowercaseFields[target] {
+		return strings.ToLower(value)
+	}
+	return value
+}
+
+// setPath sets the field at a dotted path, creating objects on the way, and
+// reports whether it did. A field already set, or a path through a field
+// that is not an object, is left alone.
+func setPath(doc map[string]interface{}, path string, value interface{}) bool {
+	parts := strings.Split(path, ".")
+	obj := doc
+	for _, part := range parts[:len(parts)-1] {
+		existing, exists := obj[part]
+		if !exists {
+			next := make(map[string]interface{})
+			obj[part] = next
+			obj = next
+			continue
+		}
+		next, ok := existing.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		obj = next
+	}
+
+	last := parts[len(parts)-1]
+	if _, exists := obj[last]; exists {
+		return false
+	}
+	obj[last] = value
+	return true
+}
+
+// category returns the event.category values of an event
+func category(fields map[string]string) []string {
+	if c, ok := categories[fields["event_type"]+"/"+fields["event_sub_type"]]; ok {
+		return c
+	}
+	return categories[fields["event_type"]]
+}
+
+// timestamp returns the event time, or now when the event has none
+func timestamp(value string) string {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+	return time.Now().UTC().Format(time.RFC3339Nano)
+}
+
+// message describes an event the way the CEF name does
+func message(fields map[string]string) string {
+	eventType, subType := fields["event_type"], fields["event_sub_type"]
+	if eventType == "" {
+		eventType = "Unknown"
+	}
+	if subType == "" {
+		subType = "Unknown"
+	}
+	return eventType + " - " + subType
+}
@@ -0,0 +1,79 @@
+package ecs
+
+// Version is the ECS version events are produced for
+const Version = "8.11.0"
+
+// DefaultFieldMappings maps Cato event fields to ECS field paths. Fields
+// without a mapping are kept under cato.<field>.
+var DefaultFieldMappings = map[string]string{
+	"account_id":          "organization.id",
+	"action":              "event.action",
+	"ad_name":             "user.name",
+	"admin":               "user.name",
+	"admin_id":            "user.id",
+	"application":         "network.application",
+	"application_name":    "network.application",
+	"bytes_in":            "destination.bytes",
+	"bytes_out":           "source.bytes",
+	"change_type":         "event.action",
+	"configure_host_name": "host.hostname",
+	"dest_country_code":   "destination.geo.country_iso_code",
+	"dest_ip":             "destination.ip",
+	"dest_port":           "destination.port",
+	"device_name":         "host.name",
+	"device_os_type":      "host.os.name",
+	"device_type":         "host.type",
+	"domain_name":         "url.domain",
+	"host_mac":            "host.mac",
+	"http_request_method": "http.request.method",
+	"network_rule":        "rule.name",
+	"protocol":            "network.transport",
+	"rule_name":           "rule.name",
+	"src_country_code":    "source.geo.country_iso_code",
+	"src_ip":              "source.ip",
+	"src_port":            "source.port",
+	"traffic_direction":   "network.direction",
+	"url":                 "url.original",
+	"vpn_user_email":      "user.email",
+}
+
+// numericFields are ECS fields whose values are integers
+var numericFields = map[string]bool{
+	"source.port":       true,
+	"source.bytes":      true,
+	"destination.port":  true,
+	"destination.bytes": true,
+}
+
+// lowercaseFields are ECS fields whose values are expected in lowercase
+var lowercaseFields = map[string]bool{
+	"network.transport":   true,
+	"network.direction":   true,
+	"network.application": true,
+}
+
+// categories maps Cato event types, or "event type/sub type" pairs, to
+// event.category values. A pair takes precedence over its event type.
+var categories = map[string][]string{
+	"Audit":                    {"configuration"},
+	"Connectivity":             {"network", "session"},
+	"Detection and Response":   {"threat"},
+	"Lateral Movement":         {"threat"},
+	"Routing":                  {"network"},
+	"Security":                 {"network"},
+	"Security/Anti Malware":    {"malware"},
+	"Security/CASB":            {"web"},
+	"Security/IPS":             {"intrusion_detection", "network"},
+	"Security/NG Anti Malware": {"malware"},
+	"Security/URL Filtering":   {"web", "network"},
+	"Sockets Management":       {"host"},
+	"System":                   {"host"},
+}
+
+// alertCategories are the categories whose events are alerts rather than
+// plain events
+var alertCategories = map[string]bool{
+	"intrusion_detection": true,
+	"malware":             true,
+	"threat":              true,
+}
@@ -0,0 +1,206 @@
+package enrich
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"cato-logger/internal/config"
+	"cato-logger/internal/logging"
+)
+
+// table is a single loaded lookup table
+type table struct {
+	cfg     config.LookupTable
+	rows    map[string]map[string]string
+	modTime time.Time
+}
+
+// Enricher joins events against user-provided lookup tables
+type Enricher struct {
+	tables    []*table
+	mu        sync.RWMutex
+	refreshMu sync.Mutex // Serializes Refresh, which feeds call concurrently
+	logger    *logging.Logger
+}
+
+// New loads all configured lookup tables
+func New(tables []config.LookupTable, logger *logging.Logger) (*Enricher, error) {
+	e := &Enricher{
+		logger: logger,
+	}
+
+	for _, tc := range tables {
+		t := &table{cfg: tc}
+		if err := t.load(); err != nil {
+			return nil, fmt.Errorf("failed to load lookup table '%s': %w", tc.Name, err)
+		}
+		logger.Info("loaded lookup table", "name", tc.Name, "path", tc.Path, "rows", len(t.rows))
+		e.tables = append(e.tables, t)
+	}
+
+	return e, nil
+}
+
+// Empty reports whether no lookup tables are configured
+func (e *Enricher) Empty() bool {
+	return len(e.tables) == 0
+}
+
+// Refresh reloads any lookup table whose file changed since it was last loaded.
+// A table that fails to reload keeps serving its previous contents. It is
+// safe for concurrent use: a table is reloaded once however many callers
+// see it changed, and Apply is only blocked while the rows are swapped.
+func (e *Enricher) Refresh() {
+	e.refreshMu.Lock()
+	defer e.refreshMu.Unlock()
+
+	for _, t := range e.tables {
+		info, err := os.Stat(t.cfg.Path)
+		if err != nil {
+			e.logger.Warn("cannot stat lookup table, keeping previous contents",
+				"name", t.cfg.Name, "error", err.Error())
+			continue
+		}
+		if !info.ModTime().After(t.modTime) {
+			continue
+		}
+
+		reloaded := &table{cfg: t.cfg}
+		if err := reloaded.load(); err != nil {
+			e.logger.Warn("failed to reload lookup table, keeping previous contents",
+				"name", t.cfg.Name, "error", err.Error())
+			continue
+		}
+
+		e.mu.Lock()
+		t.rows = reloaded.rows
+		t.modTime = reloaded.modTime
+		e.mu.Unlock()
+
+		e.logger.Info("reloaded lookup table", "name", t.cfg.Name, "rows", len(reloaded.rows))
+	}
+}
+
+// Apply adds lookup output fields to the event in place and returns it
+func (e *Enricher) Apply(event map[string]string) map[string]string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	for _, t := range e.tables {
+		key, exists := event[t.cfg.EventField]
+		if !exists || key == "" {
+			continue
+		}
+		row, found := t.rows[key]
+		if !found {
+			continue
+		}
+		for column, field := range t.cfg.OutputFields {
+			if value := row[column]; value != "" {
+				event[field] = value
+			}
+		}
+	}
+	return event
+}
+
+// load reads the table file and indexes its rows by key column
+func (t *table) load() error {
+	info, err := os.Stat(t.cfg.Path)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(t.cfg.Path)
+	if err != nil {
+		return err
+	}
+
+	var records []map[string]string
+	switch tableFormat(t.cfg) {
+	case "csv":
+		records, err = parseCSV(data)
+	case "json":
+		records, err = parseJSON(data, t.cfg.KeyColumn)
+	default:
+		err = fmt.Errorf("unsupported format '%s', must be csv or json", t.cfg.Format)
+	}
+	if err != nil {
+		return err
+	}
+
+	rows := make(map[string]map[string]string, len(records))
+	for _, record := range records {
+		if key := record[t.cfg.KeyColumn]; key != "" {
+			rows[key] = record
+		}
+	}
+
+	t.rows = rows
+	t.modTime = info.ModTime()
+	return nil
+}
+
+// tableFormat returns the configured format, falling back to the file extension
+func tableFormat(tc config.LookupTable) string {
+	if tc.Format != "" {
+		return tc.Format
+	}
+	return strings.TrimPrefix(strings.ToLower(filepath.Ext(tc.Path)), ".")
+}
+
+// parseCSV reads a CSV file with a header row into records keyed by column name
+func parseCSV(data []byte) ([]map[string]string, error) {
+	reader := csv.NewReader(strings.NewReader(string(data)))
+	reader.TrimLeadingSpace = true
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	header := rows[0]
+	records := make([]map[string]string, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		record := make(map[string]string, len(header))
+		for i, column := range header {
+			if i < len(row) {
+				record[column] = row[i]
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// parseJSON accepts either an array of objects or an object keyed by lookup key
+func parseJSON(data []byte, keyColumn string) ([]map[string]string, error) {
+	var list []map[string]string
+	if err := json.Unmarshal(data, &list); err == nil {
+		return list, nil
+	}
+
+	var keyed map[string]map[string]string
+	if err := json.Unmarshal(data, &keyed); err != nil {
+		return nil, fmt.Errorf("invalid JSON: expected an array of objects or an object of objects")
+	}
+
+	records := make([]map[string]string, 0, len(keyed))
+	for key, record := range keyed {
+		if record == nil {
+			record = make(map[string]string)
+		}
+		record[keyColumn] = key
+		records = append(records, record)
+	}
+	return records, nil
+}
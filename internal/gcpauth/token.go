@@ -0,0 +1,236 @@
+package gcpauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	metadataHost  = "metadata.google.internal"
+	tokenEndpoint = "https://oauth2.googleapis.com/token"
+)
+
+// TokenSource obtains and caches Google OAuth2 access tokens for one scope.
+// It uses the credentials file in GOOGLE_APPLICATION_CREDENTIALS when set
+// (service account key or authorized user), and the metadata server of
+// GCE, GKE, and Cloud Run otherwise.
+type TokenSource struct {
+	scope  string
+	file   string // Credentials file overriding GOOGLE_APPLICATION_CREDENTIALS
+	client *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// NewTokenSource creates a token source for a scope such as
+// https://www.googleapis.com/auth/devstorage.read_write
+func NewTokenSource(scope string, client *http.Client) *TokenSource {
+	return &TokenSource{scope: scope, client: client}
+}
+
+// NewFileTokenSource creates a token source using a specific credentials
+// file, such as the service account key issued for Chronicle ingestion
+func NewFileTokenSource(scope, file string, client *http.Client) *TokenSource {
+	return &TokenSource{scope: scope, file: file, client: client}
+}
+
+// Token returns a valid access token, refreshing it shortly before expiry
+func (t *TokenSource) Token(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.token != "" && time.Now().Add(2*time.Minute).Before(t.expires) {
+		return t.token, nil
+	}
+
+	var token string
+	var expiresIn time.Duration
+	var err error
+	path := t.file
+	if path == "" {
+		path = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	}
+	if path != "" {
+		token, expiresIn, err = t.credentialsFile(ctx, path)
+	} else {
+		token, expiresIn, err = t.metadata(ctx)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	t.token = token
+	t.expires = time.Now().Add(expiresIn)
+	return t.token, nil
+}
+
+// credentialsFile is the JSON key file written by gcloud or the console
+type credentialsFile struct {
+	Type         string `json:"type"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	PrivateKeyID string `json:"private_key_id"`
+	TokenURI     string `json:"token_uri"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// credentialsFile exchanges a service account key or an authorized user
+// refresh token for an access token
+func (t *TokenSource) credentialsFile(ctx context.Context, path string) (string, time.Duration, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read google credentials: %w", err)
+	}
+	var creds credentialsFile
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return "", 0, fmt.Errorf("invalid credentials file %s: %w", path, err)
+	}
+	endpoint := creds.TokenURI
+	if endpoint == "" {
+		endpoint = tokenEndpoint
+	}
+
+	var form url.Values
+	switch creds.Type {
+	case "service_account":
+		assertion, err := signAssertion(creds, t.scope, endpoint, time.Now())
+		if err != nil {
+			return "", 0, err
+		}
+		form = url.Values{
+			"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+			"assertion":  {assertion},
+		}
+	case "authorized_user":
+		form = url.Values{
+			"grant_type":    {"refresh_token"},
+			"client_id":     {creds.ClientID},
+			"client_secret": {creds.ClientSecret},
+			"refresh_token": {creds.RefreshToken},
+		}
+	default:
+		return "", 0, fmt.Errorf("unsupported credentials type '%s' in %s", creds.Type, path)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return t.doTokenRequest(req, creds.Type)
+}
+
+// signAssertion builds the RS256-signed JWT of the service account grant
+func signAssertion(creds credentialsFile, scope, audience string, now time.Time) (string, error) {
+	block, _ := pem.Decode([]byte(creds.PrivateKey))
+	if block == nil {
+		return "", fmt.Errorf("service account private key is not PEM encoded")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("invalid service account private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return "", fmt.Errorf("service account private key is not an RSA key")
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": creds.PrivateKeyID})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss":   creds.ClientEmail,
+		"scope": scope,
+		"aud":   audience,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	encoding := base64.RawURLEncoding
+	unsigned := encoding.EncodeToString(header) + "." + encoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign service account assertion: %w", err)
+	}
+	return unsigned + "." + encoding.EncodeToString(signature), nil
+}
+
+// metadata requests a token for the attached service account
+func (t *TokenSource) metadata(ctx context.Context) (string, time.Duration, error) {
+	host := os.Getenv("GCE_METADATA_HOST")
+	if host == "" {
+		host = metadataHost
+	}
+	endpoint := fmt.Sprintf("http://%s/computeMetadata/v1/instance/service-accounts/default/token?scopes=%s",
+		host, url.QueryEscape(t.scope))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	return t.doTokenRequest(req, "metadata server")
+}
+
+// tokenResponse is returned by both the OAuth2 endpoint and the metadata server
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+	Error       string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+// doTokenRequest executes a token request and parses the response
+func (t *TokenSource) doTokenRequest(req *http.Request, method string) (string, time.Duration, error) {
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("google %s token request failed: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", 0, fmt.Errorf("google %s token response unreadable: %w", method, err)
+	}
+
+	var tr tokenResponse
+	if err := json.Unmarshal(body, &tr); err != nil {
+		return "", 0, fmt.Errorf("google %s token response invalid (status %d)", method, resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK || tr.AccessToken == "" {
+		return "", 0, fmt.Errorf("google %s token request rejected (status %d): %s %s",
+			method, resp.StatusCode, tr.Error, tr.Description)
+	}
+
+	seconds := tr.ExpiresIn
+	if seconds <= 0 {
+		seconds = 300
+	}
+	return tr.AccessToken, time.Duration(seconds) * time.Second, nil
+}
@@ -0,0 +1,43 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+)
+
+type contextKey struct{}
+
+// ContextWith returns a context carrying attributes that are added to every
+// record logged with it through the *Context methods (InfoContext, etc.)
+func ContextWith(ctx context.Context, args ...any) context.Context {
+	existing, _ := ctx.Value(contextKey{}).([]slog.Attr)
+	r := slog.Record{}
+	r.Add(args...)
+
+	attrs := append([]slog.Attr(nil), existing...)
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	return context.WithValue(ctx, contextKey{}, attrs)
+}
+
+// contextAttrs returns the attributes attached by ContextWith
+func contextAttrs(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	attrs, _ := ctx.Value(contextKey{}).([]slog.Attr)
+	return attrs
+}
+
+// NewCorrelationID returns a short random ID for correlating related log lines
+func NewCorrelationID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,139 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dedupState tracks repeated warnings and errors across a logger tree
+type dedupState struct {
+	window  time.Duration
+	mu      sync.Mutex
+	entries map[string]*dedupEntry
+	closed  bool
+}
+
+// dedupEntry is an open suppression window for one distinct warning or error
+type dedupEntry struct {
+	handler  slog.Handler
+	last     slog.Record
+	repeated int
+	timer    *time.Timer
+}
+
+// dedupHandler collapses identical warnings and errors logged within a window
+// into the first occurrence plus one summary line carrying a repeat count.
+// Records are identical when their scope (e.g. component), level, message and
+// error attribute match; other attributes are taken from the latest repeat.
+type dedupHandler struct {
+	inner slog.Handler
+	state *dedupState
+	scope string
+}
+
+func newDedupHandler(inner slog.Handler, window time.Duration) *dedupHandler {
+	return &dedupHandler{
+		inner: inner,
+		state: &dedupState{window: window, entries: make(map[string]*dedupEntry)},
+	}
+}
+
+func (h *dedupHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h *dedupHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < WARN {
+		return h.inner.Handle(ctx, r)
+	}
+
+	key := h.key(r)
+	s := h.state
+
+	s.mu.Lock()
+	if entry, ok := s.entries[key]; ok {
+		entry.repeated++
+		entry.last = r.Clone()
+		s.mu.Unlock()
+		return nil
+	}
+	if !s.closed {
+		s.entries[key] = &dedupEntry{
+			handler: h.inner,
+			timer:   time.AfterFunc(s.window, func() { s.flush(key) }),
+		}
+	}
+	s.mu.Unlock()
+
+	return h.inner.Handle(ctx, r)
+}
+
+func (h *dedupHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var b strings.Builder
+	b.WriteString(h.scope)
+	for _, a := range attrs {
+		b.WriteString(a.String())
+		b.WriteByte(' ')
+	}
+	return &dedupHandler{inner: h.inner.WithAttrs(attrs), state: h.state, scope: b.String()}
+}
+
+func (h *dedupHandler) WithGroup(name string) slog.Handler {
+	return &dedupHandler{inner: h.inner.WithGroup(name), state: h.state, scope: h.scope + name + "."}
+}
+
+// key identifies a record for suppression purposes
+func (h *dedupHandler) key(r slog.Record) string {
+	var errValue string
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == "error" {
+			errValue = a.Value.String()
+			return false
+		}
+		return true
+	})
+	return h.scope + "\x00" + r.Level.String() + "\x00" + r.Message + "\x00" + errValue
+}
+
+// flush closes the suppression window for key, emitting a summary if anything was suppressed
+func (s *dedupState) flush(key string) {
+	s.mu.Lock()
+	entry, ok := s.entries[key]
+	if ok {
+		delete(s.entries, key)
+	}
+	s.mu.Unlock()
+
+	if ok {
+		entry.emit(s.window)
+	}
+}
+
+// Close stops all open windows and emits their pending summaries
+func (s *dedupState) Close() {
+	s.mu.Lock()
+	s.closed = true
+	entries := s.entries
+	s.entries = make(map[string]*dedupEntry)
+	s.mu.Unlock()
+
+	for _, entry := range entries {
+		entry.timer.Stop()
+		entry.emit(s.window)
+	}
+}
+
+// emit writes the latest suppressed record annotated with the repeat count
+func (e *dedupEntry) emit(window time.Duration) {
+	if e.repeated == 0 {
+		return
+	}
+	r := e.last.Clone()
+	r.AddAttrs(
+		slog.Int("repeated", e.repeated),
+		slog.Int("repeat_window_sec", int(window.Seconds())))
+	e.handler.Handle(context.Background(), r)
+}
@@ -1,40 +1,26 @@
 package logging
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
+	"strings"
 	"sync"
 	"time"
 )
 
 // Level represents a log level
-type Level int
+type Level = slog.Level
 
 const (
-	DEBUG Level = iota
-	INFO
-	WARN
-	ERROR
+	DEBUG = slog.LevelDebug
+	INFO  = slog.LevelInfo
+	WARN  = slog.LevelWarn
+	ERROR = slog.LevelError
 )
 
-// String returns the string representation of a log level
-func (l Level) String() string {
-	switch l {
-	case DEBUG:
-		return "debug"
-	case INFO:
-		return "info"
-	case WARN:
-		return "warn"
-	case ERROR:
-		return "error"
-	default:
-		return "unknown"
-	}
-}
-
 // ParseLevel converts a string to a Level
 func ParseLevel(s string) (Level, error) {
 	switch s {
@@ -71,147 +57,228 @@ func ParseFormat(s string) (Format, error) {
 	}
 }
 
-// Logger provides structured logging
+// levels holds the root level and per-component overrides shared by a logger tree
+type levels struct {
+	root       slog.LevelVar
+	mu         sync.RWMutex
+	components map[string]Level
+}
+
+// enabled reports whether a record at level should be emitted for component
+func (lv *levels) enabled(component string, level Level) bool {
+	if component != "" {
+		lv.mu.RLock()
+		override, ok := lv.components[component]
+		lv.mu.RUnlock()
+		if ok {
+			return level >= override
+		}
+	}
+	return level >= lv.root.Level()
+}
+
+// Logger provides structured logging on top of log/slog
 type Logger struct {
-	level  Level
-	format Format
-	output io.Writer
-	mu     sync.Mutex
+	*slog.Logger
+	levels    *levels
+	component string
+	closer    io.Closer
+	dedup     *dedupState
+}
+
+// Options configures a new logger
+type Options struct {
+	Level    string
+	Format   string
+	Output   string // stdout, stderr, syslog, journald, or a file path
+	Facility string // Syslog facility for the syslog and journald outputs
+	Rotation RotationOptions
+
+	// DedupWindow collapses repeated identical warnings and errors within the window; 0 disables
+	DedupWindow time.Duration
 }
 
 // New creates a new logger
-func New(levelStr, formatStr, outputStr string) (*Logger, error) {
-	level, err := ParseLevel(levelStr)
+func New(opts Options) (*Logger, error) {
+	level, err := ParseLevel(opts.Level)
 	if err != nil {
 		level = INFO
 	}
 
-	format, err := ParseFormat(formatStr)
+	format, err := ParseFormat(opts.Format)
 	if err != nil {
 		format = TEXT
 	}
 
-	var output io.Writer
-	switch outputStr {
-	case "stdout", "":
-		output = os.Stdout
-	case "stderr":
-		output = os.Stderr
-	default:
-		// Treat as file path
-		file, err := os.OpenFile(outputStr, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	var base slog.Handler
+	var closer io.Closer
+	switch opts.Output {
+	case "syslog", "journald":
+		facility, err := ParseFacility(opts.Facility)
 		if err != nil {
-			return nil, fmt.Errorf("failed to open log file: %w", err)
+			return nil, err
+		}
+
+		var sink recordSink
+		if opts.Output == "syslog" {
+			sink, err = newSyslogSink(facility)
+		} else {
+			sink, err = newJournaldSink(facility)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		handler := newSinkHandler(format, sink)
+		base = handler
+		closer = handler
+	default:
+		var output io.Writer
+		switch opts.Output {
+		case "stdout", "":
+			output = os.Stdout
+		case "stderr":
+			output = os.Stderr
+		default:
+			// Treat as file path
+			file, err := OpenRotatingFile(opts.Output, opts.Rotation)
+			if err != nil {
+				return nil, err
+			}
+			output = file
+			closer = file
+		}
+
+		handlerOpts := &slog.HandlerOptions{
+			// Filtering happens in levelHandler so component overrides can go below the root level
+			Level:       slog.LevelDebug,
+			ReplaceAttr: replaceAttr,
+		}
+
+		if format == JSON {
+			base = slog.NewJSONHandler(output, handlerOpts)
+		} else {
+			base = slog.NewTextHandler(output, handlerOpts)
 		}
-		output = file
 	}
 
+	var dedup *dedupState
+	if opts.DedupWindow > 0 {
+		handler := newDedupHandler(base, opts.DedupWindow)
+		base = handler
+		dedup = handler.state
+	}
+
+	lv := &levels{components: make(map[string]Level)}
+	lv.root.Set(level)
+
 	return &Logger{
-		level:  level,
-		format: format,
-		output: output,
+		Logger: slog.New(&levelHandler{inner: base, levels: lv}),
+		levels: lv,
+		closer: closer,
+		dedup:  dedup,
 	}, nil
 }
 
-// Debug logs a debug message
-func (l *Logger) Debug(msg string, fields ...interface{}) {
-	if l.level <= DEBUG {
-		l.log(DEBUG, msg, fields...)
+// replaceAttr keeps the established output shape: UTC timestamps and lowercase levels
+func replaceAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) > 0 {
+		return a
 	}
-}
-
-// Info logs an info message
-func (l *Logger) Info(msg string, fields ...interface{}) {
-	if l.level <= INFO {
-		l.log(INFO, msg, fields...)
+	switch a.Key {
+	case slog.TimeKey:
+		if t, ok := a.Value.Any().(time.Time); ok {
+			return slog.String(slog.TimeKey, t.UTC().Format(time.RFC3339Nano))
+		}
+	case slog.LevelKey:
+		if l, ok := a.Value.Any().(slog.Level); ok {
+			return slog.String(slog.LevelKey, strings.ToLower(l.String()))
+		}
 	}
+	return a
 }
 
-// Warn logs a warning message
-func (l *Logger) Warn(msg string, fields ...interface{}) {
-	if l.level <= WARN {
-		l.log(WARN, msg, fields...)
+// Component returns a child logger tagged with a component attribute whose
+// level can be overridden independently via SetComponentLevels
+func (l *Logger) Component(name string) *Logger {
+	return &Logger{
+		Logger:    slog.New(&levelHandler{inner: l.Handler().(*levelHandler).inner, levels: l.levels, component: name}).With("component", name),
+		levels:    l.levels,
+		component: name,
 	}
 }
 
-// Error logs an error message
-func (l *Logger) Error(msg string, fields ...interface{}) {
-	if l.level <= ERROR {
-		l.log(ERROR, msg, fields...)
+// With returns a child logger that adds the given attributes to every record
+func (l *Logger) With(args ...any) *Logger {
+	return &Logger{
+		Logger:    l.Logger.With(args...),
+		levels:    l.levels,
+		component: l.component,
 	}
 }
 
-// log performs the actual logging
-func (l *Logger) log(level Level, msg string, fields ...interface{}) {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-
-	timestamp := time.Now().UTC()
-
-	if l.format == JSON {
-		l.logJSON(timestamp, level, msg, fields...)
-	} else {
-		l.logText(timestamp, level, msg, fields...)
-	}
+// SetLevel changes the root log level
+func (l *Logger) SetLevel(level Level) {
+	l.levels.root.Set(level)
 }
 
-// logJSON outputs in JSON format
-func (l *Logger) logJSON(timestamp time.Time, level Level, msg string, fields ...interface{}) {
-	entry := map[string]interface{}{
-		"time":  timestamp.Format(time.RFC3339Nano),
-		"level": level.String(),
-		"msg":   msg,
-	}
+// Level returns the current root log level
+func (l *Logger) Level() Level {
+	return l.levels.root.Level()
+}
 
-	// Add fields as key-value pairs
-	for i := 0; i < len(fields); i += 2 {
-		if i+1 < len(fields) {
-			key := fmt.Sprint(fields[i])
-			entry[key] = fields[i+1]
+// SetComponentLevels replaces all per-component level overrides.
+// Components without an override follow the root level.
+func (l *Logger) SetComponentLevels(overrides map[string]string) error {
+	parsed := make(map[string]Level, len(overrides))
+	for component, levelStr := range overrides {
+		level, err := ParseLevel(levelStr)
+		if err != nil {
+			return fmt.Errorf("component %s: %w", component, err)
 		}
+		parsed[component] = level
 	}
 
-	jsonData, err := json.Marshal(entry)
-	if err != nil {
-		// Fallback to simple output if JSON marshaling fails
-		fmt.Fprintf(l.output, `{"time":"%s","level":"%s","msg":"json marshal error: %v"}`+"\n",
-			timestamp.Format(time.RFC3339Nano), level.String(), err)
-		return
+	l.levels.mu.Lock()
+	l.levels.components = parsed
+	l.levels.mu.Unlock()
+	return nil
+}
+
+// Close flushes pending repeat summaries and closes the underlying writer if it's a file
+func (l *Logger) Close() error {
+	if l.dedup != nil {
+		l.dedup.Close()
 	}
+	if l.closer != nil {
+		return l.closer.Close()
+	}
+	return nil
+}
 
-	fmt.Fprintln(l.output, string(jsonData))
+// levelHandler applies root and per-component level filtering in front of a slog handler
+type levelHandler struct {
+	inner     slog.Handler
+	levels    *levels
+	component string
 }
 
-// logText outputs in human-readable text format
-func (l *Logger) logText(timestamp time.Time, level Level, msg string, fields ...interface{}) {
-	fmt.Fprintf(l.output, "%s %s %s",
-		timestamp.Format(time.RFC3339Nano),
-		level.String(),
-		msg)
+func (h *levelHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.levels.enabled(h.component, level)
+}
 
-	// Add fields as key=value pairs
-	for i := 0; i < len(fields); i += 2 {
-		if i+1 < len(fields) {
-			key := fmt.Sprint(fields[i])
-			value := fmt.Sprint(fields[i+1])
-			fmt.Fprintf(l.output, " %s=%s", key, value)
-		}
+func (h *levelHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs := contextAttrs(ctx); len(attrs) > 0 {
+		r = r.Clone()
+		r.AddAttrs(attrs...)
 	}
-
-	fmt.Fprintln(l.output)
+	return h.inner.Handle(ctx, r)
 }
 
-// SetLevel changes the log level
-func (l *Logger) SetLevel(level Level) {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	l.level = level
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &levelHandler{inner: h.inner.WithAttrs(attrs), levels: h.levels, component: h.component}
 }
 
-// Close closes the underlying writer if it's a file
-func (l *Logger) Close() error {
-	if closer, ok := l.output.(io.Closer); ok {
-		return closer.Close()
-	}
-	return nil
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{inner: h.inner.WithGroup(name), levels: h.levels, component: h.component}
 }
@@ -0,0 +1,204 @@
+package logging
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat is appended to rotated file names
+const backupTimeFormat = "20060102T150405.000"
+
+// RotationOptions controls size/age based rotation of a log file
+type RotationOptions struct {
+	MaxSizeMB  int  // Rotate when the file would exceed this size; 0 disables rotation
+	MaxBackups int  // Rotated files to keep; 0 keeps all
+	MaxAgeDays int  // Delete rotated files older than this; 0 keeps all
+	Compress   bool // Gzip rotated files
+}
+
+// RotatingFile is an io.WriteCloser that rotates the underlying file by size
+// and prunes old backups by count and age
+type RotatingFile struct {
+	path string
+	opts RotationOptions
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// OpenRotatingFile opens (or creates) path for appending with rotation
+func OpenRotatingFile(path string, opts RotationOptions) (*RotatingFile, error) {
+	r := &RotatingFile{
+		path: path,
+		opts: opts,
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Write appends p, rotating first if it would push the file past the size limit
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	maxSize := int64(r.opts.MaxSizeMB) * 1024 * 1024
+	if maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > maxSize {
+		if err := r.rotate(); err != nil {
+			// Keep logging to the current file rather than dropping lines
+			fmt.Fprintf(os.Stderr, "log rotation failed: %v\n", err)
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Sync commits the current file to stable storage
+func (r *RotatingFile) Sync() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.file.Sync()
+}
+
+// Close closes the current file
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.file.Close()
+}
+
+// open opens the log file for appending and records its current size
+func (r *RotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+	r.file = file
+	r.size = info.Size()
+	return nil
+}
+
+// rotate renames the current file to a timestamped backup and starts a new one
+func (r *RotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+
+	backup := r.path + "." + time.Now().UTC().Format(backupTimeFormat)
+	if err := os.Rename(r.path, backup); err != nil {
+		// Reopen so logging continues even if the rename failed
+		if openErr := r.open(); openErr != nil {
+			return openErr
+		}
+		return fmt.Errorf("failed to rename log file: %w", err)
+	}
+
+	if err := r.open(); err != nil {
+		return err
+	}
+
+	// Compression and pruning happen off the logging path
+	go r.postRotate(backup)
+	return nil
+}
+
+// postRotate compresses the new backup and removes backups beyond the retention limits
+func (r *RotatingFile) postRotate(backup string) {
+	if r.opts.Compress {
+		if err := CompressFile(backup); err != nil {
+			fmt.Fprintf(os.Stderr, "log compression failed: %v\n", err)
+		}
+	}
+	r.prune()
+}
+
+// prune deletes backups exceeding MaxBackups or older than MaxAgeDays
+func (r *RotatingFile) prune() {
+	if r.opts.MaxBackups <= 0 && r.opts.MaxAgeDays <= 0 {
+		return
+	}
+
+	matches, err := filepath.Glob(r.path + ".*")
+	if err != nil {
+		return
+	}
+
+	type backupFile struct {
+		path    string
+		modTime time.Time
+	}
+	var backups []backupFile
+	for _, match := range matches {
+		// Skip in-progress compression output
+		if strings.HasSuffix(match, ".gz.tmp") {
+			continue
+		}
+		if info, err := os.Stat(match); err == nil && !info.IsDir() {
+			backups = append(backups, backupFile{path: match, modTime: info.ModTime()})
+		}
+	}
+
+	// Newest first
+	sort.Slice(backups, func(i, j int) bool { return backups[i].modTime.After(backups[j].modTime) })
+
+	cutoff := time.Now().Add(-time.Duration(r.opts.MaxAgeDays) * 24 * time.Hour)
+	for i, backup := range backups {
+		tooMany := r.opts.MaxBackups > 0 && i >= r.opts.MaxBackups
+		tooOld := r.opts.MaxAgeDays > 0 && backup.modTime.Before(cutoff)
+		if tooMany || tooOld {
+			os.Remove(backup.path)
+		}
+	}
+}
+
+// CompressFile gzips path to path.gz and removes the original
+func CompressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := path + ".gz.tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, path+".gz"); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
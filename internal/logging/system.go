@@ -0,0 +1,254 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"log/syslog"
+	"net"
+	"strings"
+	"sync"
+)
+
+const (
+	// Identifier tags the forwarder's own logs in syslog/journald
+	Identifier = "cato-logger"
+
+	journalSocket = "/run/systemd/journal/socket"
+)
+
+// facilities maps config names to syslog facilities
+var facilities = map[string]syslog.Priority{
+	"daemon": syslog.LOG_DAEMON,
+	"user":   syslog.LOG_USER,
+	"local0": syslog.LOG_LOCAL0,
+	"local1": syslog.LOG_LOCAL1,
+	"local2": syslog.LOG_LOCAL2,
+	"local3": syslog.LOG_LOCAL3,
+	"local4": syslog.LOG_LOCAL4,
+	"local5": syslog.LOG_LOCAL5,
+	"local6": syslog.LOG_LOCAL6,
+	"local7": syslog.LOG_LOCAL7,
+}
+
+// ParseFacility converts a facility name to a syslog facility, defaulting to daemon
+func ParseFacility(s string) (syslog.Priority, error) {
+	if s == "" {
+		return syslog.LOG_DAEMON, nil
+	}
+	if facility, ok := facilities[s]; ok {
+		return facility, nil
+	}
+	return syslog.LOG_DAEMON, fmt.Errorf("invalid syslog facility: %s", s)
+}
+
+// severity maps a log level to a syslog severity
+func severity(level slog.Level) syslog.Priority {
+	switch {
+	case level >= ERROR:
+		return syslog.LOG_ERR
+	case level >= WARN:
+		return syslog.LOG_WARNING
+	case level >= INFO:
+		return syslog.LOG_INFO
+	default:
+		return syslog.LOG_DEBUG
+	}
+}
+
+// recordSink delivers one rendered log line with its level and attributes
+type recordSink interface {
+	emit(level slog.Level, line string, attrs []slog.Attr) error
+	Close() error
+}
+
+// sinkHandler renders each record with a text/JSON handler and passes the
+// rendered line, level, and attributes to a system logging sink
+type sinkHandler struct {
+	mu     *sync.Mutex
+	buf    *bytes.Buffer
+	inner  slog.Handler
+	attrs  []slog.Attr
+	prefix string
+	sink   recordSink
+}
+
+// newSinkHandler builds a handler for sink using the configured format
+func newSinkHandler(format Format, sink recordSink) *sinkHandler {
+	buf := &bytes.Buffer{}
+	opts := &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		// The host logging system records its own timestamp and priority
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && (a.Key == slog.TimeKey || a.Key == slog.LevelKey) {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}
+
+	var inner slog.Handler
+	if format == JSON {
+		inner = slog.NewJSONHandler(buf, opts)
+	} else {
+		inner = slog.NewTextHandler(buf, opts)
+	}
+
+	return &sinkHandler{
+		mu:    &sync.Mutex{},
+		buf:   buf,
+		inner: inner,
+		sink:  sink,
+	}
+}
+
+func (h *sinkHandler) Enabled(_ context.Context, _ slog.Level) bool {
+	return true
+}
+
+func (h *sinkHandler) Handle(ctx context.Context, r slog.Record) error {
+	attrs := append([]slog.Attr(nil), h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, prefixed(h.prefix, a))
+		return true
+	})
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.buf.Reset()
+	if err := h.inner.Handle(ctx, r); err != nil {
+		return err
+	}
+	return h.sink.emit(r.Level, strings.TrimRight(h.buf.String(), "\n"), attrs)
+}
+
+func (h *sinkHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.inner = h.inner.WithAttrs(attrs)
+	clone.attrs = append([]slog.Attr(nil), h.attrs...)
+	for _, a := range attrs {
+		clone.attrs = append(clone.attrs, prefixed(h.prefix, a))
+	}
+	return &clone
+}
+
+func (h *sinkHandler) WithGroup(name string) slog.Handler {
+	clone := *h
+	clone.inner = h.inner.WithGroup(name)
+	clone.prefix = h.prefix + name + "."
+	return &clone
+}
+
+// Close closes the underlying sink
+func (h *sinkHandler) Close() error {
+	return h.sink.Close()
+}
+
+// prefixed qualifies an attribute key with its group prefix
+func prefixed(prefix string, a slog.Attr) slog.Attr {
+	if prefix == "" {
+		return a
+	}
+	return slog.Attr{Key: prefix + a.Key, Value: a.Value}
+}
+
+// syslogSink writes to the local syslog daemon
+type syslogSink struct {
+	writer *syslog.Writer
+}
+
+// newSyslogSink connects to the local syslog daemon with the given facility
+func newSyslogSink(facility syslog.Priority) (*syslogSink, error) {
+	writer, err := syslog.New(facility|syslog.LOG_INFO, Identifier)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to local syslog: %w", err)
+	}
+	return &syslogSink{writer: writer}, nil
+}
+
+func (s *syslogSink) emit(level slog.Level, line string, _ []slog.Attr) error {
+	switch severity(level) {
+	case syslog.LOG_ERR:
+		return s.writer.Err(line)
+	case syslog.LOG_WARNING:
+		return s.writer.Warning(line)
+	case syslog.LOG_INFO:
+		return s.writer.Info(line)
+	default:
+		return s.writer.Debug(line)
+	}
+}
+
+func (s *syslogSink) Close() error {
+	return s.writer.Close()
+}
+
+// journaldSink writes to systemd-journald using its native datagram protocol,
+// so attributes become structured journal fields
+type journaldSink struct {
+	conn     *net.UnixConn
+	facility syslog.Priority
+}
+
+// newJournaldSink connects to the journald socket
+func newJournaldSink(facility syslog.Priority) (*journaldSink, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: journalSocket, Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to journald: %w", err)
+	}
+	return &journaldSink{conn: conn, facility: facility}, nil
+}
+
+func (j *journaldSink) emit(level slog.Level, line string, attrs []slog.Attr) error {
+	var buf bytes.Buffer
+	writeJournalField(&buf, "MESSAGE", line)
+	writeJournalField(&buf, "PRIORITY", fmt.Sprintf("%d", severity(level)))
+	writeJournalField(&buf, "SYSLOG_IDENTIFIER", Identifier)
+	writeJournalField(&buf, "SYSLOG_FACILITY", fmt.Sprintf("%d", j.facility>>3))
+	for _, a := range attrs {
+		writeJournalField(&buf, journalFieldName(a.Key), a.Value.String())
+	}
+
+	_, err := j.conn.Write(buf.Bytes())
+	return err
+}
+
+func (j *journaldSink) Close() error {
+	return j.conn.Close()
+}
+
+// writeJournalField appends one field, using the length-prefixed form for multi-line values
+func writeJournalField(buf *bytes.Buffer, name, value string) {
+	if !strings.Contains(value, "\n") {
+		buf.WriteString(name + "=" + value + "\n")
+		return
+	}
+	buf.WriteString(name + "\n")
+	binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value + "\n")
+}
+
+// journalFieldName converts an attribute key to a valid journal field name:
+// uppercase letters, digits and underscores, not starting with an underscore or digit
+func journalFieldName(key string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(key) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+
+	name := b.String()
+	if name == "" || name[0] == '_' || (name[0] >= '0' && name[0] <= '9') {
+		name = "F_" + name
+	}
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
@@ -0,0 +1,92 @@
+package marker
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+)
+
+// encryptedPrefix starts every encrypted state file, so plaintext files from
+// before encryption was enabled can still be read
+const encryptedPrefix = "cato-logger-aesgcm:v1:"
+
+// Cipher encrypts marker and history files with AES-256-GCM
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher creates a cipher from a base64-encoded 32-byte key
+func NewCipher(key string) (*Cipher, error) {
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("encryption key is not valid base64: %w", err)
+	}
+	if len(raw) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(raw))
+	}
+
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// seal encrypts plaintext under a fresh random nonce
+func (c *Cipher) seal(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := c.aead.Seal(nonce, nonce, plaintext, nil)
+	return []byte(encryptedPrefix + base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+// open decrypts data written by seal
+func (c *Cipher) open(data []byte) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data[len(encryptedPrefix):])))
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return nil, fmt.Errorf("encrypted data is corrupt")
+	}
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decryption failed, wrong encryption key or tampered file")
+	}
+	return plaintext, nil
+}
+
+// decrypt returns the plaintext of a state file, passing plaintext files
+// through unchanged
+func decrypt(path string, data []byte, c *Cipher) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte(encryptedPrefix)) {
+		return data, nil
+	}
+	if c == nil {
+		return nil, fmt.Errorf("%s is encrypted but no state.encryption_key is set", path)
+	}
+	plaintext, err := c.open(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return plaintext, nil
+}
+
+// encrypt seals a state file's content when c is set
+func encrypt(path string, data []byte, c *Cipher) ([]byte, error) {
+	if c == nil {
+		return data, nil
+	}
+	sealed, err := c.seal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt %s: %w", path, err)
+	}
+	return sealed, nil
+}
@@ -0,0 +1,43 @@
+package marker
+
+import (
+	"encoding/base64"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+var (
+	rfc3339Pattern = regexp.MustCompile(`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})`)
+	epochPattern   = regexp.MustCompile(`\b1\d{9}(\d{3})?\b`)
+)
+
+// DecodeTime looks for a timestamp inside a marker. Markers are opaque API
+// cursors, so this is best effort: the marker and its base64 decoding are
+// searched for an RFC 3339 time or a Unix time in seconds or milliseconds.
+func DecodeTime(marker string) (time.Time, bool) {
+	candidates := []string{marker}
+	for _, encoding := range []*base64.Encoding{
+		base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding,
+	} {
+		if decoded, err := encoding.DecodeString(marker); err == nil {
+			candidates = append(candidates, string(decoded))
+		}
+	}
+
+	for _, candidate := range candidates {
+		if match := rfc3339Pattern.FindString(candidate); match != "" {
+			if t, err := time.Parse(time.RFC3339Nano, match); err == nil {
+				return t, true
+			}
+		}
+		if match := epochPattern.FindString(candidate); match != "" {
+			n, _ := strconv.ParseInt(match, 10, 64)
+			if len(match) == 13 {
+				return time.UnixMilli(n).UTC(), true
+			}
+			return time.Unix(n, 0).UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,83 @@
+package marker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"cato-logger/internal/objstore"
+)
+
+// HistoryEntry records one saved marker
+type HistoryEntry struct {
+	Time   time.Time `json:"time"`   // When the marker was saved
+	Marker string    `json:"marker"` // Position after the events below
+	Events int       `json:"events"` // Events in the page that advanced to this marker
+}
+
+// HistoryFile returns the path of the history kept next to a marker file
+func HistoryFile(markerFile string) string {
+	return markerFile + ".history"
+}
+
+// ReadHistory returns the saved markers of a marker file, oldest first. A
+// missing history file has no entries.
+func ReadHistory(markerFile string, c *Cipher) ([]HistoryEntry, error) {
+	file, err := loadState(HistoryFile(markerFile), c)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read marker history: %w", err)
+	}
+
+	var entries []HistoryEntry
+	if err := json.Unmarshal(file.data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse marker history %s: %w", HistoryFile(markerFile), err)
+	}
+	return entries, nil
+}
+
+// writeHistory replaces the history file
+func writeHistory(ctx context.Context, markerFile string, entries []HistoryEntry, c *Cipher) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if _, err := saveState(ctx, HistoryFile(markerFile), data, objstore.Condition{}, c); err != nil {
+		return fmt.Errorf("failed to write marker history: %w", err)
+	}
+	return nil
+}
+
+// FindEntry selects a history entry for a rollback. to is either an index
+// into the history, newest first (0 is the current marker), or an RFC 3339
+// time, which selects the newest marker saved at or before it so everything
+// forwarded after that time is forwarded again.
+func FindEntry(entries []HistoryEntry, to string) (HistoryEntry, error) {
+	if len(entries) == 0 {
+		return HistoryEntry{}, fmt.Errorf("no marker history recorded")
+	}
+
+	if index, err := strconv.Atoi(to); err == nil {
+		if index < 0 || index >= len(entries) {
+			return HistoryEntry{}, fmt.Errorf("index %d is out of range, history has %d entries", index, len(entries))
+		}
+		return entries[len(entries)-1-index], nil
+	}
+
+	at, err := time.Parse(time.RFC3339, to)
+	if err != nil {
+		return HistoryEntry{}, fmt.Errorf("'%s' is neither a history index nor an RFC 3339 time", to)
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if !entries[i].Time.After(at) {
+			return entries[i], nil
+		}
+	}
+	return HistoryEntry{}, fmt.Errorf("no marker saved at or before %s, the oldest is from %s",
+		at.Format(time.RFC3339), entries[0].Time.Format(time.RFC3339))
+}
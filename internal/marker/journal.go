@@ -0,0 +1,78 @@
+package marker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"cato-logger/internal/objstore"
+)
+
+// JournalEntry records how far the page being forwarded got, so a page
+// fetched again from the same marker after a crash or a failed delivery
+// skips the events each output already has
+type JournalEntry struct {
+	Marker  string         `json:"marker"`  // Marker the page was fetched from
+	Events  int            `json:"events"`  // Events in the page
+	Outputs map[string]int `json:"outputs"` // Events of the page each output has taken, by output name
+}
+
+// JournalFile returns the path of the journal kept next to a marker file
+func JournalFile(markerFile string) string {
+	return markerFile + ".journal"
+}
+
+// ReadJournal returns the journal entry of a marker file, nil when there is
+// no journal
+func ReadJournal(markerFile string, c *Cipher) (*JournalEntry, error) {
+	file, err := loadState(JournalFile(markerFile), c)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read marker journal: %w", err)
+	}
+
+	var entry JournalEntry
+	if err := json.Unmarshal(file.data, &entry); err != nil {
+		return nil, fmt.Errorf("failed to parse marker journal %s: %w", JournalFile(markerFile), err)
+	}
+	return &entry, nil
+}
+
+// ClearJournal removes the journal of a marker file, so a marker changed by
+// hand is forwarded to every output in full
+func ClearJournal(markerFile string) error {
+	if err := Remove(JournalFile(markerFile)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove marker journal: %w", err)
+	}
+	return nil
+}
+
+// KeepJournal enables the journal and returns the entry the previous run
+// left, nil when there is none
+func (m *Manager) KeepJournal() (*JournalEntry, error) {
+	entry, err := ReadJournal(m.filePath, m.cipher)
+	if err != nil {
+		return nil, err
+	}
+	m.journal = true
+	return entry, nil
+}
+
+// WriteJournal replaces the journal entry. It does nothing unless the
+// journal is enabled.
+func (m *Manager) WriteJournal(ctx context.Context, entry JournalEntry) error {
+	if !m.journal {
+		return nil
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := saveState(ctx, JournalFile(m.filePath), data, objstore.Condition{}, m.cipher); err != nil {
+		return fmt.Errorf("failed to write marker journal: %w", err)
+	}
+	return nil
+}
@@ -1,25 +1,39 @@
 package marker
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
+	"time"
 
 	"cato-logger/internal/logging"
+	"cato-logger/internal/objstore"
 )
 
 // Manager handles reading and writing event markers
 type Manager struct {
-	filePath string
-	marker   string
-	logger   *logging.Logger
+	filePath    string
+	marker      string
+	version     string         // Object version the next conditional write expects
+	exists      bool           // The marker file exists
+	updated     time.Time      // When the marker last advanced
+	historySize int            // Saved markers kept in the history file, 0 disables it
+	history     []HistoryEntry // Oldest first
+	journal     bool           // Page progress is kept in the journal file
+	cipher      *Cipher        // Encrypts the marker, history and journal files, nil for plaintext
+	logger      *logging.Logger
 }
 
-// New creates a new marker manager
-func New(filePath string, logger *logging.Logger) (*Manager, error) {
+// New creates a new marker manager. With a cipher the marker and history
+// files are written encrypted; existing plaintext files are still read.
+// filePath may be an s3:// or gs:// URL, in which case writes are
+// conditional so two instances cannot both advance the same marker.
+func New(filePath string, c *Cipher, logger *logging.Logger) (*Manager, error) {
 	m := &Manager{
 		filePath: filePath,
+		cipher:   c,
 		logger:   logger,
 	}
 
@@ -30,6 +44,7 @@ func New(filePath string, logger *logging.Logger) (*Manager, error) {
 			return nil, fmt.Errorf("failed to load marker: %w", err)
 		}
 		logger.Info("no existing marker file found, starting fresh", "path", filePath)
+		m.updated = time.Now()
 	} else {
 		logger.Info("loaded marker from file", "path", filePath, "has_marker", m.marker != "")
 	}
@@ -37,46 +52,116 @@ func New(filePath string, logger *logging.Logger) (*Manager, error) {
 	return m, nil
 }
 
+// KeepHistory records the last size saved markers, with their time and
+// event count, in a history file next to the marker file
+func (m *Manager) KeepHistory(size int) error {
+	history, err := ReadHistory(m.filePath, m.cipher)
+	if err != nil {
+		return err
+	}
+	m.historySize = size
+	m.history = history
+	return nil
+}
+
 // Load reads the marker from the file
 func (m *Manager) Load() error {
-	data, err := os.ReadFile(m.filePath)
+	file, err := loadState(m.filePath, m.cipher)
 	if err != nil {
 		return err
 	}
-	m.marker = strings.TrimSpace(string(data))
+	m.marker = strings.TrimSpace(string(file.data))
+	m.version = file.version
+	m.exists = true
+	m.updated = file.modified
 	return nil
 }
 
+// Read returns the marker stored in a marker file. Errors from reading the
+// file are returned unwrapped so os.IsNotExist works.
+func Read(filePath string, c *Cipher) (string, error) {
+	file, err := loadState(filePath, c)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(file.data)), nil
+}
+
 // Save writes the marker to the file
-func (m *Manager) Save(marker string) error {
+func (m *Manager) Save(ctx context.Context, marker string) error {
 	if marker == "" {
 		return nil // Don't save empty markers
 	}
 
-	// Create directory if it doesn't exist
-	dir := filepath.Dir(m.filePath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create directory for marker file: %w", err)
+	cond := objstore.Condition{Version: m.version, Absent: !m.exists}
+	version, err := saveState(ctx, m.filePath, []byte(marker), cond, m.cipher)
+	if errors.Is(err, ErrConflict) {
+		return m.adoptRemote(ctx)
 	}
-
-	if err := os.WriteFile(m.filePath, []byte(marker), 0644); err != nil {
+	if err != nil {
 		return fmt.Errorf("failed to write marker file: %w", err)
 	}
 
 	m.marker = marker
-	m.logger.Debug("saved marker to file", "path", m.filePath)
+	m.version = version
+	m.exists = true
+	m.updated = time.Now()
+	m.logger.DebugContext(ctx, "saved marker to file", "path", m.filePath)
 	return nil
 }
 
+// adoptRemote reloads a marker that another instance changed, so this one
+// continues from there instead of overwriting it
+func (m *Manager) adoptRemote(ctx context.Context) error {
+	err := m.Load()
+	if os.IsNotExist(err) {
+		m.marker, m.version, m.exists = "", "", false
+		err = nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w, and reloading it failed: %v", ErrConflict, err)
+	}
+	m.logger.WarnContext(ctx, "marker was changed by another instance, continuing from its marker",
+		"path", m.filePath, "has_marker", m.marker != "")
+	return ErrConflict
+}
+
+// record appends a saved marker to the history. History is informational,
+// so a failure is logged rather than failing the marker update.
+func (m *Manager) record(ctx context.Context, marker string, events int) {
+	if m.historySize <= 0 {
+		return
+	}
+
+	m.history = append(m.history, HistoryEntry{Time: time.Now().UTC(), Marker: marker, Events: events})
+	if len(m.history) > m.historySize {
+		m.history = append([]HistoryEntry(nil), m.history[len(m.history)-m.historySize:]...)
+	}
+	if err := writeHistory(ctx, m.filePath, m.history, m.cipher); err != nil {
+		m.logger.WarnContext(ctx, "failed to save marker history", "error", err.Error())
+	}
+}
+
 // Get returns the current marker
 func (m *Manager) Get() string {
 	return m.marker
 }
 
-// Update updates the marker and saves it
-func (m *Manager) Update(marker string) error {
+// LastUpdate returns when the marker last advanced, starting from the
+// marker file's modification time
+func (m *Manager) LastUpdate() time.Time {
+	return m.updated
+}
+
+// Update updates the marker and saves it. events is the size of the page
+// that advanced the marker, kept in the history.
+func (m *Manager) Update(ctx context.Context, marker string, events int) error {
 	if marker == "" || marker == m.marker {
 		return nil
 	}
-	return m.Save(marker)
+	if err := m.Save(ctx, marker); err != nil {
+		return err
+	}
+	m.record(ctx, marker, events)
+	return nil
 }
@@ -0,0 +1,160 @@
+package marker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"cato-logger/internal/objstore"
+)
+
+// ErrConflict is returned when another instance changed the marker since it
+// was read. The manager then continues from that instance's marker.
+var ErrConflict = errors.New("marker was changed by another instance")
+
+// stateFile is a marker or history file's content and the version that
+// conditional object store writes check against
+type stateFile struct {
+	data     []byte
+	version  string // Object version, empty for local files
+	modified time.Time
+}
+
+// IsRemote reports whether a marker file is kept in an object store
+// (s3://bucket/key or gs://bucket/key) rather than on local disk
+func IsRemote(path string) bool {
+	return objstore.IsURL(path)
+}
+
+// loadState reads a state file from disk or an object store, decrypting it
+// when it is encrypted. A missing file returns an error for which
+// os.IsNotExist is true.
+func loadState(path string, c *Cipher) (stateFile, error) {
+	var file stateFile
+	if objstore.IsURL(path) {
+		store, key, err := objstore.Open(path)
+		if err != nil {
+			return file, err
+		}
+		obj, err := store.Get(context.Background(), key)
+		if errors.Is(err, objstore.ErrNotFound) {
+			return file, &fs.PathError{Op: "read", Path: path, Err: fs.ErrNotExist}
+		}
+		if err != nil {
+			return file, fmt.Errorf("%s: %w", path, err)
+		}
+		file = stateFile{data: obj.Data, version: obj.Version, modified: obj.Modified}
+	} else {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return file, err
+		}
+		file = stateFile{data: data, modified: time.Now()}
+		if info, err := os.Stat(path); err == nil {
+			file.modified = info.ModTime()
+		}
+	}
+
+	data, err := decrypt(path, file.data, c)
+	if err != nil {
+		return file, err
+	}
+	file.data = data
+	return file, nil
+}
+
+// saveState writes a state file, encrypting it when c is set, and returns
+// its new version. Object store writes only succeed while the object still
+// matches cond; local files are replaced through a rename so a crash never
+// leaves them half written.
+func saveState(ctx context.Context, path string, data []byte, cond objstore.Condition, c *Cipher) (string, error) {
+	data, err := encrypt(path, data, c)
+	if err != nil {
+		return "", err
+	}
+
+	if objstore.IsURL(path) {
+		store, key, err := objstore.Open(path)
+		if err != nil {
+			return "", err
+		}
+		version, err := store.Put(ctx, key, data, cond)
+		if errors.Is(err, objstore.ErrConflict) {
+			return "", ErrConflict
+		}
+		return version, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return "", nil
+}
+
+// Modified returns when a marker file was last written. A missing file
+// returns an error for which os.IsNotExist is true.
+func Modified(path string) (time.Time, error) {
+	if !objstore.IsURL(path) {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return info.ModTime(), nil
+	}
+
+	store, key, err := objstore.Open(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	obj, err := store.Get(context.Background(), key)
+	if errors.Is(err, objstore.ErrNotFound) {
+		return time.Time{}, &fs.PathError{Op: "stat", Path: path, Err: fs.ErrNotExist}
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return obj.Modified, nil
+}
+
+// Remove deletes a marker file. A missing file returns an error for which
+// os.IsNotExist is true.
+func Remove(path string) error {
+	if !objstore.IsURL(path) {
+		return os.Remove(path)
+	}
+
+	store, key, err := objstore.Open(path)
+	if err != nil {
+		return err
+	}
+	err = store.Delete(context.Background(), key)
+	if errors.Is(err, objstore.ErrNotFound) {
+		return &fs.PathError{Op: "remove", Path: path, Err: fs.ErrNotExist}
+	}
+	return err
+}
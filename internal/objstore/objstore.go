@@ -0,0 +1,160 @@
+package objstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// requestTimeout bounds every object store request
+const requestTimeout = 30 * time.Second
+
+var (
+	// ErrNotFound is returned by Get and Delete when the object does not exist
+	ErrNotFound = errors.New("object does not exist")
+
+	// ErrConflict is returned by Put when the object changed since it was read
+	ErrConflict = errors.New("object was changed by another writer")
+)
+
+// Object is an object's content and the version a conditional write checks
+type Object struct {
+	Data     []byte
+	Version  string // ETag (S3) or generation (GCS)
+	Modified time.Time
+}
+
+// Condition restricts a Put to a known state of the object. The zero value
+// writes unconditionally.
+type Condition struct {
+	Version string // Only replace this version
+	Absent  bool   // Only create the object
+}
+
+// Store reads and writes objects in one bucket
+type Store interface {
+	Get(ctx context.Context, key string) (Object, error)
+	Put(ctx context.Context, key string, data []byte, cond Condition) (version string, err error)
+	Delete(ctx context.Context, key string) error
+}
+
+// IsURL reports whether a state path names an object store location
+// (s3://bucket/key or gs://bucket/key) rather than a local file
+func IsURL(path string) bool {
+	return strings.HasPrefix(path, "s3://") || strings.HasPrefix(path, "gs://")
+}
+
+// Split returns the scheme, bucket, and key of an object store URL
+func Split(location string) (scheme, bucket, key string, err error) {
+	scheme, rest, ok := strings.Cut(location, "://")
+	if !ok || (scheme != "s3" && scheme != "gs") {
+		return "", "", "", fmt.Errorf("'%s' is not an s3:// or gs:// URL", location)
+	}
+	bucket, key, _ = strings.Cut(rest, "/")
+	if bucket == "" || key == "" || strings.HasSuffix(key, "/") {
+		return "", "", "", fmt.Errorf("'%s' must name a bucket and an object key", location)
+	}
+	return scheme, bucket, key, nil
+}
+
+var (
+	storesMu sync.Mutex
+	stores   = make(map[string]Store)
+)
+
+// Open returns the store and key for an object store URL. Stores are shared
+// per bucket so cached credentials are reused.
+func Open(location string) (Store, string, error) {
+	scheme, bucket, key, err := Split(location)
+	if err != nil {
+		return nil, "", err
+	}
+
+	storesMu.Lock()
+	defer storesMu.Unlock()
+
+	id := scheme + "://" + bucket
+	if store, ok := stores[id]; ok {
+		return store, key, nil
+	}
+
+	client := &http.Client{Timeout: requestTimeout}
+	var store Store
+	switch scheme {
+	case "s3":
+		store, err = newS3Store(bucket, client)
+	case "gs":
+		store, err = newGCSStore(bucket, client)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	stores[id] = store
+	return store, key, nil
+}
+
+// escapeKey percent-encodes an object key for a URL path, keeping slashes
+func escapeKey(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// endpointOverride returns an endpoint from the environment, adding a
+// scheme when it only names a host
+func endpointOverride(names ...string) string {
+	for _, name := range names {
+		if endpoint := os.Getenv(name); endpoint != "" {
+			if !strings.Contains(endpoint, "://") {
+				endpoint = "http://" + endpoint
+			}
+			return strings.TrimSuffix(endpoint, "/")
+		}
+	}
+	return ""
+}
+
+// do executes a request and returns the response with its body read
+func do(client *http.Client, req *http.Request) (*http.Response, []byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, body, nil
+}
+
+// statusError describes an unexpected response, with the start of its body
+func statusError(service, op string, resp *http.Response, body []byte) error {
+	if len(body) > 256 {
+		body = body[:256]
+	}
+	return fmt.Errorf("%s %s returned status %d: %s", service, op, resp.StatusCode, strings.TrimSpace(string(body)))
+}
+
+// modified parses the Last-Modified header, falling back to now
+func modified(resp *http.Response) time.Time {
+	if t, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		return t
+	}
+	return time.Now()
+}
@@ -0,0 +1,143 @@
+package objstore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"cato-logger/internal/awsauth"
+)
+
+// s3Store keeps objects in an S3 bucket. Conditional writes use If-Match
+// and If-None-Match, which S3 enforces for PutObject.
+type s3Store struct {
+	bucket   string
+	region   string
+	endpoint string // Path-style endpoint from AWS_ENDPOINT_URL_S3, empty for AWS
+	client   *http.Client
+	creds    *awsauth.Provider
+}
+
+func newS3Store(bucket string, client *http.Client) (*s3Store, error) {
+	region := awsauth.Region()
+	if region == "" {
+		return nil, fmt.Errorf("AWS region unknown: set AWS_REGION for s3:// state files")
+	}
+	return &s3Store{
+		bucket:   bucket,
+		region:   region,
+		endpoint: endpointOverride("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"),
+		client:   client,
+		creds:    awsauth.NewProvider(client, nil, "", region),
+	}, nil
+}
+
+// NewS3Store returns a store for an S3 bucket in region with credentials from
+// creds. endpoint selects a path-style endpoint such as a VPC endpoint; when
+// empty, AWS_ENDPOINT_URL_S3 or the bucket's AWS endpoint is used.
+func NewS3Store(bucket, region, endpoint string, creds *awsauth.Provider) Store {
+	if endpoint == "" {
+		endpoint = endpointOverride("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL")
+	}
+	return &s3Store{
+		bucket:   bucket,
+		region:   region,
+		endpoint: strings.TrimRight(endpoint, "/"),
+		client:   &http.Client{Timeout: requestTimeout},
+		creds:    creds,
+	}
+}
+
+// url returns the object URL, virtual-hosted on AWS and path-style otherwise
+func (s *s3Store) url(key string) string {
+	if s.endpoint != "" {
+		return fmt.Sprintf("%s/%s/%s", s.endpoint, s.bucket, escapeKey(key))
+	}
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.bucket, s.region, escapeKey(key))
+}
+
+// send signs and executes a request
+func (s *s3Store) send(ctx context.Context, method, key string, body []byte, header http.Header) (*http.Response, []byte, error) {
+	creds, err := s.creds.Credentials(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, s.url(key), bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	awsauth.SignRequest(req, body, creds, s.region, "s3", time.Now())
+
+	resp, respBody, err := do(s.client, req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("s3 request failed: %w", err)
+	}
+	return resp, respBody, nil
+}
+
+func (s *s3Store) Get(ctx context.Context, key string) (Object, error) {
+	resp, body, err := s.send(ctx, "GET", key, nil, nil)
+	if err != nil {
+		return Object{}, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return Object{Data: body, Version: resp.Header.Get("ETag"), Modified: modified(resp)}, nil
+	case http.StatusNotFound:
+		return Object{}, ErrNotFound
+	default:
+		return Object{}, statusError("s3", "GetObject", resp, body)
+	}
+}
+
+func (s *s3Store) Put(ctx context.Context, key string, data []byte, cond Condition) (string, error) {
+	header := http.Header{}
+	switch {
+	case cond.Absent:
+		header.Set("If-None-Match", "*")
+	case cond.Version != "":
+		header.Set("If-Match", cond.Version)
+	}
+
+	resp, body, err := s.send(ctx, "PUT", key, data, header)
+	if err != nil {
+		return "", err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Header.Get("ETag"), nil
+	case http.StatusPreconditionFailed, http.StatusConflict:
+		// 409 is returned when a concurrent conditional write won the race
+		return "", ErrConflict
+	case http.StatusNotFound:
+		// If-Match against an object that was deleted
+		if cond.Version != "" {
+			return "", ErrConflict
+		}
+		return "", statusError("s3", "PutObject", resp, body)
+	default:
+		return "", statusError("s3", "PutObject", resp, body)
+	}
+}
+
+func (s *s3Store) Delete(ctx context.Context, key string) error {
+	resp, body, err := s.send(ctx, "DELETE", key, nil, nil)
+	if err != nil {
+		return err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent:
+		return nil
+	case http.StatusNotFound:
+		return ErrNotFound
+	default:
+		return statusError("s3", "DeleteObject", resp, body)
+	}
+}
@@ -0,0 +1,151 @@
+package ocsf
+
+import "strings"
+
+// Version is the OCSF schema version events are produced for
+const Version = "1.3.0"
+
+// Class is an OCSF event class and the category it belongs to
+type Class struct {
+	UID          int
+	Name         string
+	CategoryUID  int
+	CategoryName string
+	activities   map[int]string // Activity names by activity_id
+}
+
+// Supported class UIDs
+const (
+	BaseEvent        = 0
+	DetectionFinding = 2004
+	EntityManagement = 3004
+	NetworkActivity  = 4001
+	HTTPActivity     = 4002
+	DNSActivity      = 4003
+)
+
+// Classes lists the classes events can be mapped to, by UID
+var Classes = map[int]Class{
+	BaseEvent: {BaseEvent, "Base Event", 0, "Uncategorized",
+		map[int]string{0: "Unknown"}},
+	DetectionFinding: {DetectionFinding, "Detection Finding", 2, "Findings",
+		map[int]string{1: "Create", 2: "Update", 3: "Close"}},
+	EntityManagement: {EntityManagement, "Entity Management", 3, "Identity & Access Management",
+		map[int]string{1: "Create", 2: "Read", 3: "Update", 4: "Delete"}},
+	NetworkActivity: {NetworkActivity, "Network Activity", 4, "Network Activity",
+		map[int]string{1: "Open", 2: "Close", 3: "Reset", 4: "Fail", 5: "Refuse", 6: "Traffic"}},
+	HTTPActivity: {HTTPActivity, "HTTP Activity", 4, "Network Activity",
+		map[int]string{1: "Connect", 2: "Delete", 3: "Get", 4: "Head", 5: "Options", 6: "Post", 7: "Put", 8: "Trace"}},
+	DNSActivity: {DNSActivity, "DNS Activity", 4, "Network Activity",
+		map[int]string{1: "Query", 2: "Response", 6: "Traffic"}},
+}
+
+// DefaultClasses maps Cato event types, or "event type/sub type" pairs, to
+// class UIDs. A pair takes precedence over its event type; events matching
+// neither become Base Events. Audit trail records have the event type
+// Audit.
+var DefaultClasses = map[string]int{
+	"Connectivity":                 NetworkActivity,
+	"Routing":                      NetworkActivity,
+	"Security":                     NetworkActivity,
+	"Security/Internet Firewall":   NetworkActivity,
+	"Security/WAN Firewall":        NetworkActivity,
+	"Security/LAN Firewall":        NetworkActivity,
+	"Security/TLS Inspection":      NetworkActivity,
+	"Security/URL Filtering":       HTTPActivity,
+	"Security/CASB":                HTTPActivity,
+	"Security/DNS Protection":      DNSActivity,
+	"Security/IPS":                 DetectionFinding,
+	"Security/Anti Malware":        DetectionFinding,
+	"Security/NG Anti Malware":     DetectionFinding,
+	"Security/DLP":                 DetectionFinding,
+	"Security/Suspicious Activity": DetectionFinding,
+	"Detection and Response":       DetectionFinding,
+	"Lateral Movement":             DetectionFinding,
+	"Audit":                        EntityManagement,
+}
+
+// activityName returns the name of a class activity
+func (c Class) activityName(id int) string {
+	if id == 99 {
+		return "Other"
+	}
+	if name, ok := c.activities[id]; ok {
+		return name
+	}
+	return "Unknown"
+}
+
+// activityID picks the activity of an event in a class
+func activityID(class int, fields map[string]string, actionID int) int {
+	switch class {
+	case NetworkActivity:
+		subType := strings.ToLower(fields["event_sub_type"])
+		switch {
+		case strings.Contains(subType, "disconnect"):
+			return 2
+		case strings.Contains(subType, "connect"):
+			return 1
+		case actionID == 2:
+			return 5
+		}
+		return 6
+	case HTTPActivity:
+		methods := map[string]int{
+			"CONNECT": 1, "DELETE": 2, "GET": 3, "HEAD": 4,
+			"OPTIONS": 5, "POST": 6, "PUT": 7, "TRACE": 8,
+		}
+		method := strings.ToUpper(fields["http_request_method"])
+		if id, ok := methods[method]; ok {
+			return id
+		}
+		if method != "" {
+			return 99
+		}
+		return 0
+	case DNSActivity:
+		return 6
+	case DetectionFinding:
+		return 1
+	case EntityManagement:
+		switch strings.ToUpper(fields["change_type"]) {
+		case "CREATE", "CREATED", "ADD", "ADDED":
+			return 1
+		case "MODIFY", "MODIFIED", "UPDATE", "UPDATED":
+			return 3
+		case "DELETE", "DELETED", "REMOVE", "REMOVED":
+			return 4
+		case "":
+			return 0
+		}
+		return 99
+	}
+	return 0
+}
+
+// severityIDs maps Cato severity names to OCSF severity_id values
+var severityIDs = map[string]int{
+	"info":          1,
+	"informational": 1,
+	"low":           2,
+	"medium":        3,
+	"high":          4,
+	"critical":      5,
+}
+
+// severityNames are the OCSF severity names by severity_id
+var severityNames = []string{"Unknown", "Informational", "Low", "Medium", "High", "Critical"}
+
+// actionID maps a Cato action to an OCSF action_id: 1 Allowed, 2 Denied,
+// 99 Other, or 0 when the event has no action
+func actionID(action string) int {
+	switch strings.ToLower(action) {
+	case "":
+		return 0
+	case "allow", "allowed", "permit", "monitor", "bypass":
+		return 1
+	case "block", "blocked", "deny", "denied", "reject", "drop":
+		return 2
+	}
+	return 99
+}
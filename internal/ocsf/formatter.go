@@ -0,0 +1,294 @@
+package ocsf
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// attribute maps a Cato field to the path of an OCSF attribute. Where
+// several fields map to one path, the first present field wins and the
+// others stay unmapped.
+type attribute struct {
+	field string
+	path  string
+}
+
+// endpointAttributes describe the connection of network events and the
+// evidence of findings
+var endpointAttributes = []attribute{
+	{"src_ip", "src_endpoint.ip"},
+	{"src_port", "src_endpoint.port"},
+	{"device_name", "src_endpoint.hostname"},
+	{"host_mac", "src_endpoint.mac"},
+	{"src_site_name", "src_endpoint.name"},
+	{"src_country_code", "src_endpoint.location.country"},
+	{"dest_ip", "dst_endpoint.ip"},
+	{"dest_port", "dst_endpoint.port"},
+	{"dest_site_name", "dst_endpoint.name"},
+	{"dest_country_code", "dst_endpoint.location.country"},
+	{"protocol", "connection_info.protocol_name"},
+	{"bytes_in", "traffic.bytes_in"},
+	{"bytes_out", "traffic.bytes_out"},
+	{"vpn_user_email", "actor.user.email_addr"},
+	{"user_name", "actor.user.name"},
+	{"ad_name", "actor.user.name"},
+	{"application", "app_name"},
+	{"application_name", "app_name"},
+	{"rule_name", "firewall_rule.name"},
+	{"network_rule", "firewall_rule.name"},
+}
+
+// classAttributes are the class-specific attributes
+var classAttributes = map[int][]attribute{
+	HTTPActivity: {
+		{"url", "http_request.url.url_string"},
+		{"domain_name", "http_request.url.hostname"},
+		{"http_request_method", "http_request.http_method"},
+	},
+	DNSActivity: {
+		{"domain_name", "query.hostname"},
+	},
+	DetectionFinding: {
+		{"threat_name", "finding_info.title"},
+	},
+	EntityManagement: {
+		{"admin", "actor.user.name"},
+		{"admin_id", "actor.user.uid"},
+		{"object_name", "entity.name"},
+		{"object_id", "entity.uid"},
+		{"model_type", "entity.type"},
+	},
+}
+
+// numericAttributes are the attribute names whose values are integers
+var numericAttributes = map[string]bool{
+	"port":      true,
+	"bytes_in":  true,
+	"bytes_out": true,
+}
+
+// Formatter converts events to OCSF JSON
+type Formatter struct {
+	vendor  string
+	product string
+	classes map[string]int
+}
+
+// NewFormatter creates a formatter using DefaultClasses with overrides, keyed
+// the same way, replacing or adding entries
+func NewFormatter(vendor, product string, overrides map[string]int) *Formatter {
+	classes := make(map[string]int, len(DefaultClasses)+len(overrides))
+	for key, uid := range DefaultClasses {
+		classes[key] = uid
+	}
+	for key, uid := range overrides {
+		classes[key] = uid
+	}
+	return &Formatter{vendor: vendor, product: product, classes: classes}
+}
+
+// ClassOf returns the class an event maps to
+func (f *Formatter) ClassOf(fields map[string]string) Class {
+	eventType := fields["event_type"]
+	if uid, ok := f.classes[eventType+"/"+fields["event_sub_type"]]; ok {
+		return Classes[uid]
+	}
+	if uid, ok := f.classes[eventType]; ok {
+		return Classes[uid]
+	}
+	return Classes[BaseEvent]
+}
+
+// Format converts an event to an OCSF event of the class it maps to. Fields
+// without an OCSF attribute are kept in unmapped.
+func (f *Formatter) Format(fields map[string]string) ([]byte, error) {
+	class := f.ClassOf(fields)
+	event := make(map[string]interface{})
+	used := map[string]bool{"time": true, "event_type": true, "event_sub_type": true}
+
+	action := fields["action"]
+	actionCode := actionID(action)
+	activity := activityID(class.UID, fields, actionCode)
+
+	event["class_uid"] = class.UID
+	event["class_name"] = class.Name
+	event["category_uid"] = class.CategoryUID
+	event["category_name"] = class.CategoryName
+	event["activity_id"] = activity
+	event["activity_name"] = class.activityName(activity)
+	event["type_uid"] = class.UID*100 + activity
+	event["type_name"] = class.Name + ": " + class.activityName(activity)
+	event["time"] = eventTime(fields).UnixMilli()
+	event["message"] = message(fields)
+
+	metadata := map[string]interface{}{
+		"version": Version,
+		"product": map[string]string{"name": f.product, "vendor_name": f.vendor},
+	}
+	if fields["time"] != "" {
+		metadata["original_time"] = fields["time"]
+	}
+	event["metadata"] = metadata
+
+	severity := 1
+	if class.UID == DetectionFinding {
+		severity = 0
+	}
+	for _, field := range []string{"severity", "threat_severity"} {
+		if id, ok := severityIDs[strings.ToLower(fields[field])]; ok {
+			severity = id
+			used[field] = true
+			break
+		}
+	}
+	event["severity_id"] = severity
+	event["severity"] = severityNames[severity]
+
+	switch class.UID {
+	case NetworkActivity, HTTPActivity, DNSActivity:
+		for _, attr := range endpointAttributes {
+			setField(event, fields, attr, used)
+		}
+	}
+	for _, attr := range classAttributes[class.UID] {
+		setField(event, fields, attr, used)
+	}
+
+	if actionCode != 0 && class.UID != BaseEvent && class.UID != EntityManagement {
+		setDisposition(event, action, actionCode)
+		used["action"] = true
+	}
+
+	if class.UID == DetectionFinding {
+		// Network details of a finding are its evidence
+		evidence := make(map[string]interface{})
+		for _, attr := range endpointAttributes {
+			setField(evidence, fields, attr, used)
+		}
+		if len(evidence) > 0 {
+			event["evidences"] = []interface{}{evidence}
+		}
+		if threatType := fields["threat_type"]; threatType != "" {
+			setPath(event, "finding_info.types", []string{threatType})
+			used["threat_type"] = true
+		}
+		setPath(event, "finding_info.title", fallback(fields["event_sub_type"], fields["event_type"], "Unknown"))
+		setPath(event, "finding_info.uid", findingUID(fields))
+	}
+	if class.UID == EntityManagement {
+		setPath(event, "entity.name", "unknown")
+	}
+
+	unmapped := make(map[string]string)
+	for field, value := range fields {
+		if !used[field] && value != "" {
+			unmapped[field] = value
+		}
+	}
+	if len(unmapped) > 0 {
+		event["unmapped"] = unmapped
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode ocsf event: %w", err)
+	}
+	return data, nil
+}
+
+// setField sets an attribute from an event field, marking the field used.
+// Fields that are empty, or whose path is already set, are left alone.
+func setField(event map[string]interface{}, fields map[string]string, attr attribute, used map[string]bool) {
+	value := fields[attr.field]
+	if value == "" {
+		return
+	}
+
+	var typed interface{} = value
+	if numericAttributes[attr.path[strings.LastIndex(attr.path, ".")+1:]] {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return
+		}
+		typed = n
+	}
+	if setPath(event, attr.path, typed) {
+		used[attr.field] = true
+	}
+}
+
+// setPath sets the attribute at a dotted path, creating objects on the way,
+// and reports whether it did; an attribute already set is left alone
+func setPath(event map[string]interface{}, path string, value interface{}) bool {
+	parts := strings.Split(path, ".")
+	obj := event
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := obj[part].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			obj[part] = next
+		}
+		obj = next
+	}
+
+	last := parts[len(parts)-1]
+	if _, exists := obj[last]; exists {
+		return false
+	}
+	obj[last] = value
+	return true
+}
+
+// setDisposition sets the security control attributes of an event with an
+// action
+func setDisposition(event map[string]interface{}, action string, id int) {
+	event["action_id"] = id
+	event["disposition_id"] = id
+	switch id {
+	case 1:
+		event["action"] = "Allowed"
+		event["disposition"] = "Allowed"
+	case 2:
+		event["action"] = "Denied"
+		event["disposition"] = "Blocked"
+	default:
+		event["action"] = action
+		event["disposition"] = action
+	}
+}
+
+// eventTime returns the time of an event, or now when it has none
+func eventTime(fields map[string]string) time.Time {
+	if t, err := time.Parse(time.RFC3339Nano, fields["time"]); err == nil {
+		return t
+	}
+	return time.Now()
+}
+
+// message describes an event the way the CEF name does
+func message(fields map[string]string) string {
+	return fallback(fields["event_type"], "Unknown") + " - " + fallback(fields["event_sub_type"], "Unknown")
+}
+
+// findingUID identifies a finding by the hash of its event, so the same
+// event delivered twice keeps its identity
+func findingUID(fields map[string]string) string {
+	data, _ := json.Marshal(fields)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
+}
+
+// fallback returns the first non-empty value
+func fallback(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,702 @@
+package output
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"cato-logger/internal/config"
+	"cato-logger/internal/logging"
+)
+
+// AMQP 0.9.1 frame types
+const (
+	amqpFrameMethod    = 1
+	amqpFrameHeader    = 2
+	amqpFrameBody      = 3
+	amqpFrameHeartbeat = 8
+	amqpFrameEnd       = 0xCE
+)
+
+// AMQP 0.9.1 class and method IDs, packed as class<<16 | method
+const (
+	amqpConnectionStart    = 10<<16 | 10
+	amqpConnectionStartOk  = 10<<16 | 11
+	amqpConnectionTune     = 10<<16 | 30
+	amqpConnectionTuneOk   = 10<<16 | 31
+	amqpConnectionOpen     = 10<<16 | 40
+	amqpConnectionOpenOk   = 10<<16 | 41
+	amqpConnectionClose    = 10<<16 | 50
+	amqpConnectionCloseOk  = 10<<16 | 51
+	amqpChannelOpen        = 20<<16 | 10
+	amqpChannelOpenOk      = 20<<16 | 11
+	amqpChannelClose       = 20<<16 | 40
+	amqpChannelCloseOk     = 20<<16 | 41
+	amqpBasicPublish       = 60<<16 | 40
+	amqpBasicReturn        = 60<<16 | 50
+	amqpBasicAck           = 60<<16 | 80
+	amqpBasicNack          = 60<<16 | 120
+	amqpConfirmSelect      = 85<<16 | 10
+	amqpConfirmSelectOk    = 85<<16 | 11
+	amqpBasicClass         = 60
+	amqpDefaultFrameMax    = 128 << 10
+	amqpPublishChannel     = 1
+	amqpDeliveryPersistent = 2
+)
+
+// amqpSink publishes events to an AMQP 0.9.1 broker such as RabbitMQ on a
+// channel in confirm mode, waiting for the broker to confirm every message.
+// Messages are published mandatory and persistent, so unroutable messages
+// fail the write instead of being dropped. Writes are serialized.
+type amqpSink struct {
+	mu          sync.Mutex
+	name        string
+	out         config.AMQPOutput
+	formatter   batchFormatter
+	timeout     time.Duration
+	conn        *amqpConn
+	reconnects  int
+	onReconnect func(output string, err error)
+	logger      *logging.Logger
+}
+
+// amqpConn is one connection to a broker with a channel in confirm mode
+type amqpConn struct {
+	conn      net.Conn
+	reader    *bufio.Reader
+	writer    *bufio.Writer
+	frameMax  int
+	published uint64 // Delivery tag of the last published message
+}
+
+// amqpRejection is a publish the broker refused; retrying it on a new
+// connection would not help
+type amqpRejection struct {
+	reason string
+}
+
+func (e *amqpRejection) Error() string {
+	return "broker rejected the message: " + e.reason
+}
+
+// newAMQPSink connects to the output's broker
+func newAMQPSink(out config.Output, opts Options, logger *logging.Logger) (*amqpSink, error) {
+	s := &amqpSink{
+		name:        out.Name,
+		out:         *out.AMQP,
+		formatter:   newBatchFormatter(opts, out.AMQP.Format),
+		timeout:     opts.ConnTimeout,
+		onReconnect: opts.OnReconnect,
+		logger:      logger,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	conn, product, err := dialAMQP(ctx, &s.out, s.timeout)
+	if err != nil {
+		return nil, err
+	}
+	s.conn = conn
+	logger.Info("connected to amqp broker", "broker", product)
+	return s, nil
+}
+
+func (s *amqpSink) Name() string {
+	return s.name
+}
+
+func (s *amqpSink) Type() string {
+	return "amqp"
+}
+
+// Write publishes each record and waits for the broker to confirm all of
+// them. A lost connection is re-established once per call.
+func (s *amqpSink) Write(ctx context.Context, records []Record) (int64, error) {
+	bodies, err := s.formatter.FormatAll(records)
+	if err != nil {
+		return 0, err
+	}
+	messages := make([]amqpMessage, len(records))
+	for i, record := range records {
+		messages[i] = amqpMessage{routingKey: expandRoute(s.out.RoutingKey, record.Fields), body: bodies[i]}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var bytesSent int64
+	for start := 0; start < len(messages); start += s.out.MaxPending {
+		end := start + s.out.MaxPending
+		if end > len(messages) {
+			end = len(messages)
+		}
+
+		// The connection is dropped after a failed reconnect or a closed channel
+		if s.conn == nil {
+			if err := s.reconnect(ctx); err != nil {
+				return bytesSent, fmt.Errorf("reconnection failed: %w", err)
+			}
+		}
+
+		n, err := s.publish(ctx, messages[start:end])
+		if err != nil {
+			var rejected *amqpRejection
+			if errors.As(err, &rejected) {
+				return bytesSent, err
+			}
+			s.logger.WarnContext(ctx, "amqp publish failed, attempting reconnect", "error", err.Error())
+			if err := s.reconnect(ctx); err != nil {
+				return bytesSent, fmt.Errorf("reconnection failed: %w", err)
+			}
+			if n, err = s.publish(ctx, messages[start:end]); err != nil {
+				return bytesSent, fmt.Errorf("publish failed after reconnect: %w", err)
+			}
+		}
+		bytesSent += n
+	}
+	return bytesSent, nil
+}
+
+// amqpMessage is one message to publish
+type amqpMessage struct {
+	routingKey string
+	body       []byte
+}
+
+// publish sends messages and reads their confirmations. When the broker
+// closes the channel the connection is dropped, to be re-established by the
+// next call.
+func (s *amqpSink) publish(ctx context.Context, messages []amqpMessage) (int64, error) {
+	c := s.conn
+
+	deadline := time.Now().Add(s.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	c.conn.SetDeadline(deadline)
+	defer c.conn.SetDeadline(time.Time{})
+
+	contentType := "application/json"
+	if isText(s.out.Format) {
+		contentType = "text/plain"
+	}
+
+	pending := make(map[uint64]bool, len(messages))
+	var bytesSent int64
+	now := uint64(time.Now().Unix())
+	for _, msg := range messages {
+		c.writePublish(s.out.Exchange, msg.routingKey, contentType, msg.body, now)
+		c.published++
+		pending[c.published] = true
+		bytesSent += int64(len(msg.body))
+	}
+	if err := c.writer.Flush(); err != nil {
+		return 0, err
+	}
+
+	var returned error
+	for len(pending) > 0 {
+		id, args, err := c.readMethod()
+		if err != nil {
+			return 0, err
+		}
+
+		switch id {
+		case amqpBasicAck, amqpBasicNack:
+			tag := args.u64()
+			multiple := args.u8()&1 != 0
+			for t := range pending {
+				if t == tag || (multiple && t < tag) {
+					delete(pending, t)
+				}
+			}
+			if id == amqpBasicNack && returned == nil {
+				returned = &amqpRejection{reason: "message nacked by the broker"}
+			}
+		case amqpBasicReturn:
+			code, text := args.u16(), args.shortStr()
+			if returned == nil {
+				returned = &amqpRejection{reason: fmt.Sprintf("message returned as unroutable: %s (%d)", text, code)}
+			}
+			// The returned message's header and body frames follow
+			if err := c.skipContent(); err != nil {
+				return 0, err
+			}
+		case amqpChannelClose:
+			code, text := args.u16(), args.shortStr()
+			c.writeMethod(amqpPublishChannel, amqpChannelCloseOk, nil)
+			c.writer.Flush()
+			c.conn.Close()
+			s.conn = nil
+			return 0, &amqpRejection{reason: fmt.Sprintf("channel closed: %s (%d)", text, code)}
+		case amqpConnectionClose:
+			code, text := args.u16(), args.shortStr()
+			c.writeMethod(0, amqpConnectionCloseOk, nil)
+			c.writer.Flush()
+			c.conn.Close()
+			s.conn = nil
+			return 0, fmt.Errorf("broker closed the connection: %s (%d)", text, code)
+		}
+	}
+	if returned != nil {
+		return 0, returned
+	}
+	return bytesSent, nil
+}
+
+// reconnect replaces the connection
+func (s *amqpSink) reconnect(ctx context.Context) error {
+	if s.conn != nil {
+		s.conn.conn.Close()
+		s.conn = nil
+	}
+	s.reconnects++
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	conn, _, err := dialAMQP(ctx, &s.out, s.timeout)
+	if s.onReconnect != nil {
+		s.onReconnect(s.name, err)
+	}
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	s.logger.InfoContext(ctx, "reconnected to amqp broker")
+	return nil
+}
+
+func (s *amqpSink) ReconnectCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reconnects
+}
+
+// Close closes the connection cleanly, waiting briefly for the broker
+func (s *amqpSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	s.logger.Info("closing amqp connection")
+	s.conn.close(s.timeout)
+	s.conn = nil
+	return nil
+}
+
+// dialAMQP connects, authenticates with PLAIN, opens the virtual host and
+// puts a channel in confirm mode. It returns the broker's product and version.
+func dialAMQP(ctx context.Context, out *config.AMQPOutput, timeout time.Duration) (*amqpConn, string, error) {
+	u, err := url.Parse(out.URL)
+	if err != nil {
+		return nil, "", err
+	}
+	defaultPort := "5672"
+	if u.Scheme == "amqps" {
+		defaultPort = "5671"
+	}
+	address := busAddress(u.Hostname(), u.Port(), defaultPort)
+	vhost := "/"
+	if path := strings.TrimPrefix(u.Path, "/"); path != "" {
+		vhost = path
+	}
+
+	raw, err := dialBus(ctx, address, u.Scheme == "amqps", timeout)
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot connect to amqp broker at %s: %w", address, err)
+	}
+	ok := false
+	defer func() {
+		if !ok {
+			raw.Close()
+		}
+	}()
+	raw.SetDeadline(time.Now().Add(timeout))
+
+	c := &amqpConn{
+		conn:     raw,
+		reader:   bufio.NewReader(raw),
+		writer:   bufio.NewWriter(raw),
+		frameMax: amqpDefaultFrameMax,
+	}
+	c.writer.WriteString("AMQP\x00\x00\x09\x01")
+	if err := c.writer.Flush(); err != nil {
+		return nil, "", err
+	}
+
+	args, err := c.expect(amqpConnectionStart)
+	if err != nil {
+		return nil, "", fmt.Errorf("amqp handshake failed: %w", err)
+	}
+	args.u8() // version-major
+	args.u8() // version-minor
+	properties := args.table()
+	if mechanisms := args.longStr(); !strings.Contains(" "+mechanisms+" ", " PLAIN ") {
+		return nil, "", fmt.Errorf("amqp broker does not offer PLAIN authentication (offers %s)", mechanisms)
+	}
+	product := strings.TrimSpace(properties["product"] + " " + properties["version"])
+
+	var startOk amqpArgs
+	startOk.table(map[string]any{
+		"product":  "cato-logger",
+		"platform": "Go",
+		"capabilities": map[string]any{
+			"authentication_failure_close": true,
+			"basic.nack":                   true,
+			"publisher_confirms":           true,
+		},
+	})
+	startOk.shortStr("PLAIN")
+	startOk.longStr("\x00" + out.User + "\x00" + out.Password)
+	startOk.shortStr("en_US")
+	c.writeMethod(0, amqpConnectionStartOk, startOk.Bytes())
+	if err := c.writer.Flush(); err != nil {
+		return nil, "", err
+	}
+
+	args, err = c.expect(amqpConnectionTune)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = fmt.Errorf("connection closed, check amqp.user and amqp.password")
+		}
+		return nil, "", fmt.Errorf("amqp authentication failed: %w", err)
+	}
+	channelMax, frameMax := args.u16(), args.u32()
+	if frameMax > 0 && frameMax < amqpDefaultFrameMax {
+		c.frameMax = int(frameMax)
+	}
+
+	var tuneOk amqpArgs
+	tuneOk.u16(channelMax)
+	tuneOk.u32(uint32(c.frameMax))
+	tuneOk.u16(0) // No heartbeats; a dead connection surfaces as a confirm timeout
+	c.writeMethod(0, amqpConnectionTuneOk, tuneOk.Bytes())
+
+	var open amqpArgs
+	open.shortStr(vhost)
+	open.shortStr("")
+	open.u8(0)
+	c.writeMethod(0, amqpConnectionOpen, open.Bytes())
+	if err := c.writer.Flush(); err != nil {
+		return nil, "", err
+	}
+	if _, err := c.expect(amqpConnectionOpenOk); err != nil {
+		return nil, "", fmt.Errorf("cannot open amqp virtual host %s: %w", vhost, err)
+	}
+
+	var channelOpen amqpArgs
+	channelOpen.shortStr("")
+	c.writeMethod(amqpPublishChannel, amqpChannelOpen, channelOpen.Bytes())
+	c.writeMethod(amqpPublishChannel, amqpConfirmSelect, []byte{0})
+	if err := c.writer.Flush(); err != nil {
+		return nil, "", err
+	}
+	if _, err := c.expect(amqpChannelOpenOk); err != nil {
+		return nil, "", fmt.Errorf("cannot open amqp channel: %w", err)
+	}
+	if _, err := c.expect(amqpConfirmSelectOk); err != nil {
+		return nil, "", fmt.Errorf("amqp broker does not support publisher confirms: %w", err)
+	}
+
+	raw.SetDeadline(time.Time{})
+	ok = true
+	return c, product, nil
+}
+
+// writePublish buffers a persistent, mandatory Basic.Publish with its
+// content header and body frames
+func (c *amqpConn) writePublish(exchange, routingKey, contentType string, body []byte, timestamp uint64) {
+	var publish amqpArgs
+	publish.u16(0)
+	publish.shortStr(exchange)
+	publish.shortStr(routingKey)
+	publish.u8(1) // mandatory
+	c.writeMethod(amqpPublishChannel, amqpBasicPublish, publish.Bytes())
+
+	var header amqpArgs
+	header.u16(amqpBasicClass)
+	header.u16(0)
+	header.u64(uint64(len(body)))
+	header.u16(0x8000 | 0x1000 | 0x0040 | 0x0008) // content-type, delivery-mode, timestamp, app-id
+	header.shortStr(contentType)
+	header.u8(amqpDeliveryPersistent)
+	header.u64(timestamp)
+	header.shortStr("cato-logger")
+	c.writeFrame(amqpFrameHeader, amqpPublishChannel, header.Bytes())
+
+	chunk := c.frameMax - 8
+	for len(body) > 0 {
+		n := len(body)
+		if n > chunk {
+			n = chunk
+		}
+		c.writeFrame(amqpFrameBody, amqpPublishChannel, body[:n])
+		body = body[n:]
+	}
+}
+
+// writeMethod buffers a method frame
+func (c *amqpConn) writeMethod(channel uint16, id uint32, args []byte) {
+	payload := make([]byte, 4, 4+len(args))
+	binary.BigEndian.PutUint32(payload, id)
+	c.writeFrame(amqpFrameMethod, channel, append(payload, args...))
+}
+
+// writeFrame buffers one frame
+func (c *amqpConn) writeFrame(frameType byte, channel uint16, payload []byte) {
+	var header [7]byte
+	header[0] = frameType
+	binary.BigEndian.PutUint16(header[1:], channel)
+	binary.BigEndian.PutUint32(header[3:], uint32(len(payload)))
+	c.writer.Write(header[:])
+	c.writer.Write(payload)
+	c.writer.WriteByte(amqpFrameEnd)
+}
+
+// readFrame reads one frame
+func (c *amqpConn) readFrame() (byte, []byte, error) {
+	var header [7]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(header[3:])
+	if size > uint32(c.frameMax) && size > amqpDefaultFrameMax {
+		return 0, nil, fmt.Errorf("amqp frame of %d bytes exceeds the negotiated maximum", size)
+	}
+	payload := make([]byte, size+1)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return 0, nil, err
+	}
+	if payload[size] != amqpFrameEnd {
+		return 0, nil, fmt.Errorf("malformed amqp frame")
+	}
+	return header[0], payload[:size], nil
+}
+
+// readMethod reads the next method frame, skipping heartbeats
+func (c *amqpConn) readMethod() (uint32, *amqpReader, error) {
+	for {
+		frameType, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		if frameType == amqpFrameHeartbeat {
+			continue
+		}
+		if frameType != amqpFrameMethod || len(payload) < 4 {
+			return 0, nil, fmt.Errorf("unexpected amqp frame type %d", frameType)
+		}
+		return binary.BigEndian.Uint32(payload), &amqpReader{data: payload[4:]}, nil
+	}
+}
+
+// expect reads the next method and fails unless it is id. A Close from the
+// broker is reported with its reason.
+func (c *amqpConn) expect(id uint32) (*amqpReader, error) {
+	got, args, err := c.readMethod()
+	if err != nil {
+		return nil, err
+	}
+	if got == amqpConnectionClose || got == amqpChannelClose {
+		code, text := args.u16(), args.shortStr()
+		return nil, fmt.Errorf("%s (%d)", text, code)
+	}
+	if got != id {
+		return nil, fmt.Errorf("unexpected amqp method %d.%d", got>>16, got&0xFFFF)
+	}
+	return args, nil
+}
+
+// skipContent reads the content header and body frames following a method
+func (c *amqpConn) skipContent() error {
+	frameType, payload, err := c.readFrame()
+	if err != nil {
+		return err
+	}
+	if frameType != amqpFrameHeader || len(payload) < 12 {
+		return fmt.Errorf("unexpected amqp frame type %d", frameType)
+	}
+	remaining := binary.BigEndian.Uint64(payload[4:])
+	for remaining > 0 {
+		frameType, payload, err := c.readFrame()
+		if err != nil {
+			return err
+		}
+		if frameType != amqpFrameBody {
+			return fmt.Errorf("unexpected amqp frame type %d", frameType)
+		}
+		remaining -= uint64(len(payload))
+	}
+	return nil
+}
+
+// close sends Connection.Close and waits up to timeout for the broker to
+// acknowledge it
+func (c *amqpConn) close(timeout time.Duration) {
+	var args amqpArgs
+	args.u16(200)
+	args.shortStr("")
+	args.u16(0)
+	args.u16(0)
+	c.writeMethod(0, amqpConnectionClose, args.Bytes())
+	if c.writer.Flush() == nil {
+		c.conn.SetDeadline(time.Now().Add(timeout))
+		for {
+			id, _, err := c.readMethod()
+			if err != nil || id == amqpConnectionCloseOk {
+				break
+			}
+		}
+	}
+	c.conn.Close()
+}
+
+// amqpArgs encodes method arguments and content headers
+type amqpArgs struct {
+	bytes.Buffer
+}
+
+func (a *amqpArgs) u8(v uint8) {
+	a.WriteByte(v)
+}
+
+func (a *amqpArgs) u16(v uint16) {
+	a.Write(binary.BigEndian.AppendUint16(nil, v))
+}
+
+func (a *amqpArgs) u32(v uint32) {
+	a.Write(binary.BigEndian.AppendUint32(nil, v))
+}
+
+func (a *amqpArgs) u64(v uint64) {
+	a.Write(binary.BigEndian.AppendUint64(nil, v))
+}
+
+// shortStr writes a short string, truncated to 255 bytes
+func (a *amqpArgs) shortStr(s string) {
+	if len(s) > 255 {
+		s = s[:255]
+	}
+	a.u8(uint8(len(s)))
+	a.WriteString(s)
+}
+
+func (a *amqpArgs) longStr(s string) {
+	a.u32(uint32(len(s)))
+	a.WriteString(s)
+}
+
+// table writes a field table of strings, booleans and nested tables
+func (a *amqpArgs) table(fields map[string]any) {
+	var t amqpArgs
+	for name, value := range fields {
+		t.shortStr(name)
+		switch v := value.(type) {
+		case string:
+			t.u8('S')
+			t.longStr(v)
+		case bool:
+			t.u8('t')
+			if v {
+				t.u8(1)
+			} else {
+				t.u8(0)
+			}
+		case map[string]any:
+			t.u8('F')
+			t.table(v)
+		}
+	}
+	a.u32(uint32(t.Len()))
+	a.Write(t.Bytes())
+}
+
+// amqpReader decodes method arguments. Reads past the end return zero values.
+type amqpReader struct {
+	data []byte
+}
+
+func (r *amqpReader) next(n int) []byte {
+	if n > len(r.data) {
+		r.data = nil
+		return make([]byte, n)
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *amqpReader) u8() uint8 {
+	return r.next(1)[0]
+}
+
+func (r *amqpReader) u16() uint16 {
+	return binary.BigEndian.Uint16(r.next(2))
+}
+
+func (r *amqpReader) u32() uint32 {
+	return binary.BigEndian.Uint32(r.next(4))
+}
+
+func (r *amqpReader) u64() uint64 {
+	return binary.BigEndian.Uint64(r.next(8))
+}
+
+func (r *amqpReader) shortStr() string {
+	return string(r.next(int(r.u8())))
+}
+
+func (r *amqpReader) longStr() string {
+	return string(r.next(int(r.u32())))
+}
+
+// table reads a field table, keeping its string-valued fields
+func (r *amqpReader) table() map[string]string {
+	t := &amqpReader{data: r.next(int(r.u32()))}
+	fields := make(map[string]string)
+	for len(t.data) > 0 {
+		name := t.shortStr()
+		switch t.u8() {
+		case 'S':
+			fields[name] = t.longStr()
+		case 'F', 'A', 'x':
+			t.next(int(t.u32()))
+		case 't', 'b', 'B':
+			t.next(1)
+		case 's', 'u':
+			t.next(2)
+		case 'I', 'i', 'f':
+			t.next(4)
+		case 'l', 'L', 'd', 'T':
+			t.next(8)
+		case 'D':
+			t.next(5)
+		case 'V':
+		default:
+			return fields // Unknown type, the rest cannot be decoded
+		}
+	}
+	return fields
+}
+
+// probeAMQP connects, authenticates and opens a confirm-mode channel
+func probeAMQP(ctx context.Context, out *config.AMQPOutput, timeout time.Duration) (string, error) {
+	start := time.Now()
+	conn, product, err := dialAMQP(ctx, out, timeout)
+	if err != nil {
+		return "", err
+	}
+	elapsed := time.Since(start)
+	conn.close(timeout)
+	return fmt.Sprintf("amqp broker %s accepted the connection (%dms)", product, elapsed.Milliseconds()), nil
+}
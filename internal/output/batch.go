@@ -0,0 +1,23 @@
+package output
+
+// splitBatches groups encoded items into batches of at most maxCount items
+// whose sizes, plus overhead bytes per item for separators, stay within
+// maxBytes. An item larger than maxBytes forms a batch of its own; callers
+// reject those before sending.
+func splitBatches(items [][]byte, maxCount, maxBytes, overhead int) [][][]byte {
+	var batches [][][]byte
+	var batch [][]byte
+	size := 0
+	for _, item := range items {
+		if len(batch) > 0 && (len(batch) >= maxCount || size+len(item)+overhead > maxBytes) {
+			batches = append(batches, batch)
+			batch, size = nil, 0
+		}
+		batch = append(batch, item)
+		size += len(item) + overhead
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
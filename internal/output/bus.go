@@ -0,0 +1,53 @@
+package output
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// routePlaceholder matches the {field} placeholders of a subject or routing key
+var routePlaceholder = regexp.MustCompile(`\{([A-Za-z0-9_]+)\}`)
+
+// routeUnsafe matches characters replaced in field values substituted into a
+// subject or routing key, where '.' separates tokens and '*', '>' and '#'
+// are wildcards
+var routeUnsafe = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// expandRoute fills the {field} placeholders of a subject or routing key
+// from the event's fields. Missing or empty fields become "unknown".
+func expandRoute(route string, fields map[string]string) string {
+	if !strings.Contains(route, "{") {
+		return route
+	}
+	return routePlaceholder.ReplaceAllStringFunc(route, func(placeholder string) string {
+		value := fields[placeholder[1:len(placeholder)-1]]
+		if value == "" {
+			return "unknown"
+		}
+		return routeUnsafe.ReplaceAllString(value, "_")
+	})
+}
+
+// dialBus connects to a message bus at address, over TLS when useTLS is set
+func dialBus(ctx context.Context, address string, useTLS bool, timeout time.Duration) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !useTLS {
+		return dialer.DialContext(ctx, "tcp", address)
+	}
+	host, _, _ := net.SplitHostPort(address)
+	tlsDialer := &tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: host}}
+	return tlsDialer.DialContext(ctx, "tcp", address)
+}
+
+// busAddress returns the host:port of a message bus URL, adding defaultPort
+// when the URL has none
+func busAddress(host, port, defaultPort string) string {
+	if port == "" {
+		port = defaultPort
+	}
+	return net.JoinHostPort(host, port)
+}
@@ -0,0 +1,183 @@
+package output
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"cato-logger/internal/config"
+	"cato-logger/internal/gcpauth"
+	"cato-logger/internal/logging"
+)
+
+const (
+	// chronicleScope is the OAuth2 scope of the Chronicle ingestion API
+	chronicleScope = "https://www.googleapis.com/auth/malachite-ingestion"
+
+	// chronicleMaxBytes keeps requests below the API's 1 MB limit, leaving
+	// room for the request envelope
+	chronicleMaxBytes = 950 << 10
+)
+
+// chronicleSink posts events to the Chronicle unstructured log ingestion
+// API, where the log type's parser maps them to UDM
+type chronicleSink struct {
+	name      string
+	out       config.ChronicleOutput
+	formatter batchFormatter
+	tokens    *gcpauth.TokenSource
+	client    *http.Client
+	logger    *logging.Logger
+}
+
+// chronicleEntry is one unstructured log entry
+type chronicleEntry struct {
+	LogText   string `json:"log_text"`
+	Timestamp int64  `json:"ts_epoch_microseconds"`
+}
+
+// newChronicleSink creates a sink for the output's Chronicle instance
+func newChronicleSink(out config.Output, opts Options, logger *logging.Logger) (*chronicleSink, error) {
+	client := &http.Client{Timeout: opts.ConnTimeout}
+	return &chronicleSink{
+		name:      out.Name,
+		out:       *out.Chronicle,
+		formatter: newBatchFormatter(opts, out.Chronicle.Format),
+		tokens:    gcpauth.NewFileTokenSource(chronicleScope, out.Chronicle.CredentialsFile, client),
+		client:    client,
+		logger:    logger,
+	}, nil
+}
+
+func (s *chronicleSink) Name() string {
+	return s.name
+}
+
+func (s *chronicleSink) Type() string {
+	return "chronicle"
+}
+
+// Write posts records as unstructured log entries in batches of batch_size,
+// splitting batches that would exceed the request size limit
+func (s *chronicleSink) Write(ctx context.Context, records []Record) (int64, error) {
+	texts, err := s.formatter.FormatAll(records)
+	if err != nil {
+		return 0, err
+	}
+	entries := make([][]byte, len(records))
+	for i, record := range records {
+		entry, err := json.Marshal(chronicleEntry{LogText: string(texts[i]), Timestamp: eventTime(record.Fields).UnixMicro()})
+		if err != nil {
+			return 0, fmt.Errorf("failed to encode entry: %w", err)
+		}
+		if len(entry) > chronicleMaxBytes {
+			return 0, fmt.Errorf("event of %d bytes exceeds the chronicle request limit of %d bytes", len(entry), chronicleMaxBytes)
+		}
+		entries[i] = entry
+	}
+
+	var bytesSent int64
+	for _, batch := range splitBatches(entries, s.out.BatchSize, chronicleMaxBytes, 1) {
+		body, err := json.Marshal(struct {
+			CustomerID string            `json:"customer_id"`
+			LogType    string            `json:"log_type"`
+			Entries    []json.RawMessage `json:"entries"`
+		}{s.out.CustomerID, s.out.LogType, rawMessages(batch)})
+		if err != nil {
+			return bytesSent, err
+		}
+		if err := s.post(ctx, body); err != nil {
+			return bytesSent, err
+		}
+		s.logger.DebugContext(ctx, "posted batch to chronicle", "entries", len(batch), "bytes", len(body))
+		bytesSent += int64(len(body))
+	}
+	return bytesSent, nil
+}
+
+// post sends one batchCreate request
+func (s *chronicleSink) post(ctx context.Context, body []byte) error {
+	token, err := s.tokens.Token(ctx)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST",
+		s.out.IngestionURL()+"/v2/unstructuredlogentries:batchCreate", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("chronicle request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("chronicle rejected the batch with status %d: %s", resp.StatusCode, bytes.TrimSpace(respBody))
+	}
+	return nil
+}
+
+func (s *chronicleSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// eventTime returns the event's time field, or now when it is missing or
+// not RFC 3339
+func eventTime(fields map[string]string) time.Time {
+	if t, err := time.Parse(time.RFC3339, fields["time"]); err == nil {
+		return t.UTC()
+	}
+	return time.Now().UTC()
+}
+
+// rawMessages converts encoded items for embedding in a JSON document
+func rawMessages(items [][]byte) []json.RawMessage {
+	raw := make([]json.RawMessage, len(items))
+	for i, item := range items {
+		raw[i] = item
+	}
+	return raw
+}
+
+// probeChronicle checks the ingestion host accepts TLS connections and that
+// an access token can be obtained with the configured credentials
+func probeChronicle(ctx context.Context, out *config.ChronicleOutput, timeout time.Duration) (string, error) {
+	u, err := url.Parse(out.IngestionURL())
+	if err != nil {
+		return "", err
+	}
+	address := u.Host
+	if u.Port() == "" {
+		address = net.JoinHostPort(u.Hostname(), "443")
+	}
+
+	dialer := &tls.Dialer{NetDialer: &net.Dialer{}}
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return "", fmt.Errorf("cannot connect to chronicle at %s: %w", u.Hostname(), err)
+	}
+	conn.Close()
+	connectTime := time.Since(start)
+
+	tokens := gcpauth.NewFileTokenSource(chronicleScope, out.CredentialsFile, &http.Client{Timeout: timeout})
+	if _, err := tokens.Token(ctx); err != nil {
+		return "", fmt.Errorf("cannot obtain a chronicle access token: %w", err)
+	}
+	return fmt.Sprintf("chronicle ingestion API is reachable at %s (connect %dms), access token acquired",
+		u.Hostname(), connectTime.Milliseconds()), nil
+}
@@ -0,0 +1,270 @@
+package output
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"cato-logger/internal/config"
+	"cato-logger/internal/logging"
+)
+
+// Limits of the Datadog logs intake API
+const (
+	datadogMaxLogBytes     = 1 << 20
+	datadogMaxRequestBytes = 5<<20 - 4096 // Room for the array brackets
+
+	// datadogAttempts bounds the tries of a batch the intake throttles or
+	// fails with a server error
+	datadogAttempts = 4
+
+	// datadogMaxRetryDelay caps the wait a Retry-After header can ask for
+	datadogMaxRetryDelay = 30 * time.Second
+)
+
+// datadogSink posts events to the Datadog logs intake API, one log per event
+type datadogSink struct {
+	name      string
+	out       config.DatadogOutput
+	formatter batchFormatter
+	client    *http.Client
+	logger    *logging.Logger
+}
+
+// datadogLog is one log of an intake request; Timestamp is in milliseconds
+type datadogLog struct {
+	Message   string `json:"message"`
+	Source    string `json:"ddsource"`
+	Service   string `json:"service"`
+	Hostname  string `json:"hostname,omitempty"`
+	Tags      string `json:"ddtags,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// datadogRetryable is an intake response worth retrying: throttling or a
+// server error, with the delay the intake asked for, if any
+type datadogRetryable struct {
+	status int
+	body   []byte
+	delay  time.Duration
+}
+
+func (e *datadogRetryable) Error() string {
+	return fmt.Sprintf("datadog refused the batch with status %d: %s", e.status, e.body)
+}
+
+// newDatadogSink creates a sink for the output's Datadog site
+func newDatadogSink(out config.Output, opts Options, logger *logging.Logger) (*datadogSink, error) {
+	return &datadogSink{
+		name:      out.Name,
+		out:       *out.Datadog,
+		formatter: newBatchFormatter(opts, out.Datadog.Format),
+		client:    &http.Client{Timeout: opts.ConnTimeout},
+		logger:    logger,
+	}, nil
+}
+
+func (s *datadogSink) Name() string {
+	return s.name
+}
+
+func (s *datadogSink) Type() string {
+	return "datadog"
+}
+
+// Write posts records as logs in batches of batch_size, splitting batches
+// that would exceed the request size limit. Each log carries the event's
+// time, the service, source and expanded tags.
+func (s *datadogSink) Write(ctx context.Context, records []Record) (int64, error) {
+	lines, err := s.formatter.FormatAll(records)
+	if err != nil {
+		return 0, err
+	}
+	entries := make([][]byte, len(records))
+	for i, record := range records {
+		entry, err := json.Marshal(datadogLog{
+			Message:   string(lines[i]),
+			Source:    s.out.Source,
+			Service:   s.out.Service,
+			Hostname:  record.Hostname,
+			Tags:      s.tags(record.Fields),
+			Timestamp: eventTime(record.Fields).UnixMilli(),
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to encode log: %w", err)
+		}
+		if len(entry) > datadogMaxLogBytes {
+			return 0, fmt.Errorf("event of %d bytes exceeds the datadog log limit of %d bytes", len(entry), datadogMaxLogBytes)
+		}
+		entries[i] = entry
+	}
+
+	var bytesSent int64
+	for _, batch := range splitBatches(entries, s.out.BatchSize, datadogMaxRequestBytes, 1) {
+		var body bytes.Buffer
+		body.WriteByte('[')
+		body.Write(bytes.Join(batch, []byte{','}))
+		body.WriteByte(']')
+
+		attempts, err := s.postBatch(ctx, body.Bytes(), len(batch))
+		if err != nil {
+			return bytesSent, err
+		}
+		s.logger.DebugContext(ctx, "posted batch to datadog", "logs", len(batch), "bytes", body.Len(), "attempts", attempts)
+		bytesSent += int64(body.Len())
+	}
+	return bytesSent, nil
+}
+
+// tags expands the output's tags for an event into the comma-separated
+// ddtags attribute. Commas in field values would split a tag, so they are
+// replaced.
+func (s *datadogSink) tags(fields map[string]string) string {
+	if len(s.out.Tags) == 0 {
+		return ""
+	}
+	tags := make([]string, len(s.out.Tags))
+	for i, tag := range s.out.Tags {
+		tags[i] = strings.ReplaceAll(expandLabel(tag, fields), ",", "_")
+	}
+	return strings.Join(tags, ",")
+}
+
+// postBatch posts one batch, retrying when the intake throttles it (429)
+// or fails with a server error. Waits follow Retry-After when the intake
+// sends it and grow with each attempt otherwise. It returns the number of
+// attempts made.
+func (s *datadogSink) postBatch(ctx context.Context, body []byte, logs int) (int, error) {
+	for attempt := 1; ; attempt++ {
+		err := s.post(ctx, body)
+		if err == nil {
+			return attempt, nil
+		}
+		var retryable *datadogRetryable
+		if !errors.As(err, &retryable) || attempt == datadogAttempts {
+			return attempt, err
+		}
+
+		delay := retryable.delay
+		if delay == 0 {
+			delay = time.Duration(attempt) * time.Second
+		}
+		s.logger.WarnContext(ctx, "datadog did not accept the batch, retrying",
+			"status", retryable.status, "logs", logs, "attempt", attempt, "delay", delay)
+		select {
+		case <-ctx.Done():
+			return attempt, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// post sends one intake request
+func (s *datadogSink) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", s.out.IntakeURL(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("DD-API-KEY", s.out.APIKey)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("datadog request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	respBody = bytes.TrimSpace(respBody)
+
+	switch {
+	case resp.StatusCode == http.StatusAccepted || resp.StatusCode == http.StatusOK:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return &datadogRetryable{status: resp.StatusCode, body: respBody, delay: datadogRetryDelay(resp.Header)}
+	default:
+		return fmt.Errorf("datadog rejected the batch with status %d: %s", resp.StatusCode, respBody)
+	}
+}
+
+// datadogRetryDelay reads the wait a throttled response asks for, from
+// Retry-After or Datadog's X-RateLimit-Reset, both in seconds. Zero means
+// the response did not say.
+func datadogRetryDelay(header http.Header) time.Duration {
+	for _, name := range []string{"Retry-After", "X-RateLimit-Reset"} {
+		seconds, err := strconv.Atoi(strings.TrimSpace(header.Get(name)))
+		if err != nil || seconds <= 0 {
+			continue
+		}
+		return min(time.Duration(seconds)*time.Second, datadogMaxRetryDelay)
+	}
+	return 0
+}
+
+func (s *datadogSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// probeDatadog checks the intake host accepts connections and, unless an
+// endpoint overrides the site, that the site's API accepts the API key
+func probeDatadog(ctx context.Context, out *config.DatadogOutput, timeout time.Duration) (string, error) {
+	u, err := url.Parse(out.IntakeURL())
+	if err != nil {
+		return "", err
+	}
+	address := u.Host
+	if u.Port() == "" {
+		port := "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+		address = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	start := time.Now()
+	var conn net.Conn
+	if u.Scheme == "https" {
+		dialer := &tls.Dialer{NetDialer: &net.Dialer{}}
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return "", fmt.Errorf("cannot connect to datadog at %s: %w", u.Hostname(), err)
+	}
+	conn.Close()
+	connectTime := time.Since(start)
+
+	if out.Endpoint != "" {
+		return fmt.Sprintf("datadog intake is reachable at %s (connect %dms), API key not verified behind a custom endpoint",
+			u.Hostname(), connectTime.Milliseconds()), nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://api."+out.Site+"/api/v1/validate", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("DD-API-KEY", out.APIKey)
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("datadog request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("datadog refused the API key with status %d: %s", resp.StatusCode, bytes.TrimSpace(respBody))
+	}
+	return fmt.Sprintf("datadog intake is reachable at %s (connect %dms), API key accepted by %s",
+		u.Hostname(), connectTime.Milliseconds(), out.Site), nil
+}
@@ -0,0 +1,177 @@
+package output
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"cato-logger/internal/config"
+	"cato-logger/internal/logging"
+)
+
+// fileSink appends events to local files, one CEF or JSON line per event, for
+// collection by an agent tailing the files. Each batch is synced before Write
+// returns so markers never advance past events that are not on disk.
+type fileSink struct {
+	mu        sync.Mutex
+	name      string
+	out       config.FileOutput
+	formatter batchFormatter
+	path      string // Path of the open file, the template expanded for its period
+	file      *logging.RotatingFile
+	logger    *logging.Logger
+}
+
+// newFileSink opens the output's file for the current period
+func newFileSink(out config.Output, opts Options, logger *logging.Logger) (*fileSink, error) {
+	s := &fileSink{
+		name:      out.Name,
+		out:       *out.File,
+		formatter: newBatchFormatter(opts, out.File.Format),
+		logger:    logger,
+	}
+	if err := os.MkdirAll(filepath.Dir(s.out.Path), 0755); err != nil {
+		return nil, fmt.Errorf("cannot create output directory: %w", err)
+	}
+	if err := s.switchFile(time.Now()); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *fileSink) Name() string {
+	return s.name
+}
+
+func (s *fileSink) Type() string {
+	return "file"
+}
+
+// Write appends records as lines to the file for the current period, moving
+// to a new file when the period changes
+func (s *fileSink) Write(ctx context.Context, records []Record) (int64, error) {
+	lines, err := s.formatter.FormatAll(records)
+	if err != nil {
+		return 0, err
+	}
+	var buf bytes.Buffer
+	for _, line := range lines {
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.switchFile(time.Now()); err != nil {
+		return 0, err
+	}
+	n, err := s.file.Write(buf.Bytes())
+	if err != nil {
+		return int64(n), fmt.Errorf("failed to write to %s: %w", s.path, err)
+	}
+	if err := s.file.Sync(); err != nil {
+		return int64(n), fmt.Errorf("failed to sync %s: %w", s.path, err)
+	}
+	return int64(n), nil
+}
+
+// switchFile opens the file the template names for now if it is not already
+// open, finishing the previous period's file
+func (s *fileSink) switchFile(now time.Time) error {
+	path := expandFilePath(s.out.Path, s.name, now)
+	if path == s.path {
+		return nil
+	}
+
+	file, err := logging.OpenRotatingFile(path, logging.RotationOptions{
+		MaxSizeMB:  s.out.MaxSizeMB,
+		MaxBackups: s.out.MaxBackups,
+		MaxAgeDays: s.out.MaxAgeDays,
+		Compress:   s.out.Compress,
+	})
+	if err != nil {
+		return err
+	}
+
+	if s.file != nil {
+		s.file.Close()
+		s.logger.Info("finished output file", "path", s.path, "next", path)
+		// Compression and pruning happen off the delivery path
+		go s.finish(s.path, path)
+	}
+	s.file, s.path = file, path
+	return nil
+}
+
+// finish compresses a previous period's file and deletes finished files
+// older than max_age_days
+func (s *fileSink) finish(previous, current string) {
+	if s.out.Compress {
+		if err := logging.CompressFile(previous); err != nil {
+			s.logger.Warn("failed to compress output file", "path", previous, "error", err.Error())
+		}
+	}
+	if s.out.MaxAgeDays <= 0 {
+		return
+	}
+
+	pattern := s.out.Path
+	for _, placeholder := range config.FilePlaceholders {
+		pattern = strings.ReplaceAll(pattern, placeholder, "*")
+	}
+	matches, err := filepath.Glob(pattern + "*")
+	if err != nil {
+		return
+	}
+
+	cutoff := time.Now().Add(-time.Duration(s.out.MaxAgeDays) * 24 * time.Hour)
+	for _, match := range matches {
+		if match == current || strings.HasPrefix(match, current+".") {
+			continue
+		}
+		if info, err := os.Stat(match); err == nil && !info.IsDir() && info.ModTime().Before(cutoff) {
+			if err := os.Remove(match); err == nil {
+				s.logger.Debug("deleted expired output file", "path", match)
+			}
+		}
+	}
+}
+
+func (s *fileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+// expandFilePath fills in the placeholders of a file output path for the
+// period containing now
+func expandFilePath(path, name string, now time.Time) string {
+	now = now.UTC()
+	return strings.NewReplacer(
+		"{date}", now.Format("2006-01-02"),
+		"{hour}", now.Format("15"),
+		"{output}", name,
+	).Replace(path)
+}
+
+// probeFile checks the output's directory exists (creating it if needed)
+// and that the current file can be opened for appending
+func probeFile(name string, out *config.FileOutput) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(out.Path), 0755); err != nil {
+		return "", fmt.Errorf("cannot create output directory: %w", err)
+	}
+
+	path := expandFilePath(out.Path, name, time.Now())
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return "", fmt.Errorf("cannot open output file: %w", err)
+	}
+	file.Close()
+	return fmt.Sprintf("output file %s is writable", path), nil
+}
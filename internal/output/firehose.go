@@ -0,0 +1,264 @@
+package output
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"cato-logger/internal/awsauth"
+	"cato-logger/internal/config"
+	"cato-logger/internal/logging"
+)
+
+const (
+	// Firehose limits: 1000 KiB per record and 4 MiB per PutRecordBatch
+	// request. The request limit applies to the base64-encoded body, so it
+	// leaves room for the envelope.
+	firehoseMaxRecordBytes  = 1000 << 10
+	firehoseMaxRequestBytes = 4<<20 - 4096
+
+	// firehoseAttempts bounds the retries of records Firehose did not accept
+	firehoseAttempts = 3
+)
+
+// firehoseSink delivers events to an Amazon Data Firehose delivery stream
+// with PutRecordBatch, one newline-terminated record per event, so the
+// stream's S3 objects hold one event per line
+type firehoseSink struct {
+	name      string
+	out       config.FirehoseOutput
+	formatter batchFormatter
+	creds     *awsauth.Provider
+	client    *http.Client
+	logger    *logging.Logger
+}
+
+// firehoseRecord is one record of a PutRecordBatch request; Data is encoded
+// as base64
+type firehoseRecord struct {
+	Data []byte `json:"Data"`
+}
+
+// firehoseResponse is the PutRecordBatch response
+type firehoseResponse struct {
+	FailedPutCount   int `json:"FailedPutCount"`
+	RequestResponses []struct {
+		ErrorCode    string `json:"ErrorCode"`
+		ErrorMessage string `json:"ErrorMessage"`
+	} `json:"RequestResponses"`
+}
+
+// newFirehoseSink creates a sink for the output's delivery stream
+func newFirehoseSink(out config.Output, opts Options, logger *logging.Logger) (*firehoseSink, error) {
+	client := &http.Client{Timeout: opts.ConnTimeout}
+	return &firehoseSink{
+		name:      out.Name,
+		out:       *out.Firehose,
+		formatter: newBatchFormatter(opts, out.Firehose.Format),
+		creds:     newAWSProvider(out.Firehose.Credentials, out.Firehose.Region, client),
+		client:    client,
+		logger:    logger,
+	}, nil
+}
+
+// newAWSProvider returns the credential provider for an AWS output
+func newAWSProvider(creds config.AWSCredentials, region string, client *http.Client) *awsauth.Provider {
+	var static *awsauth.Credentials
+	if creds.AccessKeyID != "" {
+		static = &awsauth.Credentials{AccessKeyID: creds.AccessKeyID, SecretAccessKey: creds.SecretAccessKey}
+	}
+	return awsauth.NewProvider(client, static, creds.RoleARN, region)
+}
+
+func (s *firehoseSink) Name() string {
+	return s.name
+}
+
+func (s *firehoseSink) Type() string {
+	return "firehose"
+}
+
+// Write sends records in batches of batch_size, splitting batches that would
+// exceed the request size limit. Records Firehose did not accept are retried
+// before the write fails.
+func (s *firehoseSink) Write(ctx context.Context, records []Record) (int64, error) {
+	bodies, err := s.formatter.FormatAll(records)
+	if err != nil {
+		return 0, err
+	}
+	entries := make([][]byte, len(records))
+	for i := range records {
+		data := append(bodies[i], '\n')
+		if len(data) > firehoseMaxRecordBytes {
+			return 0, fmt.Errorf("event of %d bytes exceeds the firehose record limit of %d bytes", len(data), firehoseMaxRecordBytes)
+		}
+		entry, err := json.Marshal(firehoseRecord{Data: data})
+		if err != nil {
+			return 0, fmt.Errorf("failed to encode record: %w", err)
+		}
+		entries[i] = entry
+	}
+
+	var bytesSent int64
+	for _, batch := range splitBatches(entries, s.out.BatchSize, firehoseMaxRequestBytes, 1) {
+		n, err := s.putBatch(ctx, batch)
+		bytesSent += n
+		if err != nil {
+			return bytesSent, err
+		}
+	}
+	return bytesSent, nil
+}
+
+// putBatch sends one batch, resending the records that failed
+func (s *firehoseSink) putBatch(ctx context.Context, batch [][]byte) (int64, error) {
+	var bytesSent int64
+	pending := batch
+	for attempt := 1; ; attempt++ {
+		failed, reason, n, err := s.putRecords(ctx, pending)
+		bytesSent += n
+		if err != nil {
+			return bytesSent, err
+		}
+		if len(failed) == 0 {
+			s.logger.DebugContext(ctx, "put batch to firehose", "records", len(batch), "attempts", attempt)
+			return bytesSent, nil
+		}
+		if attempt == firehoseAttempts {
+			return bytesSent, fmt.Errorf("firehose did not accept %d of %d records: %s", len(failed), len(batch), reason)
+		}
+
+		s.logger.WarnContext(ctx, "firehose did not accept some records, retrying",
+			"failed", len(failed), "records", len(pending), "reason", reason)
+		select {
+		case <-ctx.Done():
+			return bytesSent, ctx.Err()
+		case <-time.After(time.Duration(attempt) * 500 * time.Millisecond):
+		}
+		pending = failed
+	}
+}
+
+// putRecords sends one PutRecordBatch request and returns the entries that
+// failed with the first failure's reason
+func (s *firehoseSink) putRecords(ctx context.Context, entries [][]byte) ([][]byte, string, int64, error) {
+	streamName, err := json.Marshal(s.out.Stream)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	var body bytes.Buffer
+	body.WriteString(`{"DeliveryStreamName":`)
+	body.Write(streamName)
+	body.WriteString(`,"Records":[`)
+	body.Write(bytes.Join(entries, []byte{','}))
+	body.WriteString(`]}`)
+
+	respBody, err := firehoseCall(ctx, s.client, s.creds, &s.out, "PutRecordBatch", body.Bytes())
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	var result firehoseResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, "", 0, fmt.Errorf("invalid firehose response: %w", err)
+	}
+	if result.FailedPutCount == 0 {
+		return nil, "", int64(body.Len()), nil
+	}
+	if len(result.RequestResponses) != len(entries) {
+		return nil, "", 0, fmt.Errorf("firehose reported %d failed records without per-record results", result.FailedPutCount)
+	}
+
+	var failed [][]byte
+	var reason string
+	for i, response := range result.RequestResponses {
+		if response.ErrorCode != "" {
+			failed = append(failed, entries[i])
+			if reason == "" {
+				reason = response.ErrorCode + ": " + response.ErrorMessage
+			}
+		}
+	}
+	return failed, reason, int64(body.Len()), nil
+}
+
+// firehoseCall sends a signed Firehose API request and returns the body of
+// a successful response
+func firehoseCall(ctx context.Context, client *http.Client, provider *awsauth.Provider, out *config.FirehoseOutput, operation string, body []byte) ([]byte, error) {
+	creds, err := provider.Credentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", out.FirehoseURL()+"/", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "Firehose_20150804."+operation)
+	awsauth.SignRequest(req, body, creds, out.Region, "firehose", time.Now())
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("firehose request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(respBody, &apiErr) == nil && apiErr.Type != "" {
+			// __type may be prefixed with a namespace ("...#ResourceNotFoundException")
+			code := apiErr.Type[strings.LastIndex(apiErr.Type, "#")+1:]
+			return nil, fmt.Errorf("firehose %s failed: %s: %s", operation, code, apiErr.Message)
+		}
+		return nil, fmt.Errorf("firehose %s failed with status %d: %s", operation, resp.StatusCode, bytes.TrimSpace(respBody))
+	}
+	return respBody, nil
+}
+
+func (s *firehoseSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// probeFirehose obtains credentials and checks the delivery stream exists.
+// Credentials allowed to write but not to describe the stream still pass.
+func probeFirehose(ctx context.Context, out *config.FirehoseOutput, timeout time.Duration) (string, error) {
+	client := &http.Client{Timeout: timeout}
+	provider := newAWSProvider(out.Credentials, out.Region, client)
+
+	body, err := json.Marshal(map[string]string{"DeliveryStreamName": out.Stream})
+	if err != nil {
+		return "", err
+	}
+	respBody, err := firehoseCall(ctx, client, provider, out, "DescribeDeliveryStream", body)
+	if err != nil {
+		if strings.Contains(err.Error(), "AccessDenied") {
+			return fmt.Sprintf("firehose credentials accepted; not allowed to describe delivery stream %s", out.Stream), nil
+		}
+		return "", err
+	}
+
+	var result struct {
+		Description struct {
+			Status string `json:"DeliveryStreamStatus"`
+		} `json:"DeliveryStreamDescription"`
+	}
+	json.Unmarshal(respBody, &result)
+	if result.Description.Status != "" && result.Description.Status != "ACTIVE" {
+		return "", fmt.Errorf("firehose delivery stream %s is %s", out.Stream, result.Description.Status)
+	}
+	return fmt.Sprintf("firehose delivery stream %s is active in %s", out.Stream, out.Region), nil
+}
@@ -0,0 +1,186 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"cato-logger/internal/cef"
+	"cato-logger/internal/config"
+	"cato-logger/internal/ecs"
+	"cato-logger/internal/ocsf"
+	"cato-logger/internal/tmpl"
+)
+
+// Formatter renders a record as the message or line an output sends
+type Formatter interface {
+	Format(record Record) ([]byte, error)
+}
+
+// FormatterFunc adapts a function to the Formatter interface
+type FormatterFunc func(record Record) ([]byte, error)
+
+// Format calls f(record)
+func (f FormatterFunc) Format(record Record) ([]byte, error) {
+	return f(record)
+}
+
+// minParallelRecords is the fewest records a formatting goroutine is
+// started for. Splitting a batch costs about 20µs and 10 allocations
+// (BenchmarkFormatAll), while a record takes 5-10µs to format as CEF or
+// JSON, so a share of 256 records keeps that overhead near 1%.
+const minParallelRecords = 256
+
+// batchFormatter formats whole batches of records with a Formatter, large
+// batches split across up to workers goroutines
+type batchFormatter struct {
+	Formatter
+	workers int
+}
+
+// newBatchFormatter returns the batch formatter of an output's format
+func newBatchFormatter(opts Options, format string) batchFormatter {
+	return batchFormatter{Formatter: opts.Formats.Formatter(format), workers: opts.FormatWorkers}
+}
+
+// FormatAll formats records, returning the results in the records' order.
+// Batches of at least minParallelRecords per goroutine are split across the
+// workers; on failure the error of the earliest failing record is returned.
+func (b batchFormatter) FormatAll(records []Record) ([][]byte, error) {
+	workers := b.workers
+	if workers > len(records)/minParallelRecords {
+		workers = len(records) / minParallelRecords
+	}
+	if workers <= 1 {
+		return b.formatSerial(records)
+	}
+	return b.formatParallel(records, workers)
+}
+
+// formatSerial formats records one after the other
+func (b batchFormatter) formatSerial(records []Record) ([][]byte, error) {
+	results := make([][]byte, len(records))
+	for i, record := range records {
+		result, err := b.Format(record)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = result
+	}
+	return results, nil
+}
+
+// formatParallel formats records over workers goroutines, each taking a
+// contiguous share of the batch
+func (b batchFormatter) formatParallel(records []Record, workers int) ([][]byte, error) {
+	results := make([][]byte, len(records))
+	errs := make([]error, workers)
+	share := (len(records) + workers - 1) / workers
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		start, end := w*share, min((w+1)*share, len(records))
+		wg.Add(1)
+		go func(w, start, end int) {
+			defer wg.Done()
+			for i := start; i < end; i++ {
+				result, err := b.Format(records[i])
+				if err != nil {
+					errs[w] = err
+					return
+				}
+				results[i] = result
+			}
+		}(w, start, end)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// Formats holds what the formatters of every output render with: the CEF
+// mapping profile of each feed, which a reload replaces, the OCSF and ECS
+// mappings, and the named templates
+type Formats struct {
+	mu        sync.RWMutex
+	cef       map[string]*cef.Formatter
+	ocsf      *ocsf.Formatter
+	ecs       *ecs.Formatter
+	templates map[string]*tmpl.Template
+}
+
+// NewFormats creates the formatting state shared by all outputs. CEF
+// profiles are added per feed with SetCEF.
+func NewFormats(ocsfFormatter *ocsf.Formatter, ecsFormatter *ecs.Formatter, templates map[string]*tmpl.Template) *Formats {
+	return &Formats{
+		cef:       make(map[string]*cef.Formatter),
+		ocsf:      ocsfFormatter,
+		ecs:       ecsFormatter,
+		templates: templates,
+	}
+}
+
+// SetCEF sets the CEF formatter for a feed's mapping profile
+func (f *Formats) SetCEF(feed string, formatter *cef.Formatter) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cef[feed] = formatter
+}
+
+// Formatter returns the formatter for a format name: cef, ocsf, ecs-json,
+// template:<name>, or json for the event fields as a JSON object
+func (f *Formats) Formatter(format string) Formatter {
+	if name, ok := strings.CutPrefix(format, config.TemplateFormatPrefix); ok {
+		return FormatterFunc(func(record Record) ([]byte, error) {
+			t := f.templates[name]
+			if t == nil {
+				return nil, fmt.Errorf("no template named %q", name)
+			}
+			return t.Format(tmpl.Event{Feed: record.Feed, Hostname: record.Hostname, Fields: record.Fields})
+		})
+	}
+	switch format {
+	case "cef":
+		return FormatterFunc(f.formatCEF)
+	case "ocsf":
+		return FormatterFunc(func(record Record) ([]byte, error) {
+			return f.ocsf.Format(record.Fields)
+		})
+	case "ecs-json":
+		return FormatterFunc(func(record Record) ([]byte, error) {
+			return f.ecs.Format(record.Fields)
+		})
+	}
+	return FormatterFunc(formatJSON)
+}
+
+// isText reports whether a format renders plain text rather than JSON
+func isText(format string) bool {
+	return format == "cef" || strings.HasPrefix(format, config.TemplateFormatPrefix)
+}
+
+// formatCEF renders a record with its feed's CEF mapping profile
+func (f *Formats) formatCEF(record Record) ([]byte, error) {
+	f.mu.RLock()
+	formatter := f.cef[record.Feed]
+	f.mu.RUnlock()
+
+	if formatter == nil {
+		return nil, fmt.Errorf("no CEF mapping profile for feed %q", record.Feed)
+	}
+	return []byte(formatter.Format(record.Fields)), nil
+}
+
+// formatJSON renders a record's fields as a JSON object
+func formatJSON(record Record) ([]byte, error) {
+	data, err := json.Marshal(record.Fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode event: %w", err)
+	}
+	return data, nil
+}
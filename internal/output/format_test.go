@@ -0,0 +1,63 @@
+package output
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"cato-logger/internal/cef"
+)
+
+// benchmarkRecords returns n connectivity events of the events feed
+func benchmarkRecords(n int) []Record {
+	records := make([]Record, n)
+	for i := range records {
+		records[i] = Record{Feed: "events", Hostname: "collector", Fields: map[string]string{
+			"account_id": "12345", "action": "Allow", "application": "Slack", "bytes_in": "10432",
+			"bytes_out": "2211", "dest_country_code": "US", "dest_ip": "93.68.89.125", "dest_port": "443",
+			"device_name": "LAPTOP-7F3K", "event_sub_type": "Internet Firewall", "event_type": "Security",
+			"internalId": "12345-" + strconv.Itoa(i), "ip_protocol": "TCP", "protocol": "HTTPS",
+			"src_ip": "10.1.178.2", "src_port": "51544", "src_site_name": "Berlin HQ",
+			"time": "2026-10-16T20:48:45Z", "vpn_user_email": "jane@example.com",
+		}}
+	}
+	return records
+}
+
+// BenchmarkFormatAll compares serial and parallel formatting of CEF and
+// JSON batches below and above minParallelRecords, with 4 workers. Run it
+// with -cpu to see how the parallel speedup depends on the cores available;
+// the serial/parallel ratio at small sizes is the cost minParallelRecords
+// avoids.
+func BenchmarkFormatAll(b *testing.B) {
+	formats := NewFormats(nil, nil, nil)
+	formats.SetCEF("events", cef.NewFormatter("Cato Networks", "SASE Platform", "1.0",
+		map[string]string{"src_ip": "src", "dest_ip": "dst", "src_port": "spt", "dest_port": "dpt",
+			"protocol": "proto", "bytes_in": "in", "bytes_out": "out", "account_id": "aid", "time": "rt"},
+		[]string{"rt", "src", "spt", "dst", "dpt", "proto", "in", "out", "aid"},
+		cef.Options{Timestamps: cef.Timestamps{Fields: map[string]string{"rt": "time"}}}))
+
+	const workers = 4
+	for _, format := range []string{"cef", "json"} {
+		bf := batchFormatter{Formatter: formats.Formatter(format), workers: workers}
+		for _, size := range []int{16, 64, 256, 1024, 4096} {
+			records := benchmarkRecords(size)
+			b.Run(fmt.Sprintf("%s/%d/serial", format, size), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if _, err := bf.formatSerial(records); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+			b.Run(fmt.Sprintf("%s/%d/parallel", format, size), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if _, err := bf.formatParallel(records, workers); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,670 @@
+package output
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cato-logger/internal/config"
+	"cato-logger/internal/logging"
+)
+
+const (
+	// grpcMethod is the path of the catologger.v1.EventStream/Stream call,
+	// see proto/catologger/v1/event_stream.proto
+	grpcMethod = "/catologger.v1.EventStream/Stream"
+
+	// grpcMaxMessage is the largest batch sent and ack accepted, the
+	// default receive limit of gRPC servers
+	grpcMaxMessage = 4 << 20
+
+	// grpcBatchOverhead is room kept in a batch message for its sequence
+	// and format, and grpcEventOverhead the tag and length of each event
+	grpcBatchOverhead = 1 << 10
+	grpcEventOverhead = 6
+
+	// grpcCloseWait is how long Close waits for the receiver to end the
+	// call after the stream is half-closed
+	grpcCloseWait = time.Second
+)
+
+// grpcCodes names the gRPC status codes
+var grpcCodes = []string{
+	"OK", "CANCELLED", "UNKNOWN", "INVALID_ARGUMENT", "DEADLINE_EXCEEDED", "NOT_FOUND",
+	"ALREADY_EXISTS", "PERMISSION_DENIED", "RESOURCE_EXHAUSTED", "FAILED_PRECONDITION", "ABORTED",
+	"OUT_OF_RANGE", "UNIMPLEMENTED", "INTERNAL", "UNAVAILABLE", "DATA_LOSS", "UNAUTHENTICATED",
+}
+
+// grpcSink streams event batches to a receiver of the EventStream service
+// over one long-lived call, waiting for the receiver to acknowledge every
+// batch. Batches are pipelined up to max_pending_batches unacknowledged
+// ones, so a receiver holding back acks slows delivery down. Writes are
+// serialized.
+type grpcSink struct {
+	mu          sync.Mutex
+	name        string
+	out         config.GRPCOutput
+	formatter   batchFormatter
+	client      *http.Client
+	timeout     time.Duration
+	stream      *grpcStream
+	reconnects  int
+	onReconnect func(output string, err error)
+	logger      *logging.Logger
+}
+
+// grpcStream is one Stream call: batches are written to body, acks are
+// read in the background
+type grpcStream struct {
+	body   *io.PipeWriter
+	ctx    context.Context
+	cancel context.CancelFunc
+	acks   chan grpcAck
+	done   chan struct{} // Closed when the call ends
+	err    error         // Why the call ended, set before done is closed
+	next   uint64        // Sequence of the next batch
+}
+
+// grpcAck is the receiver's answer to one batch
+type grpcAck struct {
+	sequence uint64
+	err      string
+}
+
+// grpcRejection is a batch the receiver refused; resending it on a new
+// stream would not help
+type grpcRejection struct {
+	sequence uint64
+	message  string
+}
+
+func (e *grpcRejection) Error() string {
+	return fmt.Sprintf("receiver rejected batch %d: %s", e.sequence, e.message)
+}
+
+// grpcStatusError is a call the receiver ended with a non-OK status
+type grpcStatusError struct {
+	code    int
+	message string
+}
+
+func (e *grpcStatusError) Error() string {
+	name := strconv.Itoa(e.code)
+	if e.code < len(grpcCodes) {
+		name = grpcCodes[e.code]
+	}
+	if e.message == "" {
+		return "receiver ended the stream with status " + name
+	}
+	return fmt.Sprintf("receiver ended the stream with status %s: %s", name, e.message)
+}
+
+// newGRPCSink creates a sink for the output's receiver. The stream is
+// opened by the first write.
+func newGRPCSink(out config.Output, opts Options, logger *logging.Logger) (*grpcSink, error) {
+	client, err := newGRPCClient(out.GRPC, opts.ConnTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return &grpcSink{
+		name:        out.Name,
+		out:         *out.GRPC,
+		formatter:   newBatchFormatter(opts, out.GRPC.Format),
+		client:      client,
+		timeout:     opts.ConnTimeout,
+		onReconnect: opts.OnReconnect,
+		logger:      logger,
+	}, nil
+}
+
+func (s *grpcSink) Name() string {
+	return s.name
+}
+
+func (s *grpcSink) Type() string {
+	return "grpc"
+}
+
+// Write sends the records in batches and waits for the receiver to
+// acknowledge all of them. A failed stream is replaced once per call and
+// the batches not yet acknowledged are sent again.
+func (s *grpcSink) Write(ctx context.Context, records []Record) (int64, error) {
+	payloads, err := s.formatter.FormatAll(records)
+	if err != nil {
+		return 0, err
+	}
+	events := make([][]byte, len(records))
+	for i, record := range records {
+		events[i] = encodeGRPCEvent(record.Feed, record.Hostname, payloads[i])
+		if len(events[i]) > grpcMaxMessage-grpcBatchOverhead {
+			return 0, fmt.Errorf("event of %d bytes exceeds the %d-byte gRPC message limit", len(events[i]), grpcMaxMessage)
+		}
+	}
+	batches := splitBatches(events, s.out.BatchSize, grpcMaxMessage-grpcBatchOverhead, grpcEventOverhead)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stream == nil {
+		s.stream = s.open()
+	}
+	bytesSent, unacked, err := s.send(ctx, batches)
+	if err == nil {
+		return bytesSent, nil
+	}
+	var rejected *grpcRejection
+	if errors.As(err, &rejected) || ctx.Err() != nil {
+		return bytesSent, err
+	}
+
+	s.logger.WarnContext(ctx, "grpc stream failed, attempting reconnect", "error", err.Error())
+	s.stream.close()
+	s.stream = s.open()
+	s.reconnects++
+	n, _, err := s.send(ctx, unacked)
+	bytesSent += n
+	if s.onReconnect != nil {
+		if errors.As(err, &rejected) {
+			s.onReconnect(s.name, nil)
+		} else {
+			s.onReconnect(s.name, err)
+		}
+	}
+	if err != nil {
+		if !errors.As(err, &rejected) {
+			s.stream.close()
+			s.stream = nil
+		}
+		return bytesSent, fmt.Errorf("send failed after reconnect: %w", err)
+	}
+	s.logger.InfoContext(ctx, "reconnected to grpc receiver")
+	return bytesSent, nil
+}
+
+// send sends batches on the stream, keeping at most max_pending_batches
+// unacknowledged, and waits for the remaining acks. It returns the bytes of
+// the acknowledged batches and, on failure, the batches not acknowledged.
+func (s *grpcSink) send(ctx context.Context, batches [][][]byte) (int64, [][][]byte, error) {
+	st := s.stream
+	pending := make(map[uint64]int, s.out.MaxPendingBatches) // Sequence to batch index
+	acked := make([]bool, len(batches))
+	sizes := make([]int, len(batches))
+	var bytesSent int64
+
+	fail := func(err error) (int64, [][][]byte, error) {
+		var unacked [][][]byte
+		for i, batch := range batches {
+			if !acked[i] {
+				unacked = append(unacked, batch)
+			}
+		}
+		return bytesSent, unacked, err
+	}
+	wait := func() error {
+		ack, err := st.waitAck(ctx, s.timeout)
+		if err != nil {
+			return err
+		}
+		i, ok := pending[ack.sequence]
+		if !ok {
+			return nil // Late acknowledgement of an earlier, failed call
+		}
+		delete(pending, ack.sequence)
+		if ack.err != "" {
+			return &grpcRejection{sequence: ack.sequence, message: ack.err}
+		}
+		acked[i] = true
+		bytesSent += int64(sizes[i])
+		return nil
+	}
+
+	for i, batch := range batches {
+		for len(pending) >= s.out.MaxPendingBatches {
+			if err := wait(); err != nil {
+				return fail(err)
+			}
+		}
+		message := encodeGRPCBatch(st.next, s.out.Format, batch)
+		pending[st.next] = i
+		st.next++
+		sizes[i] = len(message)
+		if err := st.send(message, s.timeout); err != nil {
+			return fail(err)
+		}
+	}
+	for len(pending) > 0 {
+		if err := wait(); err != nil {
+			return fail(err)
+		}
+	}
+	return bytesSent, nil, nil
+}
+
+// open starts a Stream call; its response is read in the background
+func (s *grpcSink) open() *grpcStream {
+	ctx, cancel := context.WithCancel(context.Background())
+	body, pipe := io.Pipe()
+	st := &grpcStream{
+		body:   pipe,
+		ctx:    ctx,
+		cancel: cancel,
+		acks:   make(chan grpcAck, s.out.MaxPendingBatches),
+		done:   make(chan struct{}),
+		next:   1,
+	}
+	go st.receive(s.client, newGRPCRequest(ctx, &s.out, body))
+	return st
+}
+
+// receive runs the call and queues the acks it returns until it ends
+func (st *grpcStream) receive(client *http.Client, req *http.Request) {
+	defer close(st.done)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		st.err = unwrapURLError(err)
+		return
+	}
+	defer resp.Body.Close()
+	if err := checkGRPCResponse(resp); err != nil {
+		st.err = err
+		return
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	for {
+		message, err := readGRPCMessage(reader)
+		if err == io.EOF {
+			st.err = grpcStatus(resp.Trailer)
+			if st.err == nil {
+				st.err = errors.New("receiver ended the stream")
+			}
+			return
+		}
+		if err != nil {
+			st.err = err
+			return
+		}
+		ack, err := decodeGRPCAck(message)
+		if err != nil {
+			st.err = err
+			return
+		}
+		select {
+		case st.acks <- ack:
+		case <-st.ctx.Done():
+			st.err = st.ctx.Err()
+			return
+		}
+	}
+}
+
+// send writes one message to the stream, failing when the receiver does
+// not take it within timeout
+func (st *grpcStream) send(message []byte, timeout time.Duration) error {
+	frame := make([]byte, 5+len(message))
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(message)))
+	copy(frame[5:], message)
+
+	timer := time.AfterFunc(timeout, func() { st.body.CloseWithError(context.DeadlineExceeded) })
+	_, err := st.body.Write(frame)
+	if !timer.Stop() {
+		return fmt.Errorf("receiver did not take a batch within %s", timeout)
+	}
+	if err != nil {
+		return st.failure(err)
+	}
+	return nil
+}
+
+// waitAck returns the next ack, failing when none arrives within timeout
+func (st *grpcStream) waitAck(ctx context.Context, timeout time.Duration) (grpcAck, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case ack := <-st.acks:
+		return ack, nil
+	case <-st.done:
+		// Acks queued before the call ended still count
+		select {
+		case ack := <-st.acks:
+			return ack, nil
+		default:
+			return grpcAck{}, st.err
+		}
+	case <-timer.C:
+		return grpcAck{}, fmt.Errorf("no acknowledgement from the receiver within %s", timeout)
+	case <-ctx.Done():
+		return grpcAck{}, ctx.Err()
+	}
+}
+
+// failure returns why the call ended, or err when it is still running
+func (st *grpcStream) failure(err error) error {
+	select {
+	case <-st.done:
+		if st.err != nil {
+			return st.err
+		}
+	case <-time.After(grpcCloseWait):
+	}
+	return err
+}
+
+// close half-closes the stream, gives the receiver a moment to end the
+// call, then cancels it
+func (st *grpcStream) close() {
+	st.body.Close()
+	select {
+	case <-st.done:
+	case <-time.After(grpcCloseWait):
+	}
+	st.cancel()
+}
+
+func (s *grpcSink) ReconnectCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reconnects
+}
+
+func (s *grpcSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stream != nil {
+		s.logger.Info("closing grpc stream")
+		s.stream.close()
+		s.stream = nil
+	}
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// newGRPCClient returns an HTTP/2 client for the output's receiver
+func newGRPCClient(out *config.GRPCOutput, timeout time.Duration) (*http.Client, error) {
+	tlsConfig, err := grpcTLSConfig(out)
+	if err != nil {
+		return nil, err
+	}
+	transport := &http.Transport{
+		DialTLSContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialGRPC(ctx, out.Address, tlsConfig, timeout)
+		},
+		ForceAttemptHTTP2: true,
+		IdleConnTimeout:   90 * time.Second,
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// grpcTLSConfig returns the TLS settings of the output, loading its CA
+// bundle and client certificate
+func grpcTLSConfig(out *config.GRPCOutput) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName: out.ServerName,
+		NextProtos: []string{"h2"},
+		MinVersion: tls.VersionTLS12,
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = out.Host()
+	}
+	if out.CAFile != "" {
+		pem, err := os.ReadFile(out.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read grpc.ca_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("grpc.ca_file %s holds no PEM certificates", out.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if out.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(out.CertFile, out.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load the grpc client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// dialGRPC connects to the receiver over TLS and checks it agreed to speak
+// HTTP/2, which gRPC runs on
+func dialGRPC(ctx context.Context, address string, tlsConfig *tls.Config, timeout time.Duration) (net.Conn, error) {
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second},
+		Config:    tlsConfig,
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to grpc receiver at %s: %w", address, err)
+	}
+	if protocol := conn.(*tls.Conn).ConnectionState().NegotiatedProtocol; protocol != "h2" {
+		conn.Close()
+		return nil, fmt.Errorf("grpc receiver at %s does not offer HTTP/2 over TLS", address)
+	}
+	return conn, nil
+}
+
+// newGRPCRequest returns the request of a Stream call sending body
+func newGRPCRequest(ctx context.Context, out *config.GRPCOutput, body io.ReadCloser) *http.Request {
+	req := &http.Request{
+		Method: http.MethodPost,
+		URL:    &url.URL{Scheme: "https", Host: out.Address, Path: grpcMethod},
+		Header: http.Header{
+			"Content-Type": {"application/grpc+proto"},
+			"Te":           {"trailers"},
+			"User-Agent":   {"cato-logger"},
+		},
+		Body: body,
+		Host: out.Address,
+	}
+	if out.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+out.Token)
+	}
+	return req.WithContext(ctx)
+}
+
+// checkGRPCResponse checks the response headers of a call; a call refused
+// outright carries its status in the headers
+func checkGRPCResponse(resp *http.Response) error {
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("grpc receiver answered HTTP %d", resp.StatusCode)
+	}
+	if resp.Header.Get("Grpc-Status") != "" {
+		if err := grpcStatus(resp.Header); err != nil {
+			return err
+		}
+		return errors.New("receiver ended the stream")
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/grpc") {
+		return fmt.Errorf("grpc receiver answered with content type '%s'", resp.Header.Get("Content-Type"))
+	}
+	return nil
+}
+
+// grpcStatus returns the error for the status a call ended with, nil for OK
+func grpcStatus(header http.Header) error {
+	code, err := strconv.Atoi(header.Get("Grpc-Status"))
+	if err != nil {
+		return errors.New("receiver ended the stream without a status")
+	}
+	if code == 0 {
+		return nil
+	}
+	message, err := url.PathUnescape(header.Get("Grpc-Message"))
+	if err != nil {
+		message = header.Get("Grpc-Message")
+	}
+	return &grpcStatusError{code: code, message: message}
+}
+
+// unwrapURLError drops the method and URL net/http adds to an error, which
+// are the same for every call
+func unwrapURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
+
+// readGRPCMessage reads one length-prefixed message; io.EOF means the
+// stream ended between messages
+func readGRPCMessage(r io.Reader) ([]byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return nil, err
+	}
+	if prefix[0] != 0 {
+		return nil, errors.New("receiver sent a compressed message, which is not supported")
+	}
+	size := binary.BigEndian.Uint32(prefix[1:])
+	if size > grpcMaxMessage {
+		return nil, fmt.Errorf("receiver sent a message of %d bytes, over the %d-byte limit", size, grpcMaxMessage)
+	}
+	message := make([]byte, size)
+	if _, err := io.ReadFull(r, message); err != nil {
+		return nil, fmt.Errorf("incomplete message from receiver: %w", err)
+	}
+	return message, nil
+}
+
+// Protobuf wire types
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+// appendProtoBytes appends a length-delimited field, leaving out empty
+// values like proto3 does
+func appendProtoBytes(b []byte, field int, value []byte) []byte {
+	if len(value) == 0 {
+		return b
+	}
+	b = binary.AppendUvarint(b, uint64(field)<<3|protoBytes)
+	b = binary.AppendUvarint(b, uint64(len(value)))
+	return append(b, value...)
+}
+
+// encodeGRPCEvent encodes an Event message
+func encodeGRPCEvent(feed, hostname string, payload []byte) []byte {
+	b := make([]byte, 0, len(feed)+len(hostname)+len(payload)+3*grpcEventOverhead)
+	b = appendProtoBytes(b, 1, []byte(feed))
+	b = appendProtoBytes(b, 2, []byte(hostname))
+	return appendProtoBytes(b, 3, payload)
+}
+
+// encodeGRPCBatch encodes an EventBatch message of encoded events
+func encodeGRPCBatch(sequence uint64, format string, events [][]byte) []byte {
+	size := grpcBatchOverhead
+	for _, event := range events {
+		size += len(event) + grpcEventOverhead
+	}
+	b := make([]byte, 0, size)
+	b = binary.AppendUvarint(b, 1<<3|protoVarint)
+	b = binary.AppendUvarint(b, sequence)
+	b = appendProtoBytes(b, 2, []byte(format))
+	for _, event := range events {
+		b = binary.AppendUvarint(b, 3<<3|protoBytes)
+		b = binary.AppendUvarint(b, uint64(len(event)))
+		b = append(b, event...)
+	}
+	return b
+}
+
+// decodeGRPCAck decodes an Ack message, skipping unknown fields
+func decodeGRPCAck(message []byte) (grpcAck, error) {
+	var ack grpcAck
+	malformed := errors.New("malformed acknowledgement from receiver")
+	for len(message) > 0 {
+		key, n := binary.Uvarint(message)
+		if n <= 0 {
+			return ack, malformed
+		}
+		message = message[n:]
+
+		switch key & 7 {
+		case protoVarint:
+			value, n := binary.Uvarint(message)
+			if n <= 0 {
+				return ack, malformed
+			}
+			message = message[n:]
+			if key>>3 == 1 {
+				ack.sequence = value
+			}
+		case protoBytes:
+			size, n := binary.Uvarint(message)
+			if n <= 0 || size > uint64(len(message)-n) {
+				return ack, malformed
+			}
+			value := message[n : n+int(size)]
+			message = message[n+int(size):]
+			if key>>3 == 2 {
+				ack.err = string(value)
+			}
+		case protoFixed64:
+			if len(message) < 8 {
+				return ack, malformed
+			}
+			message = message[8:]
+		case protoFixed32:
+			if len(message) < 4 {
+				return ack, malformed
+			}
+			message = message[4:]
+		default:
+			return ack, malformed
+		}
+	}
+	return ack, nil
+}
+
+// probeGRPC opens a Stream call and closes it without sending, which
+// checks TLS, the token and that the receiver implements the service
+func probeGRPC(ctx context.Context, out *config.GRPCOutput, timeout time.Duration) (string, error) {
+	client, err := newGRPCClient(out, timeout)
+	if err != nil {
+		return "", err
+	}
+	defer client.CloseIdleConnections()
+
+	// An empty body that is not http.NoBody ends the stream with an empty
+	// DATA frame; some servers never start a call whose HEADERS end it
+	start := time.Now()
+	resp, err := client.Do(newGRPCRequest(ctx, out, io.NopCloser(strings.NewReader(""))))
+	if err != nil {
+		return "", unwrapURLError(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("grpc receiver answered HTTP %d", resp.StatusCode)
+	}
+	status := resp.Header
+	if status.Get("Grpc-Status") == "" {
+		if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+			return "", err
+		}
+		status = resp.Trailer
+	}
+	if err := grpcStatus(status); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("grpc receiver at %s accepted a stream over TLS (%dms)", out.Address, time.Since(start).Milliseconds()), nil
+}
@@ -0,0 +1,213 @@
+package output
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cato-logger/internal/config"
+	"cato-logger/internal/logging"
+)
+
+// lokiMaxBytes keeps push requests well below the 4 MB message limit of
+// Loki's distributors, leaving room for the stream labels
+const lokiMaxBytes = 3 << 20
+
+// lokiSink pushes events to Grafana Loki as log lines, grouped into
+// streams by their labels
+type lokiSink struct {
+	name       string
+	out        config.LokiOutput
+	labelNames []string // Sorted, to key the streams of a request
+	formatter  batchFormatter
+	client     *http.Client
+	logger     *logging.Logger
+}
+
+// lokiStream is one stream of a push request: its labels and its
+// [timestamp, line] pairs
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values []json.RawMessage `json:"values"`
+}
+
+// newLokiSink creates a sink for the output's Loki instance
+func newLokiSink(out config.Output, opts Options, logger *logging.Logger) (*lokiSink, error) {
+	names := make([]string, 0, len(out.Loki.Labels))
+	for name := range out.Loki.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return &lokiSink{
+		name:       out.Name,
+		out:        *out.Loki,
+		labelNames: names,
+		formatter:  newBatchFormatter(opts, out.Loki.Format),
+		client:     &http.Client{Timeout: opts.ConnTimeout},
+		logger:     logger,
+	}, nil
+}
+
+func (s *lokiSink) Name() string {
+	return s.name
+}
+
+func (s *lokiSink) Type() string {
+	return "loki"
+}
+
+// Write pushes records as log lines in batches of batch_size, splitting
+// batches that would exceed the request size limit. Each line is stamped
+// with the event's time.
+func (s *lokiSink) Write(ctx context.Context, records []Record) (int64, error) {
+	lines, err := s.formatter.FormatAll(records)
+	if err != nil {
+		return 0, err
+	}
+	entries := make([][]byte, len(records))
+	for i, record := range records {
+		timestamp := strconv.FormatInt(eventTime(record.Fields).UnixNano(), 10)
+		entry, err := json.Marshal([2]string{timestamp, string(lines[i])})
+		if err != nil {
+			return 0, fmt.Errorf("failed to encode log line: %w", err)
+		}
+		if len(entry) > lokiMaxBytes {
+			return 0, fmt.Errorf("event of %d bytes exceeds the loki request limit of %d bytes", len(entry), lokiMaxBytes)
+		}
+		entries[i] = entry
+	}
+
+	var bytesSent int64
+	start := 0
+	for _, batch := range splitBatches(entries, s.out.BatchSize, lokiMaxBytes, 1) {
+		streams := s.streams(records[start:start+len(batch)], batch)
+		start += len(batch)
+
+		body, err := json.Marshal(struct {
+			Streams []*lokiStream `json:"streams"`
+		}{streams})
+		if err != nil {
+			return bytesSent, err
+		}
+		if err := s.push(ctx, body); err != nil {
+			return bytesSent, err
+		}
+		s.logger.DebugContext(ctx, "pushed batch to loki", "lines", len(batch), "streams", len(streams), "bytes", len(body))
+		bytesSent += int64(len(body))
+	}
+	return bytesSent, nil
+}
+
+// streams groups the entries of a batch by the labels of their records,
+// in order of first appearance
+func (s *lokiSink) streams(records []Record, entries [][]byte) []*lokiStream {
+	var streams []*lokiStream
+	byKey := make(map[string]*lokiStream)
+	values := make([]string, len(s.labelNames))
+	for i, record := range records {
+		for j, name := range s.labelNames {
+			values[j] = expandLabel(s.out.Labels[name], record.Fields)
+		}
+		key := strings.Join(values, "\xff")
+
+		stream, ok := byKey[key]
+		if !ok {
+			labels := make(map[string]string, len(values))
+			for j, name := range s.labelNames {
+				labels[name] = values[j]
+			}
+			stream = &lokiStream{Stream: labels}
+			byKey[key] = stream
+			streams = append(streams, stream)
+		}
+		stream.Values = append(stream.Values, entries[i])
+	}
+	return streams
+}
+
+// expandLabel fills the {field} placeholders of a label value from the
+// event's fields. Missing or empty fields become "unknown".
+func expandLabel(template string, fields map[string]string) string {
+	if !strings.Contains(template, "{") {
+		return template
+	}
+	return routePlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		if value := fields[placeholder[1:len(placeholder)-1]]; value != "" {
+			return value
+		}
+		return "unknown"
+	})
+}
+
+// push sends one push API request
+func (s *lokiSink) push(ctx context.Context, body []byte) error {
+	req, err := newLokiRequest(ctx, &s.out, body)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("loki request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("loki rejected the batch with status %d: %s", resp.StatusCode, bytes.TrimSpace(respBody))
+	}
+	return nil
+}
+
+// newLokiRequest builds a push request posting body for the output's
+// tenant, with its credentials
+func newLokiRequest(ctx context.Context, out *config.LokiOutput, body []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", out.PushURL(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if out.TenantID != "" {
+		req.Header.Set("X-Scope-OrgID", out.TenantID)
+	}
+	switch {
+	case out.Token != "":
+		req.Header.Set("Authorization", "Bearer "+out.Token)
+	case out.User != "":
+		req.SetBasicAuth(out.User, out.Password)
+	}
+	return req, nil
+}
+
+func (s *lokiSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// probeLoki pushes a request without streams, which checks the push URL,
+// the credentials and the tenant without storing anything
+func probeLoki(ctx context.Context, out *config.LokiOutput, timeout time.Duration) (string, error) {
+	req, err := newLokiRequest(ctx, out, []byte(`{"streams":[]}`))
+	if err != nil {
+		return "", err
+	}
+	client := &http.Client{Timeout: timeout}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("loki request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("loki refused a push with status %d: %s", resp.StatusCode, bytes.TrimSpace(respBody))
+	}
+	return fmt.Sprintf("loki accepted a push at %s (%dms)", out.PushURL(), time.Since(start).Milliseconds()), nil
+}
@@ -0,0 +1,425 @@
+package output
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cato-logger/internal/config"
+	"cato-logger/internal/logging"
+)
+
+// natsClientVersion is reported to the server in CONNECT
+const natsClientVersion = "1.0"
+
+// natsSink publishes events to NATS JetStream, waiting for the stream to
+// acknowledge every message. Publishes are pipelined up to max_pending
+// unacknowledged messages. Writes are serialized.
+type natsSink struct {
+	mu          sync.Mutex
+	name        string
+	out         config.NATSOutput
+	formatter   batchFormatter
+	timeout     time.Duration
+	conn        *natsConn
+	reconnects  int
+	onReconnect func(output string, err error)
+	logger      *logging.Logger
+}
+
+// natsConn is one client connection to a NATS server
+type natsConn struct {
+	conn       net.Conn
+	reader     *bufio.Reader
+	writer     *bufio.Writer
+	inbox      string // Prefix of the reply subjects acknowledgements arrive on
+	maxPayload int
+	next       uint64 // Sequence for the next reply subject
+}
+
+// natsInfo holds the fields of the server's INFO message used by the client
+type natsInfo struct {
+	ServerName  string `json:"server_name"`
+	Version     string `json:"version"`
+	TLSRequired bool   `json:"tls_required"`
+	Headers     bool   `json:"headers"`
+	MaxPayload  int    `json:"max_payload"`
+}
+
+// natsPubAck is JetStream's reply to a publish
+type natsPubAck struct {
+	Stream string         `json:"stream"`
+	Seq    uint64         `json:"seq"`
+	Error  *natsRejection `json:"error"`
+}
+
+// natsRejection is a publish the server refused; retrying it on a new
+// connection would not help
+type natsRejection struct {
+	Code        int    `json:"code"`
+	Description string `json:"description"`
+}
+
+func (e *natsRejection) Error() string {
+	return fmt.Sprintf("jetstream rejected the message: %s (%d)", e.Description, e.Code)
+}
+
+// newNATSSink connects to the output's NATS server
+func newNATSSink(out config.Output, opts Options, logger *logging.Logger) (*natsSink, error) {
+	s := &natsSink{
+		name:        out.Name,
+		out:         *out.NATS,
+		formatter:   newBatchFormatter(opts, out.NATS.Format),
+		timeout:     opts.ConnTimeout,
+		onReconnect: opts.OnReconnect,
+		logger:      logger,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	conn, info, err := dialNATS(ctx, &s.out, s.timeout)
+	if err != nil {
+		return nil, err
+	}
+	s.conn = conn
+	logger.Info("connected to nats server", "server", info.ServerName, "version", info.Version)
+	return s, nil
+}
+
+func (s *natsSink) Name() string {
+	return s.name
+}
+
+func (s *natsSink) Type() string {
+	return "nats"
+}
+
+// Write publishes each record and waits for JetStream to acknowledge all of
+// them. A lost connection is re-established once per call.
+func (s *natsSink) Write(ctx context.Context, records []Record) (int64, error) {
+	bodies, err := s.formatter.FormatAll(records)
+	if err != nil {
+		return 0, err
+	}
+	messages := make([]natsMessage, len(records))
+	for i, record := range records {
+		messages[i] = natsMessage{subject: expandRoute(s.out.Subject, record.Fields), body: bodies[i]}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var bytesSent int64
+	for start := 0; start < len(messages); start += s.out.MaxPending {
+		end := start + s.out.MaxPending
+		if end > len(messages) {
+			end = len(messages)
+		}
+
+		// The connection is dropped after a failed reconnect
+		if s.conn == nil {
+			if err := s.reconnect(ctx); err != nil {
+				return bytesSent, fmt.Errorf("reconnection failed: %w", err)
+			}
+		}
+
+		n, err := s.publish(ctx, messages[start:end])
+		if err != nil {
+			var rejected *natsRejection
+			if errors.As(err, &rejected) {
+				return bytesSent, err
+			}
+			s.logger.WarnContext(ctx, "nats publish failed, attempting reconnect", "error", err.Error())
+			if err := s.reconnect(ctx); err != nil {
+				return bytesSent, fmt.Errorf("reconnection failed: %w", err)
+			}
+			if n, err = s.publish(ctx, messages[start:end]); err != nil {
+				return bytesSent, fmt.Errorf("publish failed after reconnect: %w", err)
+			}
+		}
+		bytesSent += n
+	}
+	return bytesSent, nil
+}
+
+// natsMessage is one message to publish
+type natsMessage struct {
+	subject string
+	body    []byte
+}
+
+// publish sends messages and reads their acknowledgements
+func (s *natsSink) publish(ctx context.Context, messages []natsMessage) (int64, error) {
+	c := s.conn
+
+	deadline := time.Now().Add(s.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	c.conn.SetDeadline(deadline)
+	defer c.conn.SetDeadline(time.Time{})
+
+	first := c.next
+	var bytesSent int64
+	for _, msg := range messages {
+		if c.maxPayload > 0 && len(msg.body) > c.maxPayload {
+			return 0, &natsRejection{Code: 413, Description: fmt.Sprintf("message of %d bytes exceeds the server's max_payload of %d bytes", len(msg.body), c.maxPayload)}
+		}
+		reply := c.inbox + strconv.FormatUint(c.next, 10)
+		c.next++
+
+		if s.out.Stream != "" {
+			header := "NATS/1.0\r\nNats-Expected-Stream: " + s.out.Stream + "\r\n\r\n"
+			fmt.Fprintf(c.writer, "HPUB %s %s %d %d\r\n%s", msg.subject, reply, len(header), len(header)+len(msg.body), header)
+		} else {
+			fmt.Fprintf(c.writer, "PUB %s %s %d\r\n", msg.subject, reply, len(msg.body))
+		}
+		c.writer.Write(msg.body)
+		c.writer.WriteString("\r\n")
+		bytesSent += int64(len(msg.body))
+	}
+	if err := c.writer.Flush(); err != nil {
+		return 0, err
+	}
+
+	for acked := 0; acked < len(messages); {
+		seq, ack, err := c.readReply()
+		if err != nil {
+			return 0, err
+		}
+		if seq < first || seq >= first+uint64(len(messages)) {
+			continue // Late acknowledgement of an earlier, failed call
+		}
+		if ack.Error != nil {
+			return 0, ack.Error
+		}
+		acked++
+	}
+	return bytesSent, nil
+}
+
+// reconnect replaces the connection
+func (s *natsSink) reconnect(ctx context.Context) error {
+	if s.conn != nil {
+		s.conn.conn.Close()
+		s.conn = nil
+	}
+	s.reconnects++
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	conn, _, err := dialNATS(ctx, &s.out, s.timeout)
+	if s.onReconnect != nil {
+		s.onReconnect(s.name, err)
+	}
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	s.logger.InfoContext(ctx, "reconnected to nats server")
+	return nil
+}
+
+func (s *natsSink) ReconnectCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reconnects
+}
+
+func (s *natsSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	s.logger.Info("closing nats connection")
+	err := s.conn.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// dialNATS connects and authenticates to the output's server, upgrading to
+// TLS when the URL or the server asks for it, and subscribes to the inbox
+func dialNATS(ctx context.Context, out *config.NATSOutput, timeout time.Duration) (*natsConn, *natsInfo, error) {
+	u, err := url.Parse(out.URL)
+	if err != nil {
+		return nil, nil, err
+	}
+	address := busAddress(u.Hostname(), u.Port(), "4222")
+
+	raw, err := dialBus(ctx, address, false, timeout)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot connect to nats server at %s: %w", address, err)
+	}
+	ok := false
+	defer func() {
+		if !ok {
+			raw.Close()
+		}
+	}()
+	raw.SetDeadline(time.Now().Add(timeout))
+
+	c := &natsConn{conn: raw, reader: bufio.NewReader(raw)}
+	line, err := c.readLine()
+	if err != nil {
+		return nil, nil, fmt.Errorf("no INFO from nats server: %w", err)
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		return nil, nil, fmt.Errorf("unexpected greeting from nats server: %.40q", line)
+	}
+	var info natsInfo
+	if err := json.Unmarshal([]byte(line[5:]), &info); err != nil {
+		return nil, nil, fmt.Errorf("invalid INFO from nats server: %w", err)
+	}
+	if out.Stream != "" && !info.Headers {
+		return nil, nil, fmt.Errorf("nats server does not support headers, required by nats.stream")
+	}
+
+	if u.Scheme == "tls" || info.TLSRequired {
+		tlsConn := tls.Client(raw, &tls.Config{ServerName: u.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return nil, nil, fmt.Errorf("tls handshake with nats server failed: %w", err)
+		}
+		c.conn = tlsConn
+		c.reader = bufio.NewReader(tlsConn)
+	}
+	c.writer = bufio.NewWriter(c.conn)
+	c.maxPayload = info.MaxPayload
+
+	connect, err := json.Marshal(map[string]any{
+		"verbose":       false,
+		"pedantic":      false,
+		"tls_required":  u.Scheme == "tls" || info.TLSRequired,
+		"name":          "cato-logger",
+		"lang":          "go",
+		"version":       natsClientVersion,
+		"protocol":      1,
+		"headers":       info.Headers,
+		"no_responders": info.Headers,
+		"user":          out.User,
+		"pass":          out.Password,
+		"auth_token":    out.Token,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	id := make([]byte, 8)
+	rand.Read(id)
+	c.inbox = "_INBOX." + hex.EncodeToString(id) + "."
+
+	fmt.Fprintf(c.writer, "CONNECT %s\r\nSUB %s* 1\r\nPING\r\n", connect, c.inbox)
+	if err := c.writer.Flush(); err != nil {
+		return nil, nil, err
+	}
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return nil, nil, fmt.Errorf("nats handshake failed: %w", err)
+		}
+		if line == "PONG" {
+			break
+		}
+		if strings.HasPrefix(line, "-ERR") {
+			return nil, nil, fmt.Errorf("nats server refused the connection: %s", strings.TrimSpace(line[4:]))
+		}
+	}
+
+	raw.SetDeadline(time.Time{})
+	ok = true
+	return c, &info, nil
+}
+
+// readLine reads one protocol line without its CRLF
+func (c *natsConn) readLine() (string, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readReply reads until a message arrives on the inbox, answering server
+// pings, and returns its reply sequence and decoded acknowledgement
+func (c *natsConn) readReply() (uint64, *natsPubAck, error) {
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "PING":
+			c.writer.WriteString("PONG\r\n")
+			if err := c.writer.Flush(); err != nil {
+				return 0, nil, err
+			}
+		case "-ERR":
+			return 0, nil, fmt.Errorf("nats server error: %s", strings.TrimSpace(line[4:]))
+		case "MSG", "HMSG":
+			// MSG <subject> <sid> [reply] <size>
+			// HMSG <subject> <sid> [reply] <header size> <total size>
+			size, err := strconv.Atoi(fields[len(fields)-1])
+			if err != nil || len(fields) < 4 {
+				return 0, nil, fmt.Errorf("malformed nats message line: %.80q", line)
+			}
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(c.reader, payload); err != nil {
+				return 0, nil, err
+			}
+			payload = payload[:size]
+
+			seq, err := strconv.ParseUint(strings.TrimPrefix(fields[1], c.inbox), 10, 64)
+			if err != nil || !strings.HasPrefix(fields[1], c.inbox) {
+				continue
+			}
+
+			var ack natsPubAck
+			if fields[0] == "HMSG" {
+				headerSize, _ := strconv.Atoi(fields[len(fields)-2])
+				if headerSize > size {
+					return 0, nil, fmt.Errorf("malformed nats message line: %.80q", line)
+				}
+				status := strings.Fields(string(bytes.SplitN(payload[:headerSize], []byte("\r\n"), 2)[0]))
+				if len(status) >= 2 && status[1] == "503" {
+					ack.Error = &natsRejection{Code: 503, Description: "no stream captures the subject"}
+					return seq, &ack, nil
+				}
+				payload = payload[headerSize:]
+			}
+			if err := json.Unmarshal(payload, &ack); err != nil {
+				return 0, nil, fmt.Errorf("invalid jetstream acknowledgement: %w", err)
+			}
+			return seq, &ack, nil
+		}
+	}
+}
+
+// probeNATS connects and authenticates to the NATS server
+func probeNATS(ctx context.Context, out *config.NATSOutput, timeout time.Duration) (string, error) {
+	start := time.Now()
+	conn, info, err := dialNATS(ctx, out, timeout)
+	if err != nil {
+		return "", err
+	}
+	conn.conn.Close()
+	return fmt.Sprintf("nats server %s (%s) accepted the connection (%dms)",
+		info.ServerName, info.Version, time.Since(start).Milliseconds()), nil
+}
@@ -0,0 +1,151 @@
+package output
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cato-logger/internal/config"
+	"cato-logger/internal/logging"
+)
+
+// Record is one event ready for delivery
+type Record struct {
+	Feed     string            // Feed the event came from, selecting its CEF mapping profile
+	Fields   map[string]string // Event fields after the pre-formatting stages
+	Hostname string            // Source host for transport headers
+}
+
+// Sink delivers records to one configured output
+type Sink interface {
+	Name() string
+	Type() string
+	// Write delivers records in order and returns the number of bytes sent
+	Write(ctx context.Context, records []Record) (int64, error)
+	Close() error
+}
+
+// Reconfigurer is implemented by sinks that can adopt reloaded settings
+type Reconfigurer interface {
+	Reconfigure(cfg *config.Config)
+}
+
+// Reconnector is implemented by connection-oriented sinks
+type Reconnector interface {
+	ReconnectCount() int
+}
+
+// Queuer is implemented by sinks that write from a queue in the background
+type Queuer interface {
+	QueueLen() int
+}
+
+// Acknowledger is implemented by sinks whose Write can return before the
+// records are delivered, such as a syslog output with a queue. Sent counts
+// the messages Write has accepted and Acked those delivered since, in
+// order, so everything written before Sent returned n is delivered once
+// Acked reaches n.
+type Acknowledger interface {
+	Sent() int64
+	Acked() int64
+}
+
+// Options holds settings shared by all sinks
+type Options struct {
+	ConnTimeout   time.Duration
+	Logger        *logging.Logger
+	OnReconnect   func(output string, err error) // Called after every reconnect attempt, err is nil on success
+	OnDrop        func(output string)            // Called for every message a full queue discards
+	OnTruncate    func(output string)            // Called for every message cut to max_message_size
+	Formats       *Formats                       // Formatting state shared by all outputs
+	FormatWorkers int                            // Goroutines formatting a large batch, 0 or 1 formats serially
+}
+
+// Build creates a sink for each output, closing any already created on error
+func Build(outputs []config.Output, opts Options) ([]Sink, error) {
+	var sinks []Sink
+	for _, out := range outputs {
+		sink, err := newSink(out, opts)
+		if err != nil {
+			for _, created := range sinks {
+				created.Close()
+			}
+			return nil, fmt.Errorf("output %s: %w", out.Name, err)
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks, nil
+}
+
+// newSink creates the sink for an output's type
+func newSink(out config.Output, opts Options) (Sink, error) {
+	logger := opts.Logger.Component(out.Type).With("output", out.Name)
+
+	switch out.Type {
+	case "syslog":
+		return newSyslogSink(out, opts, logger)
+	case "sentinel":
+		return newSentinelSink(out, opts, logger)
+	case "chronicle":
+		return newChronicleSink(out, opts, logger)
+	case "file":
+		return newFileSink(out, opts, logger)
+	case "nats":
+		return newNATSSink(out, opts, logger)
+	case "amqp":
+		return newAMQPSink(out, opts, logger)
+	case "firehose":
+		return newFirehoseSink(out, opts, logger)
+	case "s3":
+		return newS3Sink(out, opts, logger)
+	case "grpc":
+		return newGRPCSink(out, opts, logger)
+	case "loki":
+		return newLokiSink(out, opts, logger)
+	case "datadog":
+		return newDatadogSink(out, opts, logger)
+	default:
+		return nil, fmt.Errorf("unsupported output type: %s", out.Type)
+	}
+}
+
+// Probe verifies an output is reachable (and, where applicable, that its
+// credentials are accepted) without delivering events. It returns a summary.
+func Probe(ctx context.Context, out config.Output, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	switch out.Type {
+	case "syslog":
+		return probeSyslog(ctx, out.Syslog)
+	case "sentinel":
+		return probeSentinel(ctx, out.Sentinel, timeout)
+	case "chronicle":
+		return probeChronicle(ctx, out.Chronicle, timeout)
+	case "file":
+		return probeFile(out.Name, out.File)
+	case "nats":
+		return probeNATS(ctx, out.NATS, timeout)
+	case "amqp":
+		return probeAMQP(ctx, out.AMQP, timeout)
+	case "firehose":
+		return probeFirehose(ctx, out.Firehose, timeout)
+	case "s3":
+		return probeS3(ctx, out.S3, timeout)
+	case "grpc":
+		return probeGRPC(ctx, out.GRPC, timeout)
+	case "loki":
+		return probeLoki(ctx, out.Loki, timeout)
+	case "datadog":
+		return probeDatadog(ctx, out.Datadog, timeout)
+	default:
+		return "", fmt.Errorf("unsupported output type: %s", out.Type)
+	}
+}
+
+// CloseAll closes every sink
+func CloseAll(sinks []Sink) {
+	for _, sink := range sinks {
+		sink.Close()
+	}
+}
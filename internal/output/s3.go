@@ -0,0 +1,160 @@
+package output
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"cato-logger/internal/config"
+	"cato-logger/internal/logging"
+	"cato-logger/internal/objstore"
+)
+
+// s3Sink writes each batch of events as new objects in an S3 bucket, one
+// object per partition, with one event per line. Keys follow the Hive
+// key=value layout so Athena or Glue can prune partitions.
+type s3Sink struct {
+	name      string
+	out       config.S3Output
+	formatter batchFormatter
+	store     objstore.Store
+	logger    *logging.Logger
+}
+
+// newS3Sink creates a sink for the output's bucket
+func newS3Sink(out config.Output, opts Options, logger *logging.Logger) (*s3Sink, error) {
+	return &s3Sink{
+		name:      out.Name,
+		out:       *out.S3,
+		formatter: newBatchFormatter(opts, out.S3.Format),
+		store:     newS3OutputStore(out.S3, opts.ConnTimeout),
+		logger:    logger,
+	}, nil
+}
+
+// newS3OutputStore returns the object store for an S3 output
+func newS3OutputStore(out *config.S3Output, timeout time.Duration) objstore.Store {
+	provider := newAWSProvider(out.Credentials, out.Region, &http.Client{Timeout: timeout})
+	return objstore.NewS3Store(out.Bucket, out.Region, out.Endpoint, provider)
+}
+
+func (s *s3Sink) Name() string {
+	return s.name
+}
+
+func (s *s3Sink) Type() string {
+	return "s3"
+}
+
+// Write groups records by partition and writes one new object per partition
+func (s *s3Sink) Write(ctx context.Context, records []Record) (int64, error) {
+	lines, err := s.formatter.FormatAll(records)
+	if err != nil {
+		return 0, err
+	}
+	partitions := make(map[string]*bytes.Buffer)
+	for i, record := range records {
+		line := lines[i]
+		partition := s3Partition(s.out.Partition, record.Fields)
+		buf, ok := partitions[partition]
+		if !ok {
+			buf = &bytes.Buffer{}
+			partitions[partition] = buf
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	names := make([]string, 0, len(partitions))
+	for partition := range partitions {
+		names = append(names, partition)
+	}
+	sort.Strings(names)
+
+	var bytesSent int64
+	for _, partition := range names {
+		data := partitions[partition].Bytes()
+		if s.out.Compress {
+			compressed, err := gzipBytes(data)
+			if err != nil {
+				return bytesSent, fmt.Errorf("failed to compress object: %w", err)
+			}
+			data = compressed
+		}
+
+		key := s.objectKey(partition)
+		if _, err := s.store.Put(ctx, key, data, objstore.Condition{Absent: true}); err != nil {
+			return bytesSent, fmt.Errorf("failed to write s3://%s/%s: %w", s.out.Bucket, key, err)
+		}
+		s.logger.DebugContext(ctx, "wrote object to s3", "key", key, "bytes", len(data))
+		bytesSent += int64(len(data))
+	}
+	return bytesSent, nil
+}
+
+// objectKey returns a new, unique key in a partition
+func (s *s3Sink) objectKey(partition string) string {
+	id := make([]byte, 6)
+	rand.Read(id)
+
+	extension := ".json"
+	if isText(s.out.Format) {
+		extension = ".log"
+	}
+	if s.out.Compress {
+		extension += ".gz"
+	}
+	return fmt.Sprintf("%s%s/%s-%s%s", s.out.Prefix, partition,
+		time.Now().UTC().Format("20060102T150405Z"), hex.EncodeToString(id), extension)
+}
+
+// s3Partition expands a partition template for an event: {date} and {hour}
+// from its time (UTC), other placeholders from its fields
+func s3Partition(template string, fields map[string]string) string {
+	t := eventTime(fields)
+	template = strings.NewReplacer(
+		"{date}", t.Format("2006-01-02"),
+		"{hour}", t.Format("15"),
+	).Replace(template)
+	return strings.Trim(expandRoute(template, fields), "/")
+}
+
+// gzipBytes compresses data
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(data); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *s3Sink) Close() error {
+	return nil
+}
+
+// probeS3 writes and deletes a small object under the output's prefix
+func probeS3(ctx context.Context, out *config.S3Output, timeout time.Duration) (string, error) {
+	store := newS3OutputStore(out, timeout)
+	key := out.Prefix + ".preflight"
+
+	start := time.Now()
+	if _, err := store.Put(ctx, key, []byte("cato-logger pre-flight check\n"), objstore.Condition{}); err != nil {
+		return "", fmt.Errorf("cannot write to s3://%s/%s: %w", out.Bucket, key, err)
+	}
+	elapsed := time.Since(start)
+	if err := store.Delete(ctx, key); err != nil {
+		return "", fmt.Errorf("cannot delete s3://%s/%s: %w", out.Bucket, key, err)
+	}
+	return fmt.Sprintf("s3 bucket %s is writable (put %dms)", out.Bucket, elapsed.Milliseconds()), nil
+}
@@ -0,0 +1,220 @@
+package output
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"cato-logger/internal/azureauth"
+	"cato-logger/internal/config"
+	"cato-logger/internal/logging"
+)
+
+const (
+	// monitorResource is the Azure AD resource of the Logs Ingestion API
+	monitorResource = "https://monitor.azure.com"
+
+	// Request body limits, below the documented 30 MB (Data Collector API)
+	// and 1 MB (Logs Ingestion API)
+	dataCollectorMaxBytes = 25 << 20
+	ingestionMaxBytes     = 1000 << 10
+
+	// timeGeneratedField carries the event time into the TimeGenerated column
+	timeGeneratedField = "TimeGenerated"
+)
+
+// sentinelSink posts events to a Log Analytics workspace, through the HTTP
+// Data Collector API with a shared key or the Logs Ingestion API with an
+// Azure AD token. Each event is sent as a JSON object of its fields.
+type sentinelSink struct {
+	name      string
+	out       config.SentinelOutput
+	sharedKey []byte
+	tokens    *azureauth.TokenSource // Logs Ingestion API only
+	client    *http.Client
+	logger    *logging.Logger
+}
+
+// newSentinelSink creates a sink for the output's workspace or DCR
+func newSentinelSink(out config.Output, opts Options, logger *logging.Logger) (*sentinelSink, error) {
+	client := &http.Client{Timeout: opts.ConnTimeout}
+	s := &sentinelSink{
+		name:   out.Name,
+		out:    *out.Sentinel,
+		client: client,
+		logger: logger,
+	}
+
+	if s.out.UsesIngestionAPI() {
+		s.tokens = azureauth.NewTokenSource(monitorResource, client)
+		return s, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(s.out.SharedKey)
+	if err != nil {
+		return nil, fmt.Errorf("sentinel shared key is not valid base64: %w", err)
+	}
+	s.sharedKey = key
+	return s, nil
+}
+
+func (s *sentinelSink) Name() string {
+	return s.name
+}
+
+func (s *sentinelSink) Type() string {
+	return "sentinel"
+}
+
+// Write posts records in batches of batch_size events, splitting batches
+// that would exceed the API's request size limit
+func (s *sentinelSink) Write(ctx context.Context, records []Record) (int64, error) {
+	maxBytes := dataCollectorMaxBytes
+	if s.out.UsesIngestionAPI() {
+		maxBytes = ingestionMaxBytes
+	}
+
+	events := make([][]byte, len(records))
+	for i, record := range records {
+		event, err := json.Marshal(sentinelEvent(record.Fields))
+		if err != nil {
+			return 0, fmt.Errorf("failed to encode event: %w", err)
+		}
+		if len(event)+2 > maxBytes {
+			return 0, fmt.Errorf("event of %d bytes exceeds the sentinel request limit of %d bytes", len(event), maxBytes)
+		}
+		events[i] = event
+	}
+
+	var bytesSent int64
+	for _, batch := range splitBatches(events, s.out.BatchSize, maxBytes-2, 1) {
+		body := append([]byte{'['}, bytes.Join(batch, []byte{','})...)
+		body = append(body, ']')
+		if err := s.post(ctx, body); err != nil {
+			return bytesSent, err
+		}
+		s.logger.DebugContext(ctx, "posted batch to sentinel", "events", len(batch), "bytes", len(body))
+		bytesSent += int64(len(body))
+	}
+	return bytesSent, nil
+}
+
+// sentinelEvent returns the JSON object posted for an event: its fields plus
+// TimeGenerated from the event's time field
+func sentinelEvent(fields map[string]string) map[string]string {
+	event := make(map[string]string, len(fields)+1)
+	for key, value := range fields {
+		event[key] = value
+	}
+
+	event[timeGeneratedField] = eventTime(fields).Format(time.RFC3339Nano)
+	return event
+}
+
+// post sends one batch to the configured API
+func (s *sentinelSink) post(ctx context.Context, body []byte) error {
+	req, err := s.newRequest(ctx, body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sentinel request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("sentinel rejected the batch with status %d: %s", resp.StatusCode, bytes.TrimSpace(respBody))
+	}
+	return nil
+}
+
+// newRequest builds an authorized request posting body
+func (s *sentinelSink) newRequest(ctx context.Context, body []byte) (*http.Request, error) {
+	if s.out.UsesIngestionAPI() {
+		token, err := s.tokens.Token(ctx)
+		if err != nil {
+			return nil, err
+		}
+		endpoint := fmt.Sprintf("%s/dataCollectionRules/%s/streams/%s?api-version=2023-01-01",
+			strings.TrimRight(s.out.Endpoint, "/"), url.PathEscape(s.out.RuleID), url.PathEscape(s.out.StreamName()))
+		req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		return req, nil
+	}
+
+	endpoint := fmt.Sprintf("https://%s/api/logs?api-version=2016-04-01", s.out.Host())
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	date := time.Now().UTC().Format(http.TimeFormat)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Log-Type", s.out.Table)
+	req.Header.Set("x-ms-date", date)
+	req.Header.Set("time-generated-field", timeGeneratedField)
+	req.Header.Set("Authorization", "SharedKey "+s.out.WorkspaceID+":"+s.signature(len(body), date))
+	return req, nil
+}
+
+// signature computes the Data Collector API shared key signature
+func (s *sentinelSink) signature(contentLength int, date string) string {
+	stringToSign := "POST\n" + strconv.Itoa(contentLength) + "\napplication/json\nx-ms-date:" + date + "\n/api/logs"
+	mac := hmac.New(sha256.New, s.sharedKey)
+	mac.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (s *sentinelSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// probeSentinel checks the API host accepts TLS connections and, for the
+// Logs Ingestion API, that an Azure AD token can be obtained. The shared key
+// of the Data Collector API can only be verified by posting data.
+func probeSentinel(ctx context.Context, out *config.SentinelOutput, timeout time.Duration) (string, error) {
+	host := out.Host()
+	address := net.JoinHostPort(host, "443")
+	if u, err := url.Parse(out.Endpoint); err == nil && out.UsesIngestionAPI() && u.Port() != "" {
+		address = u.Host
+	}
+
+	dialer := &tls.Dialer{NetDialer: &net.Dialer{}}
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return "", fmt.Errorf("cannot connect to sentinel at %s: %w", host, err)
+	}
+	conn.Close()
+	connectTime := time.Since(start)
+
+	if !out.UsesIngestionAPI() {
+		return fmt.Sprintf("sentinel workspace is reachable at %s (connect %dms)", host, connectTime.Milliseconds()), nil
+	}
+
+	tokens := azureauth.NewTokenSource(monitorResource, &http.Client{Timeout: timeout})
+	if _, err := tokens.Token(ctx); err != nil {
+		return "", fmt.Errorf("cannot obtain an Azure AD token for the Logs Ingestion API: %w", err)
+	}
+	return fmt.Sprintf("sentinel ingestion endpoint is reachable at %s (connect %dms), Azure AD token acquired",
+		host, connectTime.Milliseconds()), nil
+}
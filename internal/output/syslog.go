@@ -0,0 +1,263 @@
+package output
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"cato-logger/internal/config"
+	"cato-logger/internal/logging"
+	"cato-logger/internal/syslog"
+)
+
+// syslogQueueDrainTimeout is how long closing a queued syslog output waits
+// for the queued messages to be written
+const syslogQueueDrainTimeout = 10 * time.Second
+
+// syslogSink forwards messages, CEF by default, to a syslog server. Writes
+// are serialized so feeds and accounts fetched in parallel can share it.
+// With a queue, Write only enqueues and a background goroutine sends.
+type syslogSink struct {
+	mu         sync.Mutex
+	name       string
+	formatter  batchFormatter
+	writer     *syslog.Writer
+	queue      *syslog.Queue // nil when writing synchronously
+	maxSize    int           // Bytes, including the syslog header
+	onTruncate func()        // Called for every message cut to maxSize
+	logger     *logging.Logger
+}
+
+// newSyslogSink connects to the output's syslog server
+func newSyslogSink(out config.Output, opts Options, logger *logging.Logger) (*syslogSink, error) {
+	writerOpts := syslog.WriterOptions{
+		ConnTimeout:  opts.ConnTimeout,
+		WriteTimeout: time.Duration(out.Syslog.WriteTimeout) * time.Second,
+		MaxAge:       time.Duration(out.Syslog.MaxConnAge) * time.Second,
+		MaxBackoff:   time.Duration(out.Syslog.MaxReconnectDelay) * time.Second,
+		BindAddress:  out.Syslog.BindAddress,
+		IPPreference: out.Syslog.IPPreference,
+	}
+	if out.Syslog.HealthCheck != nil {
+		writerOpts.HealthInterval = time.Duration(*out.Syslog.HealthCheck) * time.Second
+	}
+	if out.Syslog.KeepAlive != nil {
+		writerOpts.KeepAlive = time.Duration(*out.Syslog.KeepAlive) * time.Second
+		if writerOpts.KeepAlive == 0 {
+			writerOpts.KeepAlive = -1 // Disabled
+		}
+	}
+	if opts.OnReconnect != nil {
+		writerOpts.OnReconnect = func(err error) { opts.OnReconnect(out.Name, err) }
+	}
+
+	writer, err := syslog.NewWriter(out.Syslog.Protocol, out.Syslog.Address(), writerOpts, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &syslogSink{
+		name:      out.Name,
+		formatter: newBatchFormatter(opts, out.Syslog.Format),
+		writer:    writer,
+		maxSize:   out.Syslog.MaxMessageSize,
+		logger:    logger,
+	}
+	if opts.OnTruncate != nil {
+		s.onTruncate = func() { opts.OnTruncate(out.Name) }
+	}
+
+	if out.Syslog.QueueSize > 0 {
+		var onDrop func()
+		if opts.OnDrop != nil {
+			onDrop = func() { opts.OnDrop(out.Name) }
+		}
+		s.queue = syslog.NewQueue(writer, out.Syslog.QueueSize, int64(out.Syslog.QueueMaxMB)<<20, out.Syslog.QueuePolicy, onDrop, logger)
+		logger.Info("syslog output queue enabled",
+			"queue_size", out.Syslog.QueueSize,
+			"queue_max_mb", out.Syslog.QueueMaxMB,
+			"queue_policy", out.Syslog.QueuePolicy)
+	}
+
+	return s, nil
+}
+
+func (s *syslogSink) Name() string {
+	return s.name
+}
+
+func (s *syslogSink) Type() string {
+	return "syslog"
+}
+
+// Write sends each record as a syslog line, reconnecting once on failure.
+// With a queue it returns once the lines are queued (or dropped).
+func (s *syslogSink) Write(ctx context.Context, records []Record) (int64, error) {
+	texts, err := s.formatter.FormatAll(records)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var bytesSent int64
+	for i, record := range records {
+		message := syslog.FormatMessage(record.Hostname, string(texts[i]))
+
+		// Truncate if necessary
+		if truncated, cut := syslog.Truncate(message, s.maxSize); cut {
+			s.logger.DebugContext(ctx, "truncating oversized message",
+				"original_size", len(message),
+				"max_size", s.maxSize)
+			message = truncated
+			if s.onTruncate != nil {
+				s.onTruncate()
+			}
+		}
+
+		if s.queue != nil {
+			if err := s.queue.Enqueue(ctx, message); err != nil {
+				return bytesSent, err
+			}
+			bytesSent += int64(len(message) + 1)
+			continue
+		}
+
+		if err := s.writer.Write(message); err != nil {
+			s.logger.WarnContext(ctx, "syslog write failed, attempting reconnect", "error", err.Error())
+			if reconnectErr := s.writer.Reconnect(ctx); reconnectErr != nil {
+				return bytesSent, fmt.Errorf("reconnection failed: %w", reconnectErr)
+			}
+
+			// Retry write after reconnect
+			if err = s.writer.Write(message); err != nil {
+				return bytesSent, fmt.Errorf("write failed after reconnect: %w", err)
+			}
+		}
+
+		bytesSent += int64(len(message) + 1) // +1 for the newline delimiter
+	}
+
+	// RELP servers acknowledge messages; the batch is only delivered once
+	// they all are. Reconnecting resends the unacknowledged ones.
+	if s.queue == nil {
+		if err := s.writer.Flush(); err != nil {
+			s.logger.WarnContext(ctx, "syslog acknowledgement failed, attempting reconnect", "error", err.Error())
+			if reconnectErr := s.writer.Reconnect(ctx); reconnectErr != nil {
+				return bytesSent, fmt.Errorf("reconnection failed: %w", reconnectErr)
+			}
+			if err := s.writer.Flush(); err != nil {
+				return bytesSent, fmt.Errorf("acknowledgement failed after reconnect: %w", err)
+			}
+		}
+	}
+
+	return bytesSent, nil
+}
+
+// Reconfigure adopts a reloaded message size limit
+func (s *syslogSink) Reconfigure(cfg *config.Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, out := range cfg.EffectiveOutputs() {
+		if out.Name == s.name && out.Syslog != nil {
+			s.maxSize = out.Syslog.MaxMessageSize
+		}
+	}
+}
+
+func (s *syslogSink) ReconnectCount() int {
+	return s.writer.ReconnectCount()
+}
+
+// Sent returns the number of messages queued so far, 0 without a queue
+func (s *syslogSink) Sent() int64 {
+	if s.queue == nil {
+		return 0
+	}
+	return s.queue.Sent()
+}
+
+// Acked returns the number of queued messages delivered so far; for RELP
+// that is those the server acknowledged
+func (s *syslogSink) Acked() int64 {
+	if s.queue == nil {
+		return 0
+	}
+	return s.queue.Acked()
+}
+
+// QueueLen returns the number of lines waiting in the queue
+func (s *syslogSink) QueueLen() int {
+	if s.queue == nil {
+		return 0
+	}
+	return s.queue.Len()
+}
+
+// Close drains the queue, if any, and closes the connection
+func (s *syslogSink) Close() error {
+	if s.queue != nil {
+		return s.queue.Close(syslogQueueDrainTimeout)
+	}
+	return s.writer.Close()
+}
+
+// probeSyslog connects to the syslog server and sends a test message
+func probeSyslog(ctx context.Context, out *config.SyslogOutput) (string, error) {
+	address := out.Address()
+
+	// Like the writer, try the server's addresses in order of preference
+	addrs := []string{address}
+	if !out.UsesSocket() {
+		resolved, err := syslog.Resolve(ctx, address, out.IPPreference, 0)
+		if err != nil {
+			return "", fmt.Errorf("cannot resolve syslog server %s: %w", out.Server, err)
+		}
+		addrs = resolved
+	}
+
+	dialer := net.Dialer{LocalAddr: syslog.LocalAddr(out.Protocol, out.BindAddress)}
+	start := time.Now()
+	var conn net.Conn
+	var err error
+	for _, addr := range addrs {
+		if conn, err = dialer.DialContext(ctx, syslog.Network(out.Protocol), addr); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("cannot connect to syslog server at %s://%s: %w", out.Protocol, address, err)
+	}
+	defer conn.Close()
+	connectTime := time.Since(start)
+
+	if out.Protocol == "relp" {
+		timeout := 10 * time.Second
+		if deadline, ok := ctx.Deadline(); ok {
+			timeout = time.Until(deadline)
+		}
+		if err := syslog.CheckRELP(conn, timeout); err != nil {
+			return "", fmt.Errorf("RELP server at %s did not open a session: %w", address, err)
+		}
+		return fmt.Sprintf("RELP server is reachable at %s and accepts syslog (connect %dms)", address, connectTime.Milliseconds()), nil
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetWriteDeadline(deadline); err != nil {
+			return "", fmt.Errorf("cannot set write deadline on syslog connection: %w", err)
+		}
+	}
+
+	// Try sending a test message
+	testMsg := []byte("<14>1 " + time.Now().Format(time.RFC3339) + " preflight-test cato-logger - - - Pre-flight connectivity test\n")
+	if _, err := conn.Write(testMsg); err != nil {
+		return "", fmt.Errorf("cannot write to syslog server at %s://%s: %w", out.Protocol, address, err)
+	}
+
+	return fmt.Sprintf("syslog server is reachable at %s://%s (connect %dms)", out.Protocol, address, connectTime.Milliseconds()), nil
+}
@@ -0,0 +1,81 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"cato-logger/internal/api"
+)
+
+// CheckClockSkew compares the local clock with the Date header returned by the
+// Cato API. Skew beyond warnAfter is reported as a warning and beyond failAfter
+// as a failure; a zero threshold disables that level.
+func (c *Checker) CheckClockSkew(apiURL string, warnAfter, failAfter, timeout time.Duration) CheckResult {
+	result := CheckResult{
+		Name:  "Clock Skew",
+		Class: ClassNetwork,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "HEAD", apiURL, nil)
+	if err != nil {
+		result.Message = "failed to create API request"
+		result.Error = err
+		return result
+	}
+	api.SetHeaders(req.Header, "preflight")
+
+	// Any response carries a Date header, so the status code is irrelevant here
+	sent := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		result.Message = fmt.Sprintf("cannot connect to Cato API at %s", apiURL)
+		result.Error = err
+		return result
+	}
+	resp.Body.Close()
+	received := time.Now()
+
+	serverTime, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		// Nothing to compare against; don't block startup on a missing header
+		result.Passed = true
+		result.Warning = true
+		result.Message = "API response has no usable Date header, clock skew not checked"
+		return result
+	}
+
+	// Compare against the midpoint of the request; Date has one-second resolution
+	local := sent.Add(received.Sub(sent) / 2)
+	skew := local.Sub(serverTime).Truncate(time.Second)
+	abs := skew
+	if abs < 0 {
+		abs = -abs
+	}
+
+	direction := "ahead of"
+	if skew < 0 {
+		direction = "behind"
+	}
+	summary := fmt.Sprintf("local clock is %s %s the Cato API", abs, direction)
+
+	switch {
+	case failAfter > 0 && abs > failAfter:
+		result.Message = fmt.Sprintf("%s (limit %s) - check NTP synchronization", summary, failAfter)
+		result.Class = ClassClock
+		result.Error = fmt.Errorf("clock skew %s exceeds %s", skew, failAfter)
+		return result
+	case warnAfter > 0 && abs > warnAfter:
+		result.Warning = true
+		result.Message = fmt.Sprintf("%s (warning threshold %s) - check NTP synchronization", summary, warnAfter)
+	default:
+		result.Message = summary
+	}
+
+	result.Passed = true
+	return result
+}
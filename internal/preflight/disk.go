@@ -0,0 +1,125 @@
+package preflight
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"cato-logger/internal/objstore"
+)
+
+// diskProbeSize is the amount written when measuring write latency
+const diskProbeSize = 4096
+
+// CheckDiskSpace verifies each directory has at least minFreeMB available
+// and measures the latency of a small synced write
+func (c *Checker) CheckDiskSpace(dirs []string, minFreeMB int) CheckResult {
+	result := CheckResult{
+		Name:  "Disk Space",
+		Class: ClassFilesystem,
+	}
+
+	var summaries []string
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		if dir == "" || seen[dir] {
+			continue
+		}
+		seen[dir] = true
+
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			result.Message = fmt.Sprintf("cannot create directory: %s", dir)
+			result.Error = err
+			return result
+		}
+
+		var fs syscall.Statfs_t
+		if err := syscall.Statfs(dir, &fs); err != nil {
+			result.Message = fmt.Sprintf("cannot determine free space for %s", dir)
+			result.Error = err
+			return result
+		}
+		freeMB := int64(fs.Bavail) * int64(fs.Bsize) / (1024 * 1024)
+
+		if freeMB < int64(minFreeMB) {
+			result.Message = fmt.Sprintf("only %d MB free in %s, need at least %d MB", freeMB, dir, minFreeMB)
+			result.Error = fmt.Errorf("insufficient disk space in %s", dir)
+			return result
+		}
+
+		latency, err := probeWrite(dir)
+		if err != nil {
+			result.Message = fmt.Sprintf("cannot write to %s", dir)
+			result.Error = err
+			return result
+		}
+
+		c.logger.Debug("disk check",
+			"dir", dir,
+			"free_mb", freeMB,
+			"write_latency_ms", latency.Milliseconds())
+
+		summaries = append(summaries, fmt.Sprintf("%s: %d MB free, write+sync %dms", dir, freeMB, latency.Milliseconds()))
+	}
+
+	result.Passed = true
+	result.Message = strings.Join(summaries, "; ")
+	if len(summaries) == 0 {
+		result.Message = "no local state directories to check"
+	}
+	return result
+}
+
+// probeWrite writes and syncs a small temporary file in dir, returning the elapsed time
+func probeWrite(dir string) (time.Duration, error) {
+	start := time.Now()
+
+	file, err := os.CreateTemp(dir, ".cato-logger-preflight-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(make([]byte, diskProbeSize)); err != nil {
+		file.Close()
+		return 0, err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return 0, err
+	}
+	if err := file.Close(); err != nil {
+		return 0, err
+	}
+
+	return time.Since(start), nil
+}
+
+// DiskPaths returns the directories that must stay writable: the directories
+// of the state files (markers, dead-letter file, file outputs) and, when logging to a file,
+// the log directory. Object store markers need no local disk.
+func DiskPaths(stateFiles []string, logOutput string) []string {
+	var dirs []string
+	seen := make(map[string]bool)
+	add := func(dir string) {
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+
+	for _, file := range stateFiles {
+		if !objstore.IsURL(file) {
+			add(filepath.Dir(file))
+		}
+	}
+	switch logOutput {
+	case "", "stdout", "stderr", "syslog", "journald":
+	default:
+		add(filepath.Dir(logOutput))
+	}
+	return dirs
+}
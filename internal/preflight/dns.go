@@ -0,0 +1,88 @@
+package preflight
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// CheckDNSResolution resolves the API and output hostnames, reporting the
+// addresses and lookup time so name problems are not hidden behind dial errors
+func (c *Checker) CheckDNSResolution(apiURL string, outputs []OutputTarget, timeout time.Duration) CheckResult {
+	result := CheckResult{
+		Name:  "DNS Resolution",
+		Class: ClassNetwork,
+	}
+
+	type target struct {
+		label string
+		host  string
+	}
+	targets := []target{{label: "Cato API"}}
+	if parsed, err := url.Parse(apiURL); err == nil {
+		targets[0].host = parsed.Hostname()
+	}
+	for _, out := range outputs {
+		// Outputs without a network endpoint have nothing to resolve
+		if out.Host != "" {
+			targets = append(targets, target{label: fmt.Sprintf("output %s", out.Name), host: out.Host})
+		}
+	}
+
+	var summaries []string
+	for _, target := range targets {
+		if target.host == "" {
+			result.Message = fmt.Sprintf("%s address has no hostname", target.label)
+			result.Error = fmt.Errorf("cannot determine hostname for %s", target.label)
+			return result
+		}
+
+		// IP literals need no resolution
+		if net.ParseIP(target.host) != nil {
+			summaries = append(summaries, fmt.Sprintf("%s %s (IP literal)", target.label, target.host))
+			continue
+		}
+
+		addrs, elapsed, err := resolve(target.host, timeout)
+		if err != nil {
+			var dnsErr *net.DNSError
+			switch {
+			case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+				result.Message = fmt.Sprintf("%s hostname %s does not exist (NXDOMAIN) - check the configured address", target.label, target.host)
+			case errors.As(err, &dnsErr) && dnsErr.IsTimeout:
+				result.Message = fmt.Sprintf("DNS lookup for %s hostname %s timed out after %s - check resolver configuration", target.label, target.host, timeout)
+			default:
+				result.Message = fmt.Sprintf("cannot resolve %s hostname %s", target.label, target.host)
+			}
+			result.Error = err
+			return result
+		}
+
+		c.logger.Debug("resolved hostname",
+			"target", target.label,
+			"host", target.host,
+			"addresses", addrs,
+			"duration_ms", elapsed.Milliseconds())
+
+		summaries = append(summaries, fmt.Sprintf("%s %s -> %s in %dms",
+			target.label, target.host, strings.Join(addrs, ", "), elapsed.Milliseconds()))
+	}
+
+	result.Passed = true
+	result.Message = strings.Join(summaries, "; ")
+	return result
+}
+
+// resolve looks up host with a timeout and returns its addresses and the lookup time
+func resolve(host string, timeout time.Duration) ([]string, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	return addrs, time.Since(start), err
+}
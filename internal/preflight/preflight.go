@@ -4,25 +4,59 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"net"
 	"net/http"
 	"os"
 	"path/filepath"
 	"time"
 
+	"cato-logger/internal/api"
 	"cato-logger/internal/logging"
+	"cato-logger/internal/objstore"
+)
+
+// Check IDs used to configure individual checks
+const (
+	CheckDNS     = "dns"
+	CheckMarker  = "marker"
+	CheckDisk    = "disk"
+	CheckOutputs = "outputs"
+	CheckAPI     = "api"
+	CheckClock   = "clock"
+)
+
+// CheckIDs lists every check in the order RunAll executes them
+var CheckIDs = []string{CheckDNS, CheckMarker, CheckDisk, CheckOutputs, CheckAPI, CheckClock}
+
+// Failure classes, used to pick the exit code of the preflight command
+const (
+	ClassNetwork    = "network"
+	ClassAuth       = "auth"
+	ClassFilesystem = "filesystem"
+	ClassClock      = "clock"
 )
 
 // CheckResult represents the result of a pre-flight check
 type CheckResult struct {
+	ID      string
 	Name    string
+	Class   string // Failure class of the check, see Class* constants
 	Passed  bool
+	Warning bool // Passed, but with a condition worth the operator's attention
+	Skipped bool // Disabled by configuration
 	Message string
 	Error   error
 }
 
+// CheckOptions adjusts how a single check runs
+type CheckOptions struct {
+	Disabled bool
+	WarnOnly bool          // Report failures as warnings instead of blocking startup
+	Timeout  time.Duration // Overrides Options.Timeout when non-zero
+}
+
 // Checker runs all pre-flight checks before starting the service
 type Checker struct {
 	logger *logging.Logger
@@ -35,26 +69,101 @@ func New(logger *logging.Logger) *Checker {
 	}
 }
 
+// OutputTarget describes one configured output for the pre-flight checks
+type OutputTarget struct {
+	Name string
+	Type string
+	Host string // Hostname to resolve, empty when not applicable
+	// Probe checks reachability and credentials, returning a summary
+	Probe func(ctx context.Context, timeout time.Duration) (string, error)
+}
+
+// Options holds the settings exercised by the pre-flight checks
+type Options struct {
+	APIURL        string
+	APIKey        string
+	AccountIDs    []string
+	Outputs       []OutputTarget
+	MarkerFiles   []string // One per feed
+	DiskPaths     []string // Directories that must have free space, see DiskPaths
+	MinFreeDiskMB int
+	ClockSkewWarn time.Duration
+	ClockSkewFail time.Duration
+	Timeout       time.Duration
+	Checks        map[string]CheckOptions // Keyed by check ID
+}
+
 // RunAll executes all pre-flight checks and returns results
-func (c *Checker) RunAll(
-	apiURL, apiKey, accountID string,
-	syslogProtocol, syslogAddress string,
-	markerFile string,
-	timeout time.Duration,
-) []CheckResult {
+func (c *Checker) RunAll(opts Options) []CheckResult {
 	c.logger.Info("running pre-flight checks")
 
-	results := []CheckResult{
-		c.CheckMarkerFileAccess(markerFile),
-		c.CheckSyslogConnectivity(syslogProtocol, syslogAddress, timeout),
-		c.CheckAPIConnectivity(apiURL, apiKey, accountID, timeout),
+	checks := map[string]func(timeout time.Duration) []CheckResult{
+		CheckDNS: func(timeout time.Duration) []CheckResult {
+			return []CheckResult{c.CheckDNSResolution(opts.APIURL, opts.Outputs, timeout)}
+		},
+		CheckMarker: func(time.Duration) []CheckResult {
+			var results []CheckResult
+			for _, markerFile := range opts.MarkerFiles {
+				results = append(results, c.CheckMarkerFileAccess(markerFile))
+			}
+			return results
+		},
+		CheckDisk: func(time.Duration) []CheckResult {
+			return []CheckResult{c.CheckDiskSpace(opts.DiskPaths, opts.MinFreeDiskMB)}
+		},
+		CheckOutputs: func(timeout time.Duration) []CheckResult {
+			return c.CheckOutputs(opts.Outputs, timeout)
+		},
+		CheckAPI: func(timeout time.Duration) []CheckResult {
+			var results []CheckResult
+			for _, accountID := range opts.AccountIDs {
+				results = append(results, c.CheckAPIConnectivity(opts.APIURL, opts.APIKey, accountID, timeout))
+			}
+			return results
+		},
+		CheckClock: func(timeout time.Duration) []CheckResult {
+			return []CheckResult{c.CheckClockSkew(opts.APIURL, opts.ClockSkewWarn, opts.ClockSkewFail, timeout)}
+		},
+	}
+
+	var results []CheckResult
+	for _, id := range CheckIDs {
+		checkOpts := opts.Checks[id]
+		if checkOpts.Disabled {
+			results = append(results, CheckResult{ID: id, Name: id, Passed: true, Skipped: true, Message: "disabled by configuration"})
+			continue
+		}
+
+		timeout := opts.Timeout
+		if checkOpts.Timeout > 0 {
+			timeout = checkOpts.Timeout
+		}
+
+		for _, result := range checks[id](timeout) {
+			result.ID = id
+			if !result.Passed && checkOpts.WarnOnly {
+				result.Passed = true
+				result.Warning = true
+				if result.Error != nil {
+					result.Message = fmt.Sprintf("%s (warn-only): %v", result.Message, result.Error)
+				}
+			}
+			results = append(results, result)
+		}
 	}
 
 	// Summary
 	passed := 0
 	failed := 0
+	skipped := 0
 	for _, result := range results {
-		if result.Passed {
+		if result.Skipped {
+			skipped++
+			c.logger.Info("pre-flight check skipped", "check", result.ID)
+		} else if result.Passed && result.Warning {
+			passed++
+			c.logger.Warn("pre-flight check passed with warning", "check", result.Name, "message", result.Message)
+		} else if result.Passed {
 			passed++
 			c.logger.Info("pre-flight check passed", "check", result.Name, "message", result.Message)
 		} else {
@@ -63,15 +172,20 @@ func (c *Checker) RunAll(
 		}
 	}
 
-	c.logger.Info("pre-flight checks complete", "passed", passed, "failed", failed, "total", len(results))
+	c.logger.Info("pre-flight checks complete", "passed", passed, "failed", failed, "skipped", skipped, "total", len(results))
 
 	return results
 }
 
-// CheckMarkerFileAccess verifies we can read/write the marker file
+// CheckMarkerFileAccess verifies we can read/write the marker file without
+// disturbing a marker saved by a previous run
 func (c *Checker) CheckMarkerFileAccess(markerFile string) CheckResult {
 	result := CheckResult{
-		Name: "Marker File Access",
+		Name:  "Marker File Access",
+		Class: ClassFilesystem,
+	}
+	if objstore.IsURL(markerFile) {
+		return c.checkMarkerObject(result, markerFile)
 	}
 
 	// Check if directory exists, create if not
@@ -82,6 +196,27 @@ func (c *Checker) CheckMarkerFileAccess(markerFile string) CheckResult {
 		return result
 	}
 
+	// An existing marker must stay intact: check access without rewriting it
+	if _, err := os.Stat(markerFile); err == nil {
+		if _, err := os.ReadFile(markerFile); err != nil {
+			result.Message = fmt.Sprintf("cannot read from marker file: %s", markerFile)
+			result.Error = err
+			return result
+		}
+
+		file, err := os.OpenFile(markerFile, os.O_WRONLY, 0)
+		if err != nil {
+			result.Message = fmt.Sprintf("cannot write to marker file: %s", markerFile)
+			result.Error = err
+			return result
+		}
+		file.Close()
+
+		result.Passed = true
+		result.Message = fmt.Sprintf("marker file is readable and writable: %s", markerFile)
+		return result
+	}
+
 	// Try to write a test marker
 	testData := []byte("preflight-test")
 	if err := os.WriteFile(markerFile, testData, 0644); err != nil {
@@ -89,6 +224,8 @@ func (c *Checker) CheckMarkerFileAccess(markerFile string) CheckResult {
 		result.Error = err
 		return result
 	}
+	// The marker manager starts fresh when the file does not exist
+	defer os.Remove(markerFile)
 
 	// Try to read it back
 	data, err := os.ReadFile(markerFile)
@@ -105,58 +242,88 @@ func (c *Checker) CheckMarkerFileAccess(markerFile string) CheckResult {
 		return result
 	}
 
-	// Clean up test data (but keep the file for the marker manager)
-	if err := os.WriteFile(markerFile, []byte(""), 0644); err != nil {
-		c.logger.Warn("could not clear test data from marker file", "error", err)
-	}
-
 	result.Passed = true
 	result.Message = fmt.Sprintf("marker file is readable and writable: %s", markerFile)
 	return result
 }
 
-// CheckSyslogConnectivity tests connection to the syslog server
-func (c *Checker) CheckSyslogConnectivity(protocol, address string, timeout time.Duration) CheckResult {
-	result := CheckResult{
-		Name: "Syslog Connectivity",
-	}
-
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
-
-	var dialer net.Dialer
-	conn, err := dialer.DialContext(ctx, protocol, address)
+// checkMarkerObject verifies an object store marker can be read, and that
+// the bucket accepts writes through a probe object next to it
+func (c *Checker) checkMarkerObject(result CheckResult, markerFile string) CheckResult {
+	store, key, err := objstore.Open(markerFile)
 	if err != nil {
-		result.Message = fmt.Sprintf("cannot connect to syslog server at %s://%s", protocol, address)
+		result.Message = fmt.Sprintf("invalid marker location: %s", markerFile)
 		result.Error = err
 		return result
 	}
-	defer conn.Close()
 
-	// Try sending a test message
-	testMsg := []byte("<14>1 " + time.Now().Format(time.RFC3339) + " preflight-test cato-logger - - - Pre-flight connectivity test\n")
-	if err := conn.SetWriteDeadline(time.Now().Add(timeout)); err != nil {
-		result.Message = "cannot set write deadline on syslog connection"
+	ctx := context.Background()
+	if _, err := store.Get(ctx, key); err != nil && !errors.Is(err, objstore.ErrNotFound) {
+		result.Message = fmt.Sprintf("cannot read marker object: %s", markerFile)
 		result.Error = err
 		return result
 	}
 
-	if _, err := conn.Write(testMsg); err != nil {
-		result.Message = fmt.Sprintf("cannot write to syslog server at %s://%s", protocol, address)
+	probe := key + ".preflight"
+	if _, err := store.Put(ctx, probe, []byte("preflight-test"), objstore.Condition{}); err != nil {
+		result.Message = fmt.Sprintf("cannot write to marker bucket: %s", markerFile)
 		result.Error = err
 		return result
 	}
+	if err := store.Delete(ctx, probe); err != nil {
+		c.logger.Warn("failed to remove pre-flight probe object", "key", probe, "error", err.Error())
+	}
 
 	result.Passed = true
-	result.Message = fmt.Sprintf("syslog server is reachable at %s://%s", protocol, address)
+	result.Message = fmt.Sprintf("marker object is readable and writable: %s", markerFile)
 	return result
 }
 
+// CheckOutputs probes every configured output, one result per output
+func (c *Checker) CheckOutputs(outputs []OutputTarget, timeout time.Duration) []CheckResult {
+	var results []CheckResult
+	for _, out := range outputs {
+		result := CheckResult{
+			Name:  fmt.Sprintf("Output %s (%s)", out.Name, out.Type),
+			Class: ClassNetwork,
+		}
+
+		summary, err := out.Probe(context.Background(), timeout)
+		if err != nil {
+			var authErr *AuthError
+			if errors.As(err, &authErr) {
+				result.Class = ClassAuth
+			}
+			result.Message = err.Error()
+			result.Error = err
+		} else {
+			result.Passed = true
+			result.Message = summary
+		}
+
+		results = append(results, result)
+	}
+	return results
+}
+
+// AuthError marks a probe failure caused by rejected credentials
+type AuthError struct {
+	Err error
+}
+
+func (e *AuthError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
 // CheckAPIConnectivity tests connection to the Cato API with a minimal query
 func (c *Checker) CheckAPIConnectivity(apiURL, apiKey, accountID string, timeout time.Duration) CheckResult {
 	result := CheckResult{
-		Name: "Cato API Connectivity",
+		Name:  "Cato API Connectivity",
+		Class: ClassNetwork,
 	}
 
 	// Create a minimal GraphQL query to test authentication and account access
@@ -190,7 +357,7 @@ func (c *Checker) CheckAPIConnectivity(apiURL, apiKey, accountID string, timeout
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("x-api-key", apiKey)
-	req.Header.Set("User-Agent", "Cato-CEF-Forwarder/3.2-preflight")
+	api.SetHeaders(req.Header, "preflight")
 
 	// Execute request with timeout
 	client := &http.Client{Timeout: timeout}
@@ -212,12 +379,14 @@ func (c *Checker) CheckAPIConnectivity(apiURL, apiKey, accountID string, timeout
 	// Check HTTP status
 	if resp.StatusCode == 401 {
 		result.Message = "API authentication failed - check your API key"
+		result.Class = ClassAuth
 		result.Error = fmt.Errorf("HTTP 401: invalid or missing API key")
 		return result
 	}
 
 	if resp.StatusCode == 403 {
 		result.Message = "API access forbidden - ensure Events Integration is enabled and API key has eventsFeed permissions"
+		result.Class = ClassAuth
 		result.Error = fmt.Errorf("HTTP 403: insufficient permissions")
 		return result
 	}
@@ -251,6 +420,7 @@ func (c *Checker) CheckAPIConnectivity(apiURL, apiKey, accountID string, timeout
 	if len(apiResponse.Errors) > 0 {
 		errMsg := apiResponse.Errors[0].Message
 		result.Message = fmt.Sprintf("API GraphQL error: %s", errMsg)
+		result.Class = ClassAuth
 		result.Error = fmt.Errorf("GraphQL error: %s", errMsg)
 		return result
 	}
@@ -277,6 +447,17 @@ func HasFailures(results []CheckResult) bool {
 	return false
 }
 
+// FailedNames returns the names of the failed checks
+func FailedNames(results []CheckResult) []string {
+	var names []string
+	for _, result := range results {
+		if !result.Passed {
+			names = append(names, result.Name)
+		}
+	}
+	return names
+}
+
 // FormatFailures returns a formatted string of all failures
 func FormatFailures(results []CheckResult) string {
 	var failures []string
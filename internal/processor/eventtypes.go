@@ -0,0 +1,100 @@
+package processor
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxEventTypes caps the distinct event types counted; further types are
+// counted under otherEventType so a misbehaving feed cannot grow the map
+// without bound
+const maxEventTypes = 500
+
+// otherEventType collects the events of types beyond maxEventTypes
+var otherEventType = EventType{Type: "other"}
+
+// topEventTypes is the number of event types in the periodic report
+const topEventTypes = 10
+
+// EventType is an event's event_type and event_sub_type
+type EventType struct {
+	Type    string `json:"event_type"`
+	SubType string `json:"event_sub_type,omitempty"`
+}
+
+// String returns the type and sub-type separated by a slash
+func (t EventType) String() string {
+	if t.SubType == "" {
+		return t.Type
+	}
+	return t.Type + "/" + t.SubType
+}
+
+// EventTypeCount is the number of events forwarded of one type
+type EventTypeCount struct {
+	EventType
+	Count int64 `json:"count"`
+}
+
+// eventTypeOf returns the type of an event, "unknown" when it has none
+func eventTypeOf(fields map[string]string) EventType {
+	t := EventType{Type: fields["event_type"], SubType: fields["event_sub_type"]}
+	if t.Type == "" {
+		t.Type = "unknown"
+	}
+	return t
+}
+
+// AddEventTypes counts forwarded events by type
+func (s *Stats) AddEventTypes(counts map[EventType]int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for t, n := range counts {
+		s.eventTypes[countedType(s.eventTypes, t)] += n
+		s.windowTypes[countedType(s.windowTypes, t)] += n
+	}
+}
+
+// countedType returns the key t is counted under in counts
+func countedType(counts map[EventType]int64, t EventType) EventType {
+	if _, ok := counts[t]; !ok && len(counts) >= maxEventTypes {
+		return otherEventType
+	}
+	return t
+}
+
+// EventTypes returns the lifetime count of every event type, largest first
+func (s *Stats) EventTypes() []EventTypeCount {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return sortedTypes(s.eventTypes, 0)
+}
+
+// sortedTypes returns counts largest first, at most limit of them unless
+// limit is 0
+func sortedTypes(counts map[EventType]int64, limit int) []EventTypeCount {
+	sorted := make([]EventTypeCount, 0, len(counts))
+	for t, n := range counts {
+		sorted = append(sorted, EventTypeCount{EventType: t, Count: n})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Count != sorted[j].Count {
+			return sorted[i].Count > sorted[j].Count
+		}
+		return sorted[i].String() < sorted[j].String()
+	})
+	if limit > 0 && len(sorted) > limit {
+		sorted = sorted[:limit]
+	}
+	return sorted
+}
+
+// FormatEventTypes renders counts as "type/sub_type=count" pairs for a log line
+func FormatEventTypes(counts []EventTypeCount) string {
+	parts := make([]string, len(counts))
+	for i, c := range counts {
+		parts[i] = c.String() + "=" + strconv.FormatInt(c.Count, 10)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,144 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"cato-logger/internal/api"
+	"cato-logger/internal/config"
+	"cato-logger/internal/logging"
+	"cato-logger/internal/marker"
+	"cato-logger/internal/output"
+)
+
+// fakeSource serves fixed pages by the marker they are fetched from
+type fakeSource struct {
+	pages   map[string]*api.EventsPage
+	err     error    // Returned by every fetch when set
+	fetches []string // Markers fetched from, in order
+}
+
+func (s *fakeSource) FetchWithRetry(ctx context.Context, marker string, maxAttempts int, retryDelay time.Duration) (*api.EventsPage, error) {
+	s.fetches = append(s.fetches, marker)
+	if s.err != nil {
+		return nil, s.err
+	}
+	page, ok := s.pages[marker]
+	if !ok {
+		return &api.EventsPage{NewMarker: marker}, nil
+	}
+	return page, nil
+}
+
+// fakeMarkers keeps the marker and journal in memory
+type fakeMarkers struct {
+	marker    string
+	updateErr error    // Returned by Update when set, leaving the marker as it is
+	updates   []string // Markers saved, in order
+	journal   []marker.JournalEntry
+}
+
+func (m *fakeMarkers) Get() string {
+	return m.marker
+}
+
+func (m *fakeMarkers) Update(ctx context.Context, marker string, events int) error {
+	if m.updateErr != nil {
+		return m.updateErr
+	}
+	m.marker = marker
+	m.updates = append(m.updates, marker)
+	return nil
+}
+
+func (m *fakeMarkers) WriteJournal(ctx context.Context, entry marker.JournalEntry) error {
+	outputs := make(map[string]int, len(entry.Outputs))
+	for name, taken := range entry.Outputs {
+		outputs[name] = taken
+	}
+	entry.Outputs = outputs
+	m.journal = append(m.journal, entry)
+	return nil
+}
+
+// fakeSink records the events written to it and fails writes containing
+// an event whose id is in failIDs
+type fakeSink struct {
+	name    string
+	failIDs map[string]bool
+
+	mu     sync.Mutex
+	writes [][]string // Event ids of each successful write
+}
+
+func (s *fakeSink) Name() string { return s.name }
+func (s *fakeSink) Type() string { return "fake" }
+func (s *fakeSink) Close() error { return nil }
+
+func (s *fakeSink) Write(ctx context.Context, records []output.Record) (int64, error) {
+	ids := make([]string, len(records))
+	for i, record := range records {
+		ids[i] = record.Fields["id"]
+		if s.failIDs[ids[i]] {
+			return 0, fmt.Errorf("%s refused event %s", s.name, ids[i])
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writes = append(s.writes, ids)
+	return int64(len(records)), nil
+}
+
+// received returns the ids of every event written, in order
+func (s *fakeSink) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ids []string
+	for _, write := range s.writes {
+		ids = append(ids, write...)
+	}
+	return ids
+}
+
+// testPage returns a page of events with the given ids
+func testPage(newMarker string, hasMore bool, ids ...string) *api.EventsPage {
+	events := make([]map[string]string, len(ids))
+	for i, id := range ids {
+		events[i] = map[string]string{"id": id, "event_type": "Security"}
+	}
+	return &api.EventsPage{Events: events, NewMarker: newMarker, HasMore: hasMore}
+}
+
+// newTestProcessor returns a processor for the events feed writing to sinks
+func newTestProcessor(t *testing.T, source EventSource, markers MarkerStore, sinks ...output.Sink) (*Processor, *Stats) {
+	t.Helper()
+	logger, err := logging.New(logging.Options{Level: "error", Output: filepath.Join(t.TempDir(), "test.log")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { logger.Close() })
+
+	cfg := &config.Config{
+		MaxPagination: 5,
+		RetryAttempts: 1,
+		FetchInterval: 60,
+		JournalChunk:  2,
+	}
+	stats := NewStats()
+	return New(cfg, "events", source, sinks, nil, markers, stats, logger), stats
+}
+
+// errorCount returns the number of errors stats recorded in a category
+func errorCount(stats *Stats, category string) int {
+	n := 0
+	for _, record := range stats.Errors() {
+		if record.Category == category {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,91 @@
+package processor
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Bucket upper bounds, in seconds, for the latency histograms in Stats
+var (
+	apiDurationBuckets   = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+	writeDurationBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 5, 10, 30}
+	eventLatencyBuckets  = []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600, 7200, 21600, 86400}
+)
+
+// Histogram counts durations in fixed buckets, like a Prometheus histogram.
+// Unlike the sample list behind Report it has constant size and never resets.
+type Histogram struct {
+	mu     sync.Mutex
+	bounds []float64 // Upper bounds in seconds, ascending
+	counts []uint64  // Per bucket, plus a final overflow bucket
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram creates a histogram with the given ascending upper bounds in seconds
+func NewHistogram(bounds []float64) *Histogram {
+	return &Histogram{
+		bounds: bounds,
+		counts: make([]uint64, len(bounds)+1),
+	}
+}
+
+// Observe records one duration
+func (h *Histogram) Observe(d time.Duration) {
+	seconds := d.Seconds()
+	i := sort.SearchFloat64s(h.bounds, seconds)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.counts[i]++
+	h.count++
+	h.sum += seconds
+}
+
+// HistogramSnapshot is a point-in-time copy of a histogram
+type HistogramSnapshot struct {
+	Bounds []float64 // Upper bounds in seconds
+	Counts []uint64  // Per bucket (not cumulative), plus the overflow bucket
+	Count  uint64
+	Sum    float64 // Seconds
+}
+
+// Snapshot returns a copy of the histogram
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return HistogramSnapshot{
+		Bounds: h.bounds,
+		Counts: append([]uint64(nil), h.counts...),
+		Count:  h.count,
+		Sum:    h.sum,
+	}
+}
+
+// Quantile estimates the q-th quantile (0-1) by interpolating within the
+// bucket that holds it. Observations beyond the last bound report that bound.
+func (s HistogramSnapshot) Quantile(q float64) time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+
+	rank := q * float64(s.Count)
+	var seen float64
+	for i, n := range s.Counts {
+		if n == 0 || seen+float64(n) < rank {
+			seen += float64(n)
+			continue
+		}
+		if i == len(s.Bounds) {
+			break
+		}
+		lower := 0.0
+		if i > 0 {
+			lower = s.Bounds[i-1]
+		}
+		seconds := lower + (s.Bounds[i]-lower)*(rank-seen)/float64(n)
+		return time.Duration(seconds * float64(time.Second))
+	}
+	return time.Duration(s.Bounds[len(s.Bounds)-1] * float64(time.Second))
+}
@@ -0,0 +1,143 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"cato-logger/internal/output"
+)
+
+// outputCounters are the delivery counters of one output
+type outputCounters struct {
+	events      int64
+	bytes       int64
+	failures    int64
+	dropped     int64
+	lastSuccess time.Time
+	queue       output.Queuer // nil for outputs that write directly
+
+	// Reporting window, reset by Report
+	windowEvents   int64
+	windowBytes    int64
+	windowFailures int64
+	windowDropped  int64
+}
+
+// OutputStats is the delivery state of one output
+type OutputStats struct {
+	Name        string
+	Events      int64     // Events the output took
+	Bytes       int64     // Bytes the output took
+	Failures    int64     // Failed batch writes
+	Dropped     int64     // Messages its queue discarded
+	QueueDepth  int       // Messages waiting in its queue now
+	LastSuccess time.Time // Last successful write, zero if none
+}
+
+// TrackOutputs registers the outputs whose delivery is tracked, in the
+// order they are reported, and the queues whose depth is reported
+func (s *Stats) TrackOutputs(sinks []output.Sink) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sink := range sinks {
+		counters := s.output(sink.Name())
+		if q, ok := sink.(output.Queuer); ok {
+			counters.queue = q
+		}
+	}
+}
+
+// output returns the counters of an output, adding them on first use
+func (s *Stats) output(name string) *outputCounters {
+	counters, ok := s.outputs[name]
+	if !ok {
+		counters = &outputCounters{}
+		s.outputs[name] = counters
+		s.outputOrder = append(s.outputOrder, name)
+	}
+	return counters
+}
+
+// RecordOutputWrite records one batch written to an output
+func (s *Stats) RecordOutputWrite(name string, events int, bytes int64, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counters := s.output(name)
+	counters.bytes += bytes
+	counters.windowBytes += bytes
+	if err != nil {
+		counters.failures++
+		counters.windowFailures++
+		return
+	}
+	counters.events += int64(events)
+	counters.windowEvents += int64(events)
+	counters.lastSuccess = time.Now()
+}
+
+// Outputs returns the lifetime delivery state of every output
+func (s *Stats) Outputs() []OutputStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	outputs := make([]OutputStats, 0, len(s.outputOrder))
+	for _, name := range s.outputOrder {
+		counters := s.outputs[name]
+		outputs = append(outputs, OutputStats{
+			Name:        name,
+			Events:      counters.events,
+			Bytes:       counters.bytes,
+			Failures:    counters.failures,
+			Dropped:     counters.dropped,
+			QueueDepth:  counters.queueDepth(),
+			LastSuccess: counters.lastSuccess,
+		})
+	}
+	return outputs
+}
+
+// windowOutputs returns the delivery state of every output in the current
+// reporting window and starts a new one. The caller holds the lock.
+func (s *Stats) windowOutputs() []OutputStats {
+	outputs := make([]OutputStats, 0, len(s.outputOrder))
+	for _, name := range s.outputOrder {
+		counters := s.outputs[name]
+		outputs = append(outputs, OutputStats{
+			Name:        name,
+			Events:      counters.windowEvents,
+			Bytes:       counters.windowBytes,
+			Failures:    counters.windowFailures,
+			Dropped:     counters.windowDropped,
+			QueueDepth:  counters.queueDepth(),
+			LastSuccess: counters.lastSuccess,
+		})
+		counters.windowEvents = 0
+		counters.windowBytes = 0
+		counters.windowFailures = 0
+		counters.windowDropped = 0
+	}
+	return outputs
+}
+
+// queueDepth returns the messages waiting in the output's queue
+func (c *outputCounters) queueDepth() int {
+	if c.queue == nil {
+		return 0
+	}
+	return c.queue.QueueLen()
+}
+
+// FormatOutputs renders the delivery state of every output for a log line,
+// with the age of the last successful write at now
+func FormatOutputs(outputs []OutputStats, now time.Time) string {
+	parts := make([]string, len(outputs))
+	for i, o := range outputs {
+		lastSuccess := "never"
+		if !o.LastSuccess.IsZero() {
+			lastSuccess = fmt.Sprintf("%ds ago", int(now.Sub(o.LastSuccess).Seconds()))
+		}
+		parts[i] = fmt.Sprintf("%s(events=%d bytes=%d failures=%d dropped=%d queued=%d last_success=%s)",
+			o.Name, o.Events, o.Bytes, o.Failures, o.Dropped, o.QueueDepth, lastSuccess)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -2,67 +2,202 @@ package processor
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"cato-logger/internal/api"
-	"cato-logger/internal/cef"
 	"cato-logger/internal/config"
+	"cato-logger/internal/dlq"
 	"cato-logger/internal/logging"
 	"cato-logger/internal/marker"
+	"cato-logger/internal/output"
 	"cato-logger/internal/syslog"
 )
 
+// EventSource fetches pages of a feed's events, retrying failed requests.
+// *api.Client implements it.
+type EventSource interface {
+	FetchWithRetry(ctx context.Context, marker string, maxAttempts int, retryDelay time.Duration) (*api.EventsPage, error)
+}
+
+// MarkerStore keeps the position of a feed. *marker.Manager implements it;
+// Update returns marker.ErrConflict when another instance moved the marker.
+type MarkerStore interface {
+	Get() string
+	Update(ctx context.Context, marker string, events int) error
+	WriteJournal(ctx context.Context, entry marker.JournalEntry) error
+}
+
+// Stage modifies an event before it is formatted
+type Stage interface {
+	Apply(event map[string]string) map[string]string
+}
+
+// Filter is implemented by stages that drop events. Events a filter does
+// not keep are not formatted or forwarded.
+type Filter interface {
+	Keep(event map[string]string) bool
+}
+
+// Aggregator is implemented by stages that roll up several events of a page
+// into one. Aggregate returns the events left and, for each, the index of
+// the last input event it covers.
+type Aggregator interface {
+	Aggregate(events []map[string]string) ([]map[string]string, []int)
+}
+
+// Refresher is implemented by stages that reload external data between
+// cycles. Feeds run their cycles in parallel over shared stages, so Refresh
+// must be safe for concurrent use.
+type Refresher interface {
+	Refresh()
+}
+
 // Processor orchestrates the event fetching and forwarding pipeline
 type Processor struct {
 	cfg           *config.Config
-	apiClient     *api.Client
-	syslogWriter  *syslog.Writer
-	cefFormatter  *cef.Formatter
-	markerManager *marker.Manager
+	apiClient     EventSource
+	feed          string
+	sinks         []output.Sink
+	stages        []Stage
+	markerManager MarkerStore
 	stats         *Stats
 	logger        *logging.Logger
+
+	// Dead-letter queue, nil when disabled
+	deadLetter     *dlq.Queue
+	accountID      string
+	failedMarker   string // Fetch marker of the page that last failed delivery
+	failedAttempts int    // Consecutive failed deliveries of that page
+
+	// Outputs that deliver in the background, and the pages whose marker
+	// waits for them. fetchMarker runs ahead of the saved marker meanwhile.
+	ackers      []output.Acknowledger
+	pending     []pendingBatch // Oldest first
+	fetchMarker string
+
+	// Progress of the page being forwarded, kept in the marker journal
+	journaling bool
+	journal    *marker.JournalEntry
+}
+
+// pendingBatch is a fetched page whose marker is saved once every output
+// has delivered its records
+type pendingBatch struct {
+	marker string
+	events int
+	sent   []int64 // Position of each acknowledger after the page was written
 }
 
-// New creates a new event processor
+// New creates a new event processor for a feed. Outputs format its records
+// with the feed's CEF mapping profile.
 func New(
 	cfg *config.Config,
-	apiClient *api.Client,
-	syslogWriter *syslog.Writer,
-	cefFormatter *cef.Formatter,
-	markerManager *marker.Manager,
+	feed string,
+	apiClient EventSource,
+	sinks []output.Sink,
+	stages []Stage,
+	markerManager MarkerStore,
 	stats *Stats,
 	logger *logging.Logger,
 ) *Processor {
-	return &Processor{
+	p := &Processor{
 		cfg:           cfg,
+		feed:          feed,
 		apiClient:     apiClient,
-		syslogWriter:  syslogWriter,
-		cefFormatter:  cefFormatter,
+		sinks:         sinks,
+		stages:        stages,
 		markerManager: markerManager,
 		stats:         stats,
 		logger:        logger,
 	}
+	for _, sink := range sinks {
+		if a, ok := sink.(output.Acknowledger); ok {
+			p.ackers = append(p.ackers, a)
+		}
+	}
+	return p
+}
+
+// EnableDeadLetter sends events that cannot be formatted, and pages that keep
+// failing delivery, to queue instead of retrying them forever
+func (p *Processor) EnableDeadLetter(queue *dlq.Queue, accountID string) {
+	p.deadLetter = queue
+	p.accountID = accountID
+}
+
+// EnableJournal keeps the progress of each page in the marker journal, so a
+// page fetched again from the same marker skips the events each output
+// already took. entry is the progress the previous run left, if any.
+func (p *Processor) EnableJournal(entry *marker.JournalEntry) {
+	p.journaling = true
+	p.journal = entry
+}
+
+// Reconfigure swaps in a reloaded configuration and stage list. It must not
+// be called while a processing cycle is running.
+func (p *Processor) Reconfigure(cfg *config.Config, stages []Stage) {
+	p.cfg = cfg
+	p.stages = stages
+
+	for _, sink := range p.sinks {
+		if r, ok := sink.(output.Reconfigurer); ok {
+			r.Reconfigure(cfg)
+		}
+	}
 }
 
 // ProcessEvents fetches and forwards all available events with pagination
 func (p *Processor) ProcessEvents(ctx context.Context) error {
+	// Tag every log line from this cycle so multi-page cycles can be reconstructed
+	cycleID := logging.NewCorrelationID()
+	ctx = logging.ContextWith(ctx, "cycle_id", cycleID)
+	p.stats.StartCycle(cycleID)
+
 	totalEventsProcessed := 0
 	paginationCount := 0
 	currentMarker := p.markerManager.Get()
+	if p.fetchMarker != "" {
+		currentMarker = p.fetchMarker
+	}
 	markerUpdates := 0
+	var bytesFetched, bytesSent int64
+	deadLettered := 0
 
 	p.stats.IncrementAPIRequests()
 
 	pollStart := time.Now()
 	pollEnd := pollStart
 	lastProgressLog := pollStart
+	var drainTime time.Duration // Set once the feed has no more events
 	progressInterval := time.Duration(p.cfg.FetchInterval) * time.Second
 	numErrors := 0
 
-	p.logger.Debug("starting event processing cycle", "has_marker", currentMarker != "")
+	p.logger.DebugContext(ctx, "starting event processing cycle", "has_marker", currentMarker != "")
+
+	// Give stages a chance to pick up changed lookup data
+	for _, stage := range p.stages {
+		if r, ok := stage.(Refresher); ok {
+			r.Refresh()
+		}
+	}
+
+	// Save the marker of pages delivered in the background since the last cycle
+	if saved, err := p.saveDelivered(ctx); err != nil {
+		numErrors++
+		p.logger.ErrorContext(ctx, "failed to save marker", "error", err.Error())
+		p.stats.RecordError(ErrorMarker, p.feed, "failed to save marker: "+err.Error())
+		if errors.Is(err, marker.ErrConflict) {
+			currentMarker = p.markerManager.Get()
+		}
+	} else if saved {
+		markerUpdates++
+	}
 
-	for paginationCount < p.cfg.MaxPagination {
+	var lag time.Duration // Age of the newest event fetched, when known
+	for p.keepPaginating(ctx, paginationCount, pollStart) {
 		select {
 		case <-ctx.Done():
 			return fmt.Errorf("context cancelled during pagination")
@@ -71,6 +206,7 @@ func (p *Processor) ProcessEvents(ctx context.Context) error {
 
 		// Fetch events page with retry logic
 		page, err := p.apiClient.FetchWithRetry(
+			ctx,
 			currentMarker,
 			p.cfg.RetryAttempts,
 			time.Duration(p.cfg.RetryDelay)*time.Second,
@@ -78,40 +214,92 @@ func (p *Processor) ProcessEvents(ctx context.Context) error {
 
 		if err != nil {
 			numErrors++
-			p.logger.Error("failed to fetch events page",
+			p.logger.ErrorContext(ctx, "failed to fetch events page",
 				"page", paginationCount+1,
-				"error", err.Error())
+				"error", err.Error(),
+				"error_class", api.ErrorClass(err))
+			p.stats.RecordError(ErrorAPI, p.feed, err.Error())
+
+			// Credentials, query, throttling, and oversized responses fail the
+			// cycle so the main loop backs off instead of polling again on schedule
+			switch api.ErrorClass(err) {
+			case api.ClassAuth, api.ClassSchema, api.ClassRateLimit, api.ClassLimit:
+				return err
+			}
 			break
 		}
 
 		paginationCount++
 		pollEnd = time.Now()
+		p.stats.RecordAPILatency(page.Latency)
+		p.stats.AddBytesFetched(page.WireBytes)
+		bytesFetched += page.WireBytes
 
-		p.logger.Debug("fetched events page",
+		if newest := newestEvent(page.Events); !newest.IsZero() {
+			lag = time.Since(newest)
+		}
+
+		p.logger.DebugContext(ctx, "fetched events page",
 			"page", paginationCount,
 			"event_count", len(page.Events),
 			"has_more", page.HasMore)
 
 		if len(page.Events) > 0 {
-			forwarded, err := p.forwardEvents(page.Events)
+			forwarded, rejected, sent, err := p.forwardEvents(ctx, currentMarker, page.Events)
+			bytesSent += sent
 			if err != nil {
 				numErrors++
-				p.logger.Error("failed to forward events",
+				p.logger.ErrorContext(ctx, "failed to forward events",
 					"page", paginationCount,
 					"error", err.Error())
-				continue
+
+				var failed *deliveryError
+				if !errors.As(err, &failed) || p.deadLetter == nil {
+					continue
+				}
+				// Retry the page next cycle, giving the output time to recover,
+				// until it has failed often enough to be dead-lettered
+				if !p.deliveryExhausted(currentMarker) {
+					break
+				}
+				entries := append(failed.rejected, p.deliveryEntries(failed)...)
+				if err := p.writeDeadLetter(entries); err != nil {
+					// Fetching the page again now would resend it to the
+					// outputs that took it; retry it next cycle instead
+					p.logger.ErrorContext(ctx, "failed to dead-letter undeliverable events",
+						"page", paginationCount,
+						"error", err.Error())
+					break
+				}
+				deadLettered += len(entries)
+				p.logger.WarnContext(ctx, "dead-lettered events that repeatedly failed delivery",
+					"page", paginationCount,
+					"events", len(failed.records),
+					"attempts", p.cfg.DeadLetterMaxAttempts,
+					"dead_letter_file", p.deadLetter.Path())
+			} else {
+				p.failedAttempts = 0
 			}
+			deadLettered += rejected
 			totalEventsProcessed += forwarded
 			p.stats.IncrementEventsForwarded(int64(forwarded))
 		}
 
-		// Update marker if it changed
+		// Update marker if it changed, once the page is delivered
 		if page.NewMarker != "" && page.NewMarker != currentMarker {
 			currentMarker = page.NewMarker
-			if err := p.markerManager.Update(currentMarker); err != nil {
+			p.fetchMarker = currentMarker
+			p.pending = append(p.pending, p.newBatch(currentMarker, len(page.Events)))
+			if saved, err := p.saveDelivered(ctx); err != nil {
 				numErrors++
-				p.logger.Error("failed to save marker", "error", err.Error())
-			} else {
+				p.logger.ErrorContext(ctx, "failed to save marker", "error", err.Error())
+				p.stats.RecordError(ErrorMarker, p.feed, "failed to save marker: "+err.Error())
+				// Another instance owns the feed now; the next cycle resumes
+				// from its marker
+				if errors.Is(err, marker.ErrConflict) {
+					break
+				}
+			} else if saved {
 				markerUpdates++
 			}
 		}
@@ -125,22 +313,31 @@ func (p *Processor) ProcessEvents(ctx context.Context) error {
 				eventsPerSecond = float64(totalEventsProcessed) / elapsed.Seconds()
 			}
 
-			p.logger.Info("processing progress",
+			p.logger.InfoContext(ctx, "processing progress",
 				"page", paginationCount,
 				"events_so_far", totalEventsProcessed,
 				"elapsed_sec", int(elapsed.Seconds()),
 				"rate", fmt.Sprintf("%.2f/sec", eventsPerSecond),
-				"marker_updates", markerUpdates)
+				"marker_updates", markerUpdates,
+				"lag_sec", int(lag.Seconds()),
+				"catching_up", paginationCount > p.cfg.MaxPagination)
 
 			lastProgressLog = pollEnd
 		}
 
 		if !page.HasMore {
-			p.logger.Debug("no more events available")
+			p.logger.DebugContext(ctx, "no more events available")
+			drainTime = time.Since(pollStart)
 			break
 		}
+
+		if err := p.pageDelay(ctx, lag); err != nil {
+			return err
+		}
 	}
 
+	p.stats.RecordCycle(paginationCount, drainTime)
+
 	// Calculate statistics
 	duration := pollEnd.Sub(pollStart)
 	eventsPerSecond := 0.0
@@ -148,77 +345,542 @@ func (p *Processor) ProcessEvents(ctx context.Context) error {
 		eventsPerSecond = float64(totalEventsProcessed) / duration.Seconds()
 	}
 
-	p.logger.Info("processing cycle complete",
+	if len(p.pending) > 0 {
+		p.logger.DebugContext(ctx, "marker waits for outputs to deliver", "pending_pages", len(p.pending))
+	}
+
+	p.logger.InfoContext(ctx, "processing cycle complete",
 		"duration_ms", duration.Milliseconds(),
 		"events_processed", totalEventsProcessed,
 		"total_events", p.stats.GetTotalEvents(),
 		"events_per_second", fmt.Sprintf("%.2f", eventsPerSecond),
 		"pages", paginationCount,
+		"drain_ms", drainTime.Milliseconds(),
 		"errors", numErrors,
-		"marker_updates", markerUpdates)
+		"marker_updates", markerUpdates,
+		"bytes_fetched", bytesFetched,
+		"bytes_sent", bytesSent,
+		"dead_lettered", deadLettered)
 
 	return nil
 }
 
-// forwardEvents sends events to syslog as CEF messages
-func (p *Processor) forwardEvents(events []map[string]string) (int, error) {
-	var forwardedCount int
+// keepPaginating reports whether the cycle fetches another page: always
+// below max_pagination_requests, and past it in catch-up mode until the
+// catch-up budget is spent
+func (p *Processor) keepPaginating(ctx context.Context, pages int, start time.Time) bool {
+	if pages < p.cfg.MaxPagination {
+		return true
+	}
+	if p.cfg.CatchUpBudget <= 0 {
+		return false
+	}
 
-	for _, fieldsMap := range events {
-		// Determine hostname/source IP
-		hostname := syslog.DetermineHostname(
-			p.cfg.UseEventIP,
-			p.cfg.CustomSourceIP,
-			fieldsMap,
-		)
+	if elapsed := time.Since(start); elapsed >= time.Duration(p.cfg.CatchUpBudget)*time.Second {
+		p.logger.WarnContext(ctx, "catch-up budget spent, remaining events are fetched next cycle",
+			"pages", pages,
+			"elapsed_sec", int(elapsed.Seconds()))
+		return false
+	}
+	if pages == p.cfg.MaxPagination {
+		p.logger.InfoContext(ctx, "feed has more than max_pagination_requests pages, catching up",
+			"pages", pages,
+			"budget_sec", p.cfg.CatchUpBudget)
+	}
+	return true
+}
+
+// pageDelay waits page_delay_ms before the next page is fetched, unless the
+// newest event fetched is more than drain_lag_seconds old, in which case the
+// backlog is drained without waiting
+func (p *Processor) pageDelay(ctx context.Context, lag time.Duration) error {
+	delay := time.Duration(p.cfg.PageDelay) * time.Millisecond
+	if delay <= 0 {
+		return nil
+	}
+	if p.cfg.DrainLag > 0 && lag > time.Duration(p.cfg.DrainLag)*time.Second {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("context cancelled during pagination")
+	case <-time.After(delay):
+		return nil
+	}
+}
+
+// newBatch records the position of every acknowledger after a page was
+// written to them
+func (p *Processor) newBatch(marker string, events int) pendingBatch {
+	sent := make([]int64, len(p.ackers))
+	for i, a := range p.ackers {
+		sent[i] = a.Sent()
+	}
+	return pendingBatch{marker: marker, events: events, sent: sent}
+}
+
+// delivered reports whether every acknowledger has delivered a page
+func (p *Processor) delivered(batch pendingBatch) bool {
+	for i, a := range p.ackers {
+		if a.Acked() < batch.sent[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// saveDelivered saves the marker of the newest pending page that was
+// delivered along with every page before it, and reports whether it saved
+// one. Pages after the first undelivered one keep waiting, so a restart
+// fetches them again. On a conflict the pending pages are abandoned and
+// fetching resumes from the other instance's marker.
+func (p *Processor) saveDelivered(ctx context.Context) (bool, error) {
+	n, events := 0, 0
+	for n < len(p.pending) && p.delivered(p.pending[n]) {
+		events += p.pending[n].events
+		n++
+	}
+	if n == 0 {
+		return false, nil
+	}
 
-		// Format as CEF
-		cefMessage := p.cefFormatter.Format(fieldsMap)
+	if err := p.markerManager.Update(ctx, p.pending[n-1].marker, events); err != nil {
+		if errors.Is(err, marker.ErrConflict) {
+			p.pending = nil
+			p.fetchMarker = ""
+		}
+		return false, err
+	}
+	p.pending = p.pending[n:]
+	p.stats.MarkMarkerUpdated()
+	return true, nil
+}
 
-		// Format as syslog
-		syslogMessage := syslog.FormatMessage(hostname, cefMessage)
+// SaveDelivered saves the marker of the pages delivered since the last
+// cycle. It is called at shutdown once the outputs are closed, and logs the
+// pages that will be fetched again.
+func (p *Processor) SaveDelivered(ctx context.Context) {
+	if _, err := p.saveDelivered(ctx); err != nil {
+		p.logger.ErrorContext(ctx, "failed to save marker", "error", err.Error())
+		p.stats.RecordError(ErrorMarker, p.feed, "failed to save marker: "+err.Error())
+	}
+	if len(p.pending) > 0 {
+		p.logger.WarnContext(ctx, "pages not delivered by every output, they will be fetched again",
+			"pages", len(p.pending))
+	}
+}
 
-		// Truncate if necessary
-		if len(syslogMessage) > p.cfg.MaxMsgSize {
-			p.logger.Debug("truncating oversized message",
-				"original_size", len(syslogMessage),
-				"max_size", p.cfg.MaxMsgSize)
-			syslogMessage = syslogMessage[:p.cfg.MaxMsgSize]
+// forwardEvents runs the stages on events once and delivers them to every
+// output, which formats them itself. It returns the number of events
+// forwarded, the number dead-lettered because the stages failed on them, and
+// the bytes written to all outputs. Outputs that fail to take the page are
+// reported as a *deliveryError. from is the marker the page was fetched
+// from, which identifies it in the journal.
+func (p *Processor) forwardEvents(ctx context.Context, from string, events []map[string]string) (int, int, int64, error) {
+	selected, indexes, sampledOut, aggregated := p.selectEvents(events)
+
+	records := make([]output.Record, 0, len(selected))
+	sources := make([]int, 0, len(selected)) // Index of the last page event each record covers
+	eventTimes := make([]time.Time, 0, len(selected))
+	eventTypes := make(map[EventType]int64)
+	var rejected []dlq.Entry
+
+	for i, fieldsMap := range selected {
+		// Read before stages run, they may rename or drop the fields
+		t, hasTime := EventTime(fieldsMap)
+		eventType := eventTypeOf(fieldsMap)
+
+		record, err := p.formatEvent(fieldsMap)
+		if err != nil {
+			var failed *formatError
+			errors.As(err, &failed)
+			rejected = append(rejected, p.deadLetterEntry(dlq.ReasonFormat, "", err, output.Record{Fields: failed.fields}))
+			continue
 		}
 
-		// Send to syslog with retry on failure
-		if err := p.syslogWriter.Write(syslogMessage); err != nil {
-			p.logger.Warn("syslog write failed, attempting reconnect", "error", err.Error())
+		if hasTime {
+			eventTimes = append(eventTimes, t)
+		}
+		records = append(records, record)
+		sources = append(sources, indexes[i])
+		eventTypes[eventType]++
+	}
 
-			if reconnectErr := p.syslogWriter.Reconnect(); reconnectErr != nil {
-				return forwardedCount, fmt.Errorf("reconnection failed: %w", reconnectErr)
+	if len(rejected) > 0 {
+		p.logger.WarnContext(ctx, "events failed formatting",
+			"count", len(rejected),
+			"error", rejected[0].Error)
+		p.stats.RecordError(ErrorFormat, p.feed, fmt.Sprintf("%d events failed formatting: %s", len(rejected), rejected[0].Error))
+	}
+
+	// A page counts as forwarded only once every output has it
+	var totalSent int64
+	var failures []outputFailure
+	if len(records) > 0 {
+		entry := p.startJournal(ctx, from, len(events))
+		for _, sink := range p.sinks {
+			pending, pendingSources := records, sources
+			if entry != nil {
+				pending, pendingSources = untaken(records, sources, entry.Outputs[sink.Name()])
+				if len(pending) == 0 {
+					continue
+				}
 			}
 
-			// Retry write after reconnect
-			if err = p.syslogWriter.Write(syslogMessage); err != nil {
-				return forwardedCount, fmt.Errorf("write failed after reconnect: %w", err)
+			writeStart := time.Now()
+			bytesSent, err := p.writeSink(ctx, sink, entry, pending, pendingSources, len(events))
+			p.stats.RecordWriteDuration(time.Since(writeStart))
+			p.stats.AddBytesSent(bytesSent)
+			p.stats.RecordOutputWrite(sink.Name(), len(pending), bytesSent, err)
+			totalSent += bytesSent
+			if err != nil {
+				failures = append(failures, outputFailure{output: sink.Name(), err: err})
+				p.stats.RecordError(ErrorOutput, sink.Name(), err.Error())
 			}
 		}
+	}
+	if len(failures) > 0 {
+		return 0, 0, totalSent, &deliveryError{records: records, rejected: rejected, failures: failures}
+	}
+
+	if err := p.writeDeadLetter(rejected); err != nil {
+		return 0, 0, totalSent, fmt.Errorf("failed to dead-letter events that failed formatting: %w", err)
+	}
 
-		forwardedCount++
+	delivered := time.Now()
+	for _, t := range eventTimes {
+		p.stats.RecordEventLatency(delivered.Sub(t))
+	}
+	p.stats.AddEventTypes(eventTypes)
+	p.stats.AddSampledOut(sampledOut)
+	p.stats.AddAggregated(aggregated)
+
+	p.logger.DebugContext(ctx, "forwarded events batch", "count", len(records), "outputs", len(p.sinks))
+	return len(records), len(rejected), totalSent, nil
+}
+
+// selectEvents runs the filter and aggregator stages on a page. It returns
+// the events to format, the index of the last page event each covers, the
+// number of events filtered out, and the number rolled into another event.
+func (p *Processor) selectEvents(events []map[string]string) ([]map[string]string, []int, int64, int64) {
+	selected := make([]map[string]string, 0, len(events))
+	indexes := make([]int, 0, len(events))
+	var filtered int64
+	for i, fields := range events {
+		if !p.keep(fields) {
+			filtered++
+			continue
+		}
+		selected = append(selected, fields)
+		indexes = append(indexes, i)
 	}
 
-	p.logger.Debug("forwarded events batch", "count", forwardedCount)
-	return forwardedCount, nil
+	var aggregated int64
+	for _, stage := range p.stages {
+		a, ok := stage.(Aggregator)
+		if !ok {
+			continue
+		}
+		rolled, last := a.Aggregate(selected)
+		aggregated += int64(len(selected) - len(rolled))
+		for j, k := range last {
+			last[j] = indexes[k]
+		}
+		selected, indexes = rolled, last
+	}
+	return selected, indexes, filtered, aggregated
+}
+
+// keep reports whether every filter stage keeps an event
+func (p *Processor) keep(fields map[string]string) bool {
+	for _, stage := range p.stages {
+		if f, ok := stage.(Filter); ok && !f.Keep(fields) {
+			return false
+		}
+	}
+	return true
+}
+
+// aggregates reports whether a stage rolls up events
+func (p *Processor) aggregates() bool {
+	for _, stage := range p.stages {
+		if _, ok := stage.(Aggregator); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// untaken returns the records covering page events from index taken on,
+// with the index of the last page event each covers
+func untaken(records []output.Record, sources []int, taken int) ([]output.Record, []int) {
+	if taken == 0 {
+		return records, sources
+	}
+	var pending []output.Record
+	var pendingSources []int
+	for i, record := range records {
+		if sources[i] >= taken {
+			pending = append(pending, record)
+			pendingSources = append(pendingSources, sources[i])
+		}
+	}
+	return pending, pendingSources
+}
+
+// writeSink delivers records to an output. With a journal entry they are
+// written in chunks of state.journal_chunk_events, journaling after each the
+// page events taken, so a crash part-way through resends at most one chunk.
+// Rolled-up records are not in page order, so a chunk only counts up to the
+// earliest page event a record still to write covers; the last chunk takes
+// the whole page, events filtered out included.
+func (p *Processor) writeSink(ctx context.Context, sink output.Sink, entry *marker.JournalEntry, records []output.Record, sources []int, events int) (int64, error) {
+	if entry == nil {
+		return sink.Write(ctx, records)
+	}
+
+	// earliest[i] is the smallest page event index records from i on cover
+	earliest := make([]int, len(sources)+1)
+	earliest[len(sources)] = events
+	for i := len(sources) - 1; i >= 0; i-- {
+		earliest[i] = min(sources[i], earliest[i+1])
+	}
+
+	chunk := p.cfg.JournalChunk
+	if chunk <= 0 {
+		chunk = len(records)
+	}
+	var bytesSent int64
+	for start := 0; start < len(records); start += chunk {
+		end := min(start+chunk, len(records))
+		sent, err := sink.Write(ctx, records[start:end])
+		bytesSent += sent
+		if err != nil {
+			return bytesSent, err
+		}
+		p.journalOutput(ctx, entry, sink, earliest[end])
+	}
+	return bytesSent, nil
+}
+
+// startJournal returns the journal entry of a page about to be forwarded,
+// nil without a journal. A page fetched again from the marker of the
+// journaled page resumes its progress; any other page starts a new entry.
+func (p *Processor) startJournal(ctx context.Context, from string, events int) *marker.JournalEntry {
+	if !p.journaling {
+		return nil
+	}
+
+	// Rolled-up events of a page that grew cover different events, so only
+	// an unchanged page resumes when events are aggregated
+	resume := p.journal != nil && from != "" && p.journal.Marker == from &&
+		(p.journal.Events == events || !p.aggregates())
+	if resume {
+		if len(p.journal.Outputs) > 0 {
+			p.logger.InfoContext(ctx, "resuming page from the marker journal, skipping events outputs already took",
+				"events", events,
+				"outputs", p.journal.Outputs)
+		}
+		if p.journal.Outputs == nil {
+			p.journal.Outputs = make(map[string]int)
+		}
+		p.journal.Events = events
+		return p.journal
+	}
+
+	p.journal = &marker.JournalEntry{Marker: from, Events: events, Outputs: make(map[string]int)}
+	if err := p.markerManager.WriteJournal(ctx, *p.journal); err != nil {
+		p.logger.WarnContext(ctx, "failed to save marker journal", "error", err.Error())
+		p.stats.RecordError(ErrorMarker, p.feed, "failed to save marker journal: "+err.Error())
+	}
+	return p.journal
+}
+
+// journalOutput records that an output took the first taken events of the
+// journaled page. An output still delivering in the background is left out,
+// since a crash loses what it has queued.
+func (p *Processor) journalOutput(ctx context.Context, entry *marker.JournalEntry, sink output.Sink, taken int) {
+	if a, ok := sink.(output.Acknowledger); ok && a.Acked() < a.Sent() {
+		return
+	}
+	entry.Outputs[sink.Name()] = taken
+	if err := p.markerManager.WriteJournal(ctx, *entry); err != nil {
+		p.logger.WarnContext(ctx, "failed to save marker journal", "error", err.Error())
+		p.stats.RecordError(ErrorMarker, p.feed, "failed to save marker journal: "+err.Error())
+	}
+}
+
+// formatEvent runs the stages on one event. With a dead-letter queue a panic
+// fails only this event, and the returned *formatError holds the fields as
+// they were before the stages ran.
+func (p *Processor) formatEvent(fields map[string]string) (record output.Record, err error) {
+	if p.deadLetter != nil {
+		// Stages modify the map in place, so keep the original for the dead-letter file
+		original := fields
+		if len(p.stages) > 0 {
+			original = make(map[string]string, len(fields))
+			for k, v := range fields {
+				original[k] = v
+			}
+		}
+		defer func() {
+			if r := recover(); r != nil {
+				err = &formatError{fields: original, cause: fmt.Sprint(r)}
+			}
+		}()
+	}
+
+	return Format(p.cfg, p.feed, p.stages, fields), nil
+}
+
+// Format runs the pre-formatting stages on one event of a feed and returns
+// the record outputs format
+func Format(cfg *config.Config, feed string, stages []Stage, fields map[string]string) output.Record {
+	// Run pre-formatting stages (transforms, etc.)
+	for _, stage := range stages {
+		fields = stage.Apply(fields)
+	}
+
+	// Labels attribute the event to this forwarder, over any event field of the same name
+	for name, value := range cfg.Labels {
+		fields[name] = value
+	}
+	if cfg.DeploymentIDField != "" {
+		fields[cfg.DeploymentIDField] = cfg.DeploymentID
+	}
+
+	// Determine hostname/source IP
+	hostname := syslog.DetermineHostname(
+		cfg.UseEventIP,
+		cfg.CustomSourceIP,
+		fields,
+	)
+
+	return output.Record{
+		Feed:     feed,
+		Fields:   fields,
+		Hostname: hostname,
+	}
+}
+
+// formatError reports an event that could not be formatted
+type formatError struct {
+	fields map[string]string
+	cause  string
+}
+
+func (e *formatError) Error() string {
+	return "formatting failed: " + e.cause
+}
+
+// outputFailure is one output that rejected a page
+type outputFailure struct {
+	output string
+	err    error
+}
+
+// deliveryError reports the outputs that failed to take a page of records
+type deliveryError struct {
+	records  []output.Record
+	rejected []dlq.Entry // Format failures from the same page, not yet dead-lettered
+	failures []outputFailure
+}
+
+func (e *deliveryError) Error() string {
+	messages := make([]string, len(e.failures))
+	for i, failure := range e.failures {
+		messages[i] = fmt.Sprintf("output %s: %v", failure.output, failure.err)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// deliveryEntries returns a dead-letter entry per record and failed output,
+// so a replay only resends to the outputs that missed the page
+func (p *Processor) deliveryEntries(e *deliveryError) []dlq.Entry {
+	entries := make([]dlq.Entry, 0, len(e.records)*len(e.failures))
+	for _, failure := range e.failures {
+		for _, record := range e.records {
+			entries = append(entries, p.deadLetterEntry(dlq.ReasonDelivery, failure.output, failure.err, record))
+		}
+	}
+	return entries
+}
+
+// deadLetterEntry describes one event for the dead-letter file
+func (p *Processor) deadLetterEntry(reason, outputName string, err error, record output.Record) dlq.Entry {
+	return dlq.Entry{
+		Time:     time.Now().UTC(),
+		Feed:     p.feed,
+		Account:  p.accountID,
+		Reason:   reason,
+		Output:   outputName,
+		Error:    err.Error(),
+		Fields:   record.Fields,
+		Hostname: record.Hostname,
+	}
+}
+
+// deliveryExhausted records a failed delivery of the page fetched at marker
+// and reports whether the page should now be dead-lettered
+func (p *Processor) deliveryExhausted(marker string) bool {
+	if p.failedAttempts == 0 || marker != p.failedMarker {
+		p.failedMarker = marker
+		p.failedAttempts = 0
+	}
+	p.failedAttempts++
+	if p.failedAttempts < p.cfg.DeadLetterMaxAttempts {
+		return false
+	}
+	p.failedAttempts = 0
+	return true
+}
+
+// writeDeadLetter appends entries to the dead-letter file
+func (p *Processor) writeDeadLetter(entries []dlq.Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	if err := p.deadLetter.Append(entries); err != nil {
+		return err
+	}
+	p.stats.AddDeadLettered(int64(len(entries)))
+	return nil
+}
+
+// EventTime returns the timestamp of an event from its time field
+func EventTime(fields map[string]string) (time.Time, bool) {
+	t, err := time.Parse(time.RFC3339Nano, fields["time"])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// newestEvent returns the latest timestamp of events, zero if none has one
+func newestEvent(events []map[string]string) time.Time {
+	var newest time.Time
+	for _, fields := range events {
+		if t, ok := EventTime(fields); ok && t.After(newest) {
+			newest = t
+		}
+	}
+	return newest
 }
 
 // ProcessWithRecovery wraps ProcessEvents with panic recovery
 func (p *Processor) ProcessWithRecovery(ctx context.Context) bool {
 	defer func() {
 		if r := recover(); r != nil {
-			p.logger.Error("PANIC recovered in event processing", "panic", r)
+			p.logger.Error("PANIC recovered in event processing", "panic", r, "cycle_id", p.stats.Snapshot().LastCycleID)
 			p.stats.IncrementFailedAPIRequests()
+			p.stats.RecordError(ErrorProcessing, p.feed, fmt.Sprintf("panic: %v", r))
 		}
 	}()
 
 	err := p.ProcessEvents(ctx)
 	if err != nil {
-		p.logger.Error("event processing failed", "error", err.Error())
+		p.logger.Error("event processing failed", "error", err.Error(), "cycle_id", p.stats.Snapshot().LastCycleID)
 		p.stats.IncrementFailedAPIRequests()
 		return false
 	}
@@ -0,0 +1,223 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"cato-logger/internal/api"
+	"cato-logger/internal/dlq"
+	"cato-logger/internal/marker"
+)
+
+func TestProcessEventsFetchError(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		wantErr bool // Whether the cycle fails rather than ending early
+	}{
+		{name: "transient", err: errors.New("connection reset by peer")},
+		{name: "auth", err: &api.AuthError{Code: "UNAUTHENTICATED", Message: "invalid API key"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &fakeSource{err: tt.err}
+			markers := &fakeMarkers{marker: "m0"}
+			sink := &fakeSink{name: "a"}
+			p, stats := newTestProcessor(t, source, markers, sink)
+
+			err := p.ProcessEvents(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ProcessEvents() error = %v, want error %v", err, tt.wantErr)
+			}
+			if len(source.fetches) != 1 {
+				t.Errorf("fetched %d pages, want the cycle to stop after the failed fetch", len(source.fetches))
+			}
+			if got := sink.received(); len(got) != 0 {
+				t.Errorf("sink received %v, want nothing", got)
+			}
+			if len(markers.updates) != 0 || markers.marker != "m0" {
+				t.Errorf("marker = %q after updates %v, want it left at m0", markers.marker, markers.updates)
+			}
+			if n := errorCount(stats, ErrorAPI); n != 1 {
+				t.Errorf("recorded %d api errors, want 1", n)
+			}
+		})
+	}
+}
+
+func TestProcessEventsPartialOutputFailure(t *testing.T) {
+	pages := map[string]*api.EventsPage{
+		"":   testPage("m1", true, "1", "2"),
+		"m1": testPage("m2", false, "3", "4", "5", "6"),
+	}
+
+	t.Run("without journal", func(t *testing.T) {
+		source := &fakeSource{pages: pages}
+		markers := &fakeMarkers{}
+		good := &fakeSink{name: "a"}
+		bad := &fakeSink{name: "b", failIDs: map[string]bool{"5": true}}
+		p, stats := newTestProcessor(t, source, markers, good, bad)
+
+		if err := p.ProcessEvents(context.Background()); err != nil {
+			t.Fatalf("ProcessEvents() error = %v", err)
+		}
+		if want := []string{"m1"}; !reflect.DeepEqual(markers.updates, want) {
+			t.Errorf("marker updates = %v, want %v: the failed page must not advance the marker", markers.updates, want)
+		}
+		for _, marker := range source.fetches[1:] {
+			if marker != "m1" {
+				t.Errorf("fetched from %q, want the failed page fetched again from m1", marker)
+			}
+		}
+		if got := bad.received(); !reflect.DeepEqual(got, []string{"1", "2"}) {
+			t.Errorf("failing sink received %v, want only the first page", got)
+		}
+		// Every retry sends the whole page to the sink that took it
+		if got, want := len(good.received()), 2+4*(len(source.fetches)-1); got != want {
+			t.Errorf("working sink received %d events, want %d", got, want)
+		}
+		if n := errorCount(stats, ErrorOutput); n != len(source.fetches)-1 {
+			t.Errorf("recorded %d output errors, want one per failed delivery (%d)", n, len(source.fetches)-1)
+		}
+	})
+
+	t.Run("with journal", func(t *testing.T) {
+		source := &fakeSource{pages: pages}
+		markers := &fakeMarkers{}
+		good := &fakeSink{name: "a"}
+		bad := &fakeSink{name: "b", failIDs: map[string]bool{"5": true}}
+		p, _ := newTestProcessor(t, source, markers, good, bad)
+		p.EnableJournal(nil)
+
+		if err := p.ProcessEvents(context.Background()); err != nil {
+			t.Fatalf("ProcessEvents() error = %v", err)
+		}
+		if want := []string{"m1"}; !reflect.DeepEqual(markers.updates, want) {
+			t.Errorf("marker updates = %v, want %v", markers.updates, want)
+		}
+		if got, want := good.received(), []string{"1", "2", "3", "4", "5", "6"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("working sink received %v, want every event once", got)
+		}
+		if got, want := bad.received(), []string{"1", "2", "3", "4"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("failing sink received %v, want the chunk before the failure once", got)
+		}
+		last := markers.journal[len(markers.journal)-1]
+		if want := map[string]int{"a": 4, "b": 2}; last.Marker != "m1" || !reflect.DeepEqual(last.Outputs, want) {
+			t.Errorf("journal = %+v, want page m1 with outputs %v", last, want)
+		}
+	})
+}
+
+func TestProcessEventsDeadLetterError(t *testing.T) {
+	source := &fakeSource{pages: map[string]*api.EventsPage{
+		"": testPage("m1", false, "1", "2"),
+	}}
+	markers := &fakeMarkers{}
+	good := &fakeSink{name: "a"}
+	bad := &fakeSink{name: "b", failIDs: map[string]bool{"2": true}}
+	p, stats := newTestProcessor(t, source, markers, good, bad)
+
+	// A full dead-letter file refuses the page on its first failure
+	path := filepath.Join(t.TempDir(), "dead-letter.jsonl")
+	if err := os.WriteFile(path, []byte("{}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	queue, err := dlq.Open(path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.cfg.DeadLetterMaxAttempts = 1
+	p.EnableDeadLetter(queue, "12345")
+
+	if err := p.ProcessEvents(context.Background()); err != nil {
+		t.Fatalf("ProcessEvents() error = %v", err)
+	}
+	if want := []string{""}; !reflect.DeepEqual(source.fetches, want) {
+		t.Errorf("fetched from %v, want the page left for the next cycle", source.fetches)
+	}
+	if got := good.received(); !reflect.DeepEqual(got, []string{"1", "2"}) {
+		t.Errorf("working sink received %v, want the page once", got)
+	}
+	if len(markers.updates) != 0 {
+		t.Errorf("marker updates = %v, want none", markers.updates)
+	}
+	if n := errorCount(stats, ErrorOutput); n != 1 {
+		t.Errorf("recorded %d output errors, want 1", n)
+	}
+}
+
+func TestProcessEventsMarkerSaveError(t *testing.T) {
+	t.Run("save fails", func(t *testing.T) {
+		source := &fakeSource{pages: map[string]*api.EventsPage{
+			"": testPage("m1", false, "1", "2"),
+		}}
+		markers := &fakeMarkers{updateErr: errors.New("disk full")}
+		sink := &fakeSink{name: "a"}
+		p, stats := newTestProcessor(t, source, markers, sink)
+
+		if err := p.ProcessEvents(context.Background()); err != nil {
+			t.Fatalf("ProcessEvents() error = %v", err)
+		}
+		if markers.marker != "" {
+			t.Errorf("marker = %q, want it unsaved", markers.marker)
+		}
+		if n := errorCount(stats, ErrorMarker); n != 1 {
+			t.Errorf("recorded %d marker errors, want 1", n)
+		}
+
+		// The next cycle saves the delivered page and fetches after it
+		// rather than sending the page again
+		markers.updateErr = nil
+		if err := p.ProcessEvents(context.Background()); err != nil {
+			t.Fatalf("ProcessEvents() error = %v", err)
+		}
+		if want := []string{"m1"}; !reflect.DeepEqual(markers.updates, want) {
+			t.Errorf("marker updates = %v, want %v", markers.updates, want)
+		}
+		if want := []string{"", "m1"}; !reflect.DeepEqual(source.fetches, want) {
+			t.Errorf("fetched from %v, want %v", source.fetches, want)
+		}
+		if got := sink.received(); !reflect.DeepEqual(got, []string{"1", "2"}) {
+			t.Errorf("sink received %v, want the page once", got)
+		}
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		source := &fakeSource{pages: map[string]*api.EventsPage{
+			"":      testPage("m1", true, "1", "2"),
+			"m1":    testPage("m2", false, "3"),
+			"other": testPage("other", false),
+		}}
+		markers := &fakeMarkers{updateErr: marker.ErrConflict}
+		sink := &fakeSink{name: "a"}
+		p, stats := newTestProcessor(t, source, markers, sink)
+
+		if err := p.ProcessEvents(context.Background()); err != nil {
+			t.Fatalf("ProcessEvents() error = %v", err)
+		}
+		if want := []string{""}; !reflect.DeepEqual(source.fetches, want) {
+			t.Errorf("fetched from %v, want the cycle to stop after the conflict", source.fetches)
+		}
+		if n := errorCount(stats, ErrorMarker); n != 1 {
+			t.Errorf("recorded %d marker errors, want 1", n)
+		}
+
+		// Another instance moved the marker; the next cycle resumes from it
+		markers.updateErr = nil
+		markers.marker = "other"
+		if err := p.ProcessEvents(context.Background()); err != nil {
+			t.Fatalf("ProcessEvents() error = %v", err)
+		}
+		if want := []string{"", "other"}; !reflect.DeepEqual(source.fetches, want) {
+			t.Errorf("fetched from %v, want %v", source.fetches, want)
+		}
+		if len(markers.updates) != 0 {
+			t.Errorf("marker updates = %v, want none over the other instance's marker", markers.updates)
+		}
+	})
+}
@@ -0,0 +1,84 @@
+package processor
+
+import "time"
+
+// SLOWindow is the outcome of one delivery latency SLO window
+type SLOWindow struct {
+	Events      int64   // Events delivered with a known event time
+	Late        int64   // Events delivered later than the SLO allows
+	Compliance  float64 // Percent of events delivered in time, 100 without events
+	Violated    bool
+	Recovered   bool // Met the target after violated windows
+	Consecutive int  // Violated windows in a row, including this one
+}
+
+// SLOStatus is the delivery latency SLO state for metrics
+type SLOStatus struct {
+	Enabled     bool
+	Latency     time.Duration
+	Target      float64 // Percent
+	Compliance  float64 // Percent in the last evaluated window
+	Evaluated   int64   // Windows evaluated
+	Violations  int64   // Windows that missed the target
+	Consecutive int     // Violated windows in a row up to the last one
+}
+
+// SetSLO sets the delivery latency SLO: target percent of events must reach
+// every output within latency of their event time. A zero latency disables
+// it. Changing the SLO starts a new window.
+func (s *Stats) SetSLO(latency time.Duration, target float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.slo.Enabled = latency > 0
+	s.slo.Latency = latency
+	s.slo.Target = target
+	s.slo.Consecutive = 0
+	s.sloEvents, s.sloLate = 0, 0
+}
+
+// recordSLO counts a delivered event against the SLO. The caller holds the
+// lock.
+func (s *Stats) recordSLO(d time.Duration) {
+	if !s.slo.Enabled {
+		return
+	}
+	s.sloEvents++
+	if d > s.slo.Latency {
+		s.sloLate++
+	}
+}
+
+// EvaluateSLO closes the current SLO window and starts a new one. A window
+// without events neither violates the SLO nor ends a run of violations.
+func (s *Stats) EvaluateSLO() SLOWindow {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	window := SLOWindow{Events: s.sloEvents, Late: s.sloLate, Compliance: 100}
+	s.sloEvents, s.sloLate = 0, 0
+	if window.Events == 0 {
+		window.Consecutive = s.slo.Consecutive
+		return window
+	}
+
+	window.Compliance = float64(window.Events-window.Late) / float64(window.Events) * 100
+	window.Violated = window.Compliance < s.slo.Target
+	if window.Violated {
+		s.slo.Violations++
+		s.slo.Consecutive++
+	} else {
+		window.Recovered = s.slo.Consecutive > 0
+		s.slo.Consecutive = 0
+	}
+	s.slo.Evaluated++
+	s.slo.Compliance = window.Compliance
+	window.Consecutive = s.slo.Consecutive
+	return window
+}
+
+// SLO returns the delivery latency SLO state
+func (s *Stats) SLO() SLOStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.slo
+}
@@ -1,20 +1,155 @@
 package processor
 
 import (
+	"maps"
+	"sort"
 	"sync"
+	"time"
 )
 
+// maxLatencySamples caps the API latency samples kept per reporting window
+const maxLatencySamples = 10000
+
+// maxRecentErrors is the number of errors kept for inspection
+const maxRecentErrors = 100
+
+// Error categories
+const (
+	ErrorAPI        = "api"        // Fetching from the Cato API
+	ErrorOutput     = "output"     // Writing to or reconnecting an output
+	ErrorMarker     = "marker"     // Saving the marker or its journal
+	ErrorFormat     = "format"     // Events that could not be formatted
+	ErrorProcessing = "processing" // Panics in a processing cycle
+)
+
+// ErrorRecord is an error kept for inspection
+type ErrorRecord struct {
+	Time     time.Time `json:"time"`
+	Category string    `json:"category"`
+	Source   string    `json:"source"` // Feed or output the error came from
+	Message  string    `json:"message"`
+}
+
+// MarkerInfo is the state of one feed's marker
+type MarkerInfo struct {
+	Feed       string    `json:"feed"` // Runner name, feed/account with several accounts
+	Marker     string    `json:"marker"`
+	LastUpdate time.Time `json:"last_update"`
+	Stale      bool      `json:"stale"`
+}
+
 // Stats tracks basic service metrics for logging purposes
 type Stats struct {
 	mu                   sync.RWMutex
 	TotalEventsForwarded int64
 	TotalAPIRequests     int64
 	FailedAPIRequests    int64
+	TotalBytesFetched    int64
+	TotalBytesSent       int64
+	TotalReconnects      int64
+	ReconnectFailures    int64
+	TotalDeadLettered    int64
+	TotalDropped         int64
+	TotalTruncated       int64
+	TotalSampledOut      int64
+	TotalAggregated      int64
+	StaleMarkerAlarms    int64
+	LastMarkerUpdate     time.Time
+	StartTime            time.Time
+	LastCycleID          string
+	LastCyclePages       int           // Pages fetched by the last finished cycle
+	LastDrainTime        time.Duration // Time the last cycle took to catch up, 0 if it did not
+	staleFeeds           []string
+	markers              []MarkerInfo
+	recentErrors         []ErrorRecord // Ring buffer of the last maxRecentErrors
+	nextError            int           // Slot of recentErrors written next
+	errorCounts          map[string]int64
+	reconnecting         map[string]bool // Outputs whose last reconnect attempt failed
+	eventTypes           map[EventType]int64
+	outputs              map[string]*outputCounters
+	outputOrder          []string // Output names in the order they were added
+	slo                  SLOStatus
+	sloEvents            int64 // Events delivered in the current SLO window
+	sloLate              int64 // Of those, events delivered too late
+
+	// Lifetime latency histograms
+	APIDuration   *Histogram // API request round trips
+	WriteDuration *Histogram // Batch writes to a single output
+	EventLatency  *Histogram // Event time until written to every output
+
+	// Reporting window, reset by Report
+	windowStart     time.Time
+	windowEvents    int64
+	windowFetched   int64
+	windowBytes     int64
+	windowRequests  int64
+	windowReconnect int64
+	windowCycles    int64
+	windowPages     int64
+	windowDrainMax  time.Duration
+	windowTypes     map[EventType]int64
+	apiLatencies    []time.Duration
+}
+
+// Report summarizes activity since the previous report
+type Report struct {
+	Window          time.Duration
+	EventsForwarded int64
+	EventsPerSecond float64
+	BytesFetched    int64
+	BytesSent       int64
+	APIRequests     int64
+	APILatencyP50   time.Duration
+	APILatencyP90   time.Duration
+	APILatencyP99   time.Duration
+	Reconnects      int64
+	Cycles          int64
+	PagesPerCycle   float64
+	MaxDrainTime    time.Duration // Longest time a cycle took to catch up
+	TopEventTypes   []EventTypeCount
+	Outputs         []OutputStats // Delivery of each output in the window
+	MarkerAge       time.Duration
+	TotalEvents     int64
+	LastCycleID     string
 }
 
 // NewStats creates a new stats tracker
 func NewStats() *Stats {
-	return &Stats{}
+	now := time.Now()
+	return &Stats{
+		StartTime:     now,
+		APIDuration:   NewHistogram(apiDurationBuckets),
+		WriteDuration: NewHistogram(writeDurationBuckets),
+		EventLatency:  NewHistogram(eventLatencyBuckets),
+		windowStart:   now,
+		reconnecting:  make(map[string]bool),
+		recentErrors:  make([]ErrorRecord, 0, maxRecentErrors),
+		errorCounts:   make(map[string]int64),
+		eventTypes:    make(map[EventType]int64),
+		outputs:       make(map[string]*outputCounters),
+		windowTypes:   make(map[EventType]int64),
+	}
+}
+
+// StartCycle records the correlation ID of the processing cycle now running
+func (s *Stats) StartCycle(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.LastCycleID = id
+}
+
+// RecordCycle records the pages a finished cycle fetched and, when it
+// caught up with the feed, how long that took
+func (s *Stats) RecordCycle(pages int, drain time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.LastCyclePages = pages
+	s.LastDrainTime = drain
+	s.windowCycles++
+	s.windowPages += int64(pages)
+	if drain > s.windowDrainMax {
+		s.windowDrainMax = drain
+	}
 }
 
 // IncrementEventsForwarded adds to the events counter
@@ -22,6 +157,7 @@ func (s *Stats) IncrementEventsForwarded(count int64) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.TotalEventsForwarded += count
+	s.windowEvents += count
 }
 
 // IncrementAPIRequests increments the API request counter
@@ -29,6 +165,7 @@ func (s *Stats) IncrementAPIRequests() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.TotalAPIRequests++
+	s.windowRequests++
 }
 
 // IncrementFailedAPIRequests increments the failed API request counter
@@ -38,6 +175,168 @@ func (s *Stats) IncrementFailedAPIRequests() {
 	s.FailedAPIRequests++
 }
 
+// AddBytesFetched adds to the bytes-received-from-the-API counter
+func (s *Stats) AddBytesFetched(count int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.TotalBytesFetched += count
+	s.windowFetched += count
+}
+
+// AddBytesSent adds to the bytes-written-to-syslog counter
+func (s *Stats) AddBytesSent(count int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.TotalBytesSent += count
+	s.windowBytes += count
+}
+
+// RecordReconnect counts an output's reconnect attempt and tracks whether
+// the output is still reconnecting
+func (s *Stats) RecordReconnect(output string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.TotalReconnects++
+	s.windowReconnect++
+	if err != nil {
+		s.ReconnectFailures++
+		s.reconnecting[output] = true
+		s.recordError(ErrorOutput, output, "reconnect failed: "+err.Error())
+	} else {
+		delete(s.reconnecting, output)
+	}
+}
+
+// AddDeadLettered adds to the counter of entries written to the dead-letter file
+func (s *Stats) AddDeadLettered(count int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.TotalDeadLettered += count
+}
+
+// IncrementDropped counts a message an output queue discarded
+func (s *Stats) IncrementDropped(output string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.TotalDropped++
+	counters := s.output(output)
+	counters.dropped++
+	counters.windowDropped++
+}
+
+// IncrementTruncated counts a message an output cut to its size limit
+func (s *Stats) IncrementTruncated() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.TotalTruncated++
+}
+
+// AddSampledOut adds to the counter of events sampling did not forward
+func (s *Stats) AddSampledOut(count int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.TotalSampledOut += count
+}
+
+// AddAggregated adds to the counter of events rolled up into another event
+func (s *Stats) AddAggregated(count int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.TotalAggregated += count
+}
+
+// IncrementStaleMarkerAlarms counts a feed reported stuck
+func (s *Stats) IncrementStaleMarkerAlarms() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.StaleMarkerAlarms++
+}
+
+// SetMarkers records the current marker of every feed
+func (s *Stats) SetMarkers(markers []MarkerInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.markers = markers
+}
+
+// RecordError keeps an error in the ring buffer, overwriting the oldest
+// one once maxRecentErrors are kept
+func (s *Stats) RecordError(category, source, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recordError(category, source, message)
+}
+
+// recordError is RecordError with the lock held
+func (s *Stats) recordError(category, source, message string) {
+	record := ErrorRecord{Time: time.Now(), Category: category, Source: source, Message: message}
+	if len(s.recentErrors) < maxRecentErrors {
+		s.recentErrors = append(s.recentErrors, record)
+	} else {
+		s.recentErrors[s.nextError] = record
+	}
+	s.nextError = (s.nextError + 1) % maxRecentErrors
+	s.errorCounts[category]++
+}
+
+// Errors returns the kept errors, newest first
+func (s *Stats) Errors() []ErrorRecord {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	errors := make([]ErrorRecord, 0, len(s.recentErrors))
+	for i := 1; i <= len(s.recentErrors); i++ {
+		errors = append(errors, s.recentErrors[(s.nextError-i+maxRecentErrors)%maxRecentErrors])
+	}
+	return errors
+}
+
+// ErrorCounts returns the errors recorded since startup by category
+func (s *Stats) ErrorCounts() map[string]int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return maps.Clone(s.errorCounts)
+}
+
+// SetStaleFeeds records the feeds whose marker is currently stale
+func (s *Stats) SetStaleFeeds(feeds []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.staleFeeds = feeds
+}
+
+// RecordAPILatency records the duration of a successful API request
+func (s *Stats) RecordAPILatency(d time.Duration) {
+	s.APIDuration.Observe(d)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.apiLatencies) < maxLatencySamples {
+		s.apiLatencies = append(s.apiLatencies, d)
+	}
+}
+
+// RecordWriteDuration records how long one output took to write a batch
+func (s *Stats) RecordWriteDuration(d time.Duration) {
+	s.WriteDuration.Observe(d)
+}
+
+// RecordEventLatency records the delay from an event's timestamp until it
+// was written to every output
+func (s *Stats) RecordEventLatency(d time.Duration) {
+	s.EventLatency.Observe(d)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recordSLO(d)
+}
+
+// MarkMarkerUpdated records that the marker advanced
+func (s *Stats) MarkMarkerUpdated() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.LastMarkerUpdate = time.Now()
+}
+
 // GetTotalEvents returns the total events forwarded (thread-safe)
 func (s *Stats) GetTotalEvents() int64 {
 	s.mu.RLock()
@@ -58,3 +357,133 @@ func (s *Stats) GetFailedAPIRequests() int64 {
 	defer s.mu.RUnlock()
 	return s.FailedAPIRequests
 }
+
+// Snapshot is a point-in-time copy of the lifetime counters
+type Snapshot struct {
+	Uptime               time.Duration
+	TotalEventsForwarded int64
+	TotalAPIRequests     int64
+	FailedAPIRequests    int64
+	TotalBytesFetched    int64
+	TotalBytesSent       int64
+	TotalReconnects      int64
+	ReconnectFailures    int64
+	TotalDeadLettered    int64
+	TotalDropped         int64
+	TotalTruncated       int64
+	TotalSampledOut      int64
+	TotalAggregated      int64
+	StaleMarkerAlarms    int64
+	StaleFeeds           []string
+	Reconnecting         []string // Outputs whose last reconnect attempt failed
+	Markers              []MarkerInfo
+	LastMarkerUpdate     time.Time
+	LastCycleID          string
+	LastCyclePages       int
+	LastDrainTime        time.Duration
+	Window               time.Duration // Time since the last report
+	WindowEvents         int64         // Events forwarded since the last report
+}
+
+// Snapshot returns the lifetime counters without affecting the reporting window
+func (s *Stats) Snapshot() Snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	reconnecting := make([]string, 0, len(s.reconnecting))
+	for output := range s.reconnecting {
+		reconnecting = append(reconnecting, output)
+	}
+	sort.Strings(reconnecting)
+
+	return Snapshot{
+		Uptime:               time.Since(s.StartTime),
+		TotalEventsForwarded: s.TotalEventsForwarded,
+		TotalAPIRequests:     s.TotalAPIRequests,
+		FailedAPIRequests:    s.FailedAPIRequests,
+		TotalBytesFetched:    s.TotalBytesFetched,
+		TotalBytesSent:       s.TotalBytesSent,
+		TotalReconnects:      s.TotalReconnects,
+		ReconnectFailures:    s.ReconnectFailures,
+		TotalDeadLettered:    s.TotalDeadLettered,
+		TotalDropped:         s.TotalDropped,
+		TotalTruncated:       s.TotalTruncated,
+		TotalSampledOut:      s.TotalSampledOut,
+		TotalAggregated:      s.TotalAggregated,
+		StaleMarkerAlarms:    s.StaleMarkerAlarms,
+		StaleFeeds:           s.staleFeeds,
+		Reconnecting:         reconnecting,
+		Markers:              s.markers,
+		LastMarkerUpdate:     s.LastMarkerUpdate,
+		LastCycleID:          s.LastCycleID,
+		LastCyclePages:       s.LastCyclePages,
+		LastDrainTime:        s.LastDrainTime,
+		Window:               time.Since(s.windowStart),
+		WindowEvents:         s.windowEvents,
+	}
+}
+
+// Report returns a summary of the current window and starts a new one
+func (s *Stats) Report() Report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	report := Report{
+		Window:          now.Sub(s.windowStart),
+		EventsForwarded: s.windowEvents,
+		BytesFetched:    s.windowFetched,
+		BytesSent:       s.windowBytes,
+		APIRequests:     s.windowRequests,
+		Reconnects:      s.windowReconnect,
+		Cycles:          s.windowCycles,
+		MaxDrainTime:    s.windowDrainMax,
+		TopEventTypes:   sortedTypes(s.windowTypes, topEventTypes),
+		Outputs:         s.windowOutputs(),
+		TotalEvents:     s.TotalEventsForwarded,
+		LastCycleID:     s.LastCycleID,
+	}
+
+	if seconds := report.Window.Seconds(); seconds > 0 {
+		report.EventsPerSecond = float64(s.windowEvents) / seconds
+	}
+	if s.windowCycles > 0 {
+		report.PagesPerCycle = float64(s.windowPages) / float64(s.windowCycles)
+	}
+	if !s.LastMarkerUpdate.IsZero() {
+		report.MarkerAge = now.Sub(s.LastMarkerUpdate)
+	}
+
+	if len(s.apiLatencies) > 0 {
+		sort.Slice(s.apiLatencies, func(i, j int) bool { return s.apiLatencies[i] < s.apiLatencies[j] })
+		report.APILatencyP50 = percentile(s.apiLatencies, 0.50)
+		report.APILatencyP90 = percentile(s.apiLatencies, 0.90)
+		report.APILatencyP99 = percentile(s.apiLatencies, 0.99)
+	}
+
+	s.windowStart = now
+	s.windowEvents = 0
+	s.windowFetched = 0
+	s.windowBytes = 0
+	s.windowRequests = 0
+	s.windowReconnect = 0
+	s.windowCycles = 0
+	s.windowPages = 0
+	s.windowDrainMax = 0
+	clear(s.windowTypes)
+	s.apiLatencies = s.apiLatencies[:0]
+
+	return report
+}
+
+// percentile returns the p-th percentile of sorted samples (nearest rank)
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	index := int(float64(len(sorted))*p+0.5) - 1
+	if index < 0 {
+		index = 0
+	}
+	if index >= len(sorted) {
+		index = len(sorted) - 1
+	}
+	return sorted[index]
+}
@@ -0,0 +1,128 @@
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+
+	"cato-logger/internal/config"
+)
+
+const (
+	ActionHash = "hash"
+	ActionMask = "mask"
+)
+
+// hashLength is the number of hex characters kept from the HMAC digest
+const hashLength = 32
+
+// rule is a compiled redaction rule
+type rule struct {
+	fields []string
+	action string
+	cidrs  []*net.IPNet
+}
+
+// Redactor hashes or masks sensitive field values before events are forwarded
+type Redactor struct {
+	salt  []byte
+	rules []rule
+}
+
+// New builds a redactor from configuration
+func New(rc config.RedactionConfig) (*Redactor, error) {
+	r := &Redactor{
+		salt: []byte(rc.Salt),
+	}
+
+	for i, rr := range rc.Rules {
+		if rr.Action != ActionHash && rr.Action != ActionMask {
+			return nil, fmt.Errorf("redaction.rules[%d]: invalid action '%s', must be hash or mask", i, rr.Action)
+		}
+
+		compiled := rule{
+			fields: rr.Fields,
+			action: rr.Action,
+		}
+		for _, cidr := range rr.CIDRs {
+			_, network, err := net.ParseCIDR(cidr)
+			if err != nil {
+				return nil, fmt.Errorf("redaction.rules[%d]: invalid CIDR '%s': %w", i, cidr, err)
+			}
+			compiled.cidrs = append(compiled.cidrs, network)
+		}
+		r.rules = append(r.rules, compiled)
+	}
+
+	return r, nil
+}
+
+// Empty reports whether no redaction rules are configured
+func (r *Redactor) Empty() bool {
+	return len(r.rules) == 0
+}
+
+// Apply redacts matching field values in place and returns the event
+func (r *Redactor) Apply(event map[string]string) map[string]string {
+	for _, rl := range r.rules {
+		for _, field := range rl.fields {
+			value, exists := event[field]
+			if !exists || value == "" {
+				continue
+			}
+			if len(rl.cidrs) > 0 && !inNetworks(value, rl.cidrs) {
+				continue
+			}
+
+			switch rl.action {
+			case ActionHash:
+				event[field] = r.hash(value)
+			case ActionMask:
+				event[field] = mask(value)
+			}
+		}
+	}
+	return event
+}
+
+// hash returns a salted, truncated HMAC-SHA256 of the value so equal inputs stay correlatable
+func (r *Redactor) hash(value string) string {
+	mac := hmac.New(sha256.New, r.salt)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))[:hashLength]
+}
+
+// mask obscures a value while keeping its general shape:
+// IPv4 addresses keep their /24, IPv6 addresses their /48, emails their domain,
+// and anything else is replaced with asterisks.
+func mask(value string) string {
+	if ip := net.ParseIP(value); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			return ip4.Mask(net.CIDRMask(24, 32)).String()
+		}
+		return ip.Mask(net.CIDRMask(48, 128)).String()
+	}
+
+	if at := strings.LastIndex(value, "@"); at > 0 {
+		return strings.Repeat("*", at) + value[at:]
+	}
+
+	return strings.Repeat("*", len(value))
+}
+
+// inNetworks reports whether value is an IP address inside any of the networks
+func inNetworks(value string, networks []*net.IPNet) bool {
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return false
+	}
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
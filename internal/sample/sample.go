@@ -0,0 +1,81 @@
+package sample
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+
+	"cato-logger/internal/config"
+)
+
+// Sampler forwards one in every N events of the configured event types. The
+// decision hashes the event, so the same event is always kept or dropped,
+// including when a page is fetched again.
+type Sampler struct {
+	rates   map[string]uint64 // Keyed by event type or "type/sub type"
+	idField string            // Field hashed, empty hashes every field
+}
+
+// New builds a sampler from configuration
+func New(sc config.SamplingConfig) (*Sampler, error) {
+	s := &Sampler{
+		rates:   make(map[string]uint64, len(sc.Rates)),
+		idField: sc.IDField,
+	}
+	for eventType, rate := range sc.Rates {
+		if rate < 1 {
+			return nil, fmt.Errorf("sampling.rates[%s] must be at least 1, got %d", eventType, rate)
+		}
+		if rate > 1 {
+			s.rates[eventType] = uint64(rate)
+		}
+	}
+	return s, nil
+}
+
+// Empty reports whether every event is forwarded
+func (s *Sampler) Empty() bool {
+	return len(s.rates) == 0
+}
+
+// Apply returns the event unchanged; sampling is decided by Keep
+func (s *Sampler) Apply(event map[string]string) map[string]string {
+	return event
+}
+
+// Keep reports whether an event is forwarded. A rate for the event's type
+// and sub type takes precedence over one for its type.
+func (s *Sampler) Keep(event map[string]string) bool {
+	eventType := event["event_type"]
+	rate, ok := s.rates[eventType+"/"+event["event_sub_type"]]
+	if !ok {
+		rate, ok = s.rates[eventType]
+	}
+	if !ok {
+		return true
+	}
+	return s.hash(event)%rate == 0
+}
+
+// hash returns the FNV-1a hash of the event's ID field, or of all its
+// fields in name order when no ID field is configured or the event lacks it
+func (s *Sampler) hash(event map[string]string) uint64 {
+	h := fnv.New64a()
+	if id := event[s.idField]; s.idField != "" && id != "" {
+		h.Write([]byte(id))
+		return h.Sum64()
+	}
+
+	names := make([]string, 0, len(event))
+	for name := range event {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		h.Write([]byte(name))
+		h.Write([]byte{0})
+		h.Write([]byte(event[name]))
+		h.Write([]byte{0})
+	}
+	return h.Sum64()
+}
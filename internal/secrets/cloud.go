@@ -0,0 +1,139 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"cato-logger/internal/awsauth"
+	"cato-logger/internal/azureauth"
+)
+
+// resolveAWS reads a secret from AWS Secrets Manager. The secret may be a
+// name or ARN; "#key" selects a field from a JSON secret string.
+func (r *Resolver) resolveAWS(ctx context.Context, ref string) (string, error) {
+	secretID, key := splitFragment(ref)
+	if secretID == "" {
+		return "", fmt.Errorf("aws-sm reference must be aws-sm:<secret-id>[#key]")
+	}
+
+	// ARNs carry their region (arn:aws:secretsmanager:<region>:...)
+	region := awsauth.Region()
+	if parts := strings.Split(secretID, ":"); len(parts) > 3 && parts[0] == "arn" {
+		region = parts[3]
+	}
+	if region == "" {
+		return "", fmt.Errorf("AWS region unknown: set AWS_REGION or use a secret ARN")
+	}
+
+	creds, err := awsauth.LoadCredentials(ctx, r.client)
+	if err != nil {
+		return "", err
+	}
+
+	body, err := json.Marshal(map[string]string{"SecretId": secretID})
+	if err != nil {
+		return "", err
+	}
+
+	endpoint := fmt.Sprintf("https://secretsmanager.%s.amazonaws.com/", region)
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	awsauth.SignRequest(req, body, creds, region, "secretsmanager", time.Now())
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("secrets manager request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secrets manager response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("secrets manager returned status %d: %s", resp.StatusCode, respBody)
+	}
+
+	var result struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return "", fmt.Errorf("invalid secrets manager response: %w", err)
+	}
+
+	if key == "" {
+		return result.SecretString, nil
+	}
+	return jsonField(result.SecretString, key)
+}
+
+// resolveAzure reads a secret from Azure Key Vault: azure-kv:<vault-name>/<secret-name>[/<version>]
+func (r *Resolver) resolveAzure(ctx context.Context, ref string) (string, error) {
+	parts := strings.SplitN(ref, "/", 3)
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return "", fmt.Errorf("azure-kv reference must be azure-kv:<vault-name>/<secret-name>")
+	}
+
+	tokens := azureauth.NewTokenSource("https://vault.azure.net", r.client)
+	token, err := tokens.Token(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	endpoint := fmt.Sprintf("https://%s.vault.azure.net/secrets/%s", parts[0], url.PathEscape(parts[1]))
+	if len(parts) == 3 && parts[2] != "" {
+		endpoint += "/" + url.PathEscape(parts[2])
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint+"?api-version=7.4", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("key vault request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read key vault response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("key vault returned status %d for secret %s", resp.StatusCode, parts[1])
+	}
+
+	var result struct {
+		Value string `json:"value"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("invalid key vault response: %w", err)
+	}
+	return result.Value, nil
+}
+
+// jsonField extracts a string field from a JSON object secret
+func jsonField(secret, key string) (string, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(secret), &fields); err != nil {
+		return "", fmt.Errorf("secret is not a JSON object, cannot select key %s", key)
+	}
+	value, ok := fields[key].(string)
+	if !ok {
+		return "", fmt.Errorf("key %s not found in secret", key)
+	}
+	return value, nil
+}
@@ -0,0 +1,105 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Scheme prefixes recognized in secret references
+const (
+	SchemeEnv     = "env:"
+	SchemeFile    = "file:"
+	SchemeVault   = "vault:"
+	SchemeAWS     = "aws-sm:"
+	SchemeAzureKV = "azure-kv:"
+)
+
+// Resolver turns secret references into their values
+type Resolver struct {
+	client *http.Client
+}
+
+// NewResolver creates a resolver whose remote lookups use the given timeout
+func NewResolver(timeout time.Duration) *Resolver {
+	return &Resolver{
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// IsReference reports whether value uses one of the secret reference schemes
+func IsReference(value string) bool {
+	for _, scheme := range []string{SchemeEnv, SchemeFile, SchemeVault, SchemeAWS, SchemeAzureKV} {
+		if strings.HasPrefix(value, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve returns the secret a reference points to. Values without a
+// recognized scheme are returned unchanged, so plaintext config still works.
+//
+// Supported references:
+//
+//	env:NAME                       environment variable
+//	file:/path/to/secret           file contents (trailing newline trimmed)
+//	vault:secret/cato#api_key      HashiCorp Vault KV v1/v2 (VAULT_ADDR, VAULT_TOKEN)
+//	aws-sm:cato-api-key[#key]      AWS Secrets Manager, optional JSON key
+//	azure-kv:myvault/cato-api-key  Azure Key Vault secret
+func (r *Resolver) Resolve(ctx context.Context, ref string) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, SchemeEnv):
+		name := strings.TrimPrefix(ref, SchemeEnv)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return value, nil
+
+	case strings.HasPrefix(ref, SchemeFile):
+		path := strings.TrimPrefix(ref, SchemeFile)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("cannot read secret file: %w", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+
+	case strings.HasPrefix(ref, SchemeVault):
+		return r.resolveVault(ctx, strings.TrimPrefix(ref, SchemeVault))
+
+	case strings.HasPrefix(ref, SchemeAWS):
+		return r.resolveAWS(ctx, strings.TrimPrefix(ref, SchemeAWS))
+
+	case strings.HasPrefix(ref, SchemeAzureKV):
+		return r.resolveAzure(ctx, strings.TrimPrefix(ref, SchemeAzureKV))
+	}
+
+	return ref, nil
+}
+
+// ResolveAll resolves every referenced value in place, naming the failing setting on error
+func (r *Resolver) ResolveAll(ctx context.Context, targets map[string]*string) error {
+	for name, target := range targets {
+		if !IsReference(*target) {
+			continue
+		}
+		value, err := r.Resolve(ctx, *target)
+		if err != nil {
+			return fmt.Errorf("failed to resolve secret for %s: %w", name, err)
+		}
+		*target = value
+	}
+	return nil
+}
+
+// splitFragment splits "path#key" into its parts
+func splitFragment(ref string) (string, string) {
+	if i := strings.LastIndex(ref, "#"); i >= 0 {
+		return ref[:i], ref[i+1:]
+	}
+	return ref, ""
+}
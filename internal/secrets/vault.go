@@ -0,0 +1,85 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// resolveVault reads a key from a Vault KV secret. Paths may be given in
+// KV v2 API form (secret/data/cato) or logical form (secret/cato); the
+// logical form is retried with /data/ inserted after the mount when needed.
+func (r *Resolver) resolveVault(ctx context.Context, ref string) (string, error) {
+	path, key := splitFragment(ref)
+	if path == "" || key == "" {
+		return "", fmt.Errorf("vault reference must be vault:<path>#<key>")
+	}
+
+	addr := strings.TrimSuffix(os.Getenv("VAULT_ADDR"), "/")
+	token := os.Getenv("VAULT_TOKEN")
+	if addr == "" || token == "" {
+		return "", fmt.Errorf("VAULT_ADDR and VAULT_TOKEN must be set to resolve vault references")
+	}
+
+	data, status, err := r.vaultRead(ctx, addr, token, path)
+	if err == nil && status == http.StatusNotFound && !strings.Contains(path, "/data/") {
+		if mount := strings.SplitN(path, "/", 2); len(mount) == 2 {
+			data, status, err = r.vaultRead(ctx, addr, token, mount[0]+"/data/"+mount[1])
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	if status != http.StatusOK {
+		return "", fmt.Errorf("vault returned status %d for %s", status, path)
+	}
+
+	var resp struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return "", fmt.Errorf("invalid vault response: %w", err)
+	}
+
+	// KV v2 nests the secret under data.data
+	fields := resp.Data
+	if nested, ok := fields["data"].(map[string]interface{}); ok {
+		if _, hasMeta := fields["metadata"]; hasMeta {
+			fields = nested
+		}
+	}
+
+	value, ok := fields[key].(string)
+	if !ok {
+		return "", fmt.Errorf("key %s not found in vault secret %s", key, path)
+	}
+	return value, nil
+}
+
+// vaultRead performs a GET against the Vault HTTP API
+func (r *Resolver) vaultRead(ctx context.Context, addr, token, path string) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", addr+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if ns := os.Getenv("VAULT_NAMESPACE"); ns != "" {
+		req.Header.Set("X-Vault-Namespace", ns)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("vault request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read vault response: %w", err)
+	}
+	return body, resp.StatusCode, nil
+}
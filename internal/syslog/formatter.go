@@ -4,8 +4,46 @@ import (
 	"fmt"
 	"os"
 	"time"
+	"unicode/utf8"
 )
 
+// Default message size limits in bytes, by transport. A UDP message must fit
+// one unfragmented datagram on a 1500 byte Ethernet MTU (less the 20 byte IPv4
+// and 8 byte UDP headers), which a message fills exactly since datagrams
+// carry no trailing newline; streams and local sockets take rsyslog's
+// default maximum message size.
+const (
+	DefaultMaxUDPMessageSize    = 1472
+	DefaultMaxStreamMessageSize = 8192
+)
+
+// DefaultMaxMessageSize returns the message size limit for a protocol
+func DefaultMaxMessageSize(protocol string) int {
+	if protocol == "udp" {
+		return DefaultMaxUDPMessageSize
+	}
+	return DefaultMaxStreamMessageSize
+}
+
+// Datagram reports whether a protocol sends each message as one datagram,
+// which needs no newline to separate it from the next
+func Datagram(protocol string) bool {
+	return protocol == "udp" || protocol == "unixgram"
+}
+
+// Truncate cuts message to at most max bytes without splitting a UTF-8
+// sequence, and reports whether it was cut
+func Truncate(message string, max int) (string, bool) {
+	if max <= 0 || len(message) <= max {
+		return message, false
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(message[cut]) {
+		cut--
+	}
+	return message[:cut], true
+}
+
 // FormatMessage creates a syslog-formatted message
 func FormatMessage(hostname, message string) string {
 	priority := "134" // local0.info
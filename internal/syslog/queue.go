@@ -0,0 +1,315 @@
+package syslog
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cato-logger/internal/logging"
+)
+
+// Queue policies for a full queue
+const (
+	PolicyBlock = "block" // Enqueue waits for room, slowing down the fetch loop
+	PolicyDrop  = "drop"  // Enqueue discards the message
+)
+
+// QueuePolicies are the policies a queue accepts
+var QueuePolicies = []string{PolicyBlock, PolicyDrop}
+
+// dropLogInterval limits how often dropped messages are logged
+const dropLogInterval = time.Minute
+
+// Queue writes messages to a Writer from its own goroutine, so callers wait
+// for room in a bounded queue rather than for the syslog server. The
+// goroutine retries a failed write until it succeeds or the queue closes.
+type Queue struct {
+	writer   *Writer
+	messages chan string
+	policy   string
+	onDrop   func()
+	logger   *logging.Logger
+
+	// Soft limit on the bytes held: concurrent callers may overshoot it by a
+	// message each, and a message larger than the limit still fits an empty queue
+	maxBytes int64
+	bytes    atomic.Int64
+	room     chan struct{} // Signalled when run takes a message
+
+	mu          sync.RWMutex // Guards closing the messages channel
+	closed      bool
+	stop        chan struct{} // Closed when Close starts, releases blocked callers
+	ctx         context.Context
+	cancel      context.CancelFunc // Aborts delivery once the drain timeout passes
+	done        chan struct{}
+	statsMu     sync.Mutex
+	dropped     int64
+	lastDropLog time.Time
+
+	// Delivery progress, in messages since the queue started
+	sent    atomic.Int64 // Queued
+	written int64        // Written to the server, only used by run
+	acked   atomic.Int64 // Written, and for RELP acknowledged
+}
+
+// NewQueue starts a queue holding up to size messages, and with maxBytes
+// above 0 about that many bytes, in front of writer. onDrop, when set, is
+// called for each discarded message.
+func NewQueue(writer *Writer, size int, maxBytes int64, policy string, onDrop func(), logger *logging.Logger) *Queue {
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &Queue{
+		writer:   writer,
+		messages: make(chan string, size),
+		policy:   policy,
+		onDrop:   onDrop,
+		logger:   logger,
+		maxBytes: maxBytes,
+		room:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+// Enqueue adds a message to the queue. With the block policy it waits for
+// room until ctx is done; with the drop policy a full queue discards the
+// message and Enqueue returns nil.
+func (q *Queue) Enqueue(ctx context.Context, message string) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return fmt.Errorf("syslog queue is closed")
+	}
+
+	if q.policy == PolicyDrop {
+		if q.overLimit(message) {
+			q.drop(ctx)
+			return nil
+		}
+		select {
+		case q.messages <- message:
+			q.bytes.Add(int64(len(message)))
+			q.sent.Add(1)
+		default:
+			q.drop(ctx)
+		}
+		return nil
+	}
+
+	for q.overLimit(message) {
+		select {
+		case <-q.room:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-q.stop:
+			return fmt.Errorf("syslog queue is closed")
+		}
+	}
+	// Pass the wakeup on to any other caller waiting for room
+	q.signalRoom()
+
+	select {
+	case q.messages <- message:
+		q.bytes.Add(int64(len(message)))
+		q.sent.Add(1)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-q.stop:
+		return fmt.Errorf("syslog queue is closed")
+	}
+}
+
+// signalRoom wakes a caller waiting for the queue to shrink
+func (q *Queue) signalRoom() {
+	select {
+	case q.room <- struct{}{}:
+	default:
+	}
+}
+
+// overLimit reports whether queuing message would take a non-empty queue
+// past its byte limit
+func (q *Queue) overLimit(message string) bool {
+	if q.maxBytes <= 0 {
+		return false
+	}
+	held := q.bytes.Load()
+	return held > 0 && held+int64(len(message)) > q.maxBytes
+}
+
+// drop counts a discarded message and logs the total at most once a minute
+func (q *Queue) drop(ctx context.Context) {
+	if q.onDrop != nil {
+		q.onDrop()
+	}
+
+	q.statsMu.Lock()
+	defer q.statsMu.Unlock()
+
+	q.dropped++
+	if time.Since(q.lastDropLog) >= dropLogInterval {
+		q.lastDropLog = time.Now()
+		q.logger.WarnContext(ctx, "syslog queue full, dropping messages",
+			"address", q.writer.address,
+			"queue_size", cap(q.messages),
+			"queued_bytes", q.bytes.Load(),
+			"dropped_total", q.dropped)
+	}
+}
+
+// run writes queued messages until the queue is closed and drained
+func (q *Queue) run() {
+	defer close(q.done)
+
+	for message := range q.messages {
+		q.bytes.Add(-int64(len(message)))
+		q.signalRoom()
+
+		if !q.deliver(message) {
+			q.discard(1 + q.writer.unacknowledged())
+			return
+		}
+		q.written++
+		q.acked.Store(q.written - int64(q.writer.unacknowledged()))
+
+		if len(q.messages) == 0 {
+			if !q.flush() {
+				q.discard(q.writer.unacknowledged())
+				return
+			}
+			q.acked.Store(q.written)
+		}
+	}
+}
+
+// flush waits for the server to acknowledge what was written, reconnecting
+// and resending until it does. It returns false if the drain timeout passed
+// first.
+func (q *Queue) flush() bool {
+	for {
+		err := q.writer.Flush()
+		if err == nil {
+			return true
+		}
+
+		q.logger.DebugContext(q.ctx, "syslog acknowledgement failed, attempting reconnect", "error", err.Error())
+		if err := q.writer.Reconnect(q.ctx); err == nil {
+			continue
+		}
+
+		select {
+		case <-time.After(q.writer.reconnectWait()):
+		case <-q.ctx.Done():
+			return false
+		}
+	}
+}
+
+// deliver writes a message, reconnecting and retrying until it is sent. It
+// returns false if the queue was closed and the drain timeout passed first.
+func (q *Queue) deliver(message string) bool {
+	for {
+		err := q.writer.Write(message)
+		if err == nil {
+			return true
+		}
+
+		q.logger.DebugContext(q.ctx, "syslog write failed, attempting reconnect", "error", err.Error())
+		if err := q.writer.Reconnect(q.ctx); err == nil {
+			continue
+		}
+
+		select {
+		case <-time.After(q.writer.reconnectWait()):
+		case <-q.ctx.Done():
+			return false
+		}
+	}
+}
+
+// discard counts the messages left after the drain timeout as lost: those
+// still queued plus lost already taken from the queue
+func (q *Queue) discard(lost int) {
+	for range q.messages {
+		lost++
+	}
+
+	q.statsMu.Lock()
+	q.dropped += int64(lost)
+	q.statsMu.Unlock()
+
+	if q.onDrop != nil {
+		for i := 0; i < lost; i++ {
+			q.onDrop()
+		}
+	}
+	q.logger.Error("syslog queue not drained before shutdown, messages lost",
+		"address", q.writer.address,
+		"lost", lost)
+}
+
+// Sent returns the number of messages queued since the queue started
+func (q *Queue) Sent() int64 {
+	return q.sent.Load()
+}
+
+// Acked returns how many of the queued messages were delivered: written to
+// the server, and for RELP also acknowledged by it. Messages are delivered
+// in order, so these are the first Acked messages queued.
+func (q *Queue) Acked() int64 {
+	return q.acked.Load()
+}
+
+// Len returns the number of messages waiting in the queue
+func (q *Queue) Len() int {
+	return len(q.messages)
+}
+
+// Bytes returns the size of the messages waiting in the queue
+func (q *Queue) Bytes() int64 {
+	return q.bytes.Load()
+}
+
+// Cap returns the queue size
+func (q *Queue) Cap() int {
+	return cap(q.messages)
+}
+
+// Dropped returns the number of messages discarded since the queue started
+func (q *Queue) Dropped() int64 {
+	q.statsMu.Lock()
+	defer q.statsMu.Unlock()
+	return q.dropped
+}
+
+// Close stops accepting messages, waits up to timeout for the queued ones
+// to be written, and closes the writer
+func (q *Queue) Close(timeout time.Duration) error {
+	close(q.stop)
+
+	q.mu.Lock()
+	q.closed = true
+	close(q.messages)
+	q.mu.Unlock()
+
+	if pending := len(q.messages); pending > 0 {
+		q.logger.Info("draining syslog queue", "address", q.writer.address, "pending", pending)
+	}
+
+	select {
+	case <-q.done:
+	case <-time.After(timeout):
+		q.cancel()
+		<-q.done
+	}
+	q.cancel()
+
+	return q.writer.Close()
+}
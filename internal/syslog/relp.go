@@ -0,0 +1,246 @@
+package syslog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RELP (Reliable Event Logging Protocol) frames are
+// "TXNR SP COMMAND SP DATALEN [SP DATA] LF". Every command is answered by
+// an rsp frame with the same transaction number whose data starts with a
+// status code, 200 on success.
+
+// relpWindow is the number of messages sent ahead of their acknowledgements
+const relpWindow = 128
+
+// relpMaxTxnr is where transaction numbers wrap back to 1
+const relpMaxTxnr = 999999999
+
+// relpOffer is the data of the open command
+const relpOffer = "relp_version=0\nrelp_software=cato-logger\ncommands=syslog"
+
+// relpFrame is a syslog message sent but not yet acknowledged
+type relpFrame struct {
+	txnr    int
+	message string
+}
+
+// relpSession is an open RELP session on a connection
+type relpSession struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	txnr    int
+	pending []relpFrame // Oldest first
+}
+
+// openRELP starts a session on conn, failing if the server does not accept
+// the syslog command
+func openRELP(conn net.Conn, timeout time.Duration) (*relpSession, error) {
+	s := &relpSession{conn: conn, reader: bufio.NewReader(conn)}
+
+	s.setDeadline(timeout)
+	defer s.conn.SetDeadline(time.Time{})
+
+	txnr, err := s.command("open", relpOffer)
+	if err != nil {
+		return nil, err
+	}
+	rsp, data, err := s.readFrame()
+	if err != nil {
+		return nil, err
+	}
+	if rsp != txnr {
+		return nil, fmt.Errorf("unexpected RELP response %d to open %d", rsp, txnr)
+	}
+	if err := relpStatus(data); err != nil {
+		return nil, fmt.Errorf("RELP server refused session: %w", err)
+	}
+	if !strings.Contains(data, "syslog") {
+		return nil, fmt.Errorf("RELP server does not offer the syslog command")
+	}
+	return s, nil
+}
+
+// CheckRELP opens and closes a RELP session on conn, to check the server
+// accepts syslog messages
+func CheckRELP(conn net.Conn, timeout time.Duration) error {
+	s, err := openRELP(conn, timeout)
+	if err != nil {
+		return err
+	}
+	s.close(timeout)
+	return nil
+}
+
+// send writes a syslog frame and adds it to the unacknowledged messages.
+// With the window full it first waits for acknowledgements.
+func (s *relpSession) send(message string, timeout time.Duration) error {
+	if len(s.pending) >= relpWindow {
+		if err := s.await(relpWindow-1, timeout); err != nil {
+			return err
+		}
+	}
+
+	s.setDeadline(timeout)
+	txnr, err := s.command("syslog", message)
+	if err != nil {
+		return err
+	}
+	s.pending = append(s.pending, relpFrame{txnr: txnr, message: message})
+	return nil
+}
+
+// resend sends the messages left unacknowledged by an earlier session
+func (s *relpSession) resend(frames []relpFrame, timeout time.Duration) error {
+	for _, frame := range frames {
+		if err := s.send(frame.message, timeout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// await reads acknowledgements until at most max messages are unacknowledged
+func (s *relpSession) await(max int, timeout time.Duration) error {
+	s.setDeadline(timeout)
+	defer s.conn.SetReadDeadline(time.Time{})
+
+	for len(s.pending) > max {
+		txnr, data, err := s.readFrame()
+		if err != nil {
+			return err
+		}
+		if txnr == 0 {
+			return fmt.Errorf("RELP server closed the session")
+		}
+
+		i := 0
+		for i < len(s.pending) && s.pending[i].txnr != txnr {
+			i++
+		}
+		if i == len(s.pending) {
+			continue // Not ours, e.g. a late answer from before a resend
+		}
+		s.pending = append(s.pending[:i], s.pending[i+1:]...)
+
+		if err := relpStatus(data); err != nil {
+			return fmt.Errorf("RELP server rejected message %d: %w", txnr, err)
+		}
+	}
+	return nil
+}
+
+// close ends the session, waiting briefly for the server to confirm
+func (s *relpSession) close(timeout time.Duration) {
+	s.setDeadline(timeout)
+	if _, err := s.command("close", ""); err != nil {
+		return
+	}
+	s.readFrame()
+}
+
+// command writes a frame with the next transaction number
+func (s *relpSession) command(command, data string) (int, error) {
+	s.txnr++
+	if s.txnr > relpMaxTxnr {
+		s.txnr = 1
+	}
+
+	frame := strconv.Itoa(s.txnr) + " " + command + " " + strconv.Itoa(len(data))
+	if data != "" {
+		frame += " " + data
+	}
+	if _, err := io.WriteString(s.conn, frame+"\n"); err != nil {
+		return 0, err
+	}
+	return s.txnr, nil
+}
+
+// readFrame reads a frame from the server: an rsp, or serverclose with
+// transaction number 0
+func (s *relpSession) readFrame() (int, string, error) {
+	txnr, err := s.readToken()
+	if err != nil {
+		return 0, "", err
+	}
+	command, err := s.readToken()
+	if err != nil {
+		return 0, "", err
+	}
+	length, err := s.readToken()
+	if err != nil {
+		return 0, "", err
+	}
+
+	n, err := strconv.Atoi(txnr)
+	if err != nil {
+		return 0, "", fmt.Errorf("malformed RELP frame: transaction number %q", txnr)
+	}
+	size, err := strconv.Atoi(length)
+	if err != nil || size < 0 {
+		return 0, "", fmt.Errorf("malformed RELP frame: data length %q", length)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(s.reader, data); err != nil {
+		return 0, "", err
+	}
+	if size > 0 {
+		// Skip the trailer after the data
+		if _, err := s.reader.ReadByte(); err != nil {
+			return 0, "", err
+		}
+	}
+
+	switch command {
+	case "rsp":
+		return n, string(data), nil
+	case "serverclose":
+		return 0, "", nil
+	}
+	return 0, "", fmt.Errorf("unexpected RELP command %q", command)
+}
+
+// readToken reads up to the next space, or the line end after an empty
+// data length
+func (s *relpSession) readToken() (string, error) {
+	var token []byte
+	for {
+		b, err := s.reader.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		if b == ' ' || b == '\n' {
+			if len(token) == 0 {
+				continue
+			}
+			return string(token), nil
+		}
+		if len(token) > 32 {
+			return "", fmt.Errorf("malformed RELP frame header")
+		}
+		token = append(token, b)
+	}
+}
+
+// setDeadline bounds the next reads and writes, unless timeout is 0
+func (s *relpSession) setDeadline(timeout time.Duration) {
+	if timeout > 0 {
+		s.conn.SetDeadline(time.Now().Add(timeout))
+	}
+}
+
+// relpStatus returns an error unless response data carries status 200
+func relpStatus(data string) error {
+	code, text, _ := strings.Cut(data, " ")
+	if code == "200" {
+		return nil
+	}
+	text, _, _ = strings.Cut(text, "\n")
+	return fmt.Errorf("status %s %s", code, text)
+}
@@ -0,0 +1,82 @@
+package syslog
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// IP preferences for servers given by name: the addresses of the preferred
+// family are tried first, the others only if none of them connects
+const (
+	PreferIPv4 = "ipv4"
+	PreferIPv6 = "ipv6"
+)
+
+// IPPreferences lists the valid IP preferences
+var IPPreferences = []string{PreferIPv4, PreferIPv6}
+
+// Resolve looks up the host of a host:port address and returns the
+// addresses to try, preferred family first. rotate shifts the start within
+// each family, so successive connections spread over a server's A and AAAA
+// records. An IP literal is returned as is.
+func Resolve(ctx context.Context, address, prefer string, rotate int) ([]string, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	if net.ParseIP(host) != nil {
+		return []string{address}, nil
+	}
+
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var v4, v6 []string
+	for _, ip := range ips {
+		if ip.IP.To4() != nil {
+			v4 = append(v4, net.JoinHostPort(ip.String(), port))
+		} else {
+			v6 = append(v6, net.JoinHostPort(ip.String(), port))
+		}
+	}
+	v4, v6 = rotated(v4, rotate), rotated(v6, rotate)
+
+	var addrs []string
+	switch prefer {
+	case PreferIPv4:
+		addrs = append(v4, v6...)
+	case PreferIPv6:
+		addrs = append(v6, v4...)
+	default:
+		// Without a preference the families alternate, keeping the
+		// resolver's choice of which comes first
+		first, second := v4, v6
+		if len(ips) > 0 && ips[0].IP.To4() == nil {
+			first, second = v6, v4
+		}
+		for i := 0; i < len(first) || i < len(second); i++ {
+			if i < len(first) {
+				addrs = append(addrs, first[i])
+			}
+			if i < len(second) {
+				addrs = append(addrs, second[i])
+			}
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no addresses found for %s", host)
+	}
+	return addrs, nil
+}
+
+// rotated returns addrs starting at offset n, wrapping around
+func rotated(addrs []string, n int) []string {
+	if len(addrs) < 2 {
+		return addrs
+	}
+	n %= len(addrs)
+	return append(addrs[n:len(addrs):len(addrs)], addrs[:n]...)
+}
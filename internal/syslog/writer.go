@@ -1,132 +1,475 @@
 package syslog
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net"
+	"sync"
 	"time"
 
 	"cato-logger/internal/logging"
 )
 
-// Writer manages a resilient connection to a syslog server
+// probeTimeout is how long a health probe waits for the peer to close
+const probeTimeout = 10 * time.Millisecond
+
+// Reconnect backoff: the delay after each attempt doubles from
+// minReconnectDelay up to the configured maximum
+const (
+	minReconnectDelay        = 1 * time.Second
+	DefaultMaxReconnectDelay = 60 * time.Second
+)
+
+// WriterOptions holds the connection settings of a Writer
+type WriterOptions struct {
+	ConnTimeout    time.Duration   // Connection attempts
+	WriteTimeout   time.Duration   // Each write, 0 waits indefinitely
+	HealthInterval time.Duration   // Idle stream connections are probed this often, 0 disables
+	KeepAlive      time.Duration   // TCP keepalive period, 0 uses the Go default, negative disables
+	MaxAge         time.Duration   // Connections older than this are replaced, 0 disables
+	MaxBackoff     time.Duration   // Longest wait between reconnect attempts, defaults to DefaultMaxReconnectDelay
+	BindAddress    string          // Local IP connections leave from, empty lets the OS choose
+	IPPreference   string          // Address family tried first for a server name: PreferIPv4, PreferIPv6 or empty
+	OnReconnect    func(err error) // Called after every reconnect attempt, with nil on success
+}
+
+// Writer manages a resilient connection to a syslog server. It is safe for
+// concurrent use, though callers normally serialize writes themselves.
 type Writer struct {
-	protocol         string
-	address          string
-	conn             net.Conn
-	reconnectCount   int
-	lastReconnect    time.Time
-	maxReconnects    int
-	reconnectDelay   time.Duration
-	connTimeout      time.Duration
-	successfulWrites int64
-	lastCounterReset time.Time
-	logger           *logging.Logger
+	mu             sync.Mutex
+	protocol       string
+	address        string
+	conn           net.Conn
+	relp           *relpSession // Set for the relp protocol
+	reconnectCount int          // Attempts since the last successful write
+	nextReconnect  time.Time    // Attempts before this are refused
+	outageStart    time.Time    // First failed write or attempt of the current outage
+	maxBackoff     time.Duration
+	connTimeout    time.Duration
+	writeTimeout   time.Duration
+	healthInterval time.Duration
+	keepAlive      time.Duration
+	localAddr      net.Addr // nil lets the OS choose
+	ipPreference   string
+	dials          int // Connections opened, rotating the server's addresses
+	maxAge         time.Duration
+	recycleAt      time.Time // When the connection is due for replacement
+	onReconnect    func(err error)
+	lastWrite      time.Time
+	stop           chan struct{}
+	logger         *logging.Logger
 }
 
 // NewWriter creates a new syslog writer
-func NewWriter(protocol, address string, connTimeout time.Duration, logger *logging.Logger) (*Writer, error) {
-	conn, err := net.DialTimeout(protocol, address, connTimeout)
+func NewWriter(protocol, address string, opts WriterOptions, logger *logging.Logger) (*Writer, error) {
+	w := &Writer{
+		protocol:       protocol,
+		address:        address,
+		maxBackoff:     opts.MaxBackoff,
+		connTimeout:    opts.ConnTimeout,
+		writeTimeout:   opts.WriteTimeout,
+		healthInterval: opts.HealthInterval,
+		keepAlive:      opts.KeepAlive,
+		localAddr:      LocalAddr(protocol, opts.BindAddress),
+		ipPreference:   opts.IPPreference,
+		maxAge:         opts.MaxAge,
+		onReconnect:    opts.OnReconnect,
+		lastWrite:      time.Now(),
+		stop:           make(chan struct{}),
+		logger:         logger,
+	}
+	if w.maxBackoff <= 0 {
+		w.maxBackoff = DefaultMaxReconnectDelay
+	}
+
+	conn, session, err := w.dial()
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to syslog server: %w", err)
 	}
+	w.setConn(conn, session)
 
 	logger.Info("connected to syslog server", "protocol", protocol, "address", address)
 
-	return &Writer{
-		protocol:         protocol,
-		address:          address,
-		conn:             conn,
-		maxReconnects:    10,
-		reconnectDelay:   5 * time.Second,
-		connTimeout:      connTimeout,
-		lastCounterReset: time.Now(),
-		logger:           logger,
-	}, nil
+	// Datagrams have no connection to lose, so only stream connections are probed
+	if w.healthInterval > 0 && !Datagram(protocol) {
+		go w.monitor()
+	}
+
+	return w, nil
 }
 
-// Write sends a message to the syslog server
+// Write sends a message to the syslog server. A write that does not finish
+// within the write timeout fails, so a stalled peer cannot block the caller.
 func (w *Writer) Write(message string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
 	if w.conn == nil {
 		return fmt.Errorf("no connection available")
 	}
 
-	_, err := fmt.Fprintln(w.conn, message)
+	if w.maxAge > 0 && time.Now().After(w.recycleAt) {
+		w.recycle()
+	}
+
+	var err error
+	if w.relp != nil {
+		err = w.relp.send(message, w.writeTimeout)
+	} else {
+		if w.writeTimeout > 0 {
+			if err := w.conn.SetWriteDeadline(time.Now().Add(w.writeTimeout)); err != nil {
+				return err
+			}
+		}
+		if Datagram(w.protocol) {
+			// A newline would make a message at the size limit a byte too
+			// large for its datagram
+			_, err = io.WriteString(w.conn, message)
+		} else {
+			_, err = fmt.Fprintln(w.conn, message)
+		}
+	}
 	if err != nil {
 		w.logger.Debug("syslog write failed", "error", err.Error())
+		if w.outageStart.IsZero() {
+			w.outageStart = time.Now()
+		}
 		return err
 	}
 
-	// Track successful writes and periodically reset reconnect counter
-	w.successfulWrites++
+	w.lastWrite = time.Now()
 
-	// Reset reconnect counter every hour of successful operation
-	if time.Since(w.lastCounterReset) >= 1*time.Hour && w.reconnectCount > 0 {
-		w.logger.Info("resetting reconnect counter after sustained successful operation",
-			"previous_count", w.reconnectCount,
-			"successful_writes", w.successfulWrites)
+	// A write getting through ends the outage and resets the backoff, so a
+	// server that accepts connections only to drop them keeps backing off
+	if w.reconnectCount > 0 {
+		w.logger.Info("syslog output recovered",
+			"address", w.address,
+			"reconnect_attempts", w.reconnectCount,
+			"outage", time.Since(w.outageStart).Round(time.Second).String())
 		w.reconnectCount = 0
-		w.lastCounterReset = time.Now()
+		w.nextReconnect = time.Time{}
+	}
+	w.outageStart = time.Time{}
+
+	return nil
+}
+
+// Flush waits until the server has acknowledged every message written. Only
+// RELP acknowledges messages; for other protocols Flush returns at once.
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.relp == nil {
+		return nil
+	}
+	if err := w.relp.await(0, w.writeTimeout); err != nil {
+		w.logger.Debug("syslog acknowledgement failed", "error", err.Error())
+		if w.outageStart.IsZero() {
+			w.outageStart = time.Now()
+		}
+		return err
 	}
+	return nil
+}
+
+// unacknowledged returns the number of RELP messages awaiting acknowledgement
+func (w *Writer) unacknowledged() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 
+	if w.relp == nil {
+		return 0
+	}
+	return len(w.relp.pending)
+}
+
+// dial opens a connection to the server with the keepalive settings. For
+// RELP it also opens a session and resends the messages the previous
+// session left unacknowledged.
+func (w *Writer) dial() (net.Conn, *relpSession, error) {
+	conn, err := w.connect()
+	if err != nil || w.protocol != "relp" {
+		return conn, nil, err
+	}
+
+	session, err := openRELP(conn, w.connTimeout)
+	if err == nil && w.relp != nil && len(w.relp.pending) > 0 {
+		w.logger.Info("resending unacknowledged messages", "count", len(w.relp.pending))
+		err = session.resend(w.relp.pending, w.writeTimeout)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, session, nil
+}
+
+// connect opens a connection to the first of the server's addresses that
+// accepts one. A server name is resolved again on every call, so a changed
+// DNS record is picked up by the next reconnect, and each call starts from
+// the next address so connections rotate over the records.
+func (w *Writer) connect() (net.Conn, error) {
+	network := Network(w.protocol)
+	dialer := net.Dialer{Timeout: w.connTimeout, KeepAlive: w.keepAlive, LocalAddr: w.localAddr}
+	if network != "tcp" && network != "udp" {
+		return dialer.Dial(network, w.address)
+	}
+
+	ctx := context.Background()
+	if w.connTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.connTimeout)
+		defer cancel()
+	}
+	addrs, err := Resolve(ctx, w.address, w.ipPreference, w.dials)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve %s: %w", w.address, err)
+	}
+	w.dials++
+
+	for _, addr := range addrs {
+		var conn net.Conn
+		conn, err = dialer.Dial(network, addr)
+		if err == nil {
+			if len(addrs) > 1 {
+				w.logger.Debug("connected to syslog server address", "address", w.address, "remote", addr)
+			}
+			return conn, nil
+		}
+		w.logger.Debug("cannot connect to syslog server address", "address", w.address, "remote", addr, "error", err.Error())
+	}
+	return nil, err
+}
+
+// Network returns the network a protocol is dialed over
+func Network(protocol string) string {
+	if protocol == "relp" {
+		return "tcp"
+	}
+	return protocol
+}
+
+// LocalAddr returns the local address to dial protocol from so connections
+// leave from the bind IP, or nil if bind is empty or protocol uses a socket
+func LocalAddr(protocol, bind string) net.Addr {
+	ip := net.ParseIP(bind)
+	if ip == nil {
+		return nil
+	}
+	switch Network(protocol) {
+	case "tcp":
+		return &net.TCPAddr{IP: ip}
+	case "udp":
+		return &net.UDPAddr{IP: ip}
+	}
 	return nil
 }
 
+// setConn adopts a new connection and schedules its replacement
+func (w *Writer) setConn(conn net.Conn, session *relpSession) {
+	w.conn = conn
+	w.relp = session
+	if w.maxAge > 0 {
+		w.recycleAt = time.Now().Add(w.maxAge)
+	}
+}
+
+// recycle replaces a connection that reached the maximum age. The new
+// connection is opened first, so if that fails the old one is kept and
+// replacing it is retried after the reconnect delay.
+func (w *Writer) recycle() {
+	if w.relp != nil {
+		// Retire the old session only once it has nothing in flight
+		if err := w.relp.await(0, w.writeTimeout); err != nil {
+			w.recycleAt = time.Now().Add(minReconnectDelay)
+			return
+		}
+	}
+
+	conn, session, err := w.dial()
+	if err != nil {
+		w.logger.Warn("failed to recycle syslog connection, keeping the current one",
+			"address", w.address,
+			"error", err.Error())
+		w.recycleAt = time.Now().Add(minReconnectDelay)
+		return
+	}
+
+	if w.relp != nil {
+		w.relp.close(w.connTimeout)
+	}
+	w.conn.Close()
+	w.setConn(conn, session)
+	w.logger.Debug("recycled syslog connection", "address", w.address, "max_age", w.maxAge)
+}
+
+// monitor probes the connection whenever it has been idle for the health
+// interval, and reconnects if the peer has gone away
+func (w *Writer) monitor() {
+	ticker := time.NewTicker(w.healthInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.checkHealth()
+		}
+	}
+}
+
+// checkHealth probes an idle connection and reconnects when the probe fails
+func (w *Writer) checkHealth() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn == nil || time.Since(w.lastWrite) < w.healthInterval {
+		return
+	}
+
+	err := w.probe()
+	if err == nil {
+		return
+	}
+
+	if w.outageStart.IsZero() {
+		w.outageStart = time.Now()
+		w.logger.Warn("syslog connection failed health check, reconnecting",
+			"address", w.address,
+			"error", err.Error())
+	}
+	w.reconnect(context.Background())
+}
+
+// probe checks an idle connection is still open. Syslog servers never send
+// anything, so a read that times out means the connection is alive, while
+// end of file or an error means the peer closed or reset it. A RELP server
+// sends nothing unasked but serverclose.
+func (w *Writer) probe() error {
+	if w.relp != nil {
+		if len(w.relp.pending) > 0 {
+			return nil // Acknowledgements are still due, so the session is in use
+		}
+		if w.relp.reader.Buffered() > 0 {
+			return fmt.Errorf("RELP server closed the session")
+		}
+	}
+
+	if err := w.conn.SetReadDeadline(time.Now().Add(probeTimeout)); err != nil {
+		return err
+	}
+	defer w.conn.SetReadDeadline(time.Time{})
+
+	var buf [64]byte
+	_, err := w.conn.Read(buf[:])
+	var netErr net.Error
+	if err == nil && w.relp != nil {
+		return fmt.Errorf("RELP server closed the session")
+	}
+	if err == nil || errors.As(err, &netErr) && netErr.Timeout() {
+		return nil
+	}
+	return err
+}
+
 // Close closes the syslog connection
 func (w *Writer) Close() error {
+	close(w.stop)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
 	if w.conn != nil {
 		w.logger.Info("closing syslog connection")
+		if w.relp != nil {
+			if err := w.relp.await(0, w.connTimeout); err != nil {
+				w.logger.Warn("closing RELP session with unacknowledged messages",
+					"count", len(w.relp.pending),
+					"error", err.Error())
+			}
+			w.relp.close(w.connTimeout)
+		}
 		return w.conn.Close()
 	}
 	return nil
 }
 
 // Reconnect attempts to reconnect to the syslog server
-func (w *Writer) Reconnect() error {
-	// Implement connection rate limiting
-	timeSinceLastReconnect := time.Since(w.lastReconnect)
-	if timeSinceLastReconnect < w.reconnectDelay {
-		w.logger.Debug("reconnection rate limited",
-			"time_since_last", timeSinceLastReconnect,
-			"delay_required", w.reconnectDelay)
-		return fmt.Errorf("reconnection rate limited")
-	}
+func (w *Writer) Reconnect(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.reconnect(ctx)
+}
 
-	if w.reconnectCount >= w.maxReconnects {
-		w.logger.Error("max reconnection attempts exceeded",
-			"count", w.reconnectCount,
-			"max", w.maxReconnects,
-			"note", "counter will reset after 1 hour of successful operation")
-		return fmt.Errorf("max reconnection attempts exceeded")
+// reconnect replaces the connection; the caller holds the lock. Attempts
+// back off exponentially up to the maximum delay and never give up; an
+// attempt made before the delay has passed is refused.
+func (w *Writer) reconnect(ctx context.Context) error {
+	if wait := time.Until(w.nextReconnect); wait > 0 {
+		w.logger.DebugContext(ctx, "reconnection backing off", "next_attempt_in", wait.Round(time.Millisecond).String())
+		return fmt.Errorf("reconnection backing off, next attempt in %s", wait.Round(time.Second))
 	}
 
 	if w.conn != nil {
 		w.conn.Close()
 	}
+	if w.outageStart.IsZero() {
+		w.outageStart = time.Now()
+	}
 
-	w.logger.Info("attempting syslog reconnection",
-		"attempt", w.reconnectCount+1,
+	w.reconnectCount++
+	delay := w.backoff()
+	w.nextReconnect = time.Now().Add(delay)
+
+	w.logger.InfoContext(ctx, "attempting syslog reconnection",
+		"attempt", w.reconnectCount,
 		"address", w.address)
 
-	conn, err := net.DialTimeout(w.protocol, w.address, w.connTimeout)
+	conn, session, err := w.dial()
+	if w.onReconnect != nil {
+		w.onReconnect(err)
+	}
 	if err != nil {
-		w.reconnectCount++
-		w.lastReconnect = time.Now()
-		w.logger.Warn("syslog reconnection failed",
+		w.logger.WarnContext(ctx, "syslog reconnection failed",
 			"attempt", w.reconnectCount,
-			"max", w.maxReconnects,
+			"next_attempt_in", delay.String(),
+			"outage", time.Since(w.outageStart).Round(time.Second).String(),
 			"error", err.Error())
 		return fmt.Errorf("failed to reconnect to syslog server: %w", err)
 	}
 
-	w.conn = conn
-	w.reconnectCount = 0           // Reset on successful reconnection
-	w.lastReconnect = time.Now()
-	w.lastCounterReset = time.Now() // Reset counter timer as well
-	w.logger.Info("syslog reconnection successful")
+	w.setConn(conn, session)
+	w.lastWrite = time.Now()
+	w.logger.InfoContext(ctx, "syslog reconnection successful", "attempt", w.reconnectCount)
 	return nil
 }
 
-// ReconnectCount returns the current reconnection attempt count
+// backoff returns the delay before the attempt after the current one
+func (w *Writer) backoff() time.Duration {
+	delay := minReconnectDelay
+	for i := 1; i < w.reconnectCount && delay < w.maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > w.maxBackoff {
+		delay = w.maxBackoff
+	}
+	return delay
+}
+
+// reconnectWait returns how long until the next reconnect attempt is allowed
+func (w *Writer) reconnectWait() time.Duration {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return time.Until(w.nextReconnect)
+}
+
+// ReconnectCount returns the reconnect attempts since the last successful write
 func (w *Writer) ReconnectCount() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 	return w.reconnectCount
 }
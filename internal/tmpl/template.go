@@ -0,0 +1,128 @@
+package tmpl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"cato-logger/internal/cef"
+)
+
+// Event is what a template renders: {{.Fields.src_ip}}, {{.Feed}} and
+// {{.Hostname}}. Missing fields render as empty strings.
+type Event struct {
+	Feed     string
+	Hostname string
+	Fields   map[string]string
+}
+
+// Template renders events with a Go text/template
+type Template struct {
+	tmpl *template.Template
+}
+
+// New parses a template. Trailing line breaks of the template are dropped
+// so it can be written over several lines.
+func New(name, text string) (*Template, error) {
+	t, err := template.New(name).
+		Option("missingkey=zero").
+		Funcs(funcs).
+		Parse(strings.TrimRight(text, "\r\n"))
+	if err != nil {
+		return nil, err
+	}
+	return &Template{tmpl: t}, nil
+}
+
+// Format renders an event
+func (t *Template) Format(event Event) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, event); err != nil {
+		return nil, fmt.Errorf("failed to render template %s: %w", t.tmpl.Name(), err)
+	}
+	return buf.Bytes(), nil
+}
+
+// funcs are the helpers templates can call
+var funcs = template.FuncMap{
+	"default": defaultValue,
+	"cef":     cef.SanitizeValue,
+	"json":    jsonString,
+	"kv":      kvValue,
+	"csv":     csvValue,
+	"xml":     html.EscapeString,
+	"time":    formatTime,
+	"upper":   strings.ToUpper,
+	"lower":   strings.ToLower,
+	"trim":    strings.TrimSpace,
+	"replace": func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+	"trunc":   truncate,
+}
+
+// defaultValue returns value, or def when value is empty:
+// {{default "unknown" .Fields.user_name}}
+func defaultValue(def, value string) string {
+	if value == "" {
+		return def
+	}
+	return value
+}
+
+// jsonString quotes a value as a JSON string
+func jsonString(value string) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// kvValue quotes a value for key=value formats when it has spaces, quotes
+// or equals signs
+func kvValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\"=") {
+		return value
+	}
+	return strconv.Quote(value)
+}
+
+// csvValue quotes a value as a CSV field when it needs it
+func csvValue(value string) string {
+	if !strings.ContainsAny(value, ",\"\r\n") {
+		return value
+	}
+	return `"` + strings.ReplaceAll(value, `"`, `""`) + `"`
+}
+
+// formatTime renders a time field in a layout: epoch, epochms, rfc3339 or a
+// Go layout such as "Jan 02 2006 15:04:05". Values that are not times are
+// returned unchanged.
+func formatTime(layout, value string) string {
+	t, ok := cef.ParseTime(value, time.UTC)
+	if !ok {
+		return value
+	}
+	switch layout {
+	case "epoch":
+		return strconv.FormatInt(t.Unix(), 10)
+	case "epochms":
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	case "rfc3339":
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+	return t.UTC().Format(layout)
+}
+
+// truncate cuts a value to at most n characters: {{trunc 64 .Fields.url}}
+func truncate(n int, value string) string {
+	if n < 0 {
+		return value
+	}
+	runes := []rune(value)
+	if len(runes) <= n {
+		return value
+	}
+	return string(runes[:n])
+}
@@ -0,0 +1,127 @@
+package transform
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"cato-logger/internal/config"
+)
+
+// replaceRule is a compiled regex find/replace rule for a single field
+type replaceRule struct {
+	field       string
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// rename moves a field's value to a new field name
+type rename struct {
+	from, to string
+}
+
+// Pipeline applies config-driven field transformations to events before formatting.
+// Steps run in a fixed order: drop, rename, replace, lowercase, add.
+type Pipeline struct {
+	dropFields      map[string]bool
+	renames         []rename
+	replaceRules    []replaceRule
+	lowercaseFields []string
+	addFields       map[string]string
+}
+
+// New builds a transform pipeline from configuration
+func New(tc config.TransformConfig) (*Pipeline, error) {
+	p := &Pipeline{
+		dropFields:      make(map[string]bool),
+		lowercaseFields: tc.LowercaseFields,
+		addFields:       tc.AddFields,
+	}
+
+	for _, field := range tc.DropFields {
+		p.dropFields[field] = true
+	}
+
+	// Two sources renamed to the same field would leave the result to
+	// whichever is applied last
+	targets := make(map[string]string, len(tc.RenameFields))
+	for from, to := range tc.RenameFields {
+		p.renames = append(p.renames, rename{from: from, to: to})
+	}
+	sort.Slice(p.renames, func(i, j int) bool { return p.renames[i].from < p.renames[j].from })
+	for _, r := range p.renames {
+		if other, exists := targets[r.to]; exists {
+			return nil, fmt.Errorf("transform.rename_fields renames both '%s' and '%s' to '%s'", other, r.from, r.to)
+		}
+		targets[r.to] = r.from
+	}
+
+	for i, rule := range tc.Replace {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern in transform.replace[%d]: %w", i, err)
+		}
+		p.replaceRules = append(p.replaceRules, replaceRule{
+			field:       rule.Field,
+			pattern:     re,
+			replacement: rule.Replacement,
+		})
+	}
+
+	return p, nil
+}
+
+// Empty reports whether the pipeline has no steps configured
+func (p *Pipeline) Empty() bool {
+	return len(p.dropFields) == 0 &&
+		len(p.renames) == 0 &&
+		len(p.replaceRules) == 0 &&
+		len(p.lowercaseFields) == 0 &&
+		len(p.addFields) == 0
+}
+
+// Apply transforms the event in place and returns it
+func (p *Pipeline) Apply(event map[string]string) map[string]string {
+	// Drop unwanted fields
+	for field := range p.dropFields {
+		delete(event, field)
+	}
+
+	// Rename fields (an existing target field is overwritten). Every source
+	// is read before any target is written, so renames that swap or chain
+	// fields see the original values.
+	if len(p.renames) > 0 {
+		moved := make([]struct{ field, value string }, 0, len(p.renames))
+		for _, r := range p.renames {
+			if value, exists := event[r.from]; exists {
+				moved = append(moved, struct{ field, value string }{r.to, value})
+				delete(event, r.from)
+			}
+		}
+		for _, m := range moved {
+			event[m.field] = m.value
+		}
+	}
+
+	// Regex find/replace on values
+	for _, rule := range p.replaceRules {
+		if value, exists := event[rule.field]; exists {
+			event[rule.field] = rule.pattern.ReplaceAllString(value, rule.replacement)
+		}
+	}
+
+	// Lowercase values
+	for _, field := range p.lowercaseFields {
+		if value, exists := event[field]; exists {
+			event[field] = strings.ToLower(value)
+		}
+	}
+
+	// Inject static fields
+	for field, value := range p.addFields {
+		event[field] = value
+	}
+
+	return event
+}
@@ -0,0 +1,78 @@
+package transform
+
+import (
+	"reflect"
+	"testing"
+
+	"cato-logger/internal/config"
+)
+
+func TestApplyRenames(t *testing.T) {
+	tests := []struct {
+		name    string
+		renames map[string]string
+		event   map[string]string
+		want    map[string]string
+	}{
+		{
+			name:    "missing source",
+			renames: map[string]string{"src_site": "site_name"},
+			event:   map[string]string{"src_ip": "10.0.0.1"},
+			want:    map[string]string{"src_ip": "10.0.0.1"},
+		},
+		{
+			name:    "overwrites target",
+			renames: map[string]string{"src_site": "site_name"},
+			event:   map[string]string{"src_site": "Berlin", "site_name": "old"},
+			want:    map[string]string{"site_name": "Berlin"},
+		},
+		{
+			name:    "swap",
+			renames: map[string]string{"src_ip": "dest_ip", "dest_ip": "src_ip"},
+			event:   map[string]string{"src_ip": "10.0.0.1", "dest_ip": "93.68.89.125"},
+			want:    map[string]string{"src_ip": "93.68.89.125", "dest_ip": "10.0.0.1"},
+		},
+		{
+			name:    "chain",
+			renames: map[string]string{"a": "b", "b": "c", "c": "d"},
+			event:   map[string]string{"a": "1", "b": "2", "c": "3"},
+			want:    map[string]string{"b": "1", "c": "2", "d": "3"},
+		},
+		{
+			name:    "chain with missing link",
+			renames: map[string]string{"a": "b", "b": "c"},
+			event:   map[string]string{"a": "1"},
+			want:    map[string]string{"b": "1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := New(config.TransformConfig{RenameFields: tt.renames})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			// Map iteration order varies between runs, so repeat to catch
+			// results that depend on it
+			for i := 0; i < 50; i++ {
+				event := make(map[string]string, len(tt.event))
+				for k, v := range tt.event {
+					event[k] = v
+				}
+				if got := p.Apply(event); !reflect.DeepEqual(got, tt.want) {
+					t.Fatalf("Apply() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewRejectsRenamesToOneField(t *testing.T) {
+	_, err := New(config.TransformConfig{RenameFields: map[string]string{"src_site": "site", "dest_site": "site"}})
+	if err == nil {
+		t.Fatal("New() accepted two fields renamed to the same name")
+	}
+	if want := "transform.rename_fields renames both 'dest_site' and 'src_site' to 'site'"; err.Error() != want {
+		t.Errorf("New() error = %q, want %q", err, want)
+	}
+}